@@ -0,0 +1,35 @@
+// Команда fakeoidc запускает фиктивного провайдера OpenID Connect для локальной проверки входа через
+// внешних провайдеров. Провайдер сразу подтверждает вход; учетную запись можно выбрать параметром
+// login_hint в адресе входа или флагами:
+//
+//	go run ./cmd/fakeoidc -addr :9000 -client-id user-management -client-secret secret
+//	go run ./cmd/fakeoidc -subject 42 -email alice@example.com
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"user-management/internal/pkg/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "адрес, на котором принимаются запросы")
+	issuer := flag.String("issuer", "http://localhost:9000", "идентификатор провайдера (адрес, по которому к нему обращается сервис)")
+	clientID := flag.String("client-id", "user-management", "client_id зарегистрированного клиента")
+	clientSecret := flag.String("client-secret", "secret", "секрет клиента (пустой — публичный клиент)")
+	subject := flag.String("subject", "fake-user", "subject подтверждаемой учетной записи")
+	email := flag.String("email", "fake-user@example.com", "подтвержденный адрес электронной почты учетной записи")
+	flag.Parse()
+
+	provider, err := oidctest.New(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Failed to create provider: %v", err)
+	}
+	provider.Identity.Subject = *subject
+	provider.Identity.Email = *email
+
+	log.Printf("Fake OIDC provider %s listening on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
@@ -0,0 +1,190 @@
+// Команда loadtest проверяет, что параллельные запросы к /api/v1/users/:id/task/complete
+// обрабатываются без ошибок сервера.
+//
+// Для каждого созданного пользователя одновременно отправляются запросы на выполнение
+// всех заданий, причем каждое задание запрашивается несколько раз. Ожидается, что каждое
+// задание будет засчитано ровно один раз, а остальные запросы получат 409 Conflict.
+//
+// Пример запуска против поднятого через docker-compose сервиса:
+//
+//	go run ./cmd/loadtest -addr http://localhost:8080 -users 20 -repeat 5
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+type registerResponse struct {
+	UserID int `json:"user_id"`
+}
+
+type testUser struct {
+	id    int
+	token string
+}
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8080", "адрес API сервера")
+	users := flag.Int("users", 20, "количество пользователей")
+	tasks := flag.Int("tasks", 3, "количество заданий (ID с 1 по tasks)")
+	repeat := flag.Int("repeat", 5, "сколько раз параллельно запрашивать выполнение каждого задания")
+	flag.Parse()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	runID := time.Now().UnixNano() % 100000
+
+	// Создаем пользователей и получаем для них токены
+	testUsers := make([]testUser, 0, *users)
+	for i := 0; i < *users; i++ {
+		u, err := createUser(client, *addr, fmt.Sprintf("load%05d%04d", runID, i))
+		if err != nil {
+			log.Fatalf("Failed to prepare user: %v", err)
+		}
+		testUsers = append(testUsers, u)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		statuses = make(map[int]int)
+		success  = make(map[[2]int]int)
+		start    = make(chan struct{})
+	)
+
+	for _, u := range testUsers {
+		for taskID := 1; taskID <= *tasks; taskID++ {
+			for r := 0; r < *repeat; r++ {
+				wg.Add(1)
+				go func(u testUser, taskID int) {
+					defer wg.Done()
+					<-start
+
+					status, err := completeTask(client, *addr, u, taskID)
+
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						statuses[0]++
+						log.Printf("Request failed: %v", err)
+						return
+					}
+					statuses[status]++
+					if status == http.StatusOK {
+						success[[2]int{u.id, taskID}]++
+					}
+				}(u, taskID)
+			}
+		}
+	}
+
+	began := time.Now()
+	close(start)
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	total := *users * *tasks * *repeat
+	log.Printf("Sent %d requests in %s", total, elapsed)
+	for status, count := range statuses {
+		log.Printf("Status %d: %d", status, count)
+	}
+
+	failed := false
+	for status, count := range statuses {
+		if status != http.StatusOK && status != http.StatusConflict {
+			log.Printf("Unexpected status %d in %d responses", status, count)
+			failed = true
+		}
+	}
+	for _, u := range testUsers {
+		for taskID := 1; taskID <= *tasks; taskID++ {
+			if n := success[[2]int{u.id, taskID}]; n != 1 {
+				log.Printf("User %d completed task %d %d times, expected exactly once", u.id, taskID, n)
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		log.Println("Load test FAILED")
+		os.Exit(1)
+	}
+	log.Println("Load test passed")
+}
+
+// createUser регистрирует пользователя и выполняет вход
+func createUser(client *http.Client, addr, username string) (testUser, error) {
+	credentials := map[string]string{"username": username, "password": "loadtest"}
+
+	var reg registerResponse
+	if err := postJSON(client, addr+"/api/v1/users/register", "", credentials, &reg); err != nil {
+		return testUser{}, fmt.Errorf("register %s: %w", username, err)
+	}
+
+	var login loginResponse
+	if err := postJSON(client, addr+"/api/v1/users/login", "", credentials, &login); err != nil {
+		return testUser{}, fmt.Errorf("login %s: %w", username, err)
+	}
+
+	return testUser{id: reg.UserID, token: login.Token}, nil
+}
+
+// completeTask отправляет запрос на выполнение задания и возвращает HTTP статус ответа
+func completeTask(client *http.Client, addr string, u testUser, taskID int) (int, error) {
+	body, _ := json.Marshal(map[string]int{"task_id": taskID})
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/users/%d/task/complete", addr, u.id), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+u.token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// postJSON отправляет POST запрос с JSON телом и декодирует успешный ответ
+func postJSON(client *http.Client, url, token string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
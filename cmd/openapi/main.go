@@ -0,0 +1,52 @@
+// Команда openapi строит спецификацию OpenAPI по маршрутам и DTO приложения.
+//
+// Без флагов спецификация выводится в stdout, с -o записывается в файл. С -check команда
+// сравнивает сохраненную спецификацию с построенной и завершается с кодом 1 при расхождении,
+// а также если маршрут зарегистрирован без описания или описание не соответствует маршруту:
+//
+//	go run ./cmd/openapi -o api/openapi.json
+//	go run ./cmd/openapi -check api/openapi.json
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"user-management/internal/pkg/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	out := flag.String("o", "", "файл для записи спецификации")
+	check := flag.String("check", "", "файл со спецификацией для сверки")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+
+	spec, err := app.OpenAPISpec()
+	if err != nil {
+		log.Fatalf("Error building OpenAPI spec: %v", err)
+	}
+
+	switch {
+	case *check != "":
+		stored, err := os.ReadFile(*check)
+		if err != nil {
+			log.Fatalf("Error reading OpenAPI spec: %v", err)
+		}
+		if !bytes.Equal(stored, spec) {
+			log.Printf("OpenAPI spec %s is out of date, regenerate it with: go run ./cmd/openapi -o %s", *check, *check)
+			os.Exit(1)
+		}
+		log.Println("OpenAPI spec is up to date")
+	case *out != "":
+		if err = os.WriteFile(*out, spec, 0o644); err != nil {
+			log.Fatalf("Error writing OpenAPI spec: %v", err)
+		}
+	default:
+		os.Stdout.Write(spec)
+	}
+}
@@ -0,0 +1,46 @@
+// Команда reconcile сверяет users.balance с суммой операций в журнале point_transactions.
+// Завершается с кодом 1, если найдено хотя бы одно расхождение.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+
+	"user-management/internal/config"
+	"user-management/internal/database"
+	"user-management/internal/pkg/logger"
+	"user-management/internal/repository"
+	"user-management/internal/service"
+)
+
+func main() {
+	cfg := config.MustLoad()
+	logger := logger.InitLogger(slog.LevelWarn)
+
+	dbPool, err := database.NewDBPool(&cfg.DatabaseConfig)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer dbPool.Close()
+
+	ledgerService := service.NewLedgerService(repository.NewLedgerRepo(dbPool, logger), logger)
+
+	mismatches, err := ledgerService.ReconcileBalances(context.Background())
+	if err != nil {
+		log.Fatalf("Error reconciling balances: %v", err)
+	}
+
+	if len(mismatches) == 0 {
+		log.Println("All balances match the ledger")
+		return
+	}
+
+	for _, m := range mismatches {
+		log.Printf("user_id=%d balance=%d ledger_sum=%d diff=%d", m.UserID, m.Balance, m.LedgerSum, m.Balance-m.LedgerSum)
+	}
+	log.Printf("Found %d balance mismatches", len(mismatches))
+	dbPool.Close()
+	os.Exit(1)
+}
@@ -0,0 +1,69 @@
+// Команда roles управляет ролями пользователей напрямую через базу данных.
+// Используется в том числе для назначения первого администратора:
+//
+//	go run ./cmd/roles -user TommyVercetti -grant admin
+//	go run ./cmd/roles -user TommyVercetti -revoke admin
+//	go run ./cmd/roles -user TommyVercetti
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"strings"
+
+	"user-management/internal/config"
+	"user-management/internal/database"
+	"user-management/internal/pkg/logger"
+	"user-management/internal/repository"
+	"user-management/internal/service"
+)
+
+func main() {
+	username := flag.String("user", "", "имя пользователя")
+	grant := flag.String("grant", "", "выдать роль")
+	revoke := flag.String("revoke", "", "отозвать роль")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("Flag -user is required")
+	}
+
+	cfg := config.MustLoad()
+	logger := logger.InitLogger(slog.LevelWarn)
+
+	dbPool, err := database.NewDBPool(&cfg.DatabaseConfig)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer dbPool.Close()
+
+	roleService := service.NewRoleService(
+		repository.NewUserRepository(dbPool, logger),
+		repository.NewRoleRepo(dbPool, logger),
+		logger,
+	)
+
+	ctx := context.Background()
+
+	if *grant != "" {
+		if err = roleService.GrantRole(ctx, *username, *grant); err != nil {
+			log.Fatalf("Error granting role: %v", err)
+		}
+		log.Printf("Role %q granted to %s", *grant, *username)
+	}
+
+	if *revoke != "" {
+		if err = roleService.RevokeRole(ctx, *username, *revoke); err != nil {
+			log.Fatalf("Error revoking role: %v", err)
+		}
+		log.Printf("Role %q revoked from %s", *revoke, *username)
+	}
+
+	roles, err := roleService.UserRoles(ctx, *username)
+	if err != nil {
+		log.Fatalf("Error getting roles: %v", err)
+	}
+	log.Printf("Roles of %s: [%s]", *username, strings.Join(roles, ", "))
+}
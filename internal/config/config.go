@@ -12,6 +12,7 @@ import (
 type Config struct {
 	ApiServerConfig ApiServer
 	DatabaseConfig  Database
+	MailConfig      Mail
 }
 
 // ApiServer представляет конфигурацию сервера API
@@ -21,6 +22,66 @@ type ApiServer struct {
 	AuthSecretKey string        `env:"API_SERVER_AUTH_SECRET_KEY" env-required:"true"`
 	Timeout       time.Duration `env:"API_SERVER_TIMEOUT" env-default:"4s"`
 	IdleTimeout   time.Duration `env:"API_SERVER_IDLE_TIMEOUT" env-default:"60s"`
+
+	TrustedProxies []string `env:"API_SERVER_TRUSTED_PROXIES" env-separator:","` // Адреса и подсети прокси через запятую, которым доверяется X-Forwarded-For (по умолчанию никому)
+
+	AccessTokenTTL  time.Duration `env:"API_SERVER_ACCESS_TOKEN_TTL" env-default:"15m"`   // Время жизни access токена
+	RefreshTokenTTL time.Duration `env:"API_SERVER_REFRESH_TOKEN_TTL" env-default:"720h"` // Время жизни refresh токена
+
+	JWTKeysDir      string        `env:"API_SERVER_JWT_KEYS_DIR"`                        // Каталог ключей подписи access токенов (без него используется HS256 с API_SERVER_AUTH_SECRET_KEY)
+	JWTAlgorithm    string        `env:"API_SERVER_JWT_ALGORITHM" env-default:"EdDSA"`   // Алгоритм новых ключей: EdDSA или RS256
+	JWTKeyRotation  time.Duration `env:"API_SERVER_JWT_KEY_ROTATION" env-default:"720h"` // Период создания нового ключа подписи (0 — без ротации)
+	JWTKeyRetention time.Duration `env:"API_SERVER_JWT_KEY_RETENTION" env-default:"24h"` // Сколько выведенный из подписи ключ еще проверяет токены (не меньше времени жизни access токена)
+
+	TaskCallbackSecret string `env:"API_SERVER_TASK_CALLBACK_SECRET"` // Секрет подписи обратных вызовов внешней системы проверки заданий
+
+	PasswordResetTTL time.Duration `env:"API_SERVER_PASSWORD_RESET_TTL" env-default:"1h"`                                   // Время жизни токена сброса пароля
+	PasswordResetURL string        `env:"API_SERVER_PASSWORD_RESET_URL" env-default:"http://localhost:8080/reset-password"` // Адрес страницы сброса пароля, к которому добавляется токен
+
+	PasswordHashAlgorithm string `env:"API_SERVER_PASSWORD_HASH_ALGORITHM" env-default:"argon2id"` // Алгоритм хэширования новых паролей: argon2id или bcrypt
+	Argon2Memory          uint32 `env:"API_SERVER_ARGON2_MEMORY" env-default:"19456"`              // Память argon2id в КиБ
+	Argon2Iterations      uint32 `env:"API_SERVER_ARGON2_ITERATIONS" env-default:"2"`              // Число проходов argon2id
+	Argon2Parallelism     uint8  `env:"API_SERVER_ARGON2_PARALLELISM" env-default:"1"`             // Число потоков argon2id
+	BcryptCost            int    `env:"API_SERVER_BCRYPT_COST" env-default:"10"`                   // Сложность bcrypt
+
+	PasswordMinLength       int      `env:"API_SERVER_PASSWORD_MIN_LENGTH" env-default:"8"`         // Минимальная длина пароля в символах
+	PasswordMaxLength       int      `env:"API_SERVER_PASSWORD_MAX_LENGTH" env-default:"128"`       // Максимальная длина пароля в символах
+	PasswordRequiredClasses []string `env:"API_SERVER_PASSWORD_REQUIRED_CLASSES" env-separator:","` // Обязательные классы символов через запятую: upper, lower, digit, symbol
+	PasswordForbidUsername  bool     `env:"API_SERVER_PASSWORD_FORBID_USERNAME" env-default:"true"` // Запретить пароль, содержащий имя пользователя
+	PasswordDenylistFile    string   `env:"API_SERVER_PASSWORD_DENYLIST_FILE"`                      // Файл распространенных паролей, по одному в строке
+	PasswordBreachDir       string   `env:"API_SERVER_PASSWORD_BREACH_DIR"`                         // Каталог диапазонов утекших паролей в формате Have I Been Pwned (без него проверка отключена)
+
+	EmailRequired           bool          `env:"API_SERVER_EMAIL_REQUIRED" env-default:"false"`                                                   // Требовать адрес электронной почты при регистрации
+	EmailVerificationPolicy string        `env:"API_SERVER_EMAIL_VERIFICATION_POLICY" env-default:"none"`                                         // Что запрещено до подтверждения адреса: none, tasks или login
+	EmailVerificationSecret string        `env:"API_SERVER_EMAIL_VERIFICATION_SECRET"`                                                            // Секрет подписи ссылок подтверждения (по умолчанию ключ jwt)
+	EmailVerificationTTL    time.Duration `env:"API_SERVER_EMAIL_VERIFICATION_TTL" env-default:"72h"`                                             // Время жизни ссылки подтверждения адреса
+	EmailVerificationURL    string        `env:"API_SERVER_EMAIL_VERIFICATION_URL" env-default:"http://localhost:8080/api/v1/users/email/verify"` // Адрес подтверждения, к которому добавляется токен
+
+	MFAIssuer       string        `env:"API_SERVER_MFA_ISSUER" env-default:"user-management"` // Название сервиса в приложении-аутентификаторе
+	MFAChallengeTTL time.Duration `env:"API_SERVER_MFA_CHALLENGE_TTL" env-default:"5m"`       // Время жизни токена запроса второго фактора при входе
+
+	LoginMaxFailures   int           `env:"API_SERVER_LOGIN_MAX_FAILURES" env-default:"5"`     // Число неудачных попыток для имени пользователя до блокировки
+	LoginIPMaxFailures int           `env:"API_SERVER_LOGIN_IP_MAX_FAILURES" env-default:"20"` // Число неудачных попыток с одного IP адреса до блокировки
+	LoginLockoutBase   time.Duration `env:"API_SERVER_LOGIN_LOCKOUT_BASE" env-default:"1m"`    // Длительность первой блокировки, каждая следующая вдвое дольше
+	LoginLockoutMax    time.Duration `env:"API_SERVER_LOGIN_LOCKOUT_MAX" env-default:"1h"`     // Максимальная длительность блокировки
+	LoginFailureWindow time.Duration `env:"API_SERVER_LOGIN_FAILURE_WINDOW" env-default:"15m"` // Счетчик сбрасывается, если за это время не было неудачных попыток
+
+	TokenCleanupInterval  time.Duration `env:"API_SERVER_TOKEN_CLEANUP_INTERVAL" env-default:"1h"`     // Период удаления устаревших токенов и сессий (0 — не удалять)
+	TokenRetention        time.Duration `env:"API_SERVER_TOKEN_RETENTION" env-default:"168h"`          // Сколько хранятся истекшие и отозванные токены и сессии
+	TokenCleanupBatchSize int           `env:"API_SERVER_TOKEN_CLEANUP_BATCH_SIZE" env-default:"1000"` // Число строк, удаляемых одним запросом
+
+	TokenCacheSize         int           `env:"API_SERVER_TOKEN_CACHE_SIZE" env-default:"10000"`             // Число результатов проверки access токенов в кэше (0 — без кэша)
+	TokenCacheTTL          time.Duration `env:"API_SERVER_TOKEN_CACHE_TTL" env-default:"30s"`                // Срок жизни записи кэша
+	TokenRevocationBackend string        `env:"API_SERVER_TOKEN_REVOCATION_BACKEND" env-default:"postgres"`  // Доставка событий отзыва между экземплярами: local, postgres или redis
+	RedisURL               string        `env:"API_SERVER_REDIS_URL" env-default:"redis://localhost:6379/0"` // Адрес Redis-совместимого хранилища
+
+	OIDCProvidersFile string        `env:"API_SERVER_OIDC_PROVIDERS_FILE"`                                                                        // JSON файл с внешними провайдерами OpenID Connect (без него вход через провайдеров отключен)
+	OIDCRedirectURL   string        `env:"API_SERVER_OIDC_REDIRECT_URL" env-default:"http://localhost:8080/api/v1/auth/oidc/{provider}/callback"` // Адрес возврата от провайдера; {provider} заменяется именем провайдера
+	OIDCStateTTL      time.Duration `env:"API_SERVER_OIDC_STATE_TTL" env-default:"10m"`                                                           // Время на вход у провайдера
+
+	OAuthCodeTTL time.Duration `env:"API_SERVER_OAUTH_CODE_TTL" env-default:"1m"` // Время жизни кода авторизации OAuth2
+
+	MetricsAddr string `env:"API_SERVER_METRICS_ADDR"` // Адрес отдельного внутреннего сервера метрик /debug/vars, например 127.0.0.1:9090 (без него метрики не отдаются)
 }
 
 // Database представляет конфигурацию подключения к базе данных
@@ -33,6 +94,23 @@ type Database struct {
 	DBPort   string `env:"DB_PORT" env-default:"5432"`         // Порт БД
 	SSLMode  string `env:"DB_SSLMODE" env-default:"disable"`   // Режим SSL для подключения к БД
 
+	MaxConns          int32         `env:"DB_MAX_CONNS" env-default:"10"`           // Максимальное число соединений в пуле
+	MinConns          int32         `env:"DB_MIN_CONNS" env-default:"2"`            // Минимальное число соединений в пуле
+	MaxConnLifetime   time.Duration `env:"DB_MAX_CONN_LIFETIME" env-default:"1h"`   // Максимальное время жизни соединения
+	MaxConnIdleTime   time.Duration `env:"DB_MAX_CONN_IDLE_TIME" env-default:"30m"` // Максимальное время простоя соединения
+	HealthCheckPeriod time.Duration `env:"DB_HEALTH_CHECK_PERIOD" env-default:"1m"` // Период проверки состояния простаивающих соединений
+}
+
+// Mail представляет конфигурацию отправки писем
+type Mail struct {
+	Driver string `env:"MAIL_DRIVER" env-default:"file"`             // Способ доставки: smtp, file или memory
+	From   string `env:"MAIL_FROM" env-default:"no-reply@localhost"` // Адрес отправителя
+	Dir    string `env:"MAIL_DIR" env-default:"mail"`                // Каталог для писем при доставке в файлы
+
+	SMTPHost     string `env:"MAIL_SMTP_HOST" env-default:"localhost"` // Хост SMTP сервера
+	SMTPPort     string `env:"MAIL_SMTP_PORT" env-default:"587"`       // Порт SMTP сервера
+	SMTPUser     string `env:"MAIL_SMTP_USER"`                         // Имя пользователя SMTP
+	SMTPPassword string `env:"MAIL_SMTP_PASSWORD"`                     // Пароль пользователя SMTP
 }
 
 var (
@@ -56,6 +134,11 @@ func MustLoad() *Config {
 			log.Fatalf("Failed to load database configuration from env: %s", err)
 		}
 
+		// Загружаем конфигурацию отправки писем из переменных окружения
+		if err := cleanenv.ReadConfig(".env", &cfg.MailConfig); err != nil {
+			log.Fatalf("Failed to load mail configuration from env: %s", err)
+		}
+
 		log.Println("Config loaded successfully...")
 	})
 
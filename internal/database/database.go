@@ -7,11 +7,11 @@ import (
 
 	"user-management/internal/config"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// NewDBConnection устанавливает подключение к базе данных с использованием строки подключения
-func NewDBConnection(dbcfg *config.Database) (*pgx.Conn, error) {
+// NewDBPool создает пул соединений к базе данных с использованием строки подключения
+func NewDBPool(dbcfg *config.Database) (*pgxpool.Pool, error) {
 	// Получаем строку подключения
 	connStr := fmt.Sprintf("%s://%s:%s@%s:%s/%s?sslmode=%s",
 		dbcfg.Driver,
@@ -23,12 +23,31 @@ func NewDBConnection(dbcfg *config.Database) (*pgx.Conn, error) {
 		dbcfg.SSLMode,
 	)
 
-	// Устанавливаем соединение с базой данных
-	conn, err := pgx.Connect(context.Background(), connStr)
+	poolCfg, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v", err)
+		return nil, fmt.Errorf("unable to parse database config: %w", err)
+	}
+
+	// Настраиваем параметры пула
+	poolCfg.MaxConns = dbcfg.MaxConns
+	poolCfg.MinConns = dbcfg.MinConns
+	poolCfg.MaxConnLifetime = dbcfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = dbcfg.MaxConnIdleTime
+	poolCfg.HealthCheckPeriod = dbcfg.HealthCheckPeriod
+
+	// Создаем пул соединений
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		log.Printf("Unable to create connection pool: %v", err)
+		return nil, err
+	}
+
+	// Проверяем доступность базы данных
+	if err = pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		log.Printf("Unable to connect to database: %v", err)
 		return nil, err
 	}
 
-	return conn, nil
+	return pool, nil
 }
@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminUserHandler struct {
+	loginThrottle service.LoginThrottle
+	userService   service.UserService
+	logger        *slog.Logger
+}
+
+func NewAdminUserHandler(loginThrottle service.LoginThrottle, userService service.UserService, logger *slog.Logger) AdminUserHandler {
+	return AdminUserHandler{
+		loginThrottle: loginThrottle,
+		userService:   userService,
+		logger:        logger,
+	}
+}
+
+// UnlockUserHandler обрабатывает запрос администратора на снятие блокировки входа с учетной записи
+func (h *AdminUserHandler) UnlockUserHandler(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.loginThrottle.Unlock(c.Request.Context(), userID); err != nil {
+		handleError(c, "Error unlocking user", err)
+		return
+	}
+
+	h.logger.Info("User unlocked successfully", "method", "UnlockUserHandler", "user_id", userID)
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Блокировка входа снята"})
+}
+
+// AdjustBalanceHandler обрабатывает корректировку баланса пользователя администратором
+func (h *AdminUserHandler) AdjustBalanceHandler(c *gin.Context) {
+	adminID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.BalanceAdjustmentDTO
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid balance adjustment input", err)
+		return
+	}
+
+	balance, err := h.userService.AdjustBalance(c.Request.Context(), adminID, userID, &req)
+	if err != nil {
+		handleError(c, "Error adjusting balance", err)
+		return
+	}
+
+	h.logger.Info("Balance adjusted successfully", "method", "AdjustBalanceHandler", "admin_id", adminID, "user_id", userID, "amount", req.Amount)
+	c.JSON(http.StatusOK, balance)
+}
@@ -0,0 +1,85 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+	logger        *slog.Logger
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyService, logger *slog.Logger) APIKeyHandler {
+	return APIKeyHandler{
+		apiKeyService: apiKeyService,
+		logger:        logger,
+	}
+}
+
+// CreateAPIKeyHandler обрабатывает запрос на создание API ключа; ключ возвращается только в этом ответе
+func (h *APIKeyHandler) CreateAPIKeyHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.CreateAPIKeyDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid API key data", err)
+		return
+	}
+
+	key, err := h.apiKeyService.CreateAPIKey(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleError(c, "Failed to create API key", err)
+		return
+	}
+
+	h.logger.Info("API key created successfully", "method", "CreateAPIKeyHandler", "user_id", userID, "api_key_id", key.ID)
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeysHandler обрабатывает запрос на получение действующих API ключей пользователя
+func (h *APIKeyHandler) ListAPIKeysHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		handleError(c, "Failed to list API keys", err)
+		return
+	}
+
+	h.logger.Info("API keys return successfully", "method", "ListAPIKeysHandler", "user_id", userID)
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKeyHandler обрабатывает запрос на отзыв API ключа пользователя
+func (h *APIKeyHandler) RevokeAPIKeyHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, ok := parseIDParam(c, "kid")
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(c.Request.Context(), userID, int64(keyID)); err != nil {
+		handleError(c, "Failed to revoke API key", err)
+		return
+	}
+
+	h.logger.Info("API key revoked successfully", "method", "RevokeAPIKeyHandler", "user_id", userID, "api_key_id", keyID)
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "API ключ отозван"})
+}
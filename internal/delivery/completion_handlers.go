@@ -0,0 +1,109 @@
+package delivery
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Заголовок с подписью тела обратного вызова внешней системы проверки
+const callbackSignatureHeader = "X-Signature"
+
+type CompletionHandler struct {
+	completionService service.CompletionService
+	logger            *slog.Logger
+}
+
+func NewCompletionHandler(completionService service.CompletionService, logger *slog.Logger) CompletionHandler {
+	return CompletionHandler{
+		completionService: completionService,
+		logger:            logger,
+	}
+}
+
+// ListCompletionsHandler обрабатывает запрос администратора на получение очереди заявок на выполнение заданий
+func (h *CompletionHandler) ListCompletionsHandler(c *gin.Context) {
+	var query dto.CompletionListQueryDTO
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	completions, err := h.completionService.ListCompletions(c.Request.Context(), &query)
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed get task completions", err)
+		return
+	}
+
+	h.logger.Info("Task completions return successfully", "method", "ListCompletionsHandler", "count", len(completions.Items))
+	c.JSON(http.StatusOK, completions)
+}
+
+// ApproveCompletionHandler обрабатывает запрос администратора на подтверждение выполнения задания
+func (h *CompletionHandler) ApproveCompletionHandler(c *gin.Context) {
+	h.reviewCompletion(c, true)
+}
+
+// RejectCompletionHandler обрабатывает запрос администратора на отклонение выполнения задания
+func (h *CompletionHandler) RejectCompletionHandler(c *gin.Context) {
+	h.reviewCompletion(c, false)
+}
+
+// CallbackHandler обрабатывает подписанный результат проверки выполнения задания от внешней системы
+func (h *CompletionHandler) CallbackHandler(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error reading callback", err)
+		return
+	}
+
+	var callback dto.TaskCallbackDTO
+
+	if err = json.Unmarshal(payload, &callback); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding callback", err)
+		return
+	}
+	if err = binding.Validator.ValidateStruct(&callback); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding callback", err)
+		return
+	}
+
+	completion, err := h.completionService.HandleCallback(c.Request.Context(), payload, c.GetHeader(callbackSignatureHeader), &callback)
+	if err != nil {
+		handleError(c, "Error handling callback", err)
+		return
+	}
+
+	h.logger.Info("Task completion callback handled", "method", "CallbackHandler", "completion_id", completion.ID, "status", completion.Status)
+	c.JSON(http.StatusOK, completion)
+}
+
+// reviewCompletion применяет решение администратора к заявке на выполнение задания
+func (h *CompletionHandler) reviewCompletion(c *gin.Context, approve bool) {
+	reviewerID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	completionID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	completion, err := h.completionService.ReviewCompletion(c.Request.Context(), reviewerID, int64(completionID), approve)
+	if err != nil {
+		handleError(c, "Error reviewing task completion", err)
+		return
+	}
+
+	h.logger.Info("Task completion reviewed successfully", "method", "reviewCompletion", "reviewer_id", reviewerID, "completion_id", completion.ID, "status", completion.Status)
+	c.JSON(http.StatusOK, completion)
+}
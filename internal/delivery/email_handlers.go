@@ -0,0 +1,79 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailHandler struct {
+	emailService service.EmailService
+	logger       *slog.Logger
+}
+
+func NewEmailHandler(emailService service.EmailService, logger *slog.Logger) EmailHandler {
+	return EmailHandler{
+		emailService: emailService,
+		logger:       logger,
+	}
+}
+
+// ChangeEmailHandler обрабатывает установку или смену адреса электронной почты пользователя с проверкой текущего пароля
+func (h *EmailHandler) ChangeEmailHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.EmailDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid email", err)
+		return
+	}
+
+	if err := h.emailService.ChangeEmail(c.Request.Context(), userID, &req, clientInfo(c)); err != nil {
+		handleError(c, "Failed to change email", err)
+		return
+	}
+
+	h.logger.Info("Email changed, verification sent", "method", "ChangeEmailHandler", "user_id", userID)
+	c.JSON(http.StatusAccepted, dto.StatusDTO{Status: "Адрес изменен, письмо для подтверждения отправлено"})
+}
+
+// ResendVerificationHandler обрабатывает повторную отправку письма для подтверждения адреса
+func (h *EmailHandler) ResendVerificationHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.emailService.SendVerification(c.Request.Context(), userID); err != nil {
+		handleError(c, "Failed to send verification email", err)
+		return
+	}
+
+	h.logger.Info("Verification email resent", "method", "ResendVerificationHandler", "user_id", userID)
+	c.JSON(http.StatusAccepted, dto.StatusDTO{Status: "Письмо для подтверждения отправлено"})
+}
+
+// VerifyEmailHandler обрабатывает переход по ссылке подтверждения адреса из письма
+func (h *EmailHandler) VerifyEmailHandler(c *gin.Context) {
+	var query dto.EmailVerifyQueryDTO
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid verification link", err)
+		return
+	}
+
+	if err := h.emailService.VerifyEmail(c.Request.Context(), query.Token); err != nil {
+		handleError(c, "Failed to verify email", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Адрес подтвержден"})
+}
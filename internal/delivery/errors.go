@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-management/internal/pkg/problem"
+	"user-management/internal/repository"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorMapping описывает HTTP-ответ для ошибки сервисного слоя или репозитория
+type errorMapping struct {
+	err    error
+	status int
+	code   problem.Code
+	title  string
+}
+
+// errorMappings соответствие ошибок HTTP статусам и стабильным кодам.
+// Ошибки проверяются по порядку через errors.Is, поэтому более конкретные ошибки должны идти раньше
+var errorMappings = []errorMapping{
+	{repository.ErrUserNotFound, http.StatusNotFound, problem.CodeUserNotFound, "User not found"},
+	{service.ErrUserAlreadyExists, http.StatusConflict, problem.CodeUserAlreadyExists, "User already exists"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid username or password"},
+	{service.ErrAccountLocked, http.StatusLocked, problem.CodeAccountLocked, "Account temporarily locked"},
+	{service.ErrTooManyLoginAttempts, http.StatusTooManyRequests, problem.CodeTooManyLoginAttempts, "Too many login attempts"},
+	{service.ErrInvalidReferrer, http.StatusBadRequest, problem.CodeInvalidReferrer, "Invalid referrer"},
+	{service.ErrSetReferrer, http.StatusConflict, problem.CodeReferrerAlreadySet, "User already has referrer"},
+	{service.ErrNegativeBalance, http.StatusConflict, problem.CodeNegativeBalance, "Balance cannot become negative"},
+	{service.ErrInvalidRefreshToken, http.StatusUnauthorized, problem.CodeInvalidRefreshToken, "Invalid refresh token"},
+	{service.ErrRefreshTokenReused, http.StatusUnauthorized, problem.CodeRefreshTokenReused, "Refresh token reuse detected"},
+	{service.ErrEmailRequired, http.StatusBadRequest, problem.CodeEmailRequired, "Email is required"},
+	{repository.ErrEmailAlreadyExists, http.StatusConflict, problem.CodeEmailAlreadyExists, "Email already in use"},
+	{service.ErrEmailNotSet, http.StatusConflict, problem.CodeEmailNotSet, "User has no email"},
+	{service.ErrEmailAlreadyVerified, http.StatusConflict, problem.CodeEmailAlreadyVerified, "Email already verified"},
+	{service.ErrEmailNotVerified, http.StatusForbidden, problem.CodeEmailNotVerified, "Email is not verified"},
+	{service.ErrInvalidVerificationToken, http.StatusBadRequest, problem.CodeInvalidVerificationToken, "Invalid or expired email verification token"},
+	{service.ErrMFAAlreadyEnabled, http.StatusConflict, problem.CodeMFAAlreadyEnabled, "Two-factor authentication already enabled"},
+	{service.ErrMFANotEnrolled, http.StatusConflict, problem.CodeMFANotEnrolled, "Two-factor authentication is not enrolled"},
+	{service.ErrInvalidMFACode, http.StatusUnprocessableEntity, problem.CodeInvalidMFACode, "Invalid two-factor code"},
+	{service.ErrInvalidMFAChallenge, http.StatusUnauthorized, problem.CodeInvalidMFAToken, "Invalid or expired mfa token"},
+	{service.ErrSessionNotFound, http.StatusNotFound, problem.CodeSessionNotFound, "Session not found"},
+	{service.ErrInvalidResetToken, http.StatusBadRequest, problem.CodeInvalidResetToken, "Invalid or expired password reset token"},
+	{service.ErrWeakPassword, http.StatusBadRequest, problem.CodeWeakPassword, "Password does not meet the policy"},
+	{service.ErrInvalidCurrentPassword, http.StatusUnprocessableEntity, problem.CodeInvalidCurrentPassword, "Invalid current password"},
+	{service.ErrSessionRequired, http.StatusUnauthorized, problem.CodeSessionRequired, "Access token is not bound to a session"},
+	{service.ErrUnknownRole, http.StatusBadRequest, problem.CodeUnknownRole, "Unknown role"},
+	{service.ErrAPIKeyNotFound, http.StatusNotFound, problem.CodeAPIKeyNotFound, "API key not found"},
+	{service.ErrUnknownScope, http.StatusBadRequest, problem.CodeUnknownScope, "Unknown scope"},
+	{service.ErrInvalidAPIKeyExpiry, http.StatusBadRequest, problem.CodeInvalidAPIKeyExpiry, "API key expiry must be in the future"},
+	{service.ErrUnknownProvider, http.StatusNotFound, problem.CodeUnknownProvider, "Unknown identity provider"},
+	{service.ErrProviderUnavailable, http.StatusBadGateway, problem.CodeProviderUnavailable, "Identity provider is unavailable"},
+	{service.ErrInvalidOIDCState, http.StatusBadRequest, problem.CodeInvalidOIDCState, "Invalid or expired login state"},
+	{service.ErrOIDCAuthFailed, http.StatusUnauthorized, problem.CodeOIDCAuthFailed, "Identity provider authentication failed"},
+	{service.ErrIdentityAlreadyLinked, http.StatusConflict, problem.CodeIdentityAlreadyLinked, "Identity already linked"},
+	{service.ErrIdentityNotFound, http.StatusNotFound, problem.CodeIdentityNotFound, "Identity not found"},
+	{service.ErrLastLoginMethod, http.StatusConflict, problem.CodeLastLoginMethod, "Cannot unlink the last login method"},
+	{service.ErrOAuthClientNotFound, http.StatusNotFound, problem.CodeOAuthClientNotFound, "OAuth client not found"},
+	{service.ErrInvalidOAuthClient, http.StatusBadRequest, problem.CodeInvalidOAuthClient, "Unknown OAuth client"},
+	{service.ErrInvalidOAuthRegistration, http.StatusBadRequest, problem.CodeInvalidOAuthRegistration, "Invalid OAuth client registration"},
+	{service.ErrScopeNotDelegable, http.StatusBadRequest, problem.CodeScopeNotDelegable, "Scope cannot be granted to third-party applications"},
+	{service.ErrInvalidRedirectURI, http.StatusBadRequest, problem.CodeInvalidRedirectURI, "Invalid redirect URI"},
+	{service.ErrInvalidOAuthRequest, http.StatusBadRequest, problem.CodeInvalidOAuthRequest, "Invalid authorization request"},
+	{service.ErrInvalidOAuthScope, http.StatusBadRequest, problem.CodeInvalidOAuthScope, "Invalid scope"},
+	{service.ErrUnauthorizedClient, http.StatusBadRequest, problem.CodeUnauthorizedOAuthClient, "Client is not allowed to use authorization code"},
+	{service.ErrOAuthConsentNotFound, http.StatusNotFound, problem.CodeOAuthConsentNotFound, "OAuth consent not found"},
+
+	{repository.ErrTaskNotFound, http.StatusNotFound, problem.CodeTaskNotFound, "Task not found"},
+	{service.ErrIsCompletedTask, http.StatusConflict, problem.CodeTaskAlreadyCompleted, "Task already completed"},
+	{service.ErrTaskLimitReached, http.StatusConflict, problem.CodeTaskLimitReached, "Task completion limit reached"},
+	{service.ErrTaskNotYetAvailable, http.StatusConflict, problem.CodeTaskNotYetAvailable, "Task is not yet available"},
+	{service.ErrTaskExpired, http.StatusGone, problem.CodeTaskExpired, "Task has expired"},
+	{service.ErrTaskArchived, http.StatusConflict, problem.CodeTaskArchived, "Task is archived"},
+	{service.ErrInvalidTaskWindow, http.StatusBadRequest, problem.CodeInvalidTaskWindow, "Task end must be after start"},
+	{service.ErrInvalidTaskRecurrence, http.StatusBadRequest, problem.CodeInvalidTaskRecurrence, "max_completions must be set only for limited recurrence"},
+	{service.ErrInvalidTaskVerification, http.StatusBadRequest, problem.CodeInvalidTaskVerification, "proof_code must be set only for proof_code verification"},
+	{service.ErrInvalidProof, http.StatusUnprocessableEntity, problem.CodeInvalidProof, "Invalid proof code"},
+
+	{repository.ErrCompletionNotFound, http.StatusNotFound, problem.CodeCompletionNotFound, "Task completion not found"},
+	{service.ErrCompletionNotPending, http.StatusConflict, problem.CodeCompletionNotPending, "Task completion already reviewed"},
+	{service.ErrCallbackNotAllowed, http.StatusConflict, problem.CodeCallbackNotAllowed, "Task is not verified by callback"},
+	{service.ErrInvalidCallbackSignature, http.StatusUnauthorized, problem.CodeInvalidCallbackSignature, "Invalid callback signature"},
+}
+
+// handleError отправляет HTTP-ответ, соответствующий ошибке сервисного слоя или репозитория.
+// Неизвестные ошибки считаются внутренними, а message используется как заголовок ответа
+func handleError(c *gin.Context, message string, err error) {
+	// Для временной блокировки клиент узнает, через сколько секунд можно повторить попытку
+	var lockout *service.LockoutError
+	if errors.As(err, &lockout) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(max(lockout.RetryAfter, time.Second).Seconds()))))
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			if m.status >= http.StatusInternalServerError {
+				slog.Error(message, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP(), "error", err)
+			} else {
+				slog.Warn(message, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP(), "error", err)
+			}
+			p := problem.New(m.status, m.code, m.title)
+			// Клиент узнает, каким правилам политики паролей не соответствует пароль
+			var policyErr *service.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				p.Detail = policyErr.Error()
+			}
+			problem.Abort(c, p)
+			return
+		}
+	}
+
+	logAndHandleError(c, http.StatusInternalServerError, message, err)
+}
+
+// NotFoundHandler отвечает на запросы к неизвестным маршрутам
+func NotFoundHandler(c *gin.Context) {
+	problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeNotFound, "Route not found"))
+}
+
+// MethodNotAllowedHandler отвечает на запросы с методом, не поддерживаемым маршрутом
+func MethodNotAllowedHandler(c *gin.Context) {
+	problem.Abort(c, problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed"))
+}
+
+// RecoveryHandler отвечает на запрос, обработка которого завершилась паникой
+func RecoveryHandler(c *gin.Context, recovered any) {
+	slog.Error("Panic recovered", "method", c.Request.Method, "path", c.Request.URL.Path, "panic", recovered)
+	problem.Abort(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Internal server error"))
+}
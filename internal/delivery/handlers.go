@@ -1,30 +1,40 @@
 package delivery
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
 
 	"user-management/internal/config"
 	"user-management/internal/dto"
+	"user-management/internal/models"
 	"user-management/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
-	userService  service.UserService
-	tokenService service.TokenService
-	logger       *slog.Logger
-	config       *config.Config
+	userService    service.UserService
+	tokenService   service.TokenService
+	ledgerService  service.LedgerService
+	emailService   service.EmailService
+	mfaService     service.MFAService
+	sessionService service.SessionService
+	logger         *slog.Logger
+	config         *config.Config
 }
 
-func NewUserHandler(userService service.UserService, tokenService service.TokenService, config *config.Config, logger *slog.Logger) UserHandler {
+func NewUserHandler(userService service.UserService, tokenService service.TokenService, ledgerService service.LedgerService, emailService service.EmailService, mfaService service.MFAService, sessionService service.SessionService, config *config.Config, logger *slog.Logger) UserHandler {
 	return UserHandler{
-		userService:  userService,
-		tokenService: tokenService,
-		logger:       logger,
-		config:       config,
+		userService:    userService,
+		tokenService:   tokenService,
+		ledgerService:  ledgerService,
+		emailService:   emailService,
+		mfaService:     mfaService,
+		sessionService: sessionService,
+		logger:         logger,
+		config:         config,
 	}
 }
 
@@ -39,14 +49,21 @@ func (h *UserHandler) RegisterHandler(c *gin.Context) {
 
 	userID, err := h.userService.Register(c.Request.Context(), &userDTO)
 	if err != nil {
-		logAndHandleError(c, http.StatusInternalServerError, "Error during user registration", err)
+		handleError(c, "Error during user registration", err)
 		return
 	}
 
+	// Регистрация уже завершена: при ошибке отправки письмо можно запросить повторно
+	if userDTO.Email != "" {
+		if err = h.emailService.SendVerification(c.Request.Context(), userID); err != nil {
+			h.logger.Error("Failed to send verification email", "method", "RegisterHandler", "user_id", userID, "error", err)
+		}
+	}
+
 	h.logger.Info("User registered successfully", "method", "RegisterHandler", "username", userDTO.UserName, "user_id", userID)
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "Успешная регистрация",
-		"user_id": userID,
+	c.JSON(http.StatusOK, dto.RegisterResponseDTO{
+		Status: "Успешная регистрация",
+		UserID: userID,
 	})
 }
 
@@ -59,23 +76,84 @@ func (h *UserHandler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.Login(c.Request.Context(), &userDTO)
+	user, err := h.userService.Login(c.Request.Context(), &userDTO, c.ClientIP())
+	if err != nil {
+		handleError(c, "Login failed", err)
+		return
+	}
+
+	// При подключенном втором факторе токены выдаются только после ввода кода
+	challenge, err := h.mfaService.BeginLogin(c.Request.Context(), user.ID)
 	if err != nil {
-		logAndHandleError(c, http.StatusUnauthorized, "Login failed", err)
+		handleError(c, "Failed to start two-factor login", err)
+		return
+	}
+	if challenge != nil {
+		h.logger.Info("Password accepted, second factor required", "method", "LoginHandler", "user_id", user.ID)
+		c.JSON(http.StatusAccepted, challenge)
 		return
 	}
 
-	token, expiresAt, err := h.tokenService.GenerateToken(c.Request.Context(), user.ID)
+	tokens, err := h.tokenService.GenerateTokenPair(c.Request.Context(), user.ID, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		logAndHandleError(c, http.StatusInternalServerError, "Failed to generate token", err)
 		return
 	}
 
 	h.logger.Info("User logged in successfully", "method", "LoginHandler", "username", user.UserName, "user_id", user.ID)
-	c.JSON(http.StatusOK, gin.H{
-		"status":     "Авторизация успешна",
-		"token":      token,
-		"expires_at": expiresAt,
+	c.JSON(http.StatusOK, dto.AuthResponseDTO{
+		Status:       "Авторизация успешна",
+		TokenPairDTO: *tokens,
+	})
+}
+
+// LoginMFAHandler обрабатывает второй шаг входа: обмен токена запроса второго фактора и кода на пару токенов
+func (h *UserHandler) LoginMFAHandler(c *gin.Context) {
+	var req dto.MFALoginDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding mfa login input", err)
+		return
+	}
+
+	userID, err := h.mfaService.CompleteLogin(c.Request.Context(), &req, c.ClientIP())
+	if err != nil {
+		handleError(c, "Two-factor login failed", err)
+		return
+	}
+
+	tokens, err := h.tokenService.GenerateTokenPair(c.Request.Context(), userID, clientInfo(c))
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	h.logger.Info("User logged in with second factor", "method", "LoginMFAHandler", "user_id", userID)
+	c.JSON(http.StatusOK, dto.AuthResponseDTO{
+		Status:       "Авторизация успешна",
+		TokenPairDTO: *tokens,
+	})
+}
+
+// RefreshTokenHandler обрабатывает запрос на обновление пары токенов по refresh токену
+func (h *UserHandler) RefreshTokenHandler(c *gin.Context) {
+	var refreshDTO dto.RefreshTokenDTO
+
+	if err := c.ShouldBindJSON(&refreshDTO); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding refresh token", err)
+		return
+	}
+
+	tokens, err := h.tokenService.RefreshToken(c.Request.Context(), refreshDTO.RefreshToken, clientInfo(c))
+	if err != nil {
+		handleError(c, "Failed to refresh token", err)
+		return
+	}
+
+	h.logger.Info("Token refreshed successfully", "method", "RefreshTokenHandler")
+	c.JSON(http.StatusOK, dto.AuthResponseDTO{
+		Status:       "Токен обновлен",
+		TokenPairDTO: *tokens,
 	})
 }
 
@@ -90,9 +168,28 @@ func (h *UserHandler) LogoutHandler(c *gin.Context) {
 		return
 	}
 
-	h.logger.Info("Token revoked successfully", "method", "LogoutHandler", "token", token)
-	c.JSON(http.StatusOK, gin.H{
-		"status": "Вышел из системы",
+	// Сессия текущего токена завершается вместе со всеми ее токенами
+	if sessionID := getSessionID(c); sessionID != 0 {
+		err = h.sessionService.RevokeSession(c.Request.Context(), c.GetInt("user_id"), sessionID)
+		if err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+			logAndHandleError(c, http.StatusInternalServerError, "Failed to revoke session", err)
+			return
+		}
+	}
+
+	// Refresh токен в теле запроса необязателен: если он передан, отзываем его семейство
+	var refreshDTO dto.RefreshTokenDTO
+	if c.ShouldBindJSON(&refreshDTO) == nil {
+		err = h.tokenService.RevokeRefreshToken(c.Request.Context(), refreshDTO.RefreshToken)
+		if err != nil && !errors.Is(err, service.ErrInvalidRefreshToken) {
+			logAndHandleError(c, http.StatusInternalServerError, "Failed to revoke refresh token", err)
+			return
+		}
+	}
+
+	h.logger.Info("Token revoked successfully", "method", "LogoutHandler", "user_id", c.GetInt("user_id"))
+	c.JSON(http.StatusOK, dto.StatusDTO{
+		Status: "Вышел из системы",
 	})
 }
 
@@ -105,7 +202,7 @@ func (h *UserHandler) UserStatusHandler(c *gin.Context) {
 
 	userStatus, err := h.userService.UserStatus(c.Request.Context(), userID)
 	if err != nil {
-		logAndHandleError(c, http.StatusInternalServerError, "Failed get user status", err)
+		handleError(c, "Failed get user status", err)
 		return
 	}
 
@@ -115,11 +212,8 @@ func (h *UserHandler) UserStatusHandler(c *gin.Context) {
 
 // UsersLeaderboardHandler обрабатывает запрос на получение списка топ пользователей с самым большим балансом
 func (h *UserHandler) UsersLeaderboardHandler(c *gin.Context) {
-	userID, err := getUserID(c)
-	if err != nil {
-		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
-		return
-	}
+	// Топ доступен и токену приложения без пользователя (client_credentials)
+	userID, _ := getUserID(c)
 
 	leaderboard, err := h.userService.UserLeaderboard(c.Request.Context())
 	if err != nil {
@@ -147,14 +241,14 @@ func (h *UserHandler) ReferrerHandler(c *gin.Context) {
 
 	err := h.userService.AddReferrer(c.Request.Context(), userID, &referrer)
 	if err != nil {
-		logAndHandleError(c, http.StatusInternalServerError, "Error adding referrer", err)
+		handleError(c, "Error adding referrer", err)
 		return
 	}
 
 	h.logger.Info("Referrer added successfully", "method", "ReferralHandler", "userID", userID, "referrer", referrer.Referrer)
-	c.JSON(http.StatusOK, gin.H{
-		"status":      "Реферер добавлен",
-		"referrer_id": referrer.Referrer,
+	c.JSON(http.StatusOK, dto.ReferrerResponseDTO{
+		Status:   "Реферер добавлен",
+		Referrer: referrer.Referrer,
 	})
 }
 
@@ -172,15 +266,50 @@ func (h *UserHandler) TaskCompleteHandler(c *gin.Context) {
 		return
 	}
 
-	err := h.userService.TaskComplete(c.Request.Context(), userID, &task)
+	completion, err := h.userService.TaskComplete(c.Request.Context(), userID, &task)
 	if err != nil {
-		logAndHandleError(c, http.StatusInternalServerError, "Error completing task", err)
+		handleError(c, "Error completing task", err)
+		return
+	}
+
+	if completion.Status == string(models.CompletionStatusPending) {
+		h.logger.Info("Task completion submitted for review", "method", "TaskComplete", "userID", userID, "task", task.ID, "completion_id", completion.ID)
+		c.JSON(http.StatusAccepted, dto.TaskCompleteResponseDTO{
+			Status:       "Задание отправлено на проверку",
+			TaskID:       task.ID,
+			CompletionID: completion.ID,
+		})
 		return
 	}
 
 	h.logger.Info("Task completed successfully", "method", "TaskComplete", "userID", userID, "task", task.ID)
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "Задание выполнено",
-		"task_id": task.ID,
+	c.JSON(http.StatusOK, dto.TaskCompleteResponseDTO{
+		Status:       "Задание выполнено",
+		TaskID:       task.ID,
+		CompletionID: completion.ID,
 	})
 }
+
+// PointTransactionsHandler обрабатывает запрос на получение истории изменений баланса пользователя
+func (h *UserHandler) PointTransactionsHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var page dto.PaginationDTO
+
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	transactions, err := h.ledgerService.PointTransactions(c.Request.Context(), userID, &page)
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed get point transactions", err)
+		return
+	}
+
+	h.logger.Info("Point transactions return successfully", "method", "PointTransactionsHandler", "user_id", userID)
+	c.JSON(http.StatusOK, transactions)
+}
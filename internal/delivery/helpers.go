@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"user-management/internal/pkg/problem"
+	"user-management/internal/service"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,6 +27,19 @@ func getUserID(c *gin.Context) (int, error) {
 	return userID, nil
 }
 
+// getSessionID возвращает ID сессии access токена из контекста или 0, если токен выпущен вне сессии
+func getSessionID(c *gin.Context) int64 {
+	return c.GetInt64("session_id")
+}
+
+// clientInfo собирает данные клиента для записи в сессию
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
 // validateUserID проверяет совпадание ID из контекста и из параметра запроса
 func validateUserID(c *gin.Context) (int, bool) {
 	userID, err := getUserID(c)
@@ -39,17 +55,35 @@ func validateUserID(c *gin.Context) (int, bool) {
 	}
 
 	if userID != userIDParam {
-		logAndHandleError(c, http.StatusUnauthorized, "User ID mismatch", nil)
+		logAndHandleError(c, http.StatusForbidden, "User ID mismatch", nil)
 		return 0, false
 	}
 
 	return userID, true
 }
 
-// logAndHandleError логирует ошибку и отправляет HTTP-ответ
+// parseIDParam извлекает числовой идентификатор из параметра пути
+func parseIDParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid "+name+" parameter", err)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// logAndHandleError логирует ошибку и отправляет HTTP-ответ в формате problem+json с общим кодом для статуса.
+// Для ошибок сервисного слоя следует использовать handleError
 func logAndHandleError(c *gin.Context, status int, message string, err error) {
 	if err != nil {
 		slog.Error(message, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP(), "error", err)
 	}
-	c.JSON(status, gin.H{"error": message})
+
+	p := problem.New(status, problem.CodeForStatus(status), message)
+	// Текст ошибки валидации помогает клиенту исправить запрос; внутренние ошибки не раскрываются
+	if err != nil && status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+	problem.Abort(c, p)
 }
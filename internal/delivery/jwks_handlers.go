@@ -0,0 +1,30 @@
+package delivery
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/pkg/jwtkeys"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keys   jwtkeys.KeySet
+	logger *slog.Logger
+}
+
+func NewJWKSHandler(keys jwtkeys.KeySet, logger *slog.Logger) JWKSHandler {
+	return JWKSHandler{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// KeysHandler отдает открытые ключи проверки подписи access токенов. Ответ можно кэшировать не дольше,
+// чем новый ключ публикуется до начала подписи
+func (h *JWKSHandler) KeysHandler(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwtkeys.PublishAhead.Seconds())))
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaService service.MFAService
+	logger     *slog.Logger
+}
+
+func NewMFAHandler(mfaService service.MFAService, logger *slog.Logger) MFAHandler {
+	return MFAHandler{
+		mfaService: mfaService,
+		logger:     logger,
+	}
+}
+
+// EnrollTOTPHandler обрабатывает запрос на подключение приложения-аутентификатора
+func (h *MFAHandler) EnrollTOTPHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(c.Request.Context(), userID)
+	if err != nil {
+		handleError(c, "Failed to enroll totp", err)
+		return
+	}
+
+	h.logger.Info("TOTP enrollment started", "method", "EnrollTOTPHandler", "user_id", userID)
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPHandler обрабатывает подтверждение подключения приложения-аутентификатора кодом
+func (h *MFAHandler) ConfirmTOTPHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.MFACodeDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid totp code", err)
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleError(c, "Failed to confirm totp", err)
+		return
+	}
+
+	h.logger.Info("TOTP enabled", "method", "ConfirmTOTPHandler", "user_id", userID)
+	c.JSON(http.StatusOK, codes)
+}
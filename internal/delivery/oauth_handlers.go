@@ -0,0 +1,271 @@
+package delivery
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthErrorMapping описывает ответ конечных точек /oauth/token и /oauth/revoke для ошибки сервисного слоя
+type oauthErrorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// oauthErrorMappings соответствие ошибок кодам RFC 6749 (раздел 5.2). Эти конечные точки вызывают сторонние
+// приложения, поэтому ошибки возвращаются в формате спецификации, а не problem+json
+var oauthErrorMappings = []oauthErrorMapping{
+	{service.ErrInvalidOAuthClient, http.StatusUnauthorized, "invalid_client"},
+	{service.ErrInvalidOAuthGrant, http.StatusBadRequest, "invalid_grant"},
+	{service.ErrInvalidOAuthRequest, http.StatusBadRequest, "invalid_request"},
+	{service.ErrInvalidOAuthScope, http.StatusBadRequest, "invalid_scope"},
+	{service.ErrUnauthorizedClient, http.StatusBadRequest, "unauthorized_client"},
+	{service.ErrUnsupportedGrantType, http.StatusBadRequest, "unsupported_grant_type"},
+}
+
+type OAuthHandler struct {
+	oauthService service.OAuthService
+	logger       *slog.Logger
+}
+
+func NewOAuthHandler(oauthService service.OAuthService, logger *slog.Logger) OAuthHandler {
+	return OAuthHandler{
+		oauthService: oauthService,
+		logger:       logger,
+	}
+}
+
+// CreateClientHandler обрабатывает запрос администратора на регистрацию стороннего приложения
+func (h *OAuthHandler) CreateClientHandler(c *gin.Context) {
+	actorID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	var req dto.CreateOAuthClientDTO
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid OAuth client data", err)
+		return
+	}
+
+	client, err := h.oauthService.RegisterClient(c.Request.Context(), actorID, &req)
+	if err != nil {
+		handleError(c, "Failed to register OAuth client", err)
+		return
+	}
+
+	h.logger.Info("OAuth client registered successfully", "method", "CreateClientHandler", "actor_id", actorID, "client_id", client.ClientID)
+	c.JSON(http.StatusCreated, client)
+}
+
+// ListClientsHandler обрабатывает запрос администратора на получение зарегистрированных приложений
+func (h *OAuthHandler) ListClientsHandler(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c.Request.Context())
+	if err != nil {
+		handleError(c, "Failed to list OAuth clients", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteClientHandler обрабатывает запрос администратора на удаление стороннего приложения
+func (h *OAuthHandler) DeleteClientHandler(c *gin.Context) {
+	clientID := c.Param("client")
+
+	if err := h.oauthService.DeleteClient(c.Request.Context(), clientID); err != nil {
+		handleError(c, "Failed to delete OAuth client", err)
+		return
+	}
+
+	h.logger.Info("OAuth client deleted successfully", "method", "DeleteClientHandler", "client_id", clientID)
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Приложение удалено"})
+}
+
+// AuthorizeHandler проверяет запрос авторизации стороннего приложения и возвращает данные для экрана согласия
+func (h *OAuthHandler) AuthorizeHandler(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	var req dto.OAuthAuthorizeDTO
+
+	if err = c.ShouldBindQuery(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid authorization request", err)
+		return
+	}
+
+	authorization, err := h.oauthService.PrepareAuthorization(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleError(c, "Invalid authorization request", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, authorization)
+}
+
+// ConsentHandler обрабатывает решение пользователя по запросу авторизации и возвращает адрес возврата в приложение
+func (h *OAuthHandler) ConsentHandler(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	var req dto.OAuthConsentDecisionDTO
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid authorization request", err)
+		return
+	}
+
+	redirect, err := h.oauthService.Authorize(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleError(c, "Failed to authorize OAuth client", err)
+		return
+	}
+
+	h.logger.Info("OAuth authorization decided", "method", "ConsentHandler", "user_id", userID, "client_id", req.ClientID, "approve", req.Approve)
+	c.JSON(http.StatusOK, redirect)
+}
+
+// TokenHandler выдает access токен стороннему приложению
+func (h *OAuthHandler) TokenHandler(c *gin.Context) {
+	var req dto.OAuthTokenRequestDTO
+
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Warn("Invalid token request", "method", "TokenHandler", "error", err)
+		abortOAuth(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	}
+
+	auth, ok := clientAuth(c, req.ClientID, req.ClientSecret)
+	if !ok {
+		return
+	}
+
+	token, err := h.oauthService.Token(c.Request.Context(), auth, &req)
+	if err != nil {
+		handleOAuthError(c, "Failed to issue OAuth token", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeHandler отзывает токен стороннего приложения. Неизвестный токен не считается ошибкой (RFC 7009)
+func (h *OAuthHandler) RevokeHandler(c *gin.Context) {
+	var req dto.OAuthRevokeDTO
+
+	if err := c.ShouldBind(&req); err != nil {
+		h.logger.Warn("Invalid revocation request", "method", "RevokeHandler", "error", err)
+		abortOAuth(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	auth, ok := clientAuth(c, req.ClientID, req.ClientSecret)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.Revoke(c.Request.Context(), auth, req.Token); err != nil {
+		handleOAuthError(c, "Failed to revoke OAuth token", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListConsentsHandler обрабатывает запрос на получение приложений, которым пользователь разрешил доступ
+func (h *OAuthHandler) ListConsentsHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	consents, err := h.oauthService.ListConsents(c.Request.Context(), userID)
+	if err != nil {
+		handleError(c, "Failed to list OAuth consents", err)
+		return
+	}
+
+	h.logger.Info("OAuth consents return successfully", "method", "ListConsentsHandler", "user_id", userID)
+	c.JSON(http.StatusOK, consents)
+}
+
+// RevokeConsentHandler обрабатывает запрос на отзыв доступа стороннего приложения
+func (h *OAuthHandler) RevokeConsentHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.RevokeConsent(c.Request.Context(), userID, c.Param("client")); err != nil {
+		handleError(c, "Failed to revoke OAuth consent", err)
+		return
+	}
+
+	h.logger.Info("OAuth consent revoked successfully", "method", "RevokeConsentHandler", "user_id", userID, "client_id", c.Param("client"))
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Доступ приложения отозван"})
+}
+
+// clientAuth извлекает учетные данные приложения из заголовка Authorization (Basic) или из тела запроса.
+// Одновременное использование обоих способов запрещено (RFC 6749, раздел 2.3)
+func clientAuth(c *gin.Context, formID, formSecret string) (service.OAuthClientAuth, bool) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return service.OAuthClientAuth{ClientID: formID, ClientSecret: formSecret}, true
+	}
+	if formID != "" || formSecret != "" {
+		abortOAuth(c, http.StatusBadRequest, "invalid_request", "multiple client authentication methods")
+		return service.OAuthClientAuth{}, false
+	}
+
+	// В заголовке Basic идентификатор и секрет передаются в кодировке application/x-www-form-urlencoded
+	clientID, errID := url.QueryUnescape(id)
+	clientSecret, errSecret := url.QueryUnescape(secret)
+	if errID != nil || errSecret != nil {
+		abortOAuth(c, http.StatusBadRequest, "invalid_request", "malformed client credentials")
+		return service.OAuthClientAuth{}, false
+	}
+
+	return service.OAuthClientAuth{ClientID: clientID, ClientSecret: clientSecret}, true
+}
+
+// handleOAuthError отправляет ответ в формате RFC 6749, соответствующий ошибке сервисного слоя.
+// Неизвестные ошибки считаются внутренними
+func handleOAuthError(c *gin.Context, message string, err error) {
+	for _, m := range oauthErrorMappings {
+		if errors.Is(err, m.err) {
+			slog.Warn(message, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP(), "error", err)
+			// Ответ 401 сообщает приложению схему аутентификации (RFC 6749, раздел 5.2)
+			if m.status == http.StatusUnauthorized {
+				c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			}
+			abortOAuth(c, m.status, m.code, err.Error())
+			return
+		}
+	}
+
+	slog.Error(message, "method", c.Request.Method, "path", c.Request.URL.Path, "client_ip", c.ClientIP(), "error", err)
+	abortOAuth(c, http.StatusInternalServerError, "server_error", "")
+}
+
+// abortOAuth прерывает обработку запроса ошибкой в формате RFC 6749
+func abortOAuth(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(status, dto.OAuthErrorDTO{Error: code, ErrorDescription: description})
+}
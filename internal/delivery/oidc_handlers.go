@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oidcService  service.OIDCService
+	tokenService service.TokenService
+	mfaService   service.MFAService
+	logger       *slog.Logger
+}
+
+func NewOIDCHandler(oidcService service.OIDCService, tokenService service.TokenService, mfaService service.MFAService, logger *slog.Logger) OIDCHandler {
+	return OIDCHandler{
+		oidcService:  oidcService,
+		tokenService: tokenService,
+		mfaService:   mfaService,
+		logger:       logger,
+	}
+}
+
+// LoginHandler перенаправляет пользователя на страницу входа внешнего провайдера
+func (h *OIDCHandler) LoginHandler(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, err := h.oidcService.BeginLogin(c.Request.Context(), provider)
+	if err != nil {
+		handleError(c, "Failed to start login with identity provider", err)
+		return
+	}
+
+	h.logger.Info("Redirecting to identity provider", "method", "LoginHandler", "provider", provider)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// CallbackHandler обрабатывает возврат от внешнего провайдера: выдает пару токенов после входа
+// или подтверждает привязку учетной записи провайдера к пользователю
+func (h *OIDCHandler) CallbackHandler(c *gin.Context) {
+	provider := c.Param("provider")
+	var req dto.OIDCCallbackDTO
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid identity provider callback", err)
+		return
+	}
+
+	result, err := h.oidcService.CompleteLogin(c.Request.Context(), provider, &req)
+	if err != nil {
+		handleError(c, "Login with identity provider failed", err)
+		return
+	}
+
+	if result.Linked {
+		h.logger.Info("Identity linked successfully", "method", "CallbackHandler", "provider", provider, "user_id", result.UserID)
+		c.JSON(http.StatusOK, dto.StatusDTO{Status: "Учетная запись провайдера привязана"})
+		return
+	}
+
+	// Вход через провайдера заменяет только пароль: подключенный второй фактор по-прежнему требуется
+	challenge, err := h.mfaService.BeginLogin(c.Request.Context(), result.UserID)
+	if err != nil {
+		handleError(c, "Failed to start two-factor login", err)
+		return
+	}
+	if challenge != nil {
+		h.logger.Info("Identity accepted, second factor required", "method", "CallbackHandler", "user_id", result.UserID)
+		c.JSON(http.StatusAccepted, challenge)
+		return
+	}
+
+	tokens, err := h.tokenService.GenerateTokenPair(c.Request.Context(), result.UserID, clientInfo(c))
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	h.logger.Info("User logged in with identity provider", "method", "CallbackHandler", "provider", provider,
+		"user_id", result.UserID, "created", result.Created)
+	c.JSON(http.StatusOK, dto.AuthResponseDTO{
+		Status:       "Авторизация успешна",
+		TokenPairDTO: *tokens,
+	})
+}
+
+// LinkIdentityHandler начинает привязку учетной записи провайдера и возвращает адрес страницы входа провайдера
+func (h *OIDCHandler) LinkIdentityHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, err := h.oidcService.BeginLink(c.Request.Context(), userID, c.Param("provider"))
+	if err != nil {
+		handleError(c, "Failed to start identity linking", err)
+		return
+	}
+
+	h.logger.Info("Identity linking started", "method", "LinkIdentityHandler", "user_id", userID, "provider", c.Param("provider"))
+	c.JSON(http.StatusOK, dto.AuthorizationURLDTO{AuthorizationURL: authURL})
+}
+
+// ListIdentitiesHandler обрабатывает запрос на получение привязанных учетных записей провайдеров
+func (h *OIDCHandler) ListIdentitiesHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.oidcService.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		handleError(c, "Failed to list identities", err)
+		return
+	}
+
+	h.logger.Info("Identities return successfully", "method", "ListIdentitiesHandler", "user_id", userID)
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkIdentityHandler обрабатывает запрос на отвязку учетной записи провайдера
+func (h *OIDCHandler) UnlinkIdentityHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oidcService.UnlinkIdentity(c.Request.Context(), userID, c.Param("provider")); err != nil {
+		handleError(c, "Failed to unlink identity", err)
+		return
+	}
+
+	h.logger.Info("Identity unlinked successfully", "method", "UnlinkIdentityHandler", "user_id", userID, "provider", c.Param("provider"))
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Учетная запись провайдера отвязана"})
+}
@@ -0,0 +1,86 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	passwordService service.PasswordService
+	logger          *slog.Logger
+}
+
+func NewPasswordHandler(passwordService service.PasswordService, logger *slog.Logger) PasswordHandler {
+	return PasswordHandler{
+		passwordService: passwordService,
+		logger:          logger,
+	}
+}
+
+// ForgotPasswordHandler обрабатывает запрос на сброс пароля. Ответ не зависит от того, существует ли пользователь
+func (h *PasswordHandler) ForgotPasswordHandler(c *gin.Context) {
+	var req dto.ForgotPasswordDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid password reset request", err)
+		return
+	}
+
+	if err := h.passwordService.ForgotPassword(c.Request.Context(), &req); err != nil {
+		handleError(c, "Failed to request password reset", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.StatusDTO{Status: "Если пользователь существует, ссылка для сброса пароля отправлена"})
+}
+
+// ResetPasswordHandler обрабатывает установку нового пароля по токену сброса
+func (h *PasswordHandler) ResetPasswordHandler(c *gin.Context) {
+	var req dto.ResetPasswordDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid password reset input", err)
+		return
+	}
+
+	if err := h.passwordService.ResetPassword(c.Request.Context(), &req); err != nil {
+		handleError(c, "Failed to reset password", err)
+		return
+	}
+
+	h.logger.Info("Password reset", "method", "ResetPasswordHandler")
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Пароль успешно изменен"})
+}
+
+// ChangePasswordHandler обрабатывает смену пароля вошедшим пользователем. Остальные сессии пользователя завершаются,
+// текущая сохраняется
+func (h *PasswordHandler) ChangePasswordHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.ChangePasswordDTO
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid password change input", err)
+		return
+	}
+
+	revoked, err := h.passwordService.ChangePassword(c.Request.Context(), userID, getSessionID(c), &req, clientInfo(c))
+	if err != nil {
+		handleError(c, "Failed to change password", err)
+		return
+	}
+
+	h.logger.Info("Password changed", "method", "ChangePasswordHandler", "user_id", userID, "revoked", revoked)
+	c.JSON(http.StatusOK, dto.ChangePasswordResponseDTO{
+		Status:  "Пароль успешно изменен",
+		Revoked: revoked,
+	})
+}
@@ -0,0 +1,94 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService service.SessionService
+	logger         *slog.Logger
+}
+
+func NewSessionHandler(sessionService service.SessionService, logger *slog.Logger) SessionHandler {
+	return SessionHandler{
+		sessionService: sessionService,
+		logger:         logger,
+	}
+}
+
+// ListSessionsHandler обрабатывает запрос на получение действующих сессий пользователя
+func (h *SessionHandler) ListSessionsHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userID, getSessionID(c))
+	if err != nil {
+		handleError(c, "Failed to list sessions", err)
+		return
+	}
+
+	h.logger.Info("Sessions return successfully", "method", "ListSessionsHandler", "user_id", userID)
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessionHandler обрабатывает запрос на завершение одной из сессий пользователя
+func (h *SessionHandler) RevokeSessionHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := parseIDParam(c, "sid")
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Request.Context(), userID, int64(sessionID)); err != nil {
+		handleError(c, "Failed to revoke session", err)
+		return
+	}
+
+	h.logger.Info("Session revoked successfully", "method", "RevokeSessionHandler", "user_id", userID, "session_id", sessionID)
+	c.JSON(http.StatusOK, dto.StatusDTO{Status: "Сессия завершена"})
+}
+
+// LogoutAllHandler обрабатывает выход из всех сессий пользователя; при keep_current=true текущая сессия сохраняется
+func (h *SessionHandler) LogoutAllHandler(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	var query dto.LogoutAllQueryDTO
+
+	if err = c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid logout parameters", err)
+		return
+	}
+
+	var keepSessionID int64
+	if query.KeepCurrent {
+		keepSessionID = getSessionID(c)
+	}
+
+	revoked, err := h.sessionService.RevokeAllSessions(c.Request.Context(), userID, keepSessionID)
+	if err != nil {
+		handleError(c, "Failed to revoke sessions", err)
+		return
+	}
+
+	h.logger.Info("Sessions revoked successfully", "method", "LogoutAllHandler", "user_id", userID, "revoked", revoked)
+	c.JSON(http.StatusOK, dto.LogoutAllResponseDTO{
+		Status:  "Выход выполнен во всех сессиях",
+		Revoked: revoked,
+	})
+}
@@ -0,0 +1,182 @@
+package delivery
+
+import (
+	"log/slog"
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskHandler struct {
+	taskService service.TaskService
+	logger      *slog.Logger
+}
+
+func NewTaskHandler(taskService service.TaskService, logger *slog.Logger) TaskHandler {
+	return TaskHandler{
+		taskService: taskService,
+		logger:      logger,
+	}
+}
+
+// CreateTaskHandler обрабатывает запрос администратора на создание задания
+func (h *TaskHandler) CreateTaskHandler(c *gin.Context) {
+	actorID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	var input dto.TaskInputDTO
+
+	if err = c.ShouldBindJSON(&input); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding task", err)
+		return
+	}
+
+	task, err := h.taskService.CreateTask(c.Request.Context(), actorID, &input)
+	if err != nil {
+		handleError(c, "Error creating task", err)
+		return
+	}
+
+	h.logger.Info("Task created successfully", "method", "CreateTaskHandler", "actor_id", actorID, "task_id", task.ID)
+	c.JSON(http.StatusCreated, task)
+}
+
+// UpdateTaskHandler обрабатывает запрос администратора на изменение задания
+func (h *TaskHandler) UpdateTaskHandler(c *gin.Context) {
+	actorID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	taskID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var input dto.TaskInputDTO
+
+	if err = c.ShouldBindJSON(&input); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Error binding task", err)
+		return
+	}
+
+	task, err := h.taskService.UpdateTask(c.Request.Context(), actorID, taskID, &input)
+	if err != nil {
+		handleError(c, "Error updating task", err)
+		return
+	}
+
+	h.logger.Info("Task updated successfully", "method", "UpdateTaskHandler", "actor_id", actorID, "task_id", taskID)
+	c.JSON(http.StatusOK, task)
+}
+
+// ArchiveTaskHandler обрабатывает запрос администратора на перенос задания в архив
+func (h *TaskHandler) ArchiveTaskHandler(c *gin.Context) {
+	actorID, err := getUserID(c)
+	if err != nil {
+		logAndHandleError(c, http.StatusUnauthorized, err.Error(), err)
+		return
+	}
+
+	taskID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	task, err := h.taskService.ArchiveTask(c.Request.Context(), actorID, taskID)
+	if err != nil {
+		handleError(c, "Error archiving task", err)
+		return
+	}
+
+	h.logger.Info("Task archived successfully", "method", "ArchiveTaskHandler", "actor_id", actorID, "task_id", taskID)
+	c.JSON(http.StatusOK, task)
+}
+
+// ListTasksHandler обрабатывает запрос администратора на получение списка заданий
+func (h *TaskHandler) ListTasksHandler(c *gin.Context) {
+	var query dto.TaskListQueryDTO
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	tasks, err := h.taskService.ListTasks(c.Request.Context(), &query)
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed get tasks", err)
+		return
+	}
+
+	h.logger.Info("Tasks return successfully", "method", "ListTasksHandler", "count", len(tasks.Items))
+	c.JSON(http.StatusOK, tasks)
+}
+
+// TaskAuditHandler обрабатывает запрос администратора на получение журнала изменений задания
+func (h *TaskHandler) TaskAuditHandler(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	audit, err := h.taskService.TaskAudit(c.Request.Context(), taskID)
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed get task audit", err)
+		return
+	}
+
+	h.logger.Info("Task audit return successfully", "method", "TaskAuditHandler", "task_id", taskID)
+	c.JSON(http.StatusOK, audit)
+}
+
+// TaskCatalogueHandler обрабатывает запрос на получение каталога заданий.
+// Если запрос аутентифицирован, для каждого задания указывается, выполнил ли его пользователь
+func (h *TaskHandler) TaskCatalogueHandler(c *gin.Context) {
+	// Для анонимного запроса user_id отсутствует в контексте, каталог строится без статуса выполнения
+	userID, _ := getUserID(c)
+
+	var query dto.TaskCatalogueQueryDTO
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	h.taskCatalogue(c, userID, &query)
+}
+
+// UserTasksHandler обрабатывает запрос на получение каталога заданий со статусом выполнения пользователем
+func (h *TaskHandler) UserTasksHandler(c *gin.Context) {
+	userID, ok := validateUserID(c)
+	if !ok {
+		return
+	}
+
+	var query dto.TaskCatalogueQueryDTO
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logAndHandleError(c, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	h.taskCatalogue(c, userID, &query)
+}
+
+// taskCatalogue получает каталог заданий и отправляет его в ответе
+func (h *TaskHandler) taskCatalogue(c *gin.Context, userID int, query *dto.TaskCatalogueQueryDTO) {
+	catalogue, err := h.taskService.TaskCatalogue(c.Request.Context(), userID, query)
+	if err != nil {
+		logAndHandleError(c, http.StatusInternalServerError, "Failed get task catalogue", err)
+		return
+	}
+
+	h.logger.Info("Task catalogue return successfully", "method", "TaskCatalogue", "user_id", userID, "count", len(catalogue.Items))
+	c.JSON(http.StatusOK, catalogue)
+}
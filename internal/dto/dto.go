@@ -1,11 +1,61 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// UserRegLogDTO представляет данные для регистрации и входа пользователя
+// UserRegLogDTO представляет данные для регистрации и входа пользователя. Адрес электронной почты учитывается только при регистрации.
+// Требования к паролю задает политика паролей и проверяются только при регистрации
 type UserRegLogDTO struct {
 	UserName string `json:"username" binding:"required,username"`
-	Password string `json:"password" binding:"required,min=6,max=20"`
+	Password string `json:"password" binding:"required,max=1024"`
+	Email    string `json:"email,omitempty" binding:"omitempty,email,max=255"`
+}
+
+// EmailDTO представляет новый адрес электронной почты пользователя и текущий пароль для подтверждения смены
+type EmailDTO struct {
+	Email           string `json:"email" binding:"required,email,max=255"`
+	CurrentPassword string `json:"current_password" binding:"required,max=1024"`
+}
+
+// EmailVerifyQueryDTO представляет параметры ссылки подтверждения адреса электронной почты
+type EmailVerifyQueryDTO struct {
+	Token string `form:"token" binding:"required,max=1024"`
+}
+
+// ForgotPasswordDTO представляет запрос на сброс пароля
+type ForgotPasswordDTO struct {
+	UserName string `json:"username" binding:"required,username"`
+}
+
+// ResetPasswordDTO представляет данные для установки нового пароля по токену сброса
+type ResetPasswordDTO struct {
+	Token    string `json:"token" binding:"required,max=128"`
+	Password string `json:"password" binding:"required,max=1024"`
+}
+
+// ChangePasswordDTO представляет данные для смены пароля вошедшим пользователем
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=1024"`
+	NewPassword     string `json:"new_password" binding:"required,max=1024"`
+}
+
+// ChangePasswordResponseDTO представляет ответ на смену пароля
+type ChangePasswordResponseDTO struct {
+	Status  string `json:"status"`
+	Revoked int64  `json:"revoked"` // Число завершенных сессий, кроме текущей
+}
+
+// MFALoginDTO представляет второй шаг входа: токен запроса второго фактора и код из приложения или код восстановления
+type MFALoginDTO struct {
+	MFAToken string `json:"mfa_token" binding:"required,max=128"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
+
+// MFACodeDTO представляет код из приложения-аутентификатора
+type MFACodeDTO struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
 }
 
 // UserLoginDTO представляет данные для ответа на вход пользователя
@@ -16,12 +66,15 @@ type UserLoginDTO struct {
 
 // UserStatusDTO представляет данные о пользователе
 type UserStatusDTO struct {
-	ID             int       `json:"id"`
-	UserName       string    `json:"username"`
-	Balance        int       `json:"balance"`
-	UpdatedBalance time.Time `json:"updated_balance"`
-	Referrer       *int      `json:"referrer_id,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	UserName       string     `json:"username"`
+	Balance        int        `json:"balance"`
+	UpdatedBalance time.Time  `json:"updated_balance"`
+	Referrer       *int       `json:"referrer_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Email          *string    `json:"email,omitempty"`
+	EmailVerified  bool       `json:"email_verified"`
+	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
 }
 
 // UserLeaderDTO представляет данные о пользователе для leaderboard
@@ -31,11 +84,430 @@ type UserLeaderDTO struct {
 	Balance  int    `json:"balance"`
 }
 
+// TaskDTO представляет данные для выполнения задания
 type TaskDTO struct {
-	ID int `json:"task_id"`
+	ID    int    `json:"task_id"`
+	Proof string `json:"proof" binding:"omitempty,max=255"`
 }
 
 // ReferrerDTO представляет данные для добавления реферера
 type ReferrerDTO struct {
 	Referrer int `json:"referrer_id"`
 }
+
+// TokenPairDTO представляет пару access и refresh токенов
+type TokenPairDTO struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// StatusDTO представляет ответ с сообщением о результате операции
+type StatusDTO struct {
+	Status string `json:"status"`
+}
+
+// RegisterResponseDTO представляет ответ на регистрацию пользователя
+type RegisterResponseDTO struct {
+	Status string `json:"status"`
+	UserID int    `json:"user_id"`
+}
+
+// AuthResponseDTO представляет ответ на вход пользователя и обновление токенов
+type AuthResponseDTO struct {
+	Status string `json:"status"`
+	TokenPairDTO
+}
+
+// MFAChallengeDTO представляет ответ на проверку пароля пользователя с подключенным вторым фактором
+type MFAChallengeDTO struct {
+	Status      string    `json:"status"`
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MFAEnrollmentDTO представляет секрет TOTP для подключения приложения-аутентификатора
+type MFAEnrollmentDTO struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesDTO представляет одноразовые коды восстановления, которые показываются только один раз
+type RecoveryCodesDTO struct {
+	Status        string   `json:"status"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ReferrerResponseDTO представляет ответ на добавление реферера
+type ReferrerResponseDTO struct {
+	Status   string `json:"status"`
+	Referrer int    `json:"referrer_id"`
+}
+
+// TaskCompleteResponseDTO представляет ответ на запрос выполнения задания
+type TaskCompleteResponseDTO struct {
+	Status       string `json:"status"`
+	TaskID       int    `json:"task_id"`
+	CompletionID int64  `json:"completion_id"`
+}
+
+// RefreshTokenDTO представляет данные для обновления пары токенов
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SessionDTO представляет сессию пользователя (вход с устройства)
+type SessionDTO struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionsDTO представляет действующие сессии пользователя
+type SessionsDTO struct {
+	Items []SessionDTO `json:"items"`
+}
+
+// LogoutAllQueryDTO представляет параметры выхода из всех сессий
+type LogoutAllQueryDTO struct {
+	KeepCurrent bool `form:"keep_current"`
+}
+
+// LogoutAllResponseDTO представляет ответ на выход из всех сессий
+type LogoutAllResponseDTO struct {
+	Status  string `json:"status"`
+	Revoked int64  `json:"revoked"`
+}
+
+// CreateAPIKeyDTO представляет данные для создания API ключа
+type CreateAPIKeyDTO struct {
+	Name      string     `json:"name" binding:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes" binding:"omitempty,max=20,dive,max=64"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyDTO представляет API ключ пользователя без самого ключа
+type APIKeyDTO struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+}
+
+// CreatedAPIKeyDTO представляет созданный API ключ; сам ключ возвращается только в этом ответе
+type CreatedAPIKeyDTO struct {
+	APIKeyDTO
+	Key string `json:"key"`
+}
+
+// APIKeysDTO представляет действующие API ключи пользователя
+type APIKeysDTO struct {
+	Items []APIKeyDTO `json:"items"`
+}
+
+// OIDCCallbackDTO представляет параметры возврата от внешнего провайдера OpenID Connect
+type OIDCCallbackDTO struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// AuthorizationURLDTO представляет адрес, на который нужно перейти для входа у провайдера
+type AuthorizationURLDTO struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// IdentityDTO представляет привязанную учетную запись внешнего провайдера
+type IdentityDTO struct {
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       *string    `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// IdentitiesDTO представляет привязанные учетные записи внешних провайдеров
+type IdentitiesDTO struct {
+	Items []IdentityDTO `json:"items"`
+}
+
+// CreateOAuthClientDTO представляет данные для регистрации стороннего приложения OAuth2
+type CreateOAuthClientDTO struct {
+	Name         string   `json:"name" binding:"required,min=1,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"omitempty,max=10,dive,url,max=2048"`
+	GrantTypes   []string `json:"grant_types" binding:"required,min=1,max=2,dive,oneof=authorization_code client_credentials"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,max=20,dive,max=64"`
+	Confidential bool     `json:"confidential"` // Выдать приложению секрет; обязательно для client_credentials
+}
+
+// OAuthClientDTO представляет зарегистрированное стороннее приложение без секрета
+type OAuthClientDTO struct {
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreatedOAuthClientDTO представляет зарегистрированное приложение; секрет возвращается только в этом ответе
+type CreatedOAuthClientDTO struct {
+	OAuthClientDTO
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthClientsDTO представляет зарегистрированные сторонние приложения
+type OAuthClientsDTO struct {
+	Items []OAuthClientDTO `json:"items"`
+}
+
+// OAuthAuthorizeDTO представляет параметры запроса авторизации стороннего приложения (RFC 6749, RFC 7636)
+type OAuthAuthorizeDTO struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required,max=64"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required,max=2048"`
+	Scope               string `form:"scope" json:"scope" binding:"max=1024"`
+	State               string `form:"state" json:"state" binding:"max=512"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// OAuthConsentDecisionDTO представляет решение пользователя по запросу авторизации
+type OAuthConsentDecisionDTO struct {
+	OAuthAuthorizeDTO
+	Approve bool `json:"approve"`
+}
+
+// OAuthAuthorizationDTO представляет запрос авторизации для показа пользователю перед согласием
+type OAuthAuthorizationDTO struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	Scopes          []string `json:"scopes"`
+	ConsentRequired bool     `json:"consent_required"` // false, если все права уже переданы приложению ранее
+}
+
+// OAuthRedirectDTO представляет адрес возврата в приложение с кодом авторизации или ошибкой
+type OAuthRedirectDTO struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// OAuthTokenRequestDTO представляет запрос токена стороннего приложения (application/x-www-form-urlencoded)
+type OAuthTokenRequestDTO struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenDTO представляет выданный стороннему приложению access токен (RFC 6749, раздел 5.1)
+type OAuthTokenDTO struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthRevokeDTO представляет запрос отзыва токена стороннего приложения (RFC 7009)
+type OAuthRevokeDTO struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// OAuthErrorDTO представляет ошибку конечных точек /oauth/token и /oauth/revoke (RFC 6749, раздел 5.2)
+type OAuthErrorDTO struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OAuthConsentDTO представляет согласие пользователя на доступ стороннего приложения
+type OAuthConsentDTO struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// OAuthConsentsDTO представляет согласия пользователя на доступ сторонних приложений
+type OAuthConsentsDTO struct {
+	Items []OAuthConsentDTO `json:"items"`
+}
+
+// PaginationDTO представляет параметры постраничного вывода
+type PaginationDTO struct {
+	Limit  int `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+}
+
+// PointTransactionDTO представляет операцию изменения баланса
+type PointTransactionDTO struct {
+	ID           int64     `json:"id"`
+	Amount       int       `json:"amount"`
+	BalanceAfter int       `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	ReferenceID  *int64    `json:"reference_id,omitempty"` // Заявка на выполнение задания, приглашенный пользователь или администратор
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PointTransactionsDTO представляет страницу истории операций пользователя
+type PointTransactionsDTO struct {
+	Items  []PointTransactionDTO `json:"items"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+// BalanceAdjustmentDTO представляет корректировку баланса пользователя администратором
+type BalanceAdjustmentDTO struct {
+	Amount int `json:"amount" binding:"required"` // Изменение баланса: положительное начисляет, отрицательное списывает
+}
+
+// BalanceDTO представляет баланс пользователя после изменения
+type BalanceDTO struct {
+	UserID  int `json:"user_id"`
+	Balance int `json:"balance"`
+}
+
+// BalanceMismatchDTO представляет расхождение баланса пользователя с журналом операций
+type BalanceMismatchDTO struct {
+	UserID    int `json:"user_id"`
+	Balance   int `json:"balance"`
+	LedgerSum int `json:"ledger_sum"`
+}
+
+// TaskInputDTO представляет данные для создания и изменения задания администратором
+type TaskInputDTO struct {
+	Description    string     `json:"description" binding:"required,min=1,max=255"`
+	Reward         int        `json:"reward" binding:"required,min=1"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Recurrence     string     `json:"recurrence" binding:"omitempty,oneof=once daily weekly limited"`
+	MaxCompletions *int       `json:"max_completions" binding:"omitempty,min=1"`
+	Verification   string     `json:"verification" binding:"omitempty,oneof=none manual callback proof_code"`
+	ProofCode      *string    `json:"proof_code" binding:"omitempty,min=4,max=64"`
+}
+
+// TaskListQueryDTO представляет параметры запроса списка заданий для администратора
+type TaskListQueryDTO struct {
+	PaginationDTO
+	IncludeArchived bool `form:"include_archived"`
+}
+
+// AdminTaskDTO представляет полные данные о задании для администратора
+type AdminTaskDTO struct {
+	ID             int        `json:"id"`
+	Description    string     `json:"description"`
+	Reward         int        `json:"reward"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	IsArchived     bool       `json:"is_archived"`
+	Recurrence     string     `json:"recurrence"`
+	MaxCompletions *int       `json:"max_completions,omitempty"`
+	Verification   string     `json:"verification"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// AdminTasksDTO представляет страницу списка заданий для администратора
+type AdminTasksDTO struct {
+	Items  []AdminTaskDTO `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// TaskAuditDTO представляет запись журнала изменений задания
+type TaskAuditDTO struct {
+	ID        int64           `json:"id"`
+	TaskID    int             `json:"task_id"`
+	ActorID   int             `json:"actor_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Значения фильтра каталога заданий по статусу выполнения
+const (
+	TaskStatusCompleted    = "completed"
+	TaskStatusNotCompleted = "not_completed"
+)
+
+// TaskCatalogueQueryDTO представляет параметры запроса каталога заданий
+type TaskCatalogueQueryDTO struct {
+	PaginationDTO
+	Status string `form:"status" binding:"omitempty,oneof=completed not_completed"`
+}
+
+// TaskCatalogueItemDTO представляет задание в каталоге со статусом выполнения пользователем
+type TaskCatalogueItemDTO struct {
+	ID             int        `json:"id"`
+	Description    string     `json:"description"`
+	Reward         int        `json:"reward"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Recurrence     string     `json:"recurrence"`
+	MaxCompletions *int       `json:"max_completions,omitempty"`
+	Verification   string     `json:"verification"`
+	Completions    int        `json:"completions"`
+	Pending        int        `json:"pending"`
+	Completed      bool       `json:"completed"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// TaskCatalogueDTO представляет страницу каталога заданий
+type TaskCatalogueDTO struct {
+	Items  []TaskCatalogueItemDTO `json:"items"`
+	Total  int                    `json:"total"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+}
+
+// TaskCompletionDTO представляет заявку пользователя на выполнение задания
+type TaskCompletionDTO struct {
+	ID          int64      `json:"id"`
+	UserID      int        `json:"user_id"`
+	TaskID      int        `json:"task_id"`
+	Status      string     `json:"status"`
+	Proof       *string    `json:"proof,omitempty"`
+	CompletedAt time.Time  `json:"completed_at"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy  *int       `json:"reviewed_by,omitempty"`
+}
+
+// CompletionListQueryDTO представляет параметры запроса очереди заявок на выполнение заданий
+type CompletionListQueryDTO struct {
+	PaginationDTO
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+}
+
+// TaskCompletionsDTO представляет страницу заявок на выполнение заданий
+type TaskCompletionsDTO struct {
+	Items  []TaskCompletionDTO `json:"items"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+// TaskCallbackDTO представляет результат проверки выполнения задания внешней системой
+type TaskCallbackDTO struct {
+	CompletionID int64 `json:"completion_id" binding:"required"`
+	Approved     *bool `json:"approved" binding:"required"`
+}
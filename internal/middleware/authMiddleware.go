@@ -5,43 +5,150 @@ import (
 	"net/http"
 	"strings"
 
+	"user-management/internal/pkg/problem"
+	"user-management/internal/pkg/rbac"
 	"user-management/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Заголовки и схемы, в которых передается API ключ
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyScheme = "ApiKey "
+)
+
 type AuthMiddleware struct {
-	tokenService service.TokenService
-	logger       *slog.Logger
+	tokenService  service.TokenService
+	apiKeyService service.APIKeyService
+	logger        *slog.Logger
 }
 
-func NewAuthMiddleware(tokenService service.TokenService, logger *slog.Logger) *AuthMiddleware {
+func NewAuthMiddleware(tokenService service.TokenService, apiKeyService service.APIKeyService, logger *slog.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
-		tokenService: tokenService,
-		logger:       logger,
+		tokenService:  tokenService,
+		apiKeyService: apiKeyService,
+		logger:        logger,
 	}
 }
 
-// AuthMiddleware проверяет JWT токен и добавляет `user_id` в GIN контекст
+// AuthMiddleware проверяет JWT токен или API ключ и добавляет `user_id`, `roles`, `session_id`,
+// для API ключа `api_key_id` и `scopes`, а для токена стороннего приложения `client_id` и `scopes` в GIN контекст.
+// Токен приложения, выданный без пользователя (client_credentials), не добавляет `user_id`
 func (m *AuthMiddleware) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var claims *service.AccessClaims
+		var err error
+
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid authorization header"})
-			c.Abort()
+		if key, ok := apiKeyFromRequest(c); ok {
+			claims, err = m.apiKeyService.ValidateAPIKey(c.Request.Context(), key, c.ClientIP())
+			if err != nil {
+				problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid API key"))
+				return
+			}
+		} else if strings.HasPrefix(authHeader, "Bearer ") {
+			claims, err = m.tokenService.ValidateToken(c.Request.Context(), strings.TrimPrefix(authHeader, "Bearer "))
+			if err != nil {
+				problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid token"))
+				return
+			}
+		} else {
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Missing or invalid authorization header"))
 			return
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if claims.UserID != 0 {
+			c.Set("user_id", claims.UserID)
+		}
+		c.Set("roles", claims.Roles)
+		c.Set("session_id", claims.SessionID)
+		c.Set("api_key_id", claims.APIKeyID)
+		c.Set("client_id", claims.ClientID)
+		c.Set("scopes", claims.Scopes)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware работает как AuthMiddleware, но пропускает запросы без заголовков Authorization и X-API-Key
+// как анонимные. Переданный, но невалидный токен или ключ по-прежнему отклоняется
+func (m *AuthMiddleware) OptionalAuthMiddleware() gin.HandlerFunc {
+	auth := m.AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.GetHeader(apiKeyHeader) == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
 
-		userID, err := m.tokenService.ValidateToken(c.Request.Context(), token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
+// RequireRole пропускает запрос, только если у пользователя есть хотя бы одна из ролей.
+// Должен применяться после AuthMiddleware
+func (m *AuthMiddleware) RequireRole(roles ...rbac.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.HasRole(c.GetStringSlice("roles"), roles...) {
+			m.logger.Warn("Access denied: missing role", "user_id", c.GetInt("user_id"), "required", roles, "path", c.Request.URL.Path)
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.CodeInsufficientRole, "Insufficient role"))
 			return
 		}
 
-		c.Set("user_id", userID)
 		c.Next()
 	}
 }
+
+// RequirePermission пропускает запрос, только если роли пользователя предоставляют указанное право,
+// а API ключ или токен приложения, которым выполнен запрос, не ограничен другими правами.
+// Токен приложения без пользователя получает только права, перечисленные в его scope.
+// Должен применяться после AuthMiddleware
+func (m *AuthMiddleware) RequirePermission(permission rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasPermission(c, permission) {
+			m.logger.Warn("Access denied: missing permission", "user_id", c.GetInt("user_id"), "required", permission, "path", c.Request.URL.Path)
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.CodeInsufficientPermissions, "Insufficient permissions"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAccessToken пропускает запрос, только если он выполнен с access токеном пользователя, а не с API ключом
+// или токеном стороннего приложения. Должен применяться после AuthMiddleware
+func (m *AuthMiddleware) RequireAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetInt64("api_key_id") != 0 {
+			m.logger.Warn("Access denied: api key used", "user_id", c.GetInt("user_id"), "api_key_id", c.GetInt64("api_key_id"), "path", c.Request.URL.Path)
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.CodeAccessTokenRequired, "Access token required"))
+			return
+		}
+		if c.GetString("client_id") != "" {
+			m.logger.Warn("Access denied: client token used", "user_id", c.GetInt("user_id"), "client_id", c.GetString("client_id"), "path", c.Request.URL.Path)
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.CodeAccessTokenRequired, "Access token required"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// hasPermission проверяет, что запросу доступно право: токену приложения без пользователя — только по его scope,
+// остальным — по ролям пользователя с учетом ограничений API ключа или токена приложения
+func hasPermission(c *gin.Context, permission rbac.Permission) bool {
+	scopes := c.GetStringSlice("scopes")
+	if _, ok := c.Get("user_id"); !ok {
+		return len(scopes) > 0 && rbac.AllowedByScopes(scopes, permission)
+	}
+	return rbac.HasPermission(c.GetStringSlice("roles"), permission) && rbac.AllowedByScopes(scopes, permission)
+}
+
+// apiKeyFromRequest извлекает API ключ из заголовка X-API-Key или Authorization со схемой ApiKey
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		return key, true
+	}
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, apiKeyScheme) {
+		return strings.TrimPrefix(authHeader, apiKeyScheme), true
+	}
+	return "", false
+}
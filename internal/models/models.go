@@ -1,19 +1,325 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type User struct {
-	ID            int       `db:"id"`
-	UserName      string    `db:"username"`
-	Password      string    `db:"password"`
-	Balance       int       `db:"balance"`
-	UpdateBalance time.Time `db:"updated_balance"`
-	Referrer      *int      `db:"referrer"`
-	CreatedAt     time.Time `db:"created_at"`
+	ID            int        `db:"id"`
+	UserName      string     `db:"username"`
+	Password      string     `db:"password"`
+	Balance       int        `db:"balance"`
+	UpdateBalance time.Time  `db:"updated_balance"`
+	Referrer      *int       `db:"referrer"`
+	CreatedAt     time.Time  `db:"created_at"`
+	Email         *string    `db:"email"`
+	VerifiedAt    *time.Time `db:"verified_at"`
 }
 
+// TaskRecurrence политика повторного выполнения задания
+type TaskRecurrence string
+
+const (
+	TaskRecurrenceOnce    TaskRecurrence = "once"    // Один раз
+	TaskRecurrenceDaily   TaskRecurrence = "daily"   // Один раз в сутки (UTC)
+	TaskRecurrenceWeekly  TaskRecurrence = "weekly"  // Один раз в ISO неделю (UTC)
+	TaskRecurrenceLimited TaskRecurrence = "limited" // Не более MaxCompletions раз за все время
+)
+
+// TaskVerification способ проверки выполнения задания
+type TaskVerification string
+
+const (
+	TaskVerificationNone      TaskVerification = "none"       // Выполнение засчитывается сразу
+	TaskVerificationManual    TaskVerification = "manual"     // Выполнение проверяет администратор
+	TaskVerificationCallback  TaskVerification = "callback"   // Результат проверки присылает внешняя система
+	TaskVerificationProofCode TaskVerification = "proof_code" // Пользователь вводит код подтверждения
+)
+
+// CompletionStatus статус проверки выполнения задания
+type CompletionStatus string
+
+const (
+	CompletionStatusPending  CompletionStatus = "pending"
+	CompletionStatusApproved CompletionStatus = "approved"
+	CompletionStatusRejected CompletionStatus = "rejected"
+)
+
 type Task struct {
-	ID          int    `db:"id"`
-	Description string `db:"description"`
-	Reward      int    `db:"reward"`
+	ID                   int              `db:"id"`
+	Description          string           `db:"description"`
+	Reward               int              `db:"reward"`
+	StartsAt             *time.Time       `db:"starts_at"`
+	EndsAt               *time.Time       `db:"ends_at"`
+	IsArchived           bool             `db:"is_archived"`
+	Recurrence           TaskRecurrence   `db:"recurrence"`
+	MaxCompletions       *int             `db:"max_completions"`
+	Verification         TaskVerification `db:"verification"`
+	VerificationCodeHash *string          `db:"verification_code_hash"`
+	CreatedAt            time.Time        `db:"created_at"`
+	UpdatedAt            time.Time        `db:"updated_at"`
+}
+
+// PeriodKey возвращает ключ текущего периода выполнения задания.
+// Для заданий без периода (once, limited) возвращается пустая строка
+func (t *Task) PeriodKey(now time.Time) string {
+	switch t.Recurrence {
+	case TaskRecurrenceDaily:
+		return DailyPeriodKey(now)
+	case TaskRecurrenceWeekly:
+		return WeeklyPeriodKey(now)
+	default:
+		return ""
+	}
+}
+
+// CompletionLimit возвращает, сколько раз задание можно выполнить за период (или за все время)
+func (t *Task) CompletionLimit() int {
+	if t.Recurrence == TaskRecurrenceLimited && t.MaxCompletions != nil {
+		return *t.MaxCompletions
+	}
+	return 1
+}
+
+// CompletionKey возвращает ключ, под которым сохраняется очередное выполнение задания.
+// last — наибольший номер среди всех выполнений задания без периода, включая отклоненные
+func (t *Task) CompletionKey(now time.Time, last int) string {
+	if key := t.PeriodKey(now); key != "" {
+		return key
+	}
+	return fmt.Sprintf("#%d", last+1)
+}
+
+// DailyPeriodKey ключ суточного периода в UTC, например 2025-01-31
+func DailyPeriodKey(now time.Time) string {
+	return now.UTC().Format(time.DateOnly)
+}
+
+// WeeklyPeriodKey ключ ISO недели в UTC, например 2025-W05
+func WeeklyPeriodKey(now time.Time) string {
+	year, week := now.UTC().ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// TaskCompletion заявка пользователя на выполнение задания
+type TaskCompletion struct {
+	ID          int64            `db:"id"`
+	UserID      int              `db:"user_id"`
+	TaskID      int              `db:"task_id"`
+	PeriodKey   string           `db:"period_key"`
+	Status      CompletionStatus `db:"status"`
+	Proof       *string          `db:"proof"`
+	CompletedAt time.Time        `db:"completed_at"`
+	ReviewedAt  *time.Time       `db:"reviewed_at"`
+	ReviewedBy  *int             `db:"reviewed_by"`
+}
+
+// UserTask задание вместе с отметкой о его выполнении пользователем
+type UserTask struct {
+	Task
+	Completions int        `db:"completions"`
+	Pending     int        `db:"pending"`
+	Completed   bool       `db:"completed"`
+	CompletedAt *time.Time `db:"completed_at"`
+}
+
+// TaskAuditAction действие администратора над заданием
+type TaskAuditAction string
+
+const (
+	TaskAuditActionCreate  TaskAuditAction = "create"
+	TaskAuditActionUpdate  TaskAuditAction = "update"
+	TaskAuditActionArchive TaskAuditAction = "archive"
+)
+
+type TaskAuditEntry struct {
+	ID        int64           `db:"id"`
+	TaskID    int             `db:"task_id"`
+	ActorID   int             `db:"actor_id"`
+	Action    TaskAuditAction `db:"action"`
+	Before    []byte          `db:"before"`
+	After     []byte          `db:"after"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+// UserAuditAction событие учетной записи пользователя, записываемое в журнал аудита
+type UserAuditAction string
+
+const (
+	UserAuditActionPasswordChange UserAuditAction = "password_change"
+)
+
+type UserAuditEntry struct {
+	ID        int64           `db:"id"`
+	UserID    int             `db:"user_id"`
+	Action    UserAuditAction `db:"action"`
+	IP        string          `db:"ip"`
+	UserAgent string          `db:"user_agent"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+type RefreshToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	FamilyID  string     `db:"family_id"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	IsRevoked bool       `db:"is_revoked"`
+}
+
+// Session сессия пользователя: вход с устройства и все токены, выпущенные после него
+type Session struct {
+	ID         int64      `db:"id"`
+	UserID     int        `db:"user_id"`
+	FamilyID   string     `db:"family_id"`
+	UserAgent  string     `db:"user_agent"`
+	IP         string     `db:"ip"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastSeenAt time.Time  `db:"last_seen_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// APIKey ключ API пользователя для скриптов и интеграций (хранится только хэш)
+type APIKey struct {
+	ID         int64      `db:"id"`
+	UserID     int        `db:"user_id"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	KeyHash    string     `db:"key_hash"`
+	Scopes     []string   `db:"scopes"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	LastUsedIP *string    `db:"last_used_ip"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// UserIdentity учетная запись внешнего провайдера OpenID Connect, привязанная к пользователю
+type UserIdentity struct {
+	ID          int64      `db:"id"`
+	UserID      int        `db:"user_id"`
+	Provider    string     `db:"provider"`
+	Subject     string     `db:"subject"`
+	Email       *string    `db:"email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+}
+
+// OIDCAuthRequest начатый вход через внешнего провайдера, ожидающий обратного вызова (хранится только хэш state)
+type OIDCAuthRequest struct {
+	ID           int64     `db:"id"`
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	UserID       *int      `db:"user_id"`
+	CreatedAt    time.Time `db:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// OAuthGrantType способ получения токена сторонним приложением
+type OAuthGrantType string
+
+const (
+	OAuthGrantAuthorizationCode OAuthGrantType = "authorization_code" // Код авторизации с PKCE от имени пользователя
+	OAuthGrantClientCredentials OAuthGrantType = "client_credentials" // Токен самого приложения без пользователя
+)
+
+// OAuthClient стороннее приложение, зарегистрированное администратором (хранится только хэш секрета)
+type OAuthClient struct {
+	ID           int64     `db:"id"`
+	ClientID     string    `db:"client_id"`
+	SecretHash   *string   `db:"secret_hash"`
+	Name         string    `db:"name"`
+	RedirectURIs []string  `db:"redirect_uris"`
+	GrantTypes   []string  `db:"grant_types"`
+	Scopes       []string  `db:"scopes"`
+	CreatedBy    *int      `db:"created_by"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// AllowsGrant проверяет, разрешен ли приложению способ получения токена
+func (c *OAuthClient) AllowsGrant(grant OAuthGrantType) bool {
+	for _, g := range c.GrantTypes {
+		if g == string(grant) {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthConsent согласие пользователя на доступ приложения к его данным
+type OAuthConsent struct {
+	ID         int64     `db:"id"`
+	UserID     int       `db:"user_id"`
+	ClientID   int64     `db:"client_id"`
+	ClientKey  string    `db:"client_key"` // Публичный идентификатор приложения (oauth_clients.client_id)
+	ClientName string    `db:"name"`
+	Scopes     []string  `db:"scopes"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// OAuthAuthorizationCode код авторизации, ожидающий обмена на токен (хранится только хэш)
+type OAuthAuthorizationCode struct {
+	ID            int64     `db:"id"`
+	CodeHash      string    `db:"code_hash"`
+	ClientID      int64     `db:"client_id"`
+	UserID        int       `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scopes        []string  `db:"scopes"`
+	CodeChallenge string    `db:"code_challenge"`
+	CreatedAt     time.Time `db:"created_at"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+// PasswordResetToken токен сброса пароля (хранится только хэш)
+type PasswordResetToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// UserMFA подключенный к учетной записи TOTP аутентификатор
+type UserMFA struct {
+	UserID       int        `db:"user_id"`
+	TOTPSecret   string     `db:"totp_secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep *int64     `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// LoginAttemptScope область учета неудачных попыток входа
+type LoginAttemptScope string
+
+const (
+	LoginAttemptScopeAccount LoginAttemptScope = "account" // Попытки входа под именем пользователя
+	LoginAttemptScopeIP      LoginAttemptScope = "ip"      // Попытки входа с IP адреса клиента
+)
+
+// PointReason тип операции изменения баланса
+type PointReason string
+
+const (
+	PointReasonOpeningBalance  PointReason = "opening_balance"  // Начальный баланс при переносе в журнал
+	PointReasonTaskReward      PointReason = "task_reward"      // Награда за выполнение задания
+	PointReasonReferralBonus   PointReason = "referral_bonus"   // Бонус за приглашенного пользователя
+	PointReasonAdminAdjustment PointReason = "admin_adjustment" // Корректировка баланса администратором
+)
+
+type PointTransaction struct {
+	ID           int64       `db:"id"`
+	UserID       int         `db:"user_id"`
+	Amount       int         `db:"amount"`
+	BalanceAfter int         `db:"balance_after"`
+	Reason       PointReason `db:"reason"`
+	ReferenceID  *int64      `db:"reference_id"`
+	CreatedAt    time.Time   `db:"created_at"`
 }
@@ -0,0 +1,498 @@
+package app
+
+//go:generate go run ../../../cmd/openapi -o ../../../api/openapi.json
+
+import (
+	"net/http"
+
+	"user-management/internal/dto"
+	"user-management/internal/pkg/jwtkeys"
+	"user-management/internal/pkg/openapi"
+	"user-management/internal/pkg/problem"
+	"user-management/internal/pkg/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Пути документации API
+const (
+	openAPIPath = "/openapi.json"
+	docsPath    = "/docs"
+)
+
+// Теги операций в документации
+const (
+	tagAuth        = "auth"
+	tagUsers       = "users"
+	tagTasks       = "tasks"
+	tagAdminUsers  = "admin-users"
+	tagAdminTasks  = "admin-tasks"
+	tagAdminReview = "admin-completions"
+	tagAdminOAuth  = "admin-oauth"
+	tagOAuth       = "oauth"
+)
+
+// apiInfo общие сведения об API для спецификации
+var apiInfo = openapi.Info{
+	Title:       "User Management API",
+	Description: "API управления пользователями, заданиями и реферальной системой",
+	Version:     "1.0.0",
+}
+
+// apiDocs описания маршрутов API для спецификации OpenAPI. Пути строятся из тех же
+// значений, что и в configureApiRoutes, а расхождение с зарегистрированными маршрутами
+// приводит к ошибке построения спецификации
+func apiDocs() []openapi.Route {
+	route := newRouteServer()
+	users := apiPrefix + "/users"
+	tasks := apiPrefix + "/tasks"
+	auth := apiPrefix + "/auth"
+	admin := apiPrefix + "/admin"
+	oauth := "/oauth"
+
+	return []openapi.Route{
+		{
+			Method: http.MethodGet, Path: route.jwks, OperationID: "jwks",
+			Summary: "Открытые ключи проверки подписи access токенов (JWKS)", Tag: tagAuth,
+			Responses: map[int]any{http.StatusOK: jwtkeys.JWKS{}},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.register, OperationID: "register",
+			Summary: "Регистрация пользователя", Tag: tagAuth,
+			Request:   dto.UserRegLogDTO{},
+			Responses: map[int]any{http.StatusOK: dto.RegisterResponseDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.login, OperationID: "login",
+			Summary: "Вход пользователя; при подключенном втором факторе возвращается токен запроса кода (202)", Tag: tagAuth,
+			Request:   dto.UserRegLogDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AuthResponseDTO{}, http.StatusAccepted: dto.MFAChallengeDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusLocked, http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.loginMFA, OperationID: "loginMFA",
+			Summary: "Второй шаг входа: обмен токена запроса и кода TOTP или кода восстановления на пару токенов", Tag: tagAuth,
+			Request:   dto.MFALoginDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AuthResponseDTO{}},
+			Errors: []int{
+				http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity, http.StatusLocked,
+				http.StatusTooManyRequests, http.StatusInternalServerError,
+			},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.refreshToken, OperationID: "refreshToken",
+			Summary: "Обновление пары токенов", Tag: tagAuth,
+			Request:   dto.RefreshTokenDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AuthResponseDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.forgotPassword, OperationID: "forgotPassword",
+			Summary: "Запрос ссылки для сброса пароля; ответ не зависит от существования пользователя", Tag: tagAuth,
+			Request:   dto.ForgotPasswordDTO{},
+			Responses: map[int]any{http.StatusAccepted: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.resetPassword, OperationID: "resetPassword",
+			Summary: "Установка нового пароля по одноразовому токену сброса; все токены пользователя отзываются", Tag: tagAuth,
+			Request:   dto.ResetPasswordDTO{},
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.changePassword, OperationID: "changePassword",
+			Summary: "Смена пароля с проверкой текущего; остальные сессии пользователя завершаются, текущая сохраняется", Tag: tagUsers,
+			Security:  openapi.SecurityAccessToken,
+			Request:   dto.ChangePasswordDTO{},
+			Responses: map[int]any{http.StatusOK: dto.ChangePasswordResponseDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity, http.StatusLocked, http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.verifyEmail, OperationID: "verifyEmail",
+			Summary: "Подтверждение адреса электронной почты по подписанной ссылке из письма", Tag: tagAuth,
+			Query:     dto.EmailVerifyQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.logout, OperationID: "logout",
+			Summary: "Выход пользователя: текущая сессия завершается, переданный refresh токен отзывается вместе с семейством", Tag: tagAuth,
+			Security:  openapi.SecurityAccessToken,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.logoutAll, OperationID: "logoutAll",
+			Summary: "Выход из всех сессий пользователя; при keep_current=true текущая сессия сохраняется", Tag: tagAuth,
+			Security:  openapi.SecurityBearer,
+			Query:     dto.LogoutAllQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.LogoutAllResponseDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.getStatus, OperationID: "getUserStatus",
+			Summary: "Информация о пользователе", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.UserStatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.getLeaderboard, OperationID: "getLeaderboard",
+			Summary: "Топ пользователей по балансу", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: []dto.UserLeaderDTO{}},
+			Errors:    []int{http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.taskComplete, OperationID: "completeTask",
+			Summary: "Выполнение задания", Tag: tagTasks,
+			Security: openapi.SecurityBearer,
+			Request:  dto.TaskDTO{},
+			Responses: map[int]any{
+				http.StatusOK:       dto.TaskCompleteResponseDTO{},
+				http.StatusAccepted: dto.TaskCompleteResponseDTO{},
+			},
+			Errors: []int{
+				http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusGone, http.StatusUnprocessableEntity, http.StatusInternalServerError,
+			},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.referral, OperationID: "addReferrer",
+			Summary: "Ввод реферального кода", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.ReferrerDTO{},
+			Responses: map[int]any{http.StatusOK: dto.ReferrerResponseDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.transactions, OperationID: "getPointTransactions",
+			Summary: "История изменений баланса", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Query:     dto.PaginationDTO{},
+			Responses: map[int]any{http.StatusOK: dto.PointTransactionsDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.userTasks, OperationID: "getUserTasks",
+			Summary: "Каталог заданий со статусом выполнения пользователем", Tag: tagTasks,
+			Security:  openapi.SecurityBearer,
+			Query:     dto.TaskCatalogueQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.TaskCatalogueDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPut, Path: users + route.email, OperationID: "changeEmail",
+			Summary: "Установка или смена адреса электронной почты с проверкой текущего пароля; на новый адрес отправляется письмо для подтверждения, на прежний — уведомление", Tag: tagUsers,
+			Security:  openapi.SecurityAccessToken,
+			Request:   dto.EmailDTO{},
+			Responses: map[int]any{http.StatusAccepted: dto.StatusDTO{}},
+			Errors: []int{
+				http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+				http.StatusUnprocessableEntity, http.StatusLocked, http.StatusTooManyRequests, http.StatusInternalServerError,
+			},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.resendEmail, OperationID: "resendEmailVerification",
+			Summary: "Повторная отправка письма для подтверждения адреса", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusAccepted: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.mfaTOTP, OperationID: "enrollTOTP",
+			Summary: "Подключение приложения-аутентификатора: секрет и otpauth URI для QR-кода", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.MFAEnrollmentDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.mfaTOTPConfirm, OperationID: "confirmTOTP",
+			Summary: "Подтверждение подключения кодом из приложения; возвращает одноразовые коды восстановления", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.MFACodeDTO{},
+			Responses: map[int]any{http.StatusOK: dto.RecoveryCodesDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.sessions, OperationID: "listSessions",
+			Summary: "Действующие сессии пользователя с User-Agent, IP адресом и временем последнего обращения", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.SessionsDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodDelete, Path: users + route.session, OperationID: "revokeSession",
+			Summary: "Завершение сессии и отзыв всех ее токенов", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.apiKeys, OperationID: "createApiKey",
+			Summary: "Создание API ключа с необязательными ограничениями прав и сроком действия; ключ возвращается только в этом ответе", Tag: tagUsers,
+			Security:  openapi.SecurityAccessToken,
+			Request:   dto.CreateAPIKeyDTO{},
+			Responses: map[int]any{http.StatusCreated: dto.CreatedAPIKeyDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.apiKeys, OperationID: "listApiKeys",
+			Summary: "Действующие API ключи пользователя с временем и IP адресом последнего использования", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.APIKeysDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodDelete, Path: users + route.apiKey, OperationID: "revokeApiKey",
+			Summary: "Отзыв API ключа", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: auth + route.oidcLogin, OperationID: "oidcLogin",
+			Summary: "Перенаправление на страницу входа внешнего провайдера OpenID Connect (authorization code с PKCE)", Tag: tagAuth,
+			Responses: map[int]any{http.StatusFound: nil},
+			Errors:    []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusBadGateway},
+		},
+		{
+			Method: http.MethodGet, Path: auth + route.oidcCallback, OperationID: "oidcCallback",
+			Summary: "Возврат от провайдера: вход или создание пользователя, при подключенном втором факторе возвращается токен запроса кода (202)", Tag: tagAuth,
+			Query:     dto.OIDCCallbackDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AuthResponseDTO{}, http.StatusAccepted: dto.MFAChallengeDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusBadGateway},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.identities, OperationID: "listIdentities",
+			Summary: "Привязанные учетные записи внешних провайдеров", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.IdentitiesDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: users + route.identity, OperationID: "linkIdentity",
+			Summary: "Начало привязки учетной записи провайдера: возвращает адрес страницы входа провайдера", Tag: tagUsers,
+			Security:  openapi.SecurityAccessToken,
+			Responses: map[int]any{http.StatusOK: dto.AuthorizationURLDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusBadGateway},
+		},
+		{
+			Method: http.MethodDelete, Path: users + route.identity, OperationID: "unlinkIdentity",
+			Summary: "Отвязка учетной записи провайдера; последний способ входа пользователя без пароля отвязать нельзя", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: users + route.oauthConsents, OperationID: "listOAuthConsents",
+			Summary: "Сторонние приложения, которым пользователь разрешил доступ, и разрешенные права", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.OAuthConsentsDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodDelete, Path: users + route.oauthConsent, OperationID: "revokeOAuthConsent",
+			Summary: "Отзыв доступа стороннего приложения и всех выданных ему токенов пользователя", Tag: tagUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: oauth + route.oauthAuthorize, OperationID: "oauthAuthorize",
+			Summary: "Проверка запроса авторизации стороннего приложения (authorization code с PKCE S256) и данные для экрана согласия", Tag: tagOAuth,
+			Security:  openapi.SecurityAccessToken,
+			Query:     dto.OAuthAuthorizeDTO{},
+			Responses: map[int]any{http.StatusOK: dto.OAuthAuthorizationDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: oauth + route.oauthAuthorize, OperationID: "oauthConsent",
+			Summary: "Решение пользователя по запросу авторизации: адрес возврата в приложение с кодом или ошибкой access_denied", Tag: tagOAuth,
+			Security:  openapi.SecurityAccessToken,
+			Request:   dto.OAuthConsentDecisionDTO{},
+			Responses: map[int]any{http.StatusOK: dto.OAuthRedirectDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: oauth + route.oauthToken, OperationID: "oauthToken",
+			Summary: "Выдача access токена приложению (authorization_code или client_credentials); ошибки в формате RFC 6749", Tag: tagOAuth,
+			Security: openapi.SecurityClient,
+			Form:     dto.OAuthTokenRequestDTO{},
+			Responses: map[int]any{
+				http.StatusOK:                  dto.OAuthTokenDTO{},
+				http.StatusBadRequest:          dto.OAuthErrorDTO{},
+				http.StatusUnauthorized:        dto.OAuthErrorDTO{},
+				http.StatusInternalServerError: dto.OAuthErrorDTO{},
+			},
+		},
+		{
+			Method: http.MethodPost, Path: oauth + route.oauthRevoke, OperationID: "oauthRevoke",
+			Summary: "Отзыв токена приложения (RFC 7009); неизвестный токен не считается ошибкой", Tag: tagOAuth,
+			Security: openapi.SecurityClient,
+			Form:     dto.OAuthRevokeDTO{},
+			Responses: map[int]any{
+				http.StatusOK:                  nil,
+				http.StatusBadRequest:          dto.OAuthErrorDTO{},
+				http.StatusUnauthorized:        dto.OAuthErrorDTO{},
+				http.StatusInternalServerError: dto.OAuthErrorDTO{},
+			},
+		},
+		{
+			Method: http.MethodGet, Path: tasks + route.taskCatalogue, OperationID: "getTaskCatalogue",
+			Summary: "Каталог активных заданий", Tag: tagTasks,
+			Security:  openapi.SecurityOptional,
+			Query:     dto.TaskCatalogueQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.TaskCatalogueDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: tasks + route.taskCallback, OperationID: "taskCompletionCallback",
+			Summary: "Результат проверки выполнения задания от внешней системы", Tag: tagTasks,
+			Headers: []openapi.Parameter{{
+				Name: "X-Signature", In: "header", Required: true,
+				Description: "HMAC-SHA256 тела запроса в hex",
+				Schema:      &openapi.Schema{Type: "string"},
+			}},
+			Request:   dto.TaskCallbackDTO{},
+			Responses: map[int]any{http.StatusOK: dto.TaskCompletionDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminUserUnlock, OperationID: "unlockUser",
+			Summary: "Снятие блокировки входа после неудачных попыток", Tag: tagAdminUsers,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminUserBalance, OperationID: "adjustUserBalance",
+			Summary: "Корректировка баланса пользователя", Tag: tagAdminUsers,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.BalanceAdjustmentDTO{},
+			Responses: map[int]any{http.StatusOK: dto.BalanceDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: admin + route.adminTasks, OperationID: "listTasks",
+			Summary: "Список заданий", Tag: tagAdminTasks,
+			Security:  openapi.SecurityBearer,
+			Query:     dto.TaskListQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AdminTasksDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminTasks, OperationID: "createTask",
+			Summary: "Создание задания", Tag: tagAdminTasks,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.TaskInputDTO{},
+			Responses: map[int]any{http.StatusCreated: dto.AdminTaskDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPut, Path: admin + route.adminTask, OperationID: "updateTask",
+			Summary: "Изменение задания", Tag: tagAdminTasks,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.TaskInputDTO{},
+			Responses: map[int]any{http.StatusOK: dto.AdminTaskDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminTaskArchive, OperationID: "archiveTask",
+			Summary: "Перенос задания в архив", Tag: tagAdminTasks,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.AdminTaskDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: admin + route.adminTaskAudit, OperationID: "getTaskAudit",
+			Summary: "Журнал изменений задания", Tag: tagAdminTasks,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: []dto.TaskAuditDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: admin + route.adminCompletions, OperationID: "listCompletions",
+			Summary: "Очередь заявок на выполнение заданий", Tag: tagAdminReview,
+			Security:  openapi.SecurityBearer,
+			Query:     dto.CompletionListQueryDTO{},
+			Responses: map[int]any{http.StatusOK: dto.TaskCompletionsDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminCompletionApprove, OperationID: "approveCompletion",
+			Summary: "Подтверждение заявки и начисление баллов", Tag: tagAdminReview,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.TaskCompletionDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminCompletionReject, OperationID: "rejectCompletion",
+			Summary: "Отклонение заявки", Tag: tagAdminReview,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.TaskCompletionDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodGet, Path: admin + route.adminOAuthClients, OperationID: "listOAuthClients",
+			Summary: "Зарегистрированные сторонние приложения OAuth2", Tag: tagAdminOAuth,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.OAuthClientsDTO{}},
+			Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodPost, Path: admin + route.adminOAuthClients, OperationID: "createOAuthClient",
+			Summary: "Регистрация стороннего приложения; секрет конфиденциального приложения возвращается только в этом ответе", Tag: tagAdminOAuth,
+			Security:  openapi.SecurityBearer,
+			Request:   dto.CreateOAuthClientDTO{},
+			Responses: map[int]any{http.StatusCreated: dto.CreatedOAuthClientDTO{}},
+			Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			Method: http.MethodDelete, Path: admin + route.adminOAuthClient, OperationID: "deleteOAuthClient",
+			Summary: "Удаление стороннего приложения вместе с согласиями пользователей и выданными токенами", Tag: tagAdminOAuth,
+			Security:  openapi.SecurityBearer,
+			Responses: map[int]any{http.StatusOK: dto.StatusDTO{}},
+			Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+	}
+}
+
+// buildOpenAPI строит спецификацию по маршрутам, зарегистрированным в роутере
+func buildOpenAPI(r *gin.Engine) ([]byte, error) {
+	registered := make([]openapi.RouteKey, 0, len(r.Routes()))
+	for _, ri := range r.Routes() {
+		registered = append(registered, openapi.RouteKey{Method: ri.Method, Path: ri.Path})
+	}
+
+	generator := &openapi.Generator{
+		Patterns: map[string]string{"username": validation.UsernamePattern},
+		Problem:  problem.Problem{},
+	}
+
+	doc, err := generator.Build(apiInfo, registered, apiDocs())
+	if err != nil {
+		return nil, err
+	}
+	return doc.JSON()
+}
+
+// OpenAPISpec строит спецификацию OpenAPI без подключения к базе данных
+func OpenAPISpec() ([]byte, error) {
+	r := gin.New()
+	(&App{}).configureApiRoutes(r)
+	return buildOpenAPI(r)
+}
+
+// configureDocsRoutes регистрирует маршруты спецификации и страницы документации.
+// Вызывается после построения спецификации, поэтому эти маршруты в нее не попадают
+func configureDocsRoutes(r *gin.Engine, spec []byte) {
+	r.GET(openAPIPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	})
+	r.GET(docsPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	})
+}
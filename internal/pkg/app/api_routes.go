@@ -1,52 +1,307 @@
 package app
 
 import (
+	"user-management/internal/delivery"
+	"user-management/internal/pkg/rbac"
+
 	"github.com/gin-gonic/gin"
 )
 
 type routeServer struct {
 	ping           string
+	jwks           string
 	register       string
 	login          string
+	loginMFA       string
 	logout         string
+	logoutAll      string
+	refreshToken   string
+	forgotPassword string
+	resetPassword  string
+	changePassword string
+	verifyEmail    string
+	email          string
+	resendEmail    string
+	mfaTOTP        string
+	mfaTOTPConfirm string
+	sessions       string
+	session        string
+	apiKeys        string
+	apiKey         string
+	identities     string
+	identity       string
+	oidcLogin      string
+	oidcCallback   string
+	oauthConsents  string
+	oauthConsent   string
+	oauthAuthorize string
+	oauthToken     string
+	oauthRevoke    string
 	getStatus      string
 	getLeaderboard string
 	taskComplete   string
 	referral       string
+	transactions   string
+	userTasks      string
+	taskCatalogue  string
+	taskCallback   string
+
+	adminUserUnlock  string
+	adminUserBalance string
+
+	adminOAuthClients string
+	adminOAuthClient  string
+
+	adminTasks       string
+	adminTask        string
+	adminTaskArchive string
+	adminTaskAudit   string
+
+	adminCompletions       string
+	adminCompletionApprove string
+	adminCompletionReject  string
 }
 
 func newRouteServer() *routeServer {
 	return &routeServer{
-		register:       "/register",          // Путь: /users/register
-		login:          "/login",             // Путь: /users/login
-		logout:         "/logout",            // Путь: /users/logout
-		getStatus:      "/:id/status",        // Путь: /users/:id/status
-		getLeaderboard: "/leaderboard",       // Путь: /users/leaderboard
-		taskComplete:   "/:id/task/complete", // Путь: /users/:id/task/complete
-		referral:       "/:id/referrer",      // Путь: /users/:id/referrer
+		jwks: "/.well-known/jwks.json", // Путь: /.well-known/jwks.json
+
+		register:       "/register",               // Путь: /api/v1/users/register
+		login:          "/login",                  // Путь: /api/v1/users/login
+		loginMFA:       "/login/mfa",              // Путь: /api/v1/users/login/mfa
+		logout:         "/logout",                 // Путь: /api/v1/users/logout
+		logoutAll:      "/logout-all",             // Путь: /api/v1/users/logout-all
+		refreshToken:   "/token/refresh",          // Путь: /api/v1/users/token/refresh
+		forgotPassword: "/password/forgot",        // Путь: /api/v1/users/password/forgot
+		resetPassword:  "/password/reset",         // Путь: /api/v1/users/password/reset
+		changePassword: "/:id/password",           // Путь: /api/v1/users/:id/password
+		verifyEmail:    "/email/verify",           // Путь: /api/v1/users/email/verify
+		email:          "/:id/email",              // Путь: /api/v1/users/:id/email
+		resendEmail:    "/:id/email/verification", // Путь: /api/v1/users/:id/email/verification
+		mfaTOTP:        "/:id/mfa/totp",           // Путь: /api/v1/users/:id/mfa/totp
+		mfaTOTPConfirm: "/:id/mfa/totp/confirm",   // Путь: /api/v1/users/:id/mfa/totp/confirm
+		sessions:       "/:id/sessions",           // Путь: /api/v1/users/:id/sessions
+		session:        "/:id/sessions/:sid",      // Путь: /api/v1/users/:id/sessions/:sid
+		apiKeys:        "/:id/api-keys",           // Путь: /api/v1/users/:id/api-keys
+		apiKey:         "/:id/api-keys/:kid",      // Путь: /api/v1/users/:id/api-keys/:kid
+		getStatus:      "/:id/status",             // Путь: /api/v1/users/:id/status
+		getLeaderboard: "/leaderboard",            // Путь: /api/v1/users/leaderboard
+		taskComplete:   "/:id/task/complete",      // Путь: /api/v1/users/:id/task/complete
+		referral:       "/:id/referrer",           // Путь: /api/v1/users/:id/referrer
+		transactions:   "/:id/transactions",       // Путь: /api/v1/users/:id/transactions
+		userTasks:      "/:id/tasks",              // Путь: /api/v1/users/:id/tasks
+		taskCatalogue:  "",                        // Путь: /api/v1/tasks
+		taskCallback:   "/completions/callback",   // Путь: /api/v1/tasks/completions/callback
+
+		identities:   "/:id/identities",           // Путь: /api/v1/users/:id/identities
+		identity:     "/:id/identities/:provider", // Путь: /api/v1/users/:id/identities/:provider
+		oidcLogin:    "/oidc/:provider/login",     // Путь: /api/v1/auth/oidc/:provider/login
+		oidcCallback: "/oidc/:provider/callback",  // Путь: /api/v1/auth/oidc/:provider/callback
+
+		oauthConsents:  "/:id/oauth/consents",         // Путь: /api/v1/users/:id/oauth/consents
+		oauthConsent:   "/:id/oauth/consents/:client", // Путь: /api/v1/users/:id/oauth/consents/:client
+		oauthAuthorize: "/authorize",                  // Путь: /oauth/authorize
+		oauthToken:     "/token",                      // Путь: /oauth/token
+		oauthRevoke:    "/revoke",                     // Путь: /oauth/revoke
+
+		adminUserUnlock:  "/users/:id/unlock",  // Путь: /api/v1/admin/users/:id/unlock
+		adminUserBalance: "/users/:id/balance", // Путь: /api/v1/admin/users/:id/balance
+
+		adminOAuthClients: "/oauth/clients",         // Путь: /api/v1/admin/oauth/clients
+		adminOAuthClient:  "/oauth/clients/:client", // Путь: /api/v1/admin/oauth/clients/:client
+
+		adminTasks:       "/tasks",             // Путь: /api/v1/admin/tasks
+		adminTask:        "/tasks/:id",         // Путь: /api/v1/admin/tasks/:id
+		adminTaskArchive: "/tasks/:id/archive", // Путь: /api/v1/admin/tasks/:id/archive
+		adminTaskAudit:   "/tasks/:id/audit",   // Путь: /api/v1/admin/tasks/:id/audit
+
+		adminCompletions:       "/completions",             // Путь: /api/v1/admin/completions
+		adminCompletionApprove: "/completions/:id/approve", // Путь: /api/v1/admin/completions/:id/approve
+		adminCompletionReject:  "/completions/:id/reject",  // Путь: /api/v1/admin/completions/:id/reject
 	}
 }
 
+// apiPrefix префикс версии API, под которым смонтированы все маршруты
+const apiPrefix = "/api/v1"
+
 func (app *App) configureApiRoutes(r *gin.Engine) {
 	route := newRouteServer()
 
-	// Группа маршрутов /users
-	users := r.Group("/users")
+	// Ошибки маршрутизации возвращаются в том же формате problem+json, что и ошибки обработчиков
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(delivery.NotFoundHandler)
+	r.NoMethod(delivery.MethodNotAllowedHandler)
+
+	// Открытые ключи проверки подписи access токенов для других сервисов
+	r.GET(route.jwks, app.jwksHandler.KeysHandler) // Путь: /.well-known/jwks.json
+
+	// Группа маршрутов /oauth: сервер авторизации OAuth2 для сторонних приложений
+	oauth := r.Group("/oauth")
+	{
+		oauth.POST(route.oauthToken, app.oauthHandler.TokenHandler)   // Путь: /oauth/token (аутентификация приложения)
+		oauth.POST(route.oauthRevoke, app.oauthHandler.RevokeHandler) // Путь: /oauth/revoke (аутентификация приложения)
+	}
+
+	// Согласие дает сам пользователь: ни API ключ, ни токен другого приложения не могут выдать доступ приложению
+	oauthUser := oauth.Group("/")
+	oauthUser.Use(app.authMiddleware.AuthMiddleware(), app.authMiddleware.RequireAccessToken())
+
+	{
+		oauthUser.GET(route.oauthAuthorize, app.oauthHandler.AuthorizeHandler) // Путь: /oauth/authorize
+		oauthUser.POST(route.oauthAuthorize, app.oauthHandler.ConsentHandler)  // Путь: /oauth/authorize
+	}
+
+	api := r.Group(apiPrefix)
+
+	// Группа маршрутов /api/v1/users
+	users := api.Group("/users")
 	{
 		// Публичные маршруты (не требуют аутентификации)
-		users.POST(route.register, app.userHandler.RegisterHandler) // Путь: /users/register
-		users.POST(route.login, app.userHandler.LoginHandler)       // Путь: /users/login
+		users.POST(route.register, app.userHandler.RegisterHandler)                 // Путь: /api/v1/users/register
+		users.POST(route.login, app.userHandler.LoginHandler)                       // Путь: /api/v1/users/login
+		users.POST(route.loginMFA, app.userHandler.LoginMFAHandler)                 // Путь: /api/v1/users/login/mfa
+		users.POST(route.refreshToken, app.userHandler.RefreshTokenHandler)         // Путь: /api/v1/users/token/refresh
+		users.POST(route.forgotPassword, app.passwordHandler.ForgotPasswordHandler) // Путь: /api/v1/users/password/forgot
+		users.POST(route.resetPassword, app.passwordHandler.ResetPasswordHandler)   // Путь: /api/v1/users/password/reset
+		users.GET(route.verifyEmail, app.emailHandler.VerifyEmailHandler)           // Путь: /api/v1/users/email/verify (ссылка из письма)
 	}
 
-	// Приватные маршруты (с защитой через middleware)
+	// Группа маршрутов /api/v1/auth: вход через внешних провайдеров OpenID Connect
+	auth := api.Group("/auth")
+	{
+		auth.GET(route.oidcLogin, app.oidcHandler.LoginHandler)       // Путь: /api/v1/auth/oidc/:provider/login (перенаправляет к провайдеру)
+		auth.GET(route.oidcCallback, app.oidcHandler.CallbackHandler) // Путь: /api/v1/auth/oidc/:provider/callback (возврат от провайдера)
+	}
+
+	// Приватные маршруты (с защитой через middleware). Каждая группа требует права, которым можно ограничить
+	// API ключ или токен стороннего приложения; у любого пользователя эти права есть
 	privateUsers := users.Group("/")
 	privateUsers.Use(app.authMiddleware.AuthMiddleware()) // Применяем middleware аутентификации
 
+	// Профиль, баланс и место в топе
+	profileRead := privateUsers.Group("/")
+	profileRead.Use(app.authMiddleware.RequirePermission(rbac.PermissionProfileRead))
+
+	{
+		profileRead.GET(route.getStatus, app.userHandler.UserStatusHandler)            // Путь: /api/v1/users/:id/status
+		profileRead.GET(route.getLeaderboard, app.userHandler.UsersLeaderboardHandler) // Путь: /api/v1/users/leaderboard
+		profileRead.GET(route.transactions, app.userHandler.PointTransactionsHandler)  // Путь: /api/v1/users/:id/transactions
+	}
+
+	// Реферальный код и подтверждение адреса электронной почты
+	profileWrite := privateUsers.Group("/")
+	profileWrite.Use(app.authMiddleware.RequirePermission(rbac.PermissionProfileWrite))
+
+	{
+		profileWrite.POST(route.referral, app.userHandler.ReferrerHandler)               // Путь: /api/v1/users/:id/referrer
+		profileWrite.POST(route.resendEmail, app.emailHandler.ResendVerificationHandler) // Путь: /api/v1/users/:id/email/verification
+	}
+
+	// Задания пользователя
+	userTasks := privateUsers.Group("/")
+	userTasks.Use(app.authMiddleware.RequirePermission(rbac.PermissionTasksRead))
+
+	{
+		userTasks.GET(route.userTasks, app.taskHandler.UserTasksHandler) // Путь: /api/v1/users/:id/tasks
+	}
+
+	taskComplete := privateUsers.Group("/")
+	taskComplete.Use(app.authMiddleware.RequirePermission(rbac.PermissionTasksComplete))
+
+	{
+		taskComplete.POST(route.taskComplete, app.userHandler.TaskCompleteHandler) // Путь: /api/v1/users/:id/task/complete
+	}
+
+	// Безопасность аккаунта: сессии, второй фактор, API ключи, привязанные учетные записи и доступ приложений
+	account := privateUsers.Group("/")
+	account.Use(app.authMiddleware.RequirePermission(rbac.PermissionAccountManage))
+
+	{
+		account.POST(route.logoutAll, app.sessionHandler.LogoutAllHandler)        // Путь: /api/v1/users/logout-all
+		account.POST(route.mfaTOTP, app.mfaHandler.EnrollTOTPHandler)             // Путь: /api/v1/users/:id/mfa/totp
+		account.POST(route.mfaTOTPConfirm, app.mfaHandler.ConfirmTOTPHandler)     // Путь: /api/v1/users/:id/mfa/totp/confirm
+		account.GET(route.sessions, app.sessionHandler.ListSessionsHandler)       // Путь: /api/v1/users/:id/sessions
+		account.DELETE(route.session, app.sessionHandler.RevokeSessionHandler)    // Путь: /api/v1/users/:id/sessions/:sid
+		account.GET(route.apiKeys, app.apiKeyHandler.ListAPIKeysHandler)          // Путь: /api/v1/users/:id/api-keys
+		account.DELETE(route.apiKey, app.apiKeyHandler.RevokeAPIKeyHandler)       // Путь: /api/v1/users/:id/api-keys/:kid
+		account.GET(route.identities, app.oidcHandler.ListIdentitiesHandler)      // Путь: /api/v1/users/:id/identities
+		account.DELETE(route.identity, app.oidcHandler.UnlinkIdentityHandler)     // Путь: /api/v1/users/:id/identities/:provider
+		account.GET(route.oauthConsents, app.oauthHandler.ListConsentsHandler)    // Путь: /api/v1/users/:id/oauth/consents
+		account.DELETE(route.oauthConsent, app.oauthHandler.RevokeConsentHandler) // Путь: /api/v1/users/:id/oauth/consents/:client
+	}
+
+	// Смена адреса электронной почты: на адрес отправляется ссылка сброса пароля, поэтому смена требует права account:manage,
+	// которое нельзя передать стороннему приложению, и, как смена пароля, доступна только по access токену
+	emailChange := account.Group("/")
+	emailChange.Use(app.authMiddleware.RequireAccessToken())
+
+	{
+		emailChange.PUT(route.email, app.emailHandler.ChangeEmailHandler) // Путь: /api/v1/users/:id/email
+	}
+
+	// Маршруты, недоступные по API ключу и токену приложения: утекший ключ не должен позволять выпускать новые ключи,
+	// привязывать чужие учетные записи и менять пароль
+	accessTokenUsers := privateUsers.Group("/")
+	accessTokenUsers.Use(app.authMiddleware.RequireAccessToken())
+
+	{
+		accessTokenUsers.POST(route.logout, app.userHandler.LogoutHandler)                     // Путь: /api/v1/users/logout
+		accessTokenUsers.POST(route.apiKeys, app.apiKeyHandler.CreateAPIKeyHandler)            // Путь: /api/v1/users/:id/api-keys
+		accessTokenUsers.POST(route.identity, app.oidcHandler.LinkIdentityHandler)             // Путь: /api/v1/users/:id/identities/:provider
+		accessTokenUsers.POST(route.changePassword, app.passwordHandler.ChangePasswordHandler) // Путь: /api/v1/users/:id/password
+	}
+
+	// Группа маршрутов /api/v1/tasks (аутентификация необязательна)
+	tasks := api.Group("/tasks")
+	tasks.Use(app.authMiddleware.OptionalAuthMiddleware())
+
+	{
+		tasks.GET(route.taskCatalogue, app.taskHandler.TaskCatalogueHandler)  // Путь: /api/v1/tasks
+		tasks.POST(route.taskCallback, app.completionHandler.CallbackHandler) // Путь: /api/v1/tasks/completions/callback (проверяется подпись тела запроса)
+	}
+
+	// Группа административных маршрутов /api/v1/admin
+	admin := api.Group("/admin")
+	admin.Use(app.authMiddleware.AuthMiddleware(), app.authMiddleware.RequireRole(rbac.RoleAdmin)) // Доступ только для администраторов
+
+	// Управление пользователями
+	adminUsers := admin.Group("/")
+	adminUsers.Use(app.authMiddleware.RequirePermission(rbac.PermissionUsersManage))
+
+	{
+		adminUsers.POST(route.adminUserUnlock, app.adminUserHandler.UnlockUserHandler)     // Путь: /api/v1/admin/users/:id/unlock
+		adminUsers.POST(route.adminUserBalance, app.adminUserHandler.AdjustBalanceHandler) // Путь: /api/v1/admin/users/:id/balance
+	}
+
+	// Регистрация сторонних приложений OAuth2
+	adminOAuth := admin.Group("/")
+	adminOAuth.Use(app.authMiddleware.RequirePermission(rbac.PermissionClientsManage))
+
+	{
+		adminOAuth.GET(route.adminOAuthClients, app.oauthHandler.ListClientsHandler)    // Путь: /api/v1/admin/oauth/clients
+		adminOAuth.POST(route.adminOAuthClients, app.oauthHandler.CreateClientHandler)  // Путь: /api/v1/admin/oauth/clients
+		adminOAuth.DELETE(route.adminOAuthClient, app.oauthHandler.DeleteClientHandler) // Путь: /api/v1/admin/oauth/clients/:client
+	}
+
+	// Управление заданиями
+	adminTasks := admin.Group("/")
+	adminTasks.Use(app.authMiddleware.RequirePermission(rbac.PermissionTasksManage))
+
+	{
+		adminTasks.GET(route.adminTasks, app.taskHandler.ListTasksHandler)          // Путь: /api/v1/admin/tasks
+		adminTasks.POST(route.adminTasks, app.taskHandler.CreateTaskHandler)        // Путь: /api/v1/admin/tasks
+		adminTasks.PUT(route.adminTask, app.taskHandler.UpdateTaskHandler)          // Путь: /api/v1/admin/tasks/:id
+		adminTasks.POST(route.adminTaskArchive, app.taskHandler.ArchiveTaskHandler) // Путь: /api/v1/admin/tasks/:id/archive
+		adminTasks.GET(route.adminTaskAudit, app.taskHandler.TaskAuditHandler)      // Путь: /api/v1/admin/tasks/:id/audit
+	}
+
+	// Проверка заявок на выполнение заданий
+	adminCompletions := admin.Group("/")
+	adminCompletions.Use(app.authMiddleware.RequirePermission(rbac.PermissionTasksReview))
+
 	{
-		privateUsers.GET(route.getStatus, app.userHandler.UserStatusHandler)            // Путь: /users/:id/status
-		privateUsers.GET(route.getLeaderboard, app.userHandler.UsersLeaderboardHandler) // Путь: /users/leaderboard
-		privateUsers.POST(route.taskComplete, app.userHandler.TaskCompleteHandler)      // Путь: /users/:id/task/complete
-		privateUsers.POST(route.referral, app.userHandler.ReferrerHandler)              // Путь: /users/:id/referrer
-		privateUsers.POST(route.logout, app.userHandler.LogoutHandler)                  // Путь: /users/logout
+		adminCompletions.GET(route.adminCompletions, app.completionHandler.ListCompletionsHandler)          // Путь: /api/v1/admin/completions
+		adminCompletions.POST(route.adminCompletionApprove, app.completionHandler.ApproveCompletionHandler) // Путь: /api/v1/admin/completions/:id/approve
+		adminCompletions.POST(route.adminCompletionReject, app.completionHandler.RejectCompletionHandler)   // Путь: /api/v1/admin/completions/:id/reject
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,24 +16,49 @@ import (
 	"user-management/internal/database"
 	"user-management/internal/delivery"
 	"user-management/internal/middleware"
+	"user-management/internal/pkg/jwtkeys"
 	"user-management/internal/pkg/logger"
+	"user-management/internal/pkg/mailer"
+	"user-management/internal/pkg/metrics"
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/pkg/passhash"
+	"user-management/internal/pkg/pwned"
+	"user-management/internal/pkg/revocation"
 	_ "user-management/internal/pkg/validation"
 	"user-management/internal/repository"
 	"user-management/internal/service"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// metricsPath адрес метрик приложения (не входит в спецификацию API)
+const metricsPath = "/debug/vars"
+
 type App struct {
-	dbConn         *pgx.Conn
-	config         *config.Config
-	logger         *slog.Logger
-	apiServer      *http.Server
-	userService    service.UserService
-	userHandler    delivery.UserHandler
-	tokenService   service.TokenService
-	authMiddleware *middleware.AuthMiddleware
+	dbPool            *pgxpool.Pool
+	config            *config.Config
+	logger            *slog.Logger
+	apiServer         *http.Server
+	metricsServer     *http.Server
+	userService       service.UserService
+	userHandler       delivery.UserHandler
+	taskHandler       delivery.TaskHandler
+	completionHandler delivery.CompletionHandler
+	passwordHandler   delivery.PasswordHandler
+	emailHandler      delivery.EmailHandler
+	mfaHandler        delivery.MFAHandler
+	sessionHandler    delivery.SessionHandler
+	apiKeyHandler     delivery.APIKeyHandler
+	oidcHandler       delivery.OIDCHandler
+	oauthHandler      delivery.OAuthHandler
+	adminUserHandler  delivery.AdminUserHandler
+	jwksHandler       delivery.JWKSHandler
+	jwtKeys           jwtkeys.KeySet
+	tokenJanitor      service.TokenJanitor
+	revocations       revocation.Bus
+	tokenService      service.TokenService
+	authMiddleware    *middleware.AuthMiddleware
 }
 
 func New() (*App, error) {
@@ -43,40 +70,170 @@ func New() (*App, error) {
 	// Инициализируем логгер
 	logger := logger.InitLogger(slog.LevelDebug)
 
-	// Подключаемся к базе данных
-	dbConn, err := database.NewDBConnection(&config.DatabaseConfig)
+	// Создаем пул соединений к базе данных
+	dbPool, err := database.NewDBPool(&config.DatabaseConfig)
 	if err != nil {
 		logger.Error("Failed to connect to the database", "error", err)
 		return nil, fmt.Errorf("database connection error: %w", err)
 	}
 
 	// Инициализация репозитория
-	userRepo := repository.NewUserRepository(dbConn, logger)
-	tokenRepo := repository.NewTokenRepo(dbConn, logger)
+	userRepo := repository.NewUserRepository(dbPool, logger)
+	tokenRepo := repository.NewTokenRepo(dbPool, logger)
+	ledgerRepo := repository.NewLedgerRepo(dbPool, logger)
+	taskRepo := repository.NewTaskRepo(dbPool, logger)
+	roleRepo := repository.NewRoleRepo(dbPool, logger)
+	passwordResetRepo := repository.NewPasswordResetRepo(dbPool, logger)
+	mfaRepo := repository.NewMFARepo(dbPool, logger)
+	loginAttemptRepo := repository.NewLoginAttemptRepo(dbPool, logger)
+	sessionRepo := repository.NewSessionRepo(dbPool, logger)
+	apiKeyRepo := repository.NewAPIKeyRepo(dbPool, logger)
+	identityRepo := repository.NewIdentityRepo(dbPool, logger)
+	oauthRepo := repository.NewOAuthRepo(dbPool, logger)
+
+	// Инициализация отправки писем
+	mail, err := mailer.New(&config.MailConfig)
+	if err != nil {
+		logger.Error("Failed to initialize mailer", "error", err)
+		return nil, fmt.Errorf("mailer error: %w", err)
+	}
+
+	// Политика подтверждения адреса электронной почты
+	emailVerification, err := service.ParseEmailVerificationPolicy(config.ApiServerConfig.EmailVerificationPolicy)
+	if err != nil {
+		logger.Error("Invalid email verification policy", "error", err)
+		return nil, fmt.Errorf("config error: %w", err)
+	}
+	emailPolicy := service.EmailPolicy{Required: config.ApiServerConfig.EmailRequired, Verification: emailVerification}
+
+	// Ключи подписи access токенов
+	jwtKeys, err := jwtkeys.New(&config.ApiServerConfig, logger)
+	if err != nil {
+		logger.Error("Failed to load jwt keys", "error", err)
+		return nil, fmt.Errorf("jwt keys error: %w", err)
+	}
+
+	// Хэширование паролей
+	passwordHasher, err := passhash.New(&config.ApiServerConfig)
+	if err != nil {
+		logger.Error("Invalid password hashing configuration", "error", err)
+		return nil, fmt.Errorf("config error: %w", err)
+	}
+
+	// Политика паролей с необязательной проверкой по утечкам
+	var breaches pwned.RangeSource
+	if dir := config.ApiServerConfig.PasswordBreachDir; dir != "" {
+		dirSource, err := pwned.NewDirSource(dir)
+		if err != nil {
+			logger.Error("Failed to open breached passwords", "error", err)
+			return nil, fmt.Errorf("config error: %w", err)
+		}
+		breaches = dirSource
+	}
+	passwordPolicy, err := service.NewPasswordPolicy(&config.ApiServerConfig, breaches, logger)
+	if err != nil {
+		logger.Error("Invalid password policy", "error", err)
+		return nil, fmt.Errorf("config error: %w", err)
+	}
+
+	// Доставка событий отзыва токенов между экземплярами сервиса
+	revocations, err := revocation.New(&config.ApiServerConfig, dbPool, logger)
+	if err != nil {
+		logger.Error("Failed to initialize token revocation backend", "error", err)
+		return nil, fmt.Errorf("token revocation error: %w", err)
+	}
+
+	// Внешние провайдеры OpenID Connect для входа через сторонние учетные записи
+	var oidcProviders []*oidc.Provider
+	if path := config.ApiServerConfig.OIDCProvidersFile; path != "" {
+		providerConfigs, err := oidc.LoadProviders(path)
+		if err != nil {
+			logger.Error("Failed to load oidc providers", "error", err)
+			return nil, fmt.Errorf("oidc providers error: %w", err)
+		}
+		for _, providerConfig := range providerConfigs {
+			redirectURL := strings.ReplaceAll(config.ApiServerConfig.OIDCRedirectURL, "{provider}", providerConfig.Name)
+			oidcProviders = append(oidcProviders, oidc.NewProvider(providerConfig, redirectURL))
+		}
+		logger.Info("OIDC providers loaded", "count", len(oidcProviders))
+	}
 
 	// Инициализация сервисного слоя
-	userService := service.NewUserService(userRepo, logger)
-	tokenService := service.NewTokenService(tokenRepo, config.ApiServerConfig.AuthSecretKey, logger)
+	callbackVerifier := service.NewCallbackVerifier(config.ApiServerConfig.TaskCallbackSecret)
+	loginThrottle := service.NewLoginThrottle(loginAttemptRepo, userRepo, &config.ApiServerConfig, logger)
+	userService := service.NewUserService(userRepo, passwordHasher, passwordPolicy, service.NewTaskVerifiers(callbackVerifier), emailPolicy, loginThrottle, logger)
+	tokenService := service.NewTokenService(tokenRepo, roleRepo, sessionRepo, jwtKeys, revocations, &config.ApiServerConfig, logger)
+	ledgerService := service.NewLedgerService(ledgerRepo, logger)
+	taskService := service.NewTaskService(taskRepo, logger)
+	completionService := service.NewCompletionService(userRepo, callbackVerifier, logger)
+	emailService := service.NewEmailService(userRepo, passwordHasher, loginThrottle, mail, &config.ApiServerConfig, logger)
+	passwordService := service.NewPasswordService(userRepo, passwordResetRepo, tokenRepo, sessionRepo, passwordHasher, passwordPolicy, loginThrottle, mail, revocations, &config.ApiServerConfig, logger)
+	mfaService := service.NewMFAService(mfaRepo, userRepo, loginThrottle, &config.ApiServerConfig, logger)
+	sessionService := service.NewSessionService(sessionRepo, userRepo, revocations, logger)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, roleRepo, logger)
+	oidcService := service.NewOIDCService(oidcProviders, identityRepo, userRepo, emailPolicy, &config.ApiServerConfig, logger)
+	oauthService := service.NewOAuthService(oauthRepo, tokenRepo, userRepo, roleRepo, tokenService, revocations, &config.ApiServerConfig, logger)
+	tokenJanitor := service.NewTokenJanitor(tokenRepo, sessionRepo, identityRepo, oauthRepo, &config.ApiServerConfig, logger)
 
 	// Инициализация обработчиков
-	userHandler := delivery.NewUserHandler(userService, tokenService, config, logger)
+	userHandler := delivery.NewUserHandler(userService, tokenService, ledgerService, emailService, mfaService, sessionService, config, logger)
+	taskHandler := delivery.NewTaskHandler(taskService, logger)
+	completionHandler := delivery.NewCompletionHandler(completionService, logger)
+	passwordHandler := delivery.NewPasswordHandler(passwordService, logger)
+	emailHandler := delivery.NewEmailHandler(emailService, logger)
+	mfaHandler := delivery.NewMFAHandler(mfaService, logger)
+	sessionHandler := delivery.NewSessionHandler(sessionService, logger)
+	apiKeyHandler := delivery.NewAPIKeyHandler(apiKeyService, logger)
+	oidcHandler := delivery.NewOIDCHandler(oidcService, tokenService, mfaService, logger)
+	oauthHandler := delivery.NewOAuthHandler(oauthService, logger)
+	adminUserHandler := delivery.NewAdminUserHandler(loginThrottle, userService, logger)
+	jwksHandler := delivery.NewJWKSHandler(jwtKeys, logger)
 
 	// Инициализация middleware
-	authMiddleware := middleware.NewAuthMiddleware(tokenService, logger)
+	authMiddleware := middleware.NewAuthMiddleware(tokenService, apiKeyService, logger)
 
 	// Собираем приложение
 	app.config = config
 	app.logger = logger
-	app.dbConn = dbConn
+	app.dbPool = dbPool
 	app.userService = userService
 	app.userHandler = userHandler
+	app.taskHandler = taskHandler
+	app.completionHandler = completionHandler
+	app.passwordHandler = passwordHandler
+	app.emailHandler = emailHandler
+	app.mfaHandler = mfaHandler
+	app.sessionHandler = sessionHandler
+	app.apiKeyHandler = apiKeyHandler
+	app.oidcHandler = oidcHandler
+	app.oauthHandler = oauthHandler
+	app.adminUserHandler = adminUserHandler
+	app.jwksHandler = jwksHandler
+	app.jwtKeys = jwtKeys
+	app.tokenJanitor = tokenJanitor
+	app.revocations = revocations
 	app.tokenService = tokenService
 	app.authMiddleware = authMiddleware
 
 	// Настраиваем API
-	apiRouter := gin.Default()
+	apiRouter := gin.New()
+	// IP адрес клиента используется в ограничении попыток входа и в сессиях, поэтому X-Forwarded-For принимается
+	// только от заданных прокси: иначе клиент подставит любой адрес и обойдет ограничение по IP
+	if err := apiRouter.SetTrustedProxies(config.ApiServerConfig.TrustedProxies); err != nil {
+		logger.Error("Invalid trusted proxies", "error", err)
+		return nil, fmt.Errorf("trusted proxies error: %w", err)
+	}
+	apiRouter.Use(gin.Logger(), gin.CustomRecovery(delivery.RecoveryHandler))
 	app.configureApiRoutes(apiRouter)
 
+	// Строим спецификацию OpenAPI по зарегистрированным маршрутам
+	spec, err := buildOpenAPI(apiRouter)
+	if err != nil {
+		logger.Error("Failed to build OpenAPI spec", "error", err)
+		return nil, fmt.Errorf("openapi spec error: %w", err)
+	}
+	configureDocsRoutes(apiRouter, spec)
+
 	// Формируем адрес для сервера из конфигурации
 	apiAddress := fmt.Sprintf("%s:%s", app.config.ApiServerConfig.Host, app.config.ApiServerConfig.Port)
 
@@ -86,6 +243,17 @@ func New() (*App, error) {
 		Handler: apiRouter,
 	}
 
+	// Метрики раскрывают внутреннее состояние процесса, поэтому отдаются не на адресе API, а отдельным сервером,
+	// который следует слушать только во внутренней сети
+	if config.ApiServerConfig.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle(metricsPath, metrics.Handler())
+		app.metricsServer = &http.Server{
+			Addr:    config.ApiServerConfig.MetricsAddr,
+			Handler: metricsMux,
+		}
+	}
+
 	logger.Info("Application initialized successfully")
 	return app, nil
 }
@@ -95,6 +263,19 @@ func (app *App) Run() error {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
+	// Фоновые задачи (ротация ключей подписи, очистка устаревших токенов, прием событий отзыва) работают до завершения работы
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	var background sync.WaitGroup
+	for _, run := range []func(context.Context){app.jwtKeys.Run, app.tokenJanitor.Run, app.revocations.Run} {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(backgroundCtx)
+		}()
+	}
+
 	// Запуск HTTP-сервера
 	go func() {
 		app.logger.Info("API server started successfully:", "address", app.apiServer.Addr)
@@ -102,6 +283,14 @@ func (app *App) Run() error {
 			app.logger.Error("Failed to start the server", "error", err)
 		}
 	}()
+	if app.metricsServer != nil {
+		go func() {
+			app.logger.Info("Metrics server started successfully:", "address", app.metricsServer.Addr)
+			if err := app.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				app.logger.Error("Failed to start the metrics server", "error", err)
+			}
+		}()
+	}
 
 	// Ожидаем сигнал завершения
 	sig := <-stop
@@ -116,21 +305,28 @@ func (app *App) Run() error {
 		app.logger.Error("HTTP server shutdown failed", "error", err)
 		return err
 	}
+	if app.metricsServer != nil {
+		if err := app.metricsServer.Shutdown(ctx); err != nil {
+			app.logger.Error("Metrics server shutdown failed", "error", err)
+		}
+	}
 
-	// Закрываем соединение с базой данных
+	// Дожидаемся остановки фоновых задач, чтобы они не обращались к закрытому пулу соединений
+	stopBackground()
+	background.Wait()
+
+	// Закрываем пул соединений с базой данных
 	app.Close()
 
 	app.logger.Info("Application stopped gracefully")
 	return nil
 }
 
-// Close закрывает соединение с базой данных
+// Close закрывает пул соединений с базой данных
 func (app *App) Close() {
-	if app.dbConn != nil {
-		if err := app.dbConn.Close(context.Background()); err != nil {
-			app.logger.Error("Failed to close database connection", "error", err)
-		} else {
-			app.logger.Info("Database connection closed successfully")
-		}
+	if app.dbPool != nil {
+		app.dbPool.Close()
+		app.dbPool = nil
+		app.logger.Info("Database connection pool closed successfully")
 	}
 }
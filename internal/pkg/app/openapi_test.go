@@ -0,0 +1,31 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// specPath сохраненная спецификация относительно каталога пакета
+const specPath = "../../../api/openapi.json"
+
+// TestOpenAPISpecUpToDate падает, если маршрут или DTO изменены без обновления api/openapi.json
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	spec, err := OpenAPISpec()
+	if err != nil {
+		t.Fatalf("OpenAPISpec: %v", err)
+	}
+
+	stored, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatalf("read %s: %v", specPath, err)
+	}
+
+	if !bytes.Equal(stored, spec) {
+		t.Fatalf("api/openapi.json is out of date, regenerate it with: go run ./cmd/openapi -o api/openapi.json")
+	}
+}
@@ -0,0 +1,394 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"user-management/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Параметры ротации ключей
+const (
+	// PublishAhead за сколько до начала подписи новый ключ публикуется в JWKS, чтобы сервисы,
+	// кэширующие набор ключей, узнали о нем раньше, чем получат подписанный им токен
+	PublishAhead = 5 * time.Minute
+	// reloadInterval как часто перечитывается каталог ключей, чтобы подхватить ключи других экземпляров
+	reloadInterval = time.Minute
+	// rsaKeyBits размер генерируемых ключей RSA
+	rsaKeyBits = 2048
+	// keyFileExt расширение файлов ключей в каталоге
+	keyFileExt = ".pem"
+	// createdHeader заголовок PEM с временем создания ключа
+	createdHeader = "Created"
+)
+
+// signingKey закрытый ключ из каталога
+type signingKey struct {
+	id        string
+	path      string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	jwk       JWK
+	createdAt time.Time
+}
+
+// FileKeySet набор ключей RS256 и EdDSA из каталога. Жизненный цикл ключа:
+// создан и опубликован → подписывает токены через PublishAhead → выведен из подписи, когда
+// подписывать начал более новый ключ → еще retention проверяет выданные им токены → удален.
+// Каталог может быть общим для нескольких экземпляров сервиса: идентификатор ключа (kid)
+// вычисляется по открытому ключу, поэтому все экземпляры выбирают одинаковый ключ подписи
+type FileKeySet struct {
+	dir       string
+	algorithm string
+	rotation  time.Duration
+	retention time.Duration
+	logger    *slog.Logger
+
+	mu     sync.RWMutex
+	signer *signingKey
+	keys   map[string]*signingKey // Ключи проверки подписи по kid
+	jwks   JWKS
+}
+
+// NewFileKeySet загружает ключи из каталога. Если подходящих ключей нет, создается первый ключ
+func NewFileKeySet(cfg *config.ApiServer, logger *slog.Logger) (*FileKeySet, error) {
+	if cfg.JWTAlgorithm != AlgorithmRS256 && cfg.JWTAlgorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported jwt algorithm: %s", cfg.JWTAlgorithm)
+	}
+
+	s := &FileKeySet{
+		dir:       cfg.JWTKeysDir,
+		algorithm: cfg.JWTAlgorithm,
+		rotation:  cfg.JWTKeyRotation,
+		// Выведенный из подписи ключ должен проверять токены как минимум до истечения их срока действия
+		retention: max(cfg.JWTKeyRetention, cfg.AccessTokenTTL),
+		logger:    logger,
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create jwt keys directory: %w", err)
+	}
+	if err := s.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.rotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Sign подписывает claims действующим ключом и указывает его kid в заголовке токена
+func (s *FileKeySet) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	signer := s.signer
+	s.mu.RUnlock()
+
+	token := jwt.NewWithClaims(signer.method, claims)
+	token.Header["kid"] = signer.id
+	return token.SignedString(signer.private)
+}
+
+// Keyfunc находит ключ по kid из заголовка токена. Алгоритм токена должен совпадать с алгоритмом ключа,
+// поэтому токены HS256, подписанные до перехода на асимметричные ключи, отклоняются
+func (s *FileKeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedAlgorithm, token.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
+
+// JWKS возвращает открытые ключи: действующий, опубликованные заранее и выведенные из подписи, но еще не удаленные
+func (s *FileKeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jwks
+}
+
+// Run периодически создает новый ключ по расписанию ротации и перечитывает каталог
+func (s *FileKeySet) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := s.reload(now); err != nil {
+				s.logger.Error("Failed to reload jwt keys", "dir", s.dir, "error", err)
+				continue
+			}
+			if err := s.rotateIfDue(now); err != nil {
+				s.logger.Error("Failed to rotate jwt key", "dir", s.dir, "error", err)
+			}
+		}
+	}
+}
+
+// rotateIfDue создает новый ключ, если каталог пуст или самый новый ключ старше периода ротации.
+// Нулевой период отключает ротацию
+func (s *FileKeySet) rotateIfDue(now time.Time) error {
+	s.mu.RLock()
+	newest := s.newestCreatedAt()
+	empty := s.signer == nil
+	s.mu.RUnlock()
+
+	if !empty && (s.rotation <= 0 || now.Before(newest.Add(s.rotation))) {
+		return nil
+	}
+
+	kid, err := s.generate(now)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("JWT signing key generated", "kid", kid, "algorithm", s.algorithm, "active_from", now.Add(PublishAhead))
+
+	return s.reload(now)
+}
+
+// newestCreatedAt возвращает время создания самого нового ключа. Вызывается под блокировкой
+func (s *FileKeySet) newestCreatedAt() time.Time {
+	var newest time.Time
+	for _, key := range s.keys {
+		if key.createdAt.After(newest) {
+			newest = key.createdAt
+		}
+	}
+	return newest
+}
+
+// reload перечитывает каталог, выбирает ключ подписи и удаляет ключи, срок проверки которых истек
+func (s *FileKeySet) reload(now time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read jwt keys directory: %w", err)
+	}
+
+	var loaded []*signingKey
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		key, err := loadKey(path)
+		if err != nil {
+			// Поврежденный файл не должен мешать работе с остальными ключами
+			s.logger.Error("Failed to load jwt key", "file", path, "error", err)
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+	if len(loaded) == 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.signer != nil {
+			return fmt.Errorf("no jwt keys found in %s", s.dir)
+		}
+		s.keys = map[string]*signingKey{}
+		s.jwks = JWKS{Keys: []JWK{}}
+		return nil
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		if !loaded[i].createdAt.Equal(loaded[j].createdAt) {
+			return loaded[i].createdAt.Before(loaded[j].createdAt)
+		}
+		return loaded[i].id < loaded[j].id
+	})
+
+	// Подписывает самый новый ключ, опубликованный заранее; если таких нет — самый старый
+	active := 0
+	for i, key := range loaded {
+		if !now.Before(key.createdAt.Add(PublishAhead)) {
+			active = i
+		}
+	}
+
+	keys := make(map[string]*signingKey, len(loaded))
+	jwks := JWKS{Keys: make([]JWK, 0, len(loaded))}
+	for i := len(loaded) - 1; i >= 0; i-- {
+		key := loaded[i]
+		// Ключ выведен из подписи, когда подписывать начал следующий за ним
+		if i < active && now.After(loaded[i+1].createdAt.Add(PublishAhead).Add(s.retention)) {
+			s.removeKey(key)
+			continue
+		}
+		keys[key.id] = key
+		jwks.Keys = append(jwks.Keys, key.jwk)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.signer == nil || s.signer.id != loaded[active].id {
+		s.logger.Info("JWT signing key activated", "kid", loaded[active].id, "algorithm", loaded[active].method.Alg())
+	}
+	s.signer = loaded[active]
+	s.keys = keys
+	s.jwks = jwks
+	return nil
+}
+
+// removeKey удаляет файл ключа, срок проверки которого истек. Файл мог уже удалить другой экземпляр
+func (s *FileKeySet) removeKey(key *signingKey) {
+	if err := os.Remove(key.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Warn("Failed to remove expired jwt key", "kid", key.id, "error", err)
+		return
+	}
+	s.logger.Info("Expired jwt key removed", "kid", key.id)
+}
+
+// generate создает ключ и атомарно записывает его в каталог под именем <kid>.pem
+func (s *FileKeySet) generate(now time.Time) (string, error) {
+	var private crypto.Signer
+	var err error
+	switch s.algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate jwt key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal jwt key: %w", err)
+	}
+	key, err := newSigningKey(private, now)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".key-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create jwt key file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: now.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	}
+	if err = pem.Encode(tmp, block); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write jwt key file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write jwt key file: %w", err)
+	}
+	key.path = filepath.Join(s.dir, key.id+keyFileExt)
+	if err = os.Rename(tmp.Name(), key.path); err != nil {
+		return "", fmt.Errorf("failed to save jwt key file: %w", err)
+	}
+
+	return key.id, nil
+}
+
+// loadKey читает закрытый ключ RSA или Ed25519 в PEM (PKCS#8 или PKCS#1). Время создания берется
+// из заголовка Created, а для ключей, созданных вручную, — из времени изменения файла
+func loadKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported pem block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[createdHeader])
+	if err != nil {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, statErr
+		}
+		createdAt = info.ModTime()
+	}
+
+	key, err := newSigningKey(private, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	key.path = path
+	return key, nil
+}
+
+// newSigningKey определяет алгоритм по типу ключа и вычисляет kid по открытому ключу (RFC 7638)
+func newSigningKey(private crypto.Signer, createdAt time.Time) (*signingKey, error) {
+	var method jwt.SigningMethod
+	var jwk JWK
+	switch public := private.Public().(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("rsa key is too short: %d bits", public.N.BitLen())
+		}
+		method = jwt.SigningMethodRS256
+		jwk = JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		jwk = JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public)}
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", public)
+	}
+
+	kid, err := jwk.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	jwk.KeyID = kid
+	jwk.Use = "sig"
+	jwk.Algorithm = method.Alg()
+
+	return &signingKey{id: kid, method: method, private: private, jwk: jwk, createdAt: createdAt}, nil
+}
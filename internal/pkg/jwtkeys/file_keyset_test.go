@@ -0,0 +1,215 @@
+package jwtkeys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testRotation  = time.Hour
+	testRetention = 30 * time.Minute
+)
+
+func newTestFileKeySet(t *testing.T, dir, algorithm string) *FileKeySet {
+	t.Helper()
+
+	cfg := &config.ApiServer{
+		JWTKeysDir:      dir,
+		JWTAlgorithm:    algorithm,
+		JWTKeyRotation:  testRotation,
+		JWTKeyRetention: testRetention,
+		AccessTokenTTL:  15 * time.Minute,
+	}
+	keys, err := NewFileKeySet(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewFileKeySet: %v", err)
+	}
+	return keys
+}
+
+// sign подписывает тестовые claims и возвращает токен и kid из его заголовка
+func sign(t *testing.T, keys *FileKeySet) (string, string) {
+	t.Helper()
+
+	signed, err := keys.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return signed, kid
+}
+
+func verify(keys *FileKeySet, signed string) error {
+	_, err := jwt.Parse(signed, keys.Keyfunc)
+	return err
+}
+
+func TestFileKeySetRotation(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			keys := newTestFileKeySet(t, dir, algorithm)
+			start := keys.signer.createdAt
+
+			oldToken, oldKid := sign(t, keys)
+			if err := verify(keys, oldToken); err != nil {
+				t.Fatalf("verify token of the first key: %v", err)
+			}
+
+			// Новый ключ сначала только публикуется, подписывает прежний
+			rotatedAt := start.Add(testRotation)
+			if err := keys.rotateIfDue(rotatedAt); err != nil {
+				t.Fatalf("rotateIfDue: %v", err)
+			}
+			if _, kid := sign(t, keys); kid != oldKid {
+				t.Fatalf("signing kid right after rotation = %s, want %s", kid, oldKid)
+			}
+			if got := len(keys.JWKS().Keys); got != 2 {
+				t.Fatalf("published keys after rotation = %d, want 2", got)
+			}
+
+			// Через PublishAhead подписывает новый ключ, прежний еще проверяет выданные им токены
+			if err := keys.reload(rotatedAt.Add(PublishAhead)); err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			newToken, newKid := sign(t, keys)
+			if newKid == oldKid {
+				t.Fatalf("signing kid after PublishAhead = %s, want the new key", newKid)
+			}
+			for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+				if err := verify(keys, token); err != nil {
+					t.Errorf("verify %s token during retention: %v", name, err)
+				}
+			}
+
+			// По истечении retention прежний ключ удаляется из набора и каталога
+			if err := keys.reload(rotatedAt.Add(PublishAhead).Add(testRetention).Add(time.Second)); err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if err := verify(keys, oldToken); !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("verify old token after retention = %v, want %v", err, ErrUnknownKey)
+			}
+			if err := verify(keys, newToken); err != nil {
+				t.Errorf("verify new token after retention: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, oldKid+keyFileExt)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("old key file stat = %v, want removed", err)
+			}
+			if jwks := keys.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != newKid {
+				t.Errorf("published keys after retention = %+v, want only %s", jwks.Keys, newKid)
+			}
+		})
+	}
+}
+
+func TestFileKeySetSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestFileKeySet(t, dir, AlgorithmEdDSA)
+	second := newTestFileKeySet(t, dir, AlgorithmEdDSA)
+
+	// Второй экземпляр не создает свой ключ, а подписывает тем же, и токены принимаются обоими
+	signed, kid := sign(t, first)
+	if _, secondKid := sign(t, second); secondKid != kid {
+		t.Errorf("second instance signing kid = %s, want %s", secondKid, kid)
+	}
+	if err := verify(second, signed); err != nil {
+		t.Errorf("verify on the second instance: %v", err)
+	}
+}
+
+func TestFileKeySetKeyfuncRejectsAlgorithmMismatch(t *testing.T) {
+	keys := newTestFileKeySet(t, t.TempDir(), AlgorithmEdDSA)
+	_, kid := sign(t, keys)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		key     any
+		kid     string
+		wantErr error
+	}{
+		// Токен HS256 с kid асимметричного ключа: открытый ключ нельзя использовать как секрет HMAC
+		{name: "hs256 with ed25519 kid", method: jwt.SigningMethodHS256, key: []byte("secret"), kid: kid, wantErr: ErrUnexpectedAlgorithm},
+		{name: "rs256 with ed25519 kid", method: jwt.SigningMethodRS256, key: rsaKey, kid: kid, wantErr: ErrUnexpectedAlgorithm},
+		{name: "unknown kid", method: jwt.SigningMethodRS256, key: rsaKey, kid: "unknown", wantErr: ErrUnknownKey},
+		{name: "no kid", method: jwt.SigningMethodHS256, key: []byte("secret"), wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, jwt.RegisteredClaims{Subject: "1"})
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			signed, err := token.SignedString(tt.key)
+			if err != nil {
+				t.Fatalf("SignedString: %v", err)
+			}
+
+			if err = verify(keys, signed); !errors.Is(err, tt.wantErr) {
+				t.Errorf("verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638, раздел 3.1
+			name: "rsa",
+			jwk: JWK{
+				KeyType: "RSA",
+				N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn" +
+					"64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91Cb" +
+					"OpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E: "AQAB",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037, приложение A.3
+			name: "ed25519",
+			jwk:  JWK{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Необязательные поля не входят в отпечаток
+			tt.jwk.KeyID, tt.jwk.Use, tt.jwk.Algorithm = "ignored", "sig", "ignored"
+			got, err := tt.jwk.thumbprint()
+			if err != nil {
+				t.Fatalf("thumbprint: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("thumbprint = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Алгоритмы подписи для новых ключей
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Ошибки проверки подписи токена
+var (
+	ErrUnknownKey          = errors.New("unknown signing key")
+	ErrUnexpectedAlgorithm = errors.New("unexpected signing algorithm")
+)
+
+// KeySet подписывает access токены и предоставляет ключи для проверки их подписи
+type KeySet interface {
+	// Sign подписывает claims действующим ключом
+	Sign(claims jwt.Claims) (string, error)
+	// Keyfunc возвращает ключ проверки подписи токена для jwt.Parse
+	Keyfunc(token *jwt.Token) (any, error)
+	// JWKS возвращает открытые ключи проверки подписи для публикации
+	JWKS() JWKS
+	// Run периодически перечитывает каталог ключей и выполняет ротацию до отмены контекста
+	Run(ctx context.Context)
+}
+
+// JWK открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"` // Кривая ключа OKP
+	X         string `json:"x,omitempty"`   // Открытый ключ OKP
+	N         string `json:"n,omitempty"`   // Модуль ключа RSA
+	E         string `json:"e,omitempty"`   // Открытая экспонента ключа RSA
+}
+
+// JWKS набор открытых ключей, публикуемый по адресу /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// thumbprint вычисляет идентификатор ключа по RFC 7638: SHA-256 от обязательных полей JWK в лексикографическом порядке
+func (k JWK) thumbprint() (string, error) {
+	var members any
+	switch k.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.KeyType, k.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Curve, k.KeyType, k.X}
+	default:
+		return "", fmt.Errorf("unsupported key type: %s", k.KeyType)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// New создает набор ключей по конфигурации. Если каталог ключей не задан, токены подписываются
+// общим секретом HS256, как до появления асимметричных ключей
+func New(cfg *config.ApiServer, logger *slog.Logger) (KeySet, error) {
+	if cfg.JWTKeysDir == "" {
+		logger.Warn("JWT keys directory is not configured, falling back to HS256 shared secret")
+		return NewSecretKeySet(cfg.AuthSecretKey), nil
+	}
+
+	return NewFileKeySet(cfg, logger)
+}
+
+// SecretKeySet подписывает и проверяет токены общим секретом HS256. Ключи не публикуются
+type SecretKeySet struct {
+	secret []byte
+}
+
+func NewSecretKeySet(secret string) *SecretKeySet {
+	return &SecretKeySet{secret: []byte(secret)}
+}
+
+// Sign подписывает claims общим секретом
+func (s *SecretKeySet) Sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// Keyfunc принимает только токены, подписанные HMAC
+func (s *SecretKeySet) Keyfunc(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedAlgorithm, token.Header["alg"])
+	}
+	return s.secret, nil
+}
+
+// JWKS возвращает пустой набор: общий секрет не публикуется
+func (s *SecretKeySet) JWKS() JWKS {
+	return JWKS{Keys: []JWK{}}
+}
+
+// Run ничего не делает: общий секрет не ротируется
+func (s *SecretKeySet) Run(ctx context.Context) {}
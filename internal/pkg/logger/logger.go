@@ -5,14 +5,20 @@ import (
 	"os"
 )
 
-// InitLogger создает и возвращает новый логгер
+// InitLogger создает новый логгер, устанавливает его логгером по умолчанию и возвращает его.
+// Токены, пароли и другие секреты вырезаются из всех записей (см. RedactAttr)
 func InitLogger(level slog.Level) *slog.Logger {
 	// Кастомизация обработчика
 	options := &slog.HandlerOptions{
-		Level: level,
+		Level:       level,
+		ReplaceAttr: RedactAttr,
 	}
 	// Создает обработчик, который выводит логи в консоль.
 	handler := slog.NewTextHandler(os.Stdout, options)
 
-	return slog.New(handler)
+	logger := slog.New(handler)
+	// Пакетные функции slog (например, в обработчиках ошибок) проходят через тот же фильтр секретов
+	slog.SetDefault(logger)
+
+	return logger
 }
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted значение, которым заменяются секреты в логах
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys ключи атрибутов, значения которых никогда не выводятся
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+}
+
+// sensitiveSuffixes окончания ключей атрибутов с секретами (refresh_token, new_password, client_secret и т.п.)
+var sensitiveSuffixes = []string{"_token", "_password", "_secret", "_key"}
+
+// Шаблоны секретов, которые вырезаются из любых строк: сообщений, текстов ошибок и значений атрибутов
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)\b(Bearer|ApiKey)\s+[^\s"',]+`)
+	// Пары ключ-значение в форматах query строки, JSON и %+v структур: token=..., "password":"...", Password:...
+	pairPattern = regexp.MustCompile(`(?i)((?:token|password|secret)"?\s*[:=]\s*)("[^"]*"|[^\s&,}"]+)`)
+)
+
+// isSensitiveKey проверяет, содержит ли атрибут с таким ключом секрет
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString вырезает из строки токены и заголовки авторизации
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = bearerPattern.ReplaceAllString(s, "$1 "+Redacted)
+	return pairPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// RedactAttr заменяет секреты в атрибуте записи лога. Используется как HandlerOptions.ReplaceAttr,
+// поэтому применяется ко всем атрибутам, включая сообщение и атрибуты внутри групп
+func RedactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.MessageKey && isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		// Ошибки и произвольные значения выводятся как текст, поэтому проверяется их текстовое представление.
+		// Значение без секретов остается как есть, чтобы обработчик отформатировал его обычным образом
+		var text string
+		switch v := a.Value.Any().(type) {
+		case error:
+			text = v.Error()
+		case fmt.Stringer:
+			text = v.String()
+		default:
+			text = fmt.Sprintf("%+v", v)
+		}
+		if redacted := RedactString(text); redacted != text {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	return a
+}
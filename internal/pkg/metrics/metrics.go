@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// Метрики публикуются через expvar и отдаются в JSON вместе со статистикой среды выполнения Go
+var (
+	// TokenJanitorPurged число удаленных устаревших строк по таблицам
+	TokenJanitorPurged = expvar.NewMap("token_janitor_purged_total")
+	// TokenJanitorRuns число выполненных очисток
+	TokenJanitorRuns = expvar.NewInt("token_janitor_runs_total")
+	// TokenJanitorFailures число очисток, завершившихся ошибкой
+	TokenJanitorFailures = expvar.NewInt("token_janitor_failures_total")
+	// TokenJanitorLastRun время завершения последней очистки (Unix)
+	TokenJanitorLastRun = expvar.NewInt("token_janitor_last_run_unix")
+	// TokenJanitorLastDuration длительность последней очистки в секундах
+	TokenJanitorLastDuration = expvar.NewFloat("token_janitor_last_duration_seconds")
+
+	// TokenCacheHits число проверок access токена, выполненных по кэшу
+	TokenCacheHits = expvar.NewInt("token_cache_hits_total")
+	// TokenCacheMisses число проверок access токена, потребовавших обращения к базе данных
+	TokenCacheMisses = expvar.NewInt("token_cache_misses_total")
+	// TokenCacheEvictions число записей, вытесненных из кэша при переполнении
+	TokenCacheEvictions = expvar.NewInt("token_cache_evictions_total")
+	// TokenCacheInvalidations число полученных событий отзыва токенов
+	TokenCacheInvalidations = expvar.NewInt("token_cache_invalidations_total")
+)
+
+// Handler отдает все метрики в JSON
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,396 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Ошибки входа через внешнего провайдера
+var (
+	ErrDiscoveryFailed = errors.New("oidc discovery failed")
+	ErrExchangeFailed  = errors.New("authorization code exchange failed")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+)
+
+const (
+	// DiscoveryPath путь документа с адресами провайдера относительно issuer
+	DiscoveryPath = "/.well-known/openid-configuration"
+	// httpTimeout предельное время запроса к провайдеру
+	httpTimeout = 10 * time.Second
+	// jwksRefreshInterval как часто можно перечитывать ключи провайдера, встретив токен с неизвестным kid
+	jwksRefreshInterval = time.Minute
+	// clockSkew допустимое расхождение часов с провайдером при проверке сроков ID токена
+	clockSkew = time.Minute
+	// maxResponseSize предельный размер ответа провайдера
+	maxResponseSize = 1 << 20
+)
+
+// defaultScopes запрашиваемые права, если они не заданы в настройках провайдера
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// idTokenAlgorithms алгоритмы подписи ID токена, которые принимаются от провайдеров
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// providerNamePattern допустимое имя провайдера: оно входит в адреса маршрутов
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ProviderConfig настройки внешнего провайдера OpenID Connect
+type ProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// LoadProviders читает настройки провайдеров из JSON файла. Ссылки вида ${VAR} заменяются значениями
+// переменных окружения, чтобы секреты клиентов не хранились в файле
+func LoadProviders(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read oidc providers: %w", err)
+	}
+
+	var providers []ProviderConfig
+	if err = json.Unmarshal([]byte(os.ExpandEnv(string(data))), &providers); err != nil {
+		return nil, fmt.Errorf("parse oidc providers: %w", err)
+	}
+
+	seen := make(map[string]bool, len(providers))
+	for i := range providers {
+		p := &providers[i]
+		if !providerNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("invalid oidc provider name %q", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("oidc provider %q is configured twice", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %q: issuer and client_id are required", p.Name)
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = defaultScopes
+		} else if !slices.Contains(p.Scopes, "openid") {
+			p.Scopes = append([]string{"openid"}, p.Scopes...)
+		}
+	}
+
+	return providers, nil
+}
+
+// Claims сведения о пользователе из проверенного ID токена
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// idTokenClaims claims ID токена (OpenID Connect Core, раздел 2)
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string       `json:"nonce"`
+	AuthorizedParty   string       `json:"azp"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// flexibleBool логическое значение claim, которое часть провайдеров передает строкой "true"
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	*b = string(data) == "true" || string(data) == `"true"`
+	return nil
+}
+
+// metadata адреса провайдера из документа discovery
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider клиент провайдера OpenID Connect: адреса и ключи провайдера запрашиваются при первом обращении и кэшируются
+type Provider struct {
+	cfg         ProviderConfig
+	redirectURL string
+	client      *http.Client
+
+	mu            sync.Mutex
+	meta          *metadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+// NewProvider создает клиента провайдера. redirectURL — адрес обратного вызова, зарегистрированный у провайдера
+func NewProvider(cfg ProviderConfig, redirectURL string) *Provider {
+	return &Provider{
+		cfg:         cfg,
+		redirectURL: redirectURL,
+		client:      &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// Name возвращает имя провайдера из настроек
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера для authorization code flow с PKCE (S256)
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid authorization endpoint: %v", ErrDiscoveryFailed, err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.redirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange обменивает код авторизации на токены провайдера и возвращает непроверенный ID токен
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// Публичные клиенты (без секрета) подтверждают обмен только через PKCE
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status %d: %s", ErrExchangeFailed, resp.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: id_token is missing in response", ErrExchangeFailed)
+	}
+
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken проверяет подпись ID токена ключом провайдера, издателя, получателя, сроки действия и nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+
+	var claims idTokenClaims
+	_, err = parser.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is missing", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	// Токен, выпущенный для нескольких получателей, должен быть предназначен именно этому клиенту
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp mismatch", ErrInvalidIDToken)
+	}
+
+	return &Claims{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// metadata возвращает адреса провайдера, при первом обращении запрашивая документ discovery
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+DiscoveryPath, &meta); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	// Издатель в документе должен совпадать с настроенным, иначе документ мог быть подменен (OpenID Connect Discovery, раздел 4.3)
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch: %q", ErrDiscoveryFailed, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: required endpoints are missing", ErrDiscoveryFailed)
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key возвращает открытый ключ провайдера по kid. Ключи перечитываются, если kid неизвестен
+// (провайдер выполнил ротацию), но не чаще jwksRefreshInterval
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		// Ключи неподдерживаемых типов пропускаются: ими подписываются токены, которые все равно не будут приняты
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.KeyID] = pub
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey ищет ключ по kid; токен без kid принимается, только если у провайдера единственный ключ
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// getJSON запрашивает у провайдера JSON документ
+func (p *Provider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// NewCodeVerifier генерирует случайный code_verifier для PKCE (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge вычисляет code_challenge методом S256 по code_verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
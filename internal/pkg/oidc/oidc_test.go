@@ -0,0 +1,170 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/pkg/oidc/oidctest"
+)
+
+const (
+	testClientID     = "client"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost/api/v1/auth/oidc/fake/callback"
+)
+
+// authorize проходит страницу входа фиктивного провайдера и возвращает код авторизации
+func authorize(t *testing.T, p *oidc.Provider, state, nonce, challenge string) string {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if got := back.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return back.Query().Get("code")
+}
+
+func TestProviderFlow(t *testing.T) {
+	fake, srv, err := oidctest.NewServer(testClientID, testClientSecret)
+	if err != nil {
+		t.Fatalf("oidctest.NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		override     map[string]any
+		wrongVerify  bool   // Обмен кода с другим code_verifier
+		verifyNonce  string // Nonce, ожидаемый при проверке ID токена (по умолчанию отправленный провайдеру)
+		wantExchange error
+		wantVerify   error
+	}{
+		{name: "valid flow"},
+		{name: "pkce verifier mismatch", wrongVerify: true, wantExchange: oidc.ErrExchangeFailed},
+		{name: "nonce mismatch", verifyNonce: "other-nonce", wantVerify: oidc.ErrInvalidIDToken},
+		{name: "nonce missing", override: map[string]any{"nonce": nil}, wantVerify: oidc.ErrInvalidIDToken},
+		{name: "foreign issuer", override: map[string]any{"iss": "https://evil.example.com"}, wantVerify: oidc.ErrInvalidIDToken},
+		{name: "foreign audience", override: map[string]any{"aud": "other-client"}, wantVerify: oidc.ErrInvalidIDToken},
+		{
+			name: "several audiences without azp", override: map[string]any{"aud": []string{testClientID, "other-client"}},
+			wantVerify: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:     "several audiences with azp",
+			override: map[string]any{"aud": []string{testClientID, "other-client"}, "azp": testClientID},
+		},
+		{name: "expired", override: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, wantVerify: oidc.ErrInvalidIDToken},
+		{name: "subject missing", override: map[string]any{"sub": nil}, wantVerify: oidc.ErrInvalidIDToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Override = tt.override
+			defer func() { fake.Override = nil }()
+
+			ctx := context.Background()
+			p := oidc.NewProvider(oidc.ProviderConfig{
+				Name: "fake", Issuer: fake.Issuer, ClientID: testClientID, ClientSecret: testClientSecret,
+			}, testRedirectURL)
+
+			verifier, err := oidc.NewCodeVerifier()
+			if err != nil {
+				t.Fatalf("NewCodeVerifier: %v", err)
+			}
+			code := authorize(t, p, "state", "nonce", oidc.CodeChallenge(verifier))
+
+			if tt.wrongVerify {
+				if verifier, err = oidc.NewCodeVerifier(); err != nil {
+					t.Fatalf("NewCodeVerifier: %v", err)
+				}
+			}
+			rawIDToken, err := p.Exchange(ctx, code, verifier)
+			if !errors.Is(err, tt.wantExchange) {
+				t.Fatalf("Exchange error = %v, want %v", err, tt.wantExchange)
+			}
+			if err != nil {
+				return
+			}
+
+			// Код одноразовый: повторный обмен отклоняется
+			if _, err = p.Exchange(ctx, code, verifier); !errors.Is(err, oidc.ErrExchangeFailed) {
+				t.Errorf("second Exchange error = %v, want %v", err, oidc.ErrExchangeFailed)
+			}
+
+			nonce := "nonce"
+			if tt.verifyNonce != "" {
+				nonce = tt.verifyNonce
+			}
+			claims, err := p.VerifyIDToken(ctx, rawIDToken, nonce)
+			if !errors.Is(err, tt.wantVerify) {
+				t.Fatalf("VerifyIDToken error = %v, want %v", err, tt.wantVerify)
+			}
+			if err == nil && (claims.Subject != fake.Identity.Subject || claims.Email != fake.Identity.Email || !claims.EmailVerified) {
+				t.Errorf("claims = %+v, want identity %+v", claims, fake.Identity)
+			}
+		})
+	}
+}
+
+func TestProviderDiscoveryIssuerMismatch(t *testing.T) {
+	fake, srv, err := oidctest.NewServer(testClientID, testClientSecret)
+	if err != nil {
+		t.Fatalf("oidctest.NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	// Документ discovery доступен и по адресу с завершающей косой чертой, но издатель в нем другой
+	p := oidc.NewProvider(oidc.ProviderConfig{Name: "fake", Issuer: fake.Issuer + "/", ClientID: testClientID}, testRedirectURL)
+
+	if _, err = p.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); !errors.Is(err, oidc.ErrDiscoveryFailed) {
+		t.Fatalf("AuthCodeURL error = %v, want %v", err, oidc.ErrDiscoveryFailed)
+	}
+}
+
+func TestProviderPublicClient(t *testing.T) {
+	fake, srv, err := oidctest.NewServer(testClientID, "")
+	if err != nil {
+		t.Fatalf("oidctest.NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	p := oidc.NewProvider(oidc.ProviderConfig{Name: "fake", Issuer: fake.Issuer, ClientID: testClientID}, testRedirectURL)
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	code := authorize(t, p, "state", "nonce", oidc.CodeChallenge(verifier))
+
+	rawIDToken, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err = p.VerifyIDToken(ctx, rawIDToken, "nonce"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package oidctest реализует фиктивного провайдера OpenID Connect для локального запуска и проверки входа через
+// внешних провайдеров без обращения к настоящим сервисам. Провайдер сразу подтверждает вход без страницы логина
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"user-management/internal/pkg/jwtkeys"
+	"user-management/internal/pkg/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID        = "oidctest"
+	codeTTL      = time.Minute
+	idTokenTTL   = 5 * time.Minute
+	rsaKeyLength = 2048
+)
+
+// Identity учетная запись пользователя у фиктивного провайдера
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider фиктивный провайдер OpenID Connect с одним зарегистрированным клиентом
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Пустой секрет — публичный клиент, обмен кода подтверждается только PKCE
+	// Identity учетная запись, вход которой подтверждается, если в запросе нет параметра login_hint.
+	// С login_hint подтверждается вход учетной записи с таким subject и подтвержденным адресом, если hint похож на адрес
+	Identity Identity
+	// Override claims, которые заменяют claims выпускаемого ID токена (значение nil удаляет claim). Позволяет проверить
+	// отказ в токене с чужим издателем, получателем или nonce
+	Override map[string]any
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+// authCode выданный, но еще не обменянный код авторизации
+type authCode struct {
+	identity      Identity
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// New создает провайдера с новым ключом подписи RS256
+func New(issuer, clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Identity: Identity{
+			Subject:           "fake-user",
+			Email:             "fake-user@example.com",
+			EmailVerified:     true,
+			Name:              "Fake User",
+			PreferredUsername: "fakeuser",
+		},
+		key:   key,
+		codes: make(map[string]authCode),
+	}, nil
+}
+
+// NewServer запускает провайдера на локальном адресе; issuer совпадает с адресом сервера
+func NewServer(clientID, clientSecret string) (*Provider, *httptest.Server, error) {
+	p, err := New("", clientID, clientSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srv := httptest.NewServer(p.Handler())
+	p.Issuer = srv.URL
+	return p, srv, nil
+}
+
+// Handler возвращает обработчик адресов провайдера: discovery, authorize, token и jwks
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+oidc.DiscoveryPath, p.discovery)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	return mux
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize сразу подтверждает вход и перенаправляет на redirect_uri с кодом авторизации
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "authorization code flow with PKCE S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	identity := p.Identity
+	if hint := q.Get("login_hint"); hint != "" {
+		identity = Identity{Subject: hint, Name: hint, PreferredUsername: strings.Split(hint, "@")[0]}
+		if strings.Contains(hint, "@") {
+			identity.Email = hint
+			identity.EmailVerified = true
+		}
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = authCode{
+		identity:      identity,
+		redirectURI:   redirectURI.String(),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		expiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	back := redirectURI.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token обменивает код авторизации на ID токен, проверяя клиента, redirect_uri и code_verifier
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// Код одноразовый и удаляется при первом предъявлении, даже неудачном
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") ||
+		oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.Issuer,
+		"sub":                code.identity.Subject,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(idTokenTTL).Unix(),
+		"email":              code.identity.Email,
+		"email_verified":     code.identity.EmailVerified,
+		"name":               code.identity.Name,
+		"preferred_username": code.identity.PreferredUsername,
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	for name, value := range p.Override {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, jwtkeys.JWKS{Keys: []jwtkeys.JWK{{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: "RS256",
+		N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// randomString возвращает случайную строку для кодов авторизации и access токенов
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// tokenError отвечает ошибкой в формате RFC 6749, раздел 5.2
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
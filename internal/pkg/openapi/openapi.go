@@ -0,0 +1,525 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version версия спецификации OpenAPI
+const Version = "3.0.3"
+
+// Типы содержимого ответов
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeProblem = "application/problem+json"
+	contentTypeForm    = "application/x-www-form-urlencoded"
+)
+
+// Схемы аутентификации операции
+const (
+	SecurityNone        = ""             // Аутентификация не требуется
+	SecurityBearer      = "bearer"       // Требуется access токен или API ключ
+	SecurityAccessToken = "access_token" // Требуется access токен, API ключ не принимается
+	SecurityOptional    = "optional"     // Access токен или API ключ необязателен
+	SecurityClient      = "client"       // Приложение OAuth2 передает client_id и секрет в заголовке Basic или в теле запроса
+)
+
+const (
+	bearerSchemeName = "bearerAuth"
+	apiKeySchemeName = "apiKeyAuth"
+	clientSchemeName = "clientBasicAuth"
+)
+
+// DocsPage HTML страница интерактивной документации, загружающая спецификацию с /openapi.json
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// Document корневой объект спецификации OpenAPI
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info общие сведения об API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem операции пути по HTTP методам (в нижнем регистре)
+type PathItem map[string]*Operation
+
+// Components переиспользуемые схемы и схемы аутентификации
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme схема аутентификации
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Operation описание операции
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter параметр пути, запроса или заголовка
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody тело запроса
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response ответ операции
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType схема содержимого определенного типа
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema схема данных (подмножество JSON Schema, используемое OpenAPI 3.0)
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+	MinLength  *int               `json:"minLength,omitempty"`
+	MaxLength  *int               `json:"maxLength,omitempty"`
+	MinItems   *int               `json:"minItems,omitempty"`
+	MaxItems   *int               `json:"maxItems,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+}
+
+// Route описание маршрута API: по нему строится операция спецификации.
+// Path указывается в формате gin (/users/:id/status)
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Tag         string
+	Security    string
+	Query       any         // DTO с тегами form для параметров запроса
+	Request     any         // DTO тела запроса
+	Form        any         // DTO с тегами form для тела application/x-www-form-urlencoded
+	Headers     []Parameter // Дополнительные заголовки запроса
+	Responses   map[int]any // DTO успешных ответов по HTTP статусу (nil — ответ без тела)
+	Errors      []int       // HTTP статусы ответов с ошибкой в формате problem+json
+}
+
+// RouteKey метод и путь зарегистрированного маршрута
+type RouteKey struct {
+	Method string
+	Path   string
+}
+
+// Generator строит спецификацию по описаниям маршрутов
+type Generator struct {
+	// Patterns регулярные выражения для пользовательских правил валидации binding (например, username)
+	Patterns map[string]string
+	// Problem тип тела ответа с ошибкой
+	Problem any
+
+	schemas map[string]*Schema
+}
+
+// Build строит спецификацию. Каждый зарегистрированный маршрут должен иметь описание,
+// а каждое описание — соответствовать зарегистрированному маршруту
+func (g *Generator) Build(info Info, registered []RouteKey, routes []Route) (*Document, error) {
+	if err := checkRoutes(registered, routes); err != nil {
+		return nil, err
+	}
+
+	g.schemas = make(map[string]*Schema)
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerSchemeName: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				apiKeySchemeName: {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				clientSchemeName: {Type: "http", Scheme: "basic"},
+			},
+		},
+	}
+
+	for _, r := range routes {
+		path, params := convertPath(r.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(r.Method)] = g.operation(r, params)
+	}
+
+	return doc, nil
+}
+
+// JSON возвращает спецификацию в виде JSON с отступами и переводом строки в конце
+func (d *Document) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// operation строит операцию по описанию маршрута
+func (g *Generator) operation(r Route, pathParams []Parameter) *Operation {
+	op := &Operation{
+		Summary:     r.Summary,
+		OperationID: r.OperationID,
+		Parameters:  append(pathParams, r.Headers...),
+		Responses:   make(map[string]Response),
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+
+	switch r.Security {
+	case SecurityBearer:
+		op.Security = []map[string][]string{{bearerSchemeName: {}}, {apiKeySchemeName: {}}}
+	case SecurityAccessToken:
+		op.Security = []map[string][]string{{bearerSchemeName: {}}}
+	case SecurityOptional:
+		op.Security = []map[string][]string{{}, {bearerSchemeName: {}}, {apiKeySchemeName: {}}}
+	case SecurityClient:
+		op.Security = []map[string][]string{{clientSchemeName: {}}, {}}
+	}
+
+	if r.Query != nil {
+		op.Parameters = append(op.Parameters, g.queryParameters(reflect.TypeOf(r.Query))...)
+	}
+	if r.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentTypeJSON: {Schema: g.schema(reflect.TypeOf(r.Request))}},
+		}
+	}
+
+	if r.Form != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentTypeForm: {Schema: g.formSchema(reflect.TypeOf(r.Form))}},
+		}
+	}
+
+	for status, body := range r.Responses {
+		resp := Response{Description: http.StatusText(status)}
+		if body != nil {
+			resp.Content = map[string]MediaType{contentTypeJSON: {Schema: g.schema(reflect.TypeOf(body))}}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+	}
+	for _, status := range r.Errors {
+		op.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{contentTypeProblem: {Schema: g.schema(reflect.TypeOf(g.Problem))}},
+		}
+	}
+
+	return op
+}
+
+// queryParameters строит параметры запроса по полям с тегом form
+func (g *Generator) queryParameters(t reflect.Type) []Parameter {
+	t = indirect(t)
+
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			params = append(params, g.queryParameters(f.Type)...)
+			continue
+		}
+
+		name := tagName(f.Tag.Get("form"))
+		if name == "" || !f.IsExported() {
+			continue
+		}
+
+		schema := g.schema(f.Type)
+		rules := applyBinding(schema, f.Tag.Get("binding"), g.Patterns)
+		params = append(params, Parameter{Name: name, In: "query", Required: rules.required, Schema: schema})
+	}
+
+	return params
+}
+
+// formSchema строит схему тела формы по полям с тегом form. Схема не выносится в components:
+// те же DTO без тегов json не описывают JSON объект
+func (g *Generator) formSchema(t reflect.Type) *Schema {
+	t = indirect(t)
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tagName(f.Tag.Get("form"))
+		if name == "" || !f.IsExported() {
+			continue
+		}
+
+		prop := g.schema(f.Type)
+		if applyBinding(prop, f.Tag.Get("binding"), g.Patterns).required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// schema строит схему для типа. Структуры выносятся в components и возвращаются ссылкой
+func (g *Generator) schema(t reflect.Type) *Schema {
+	t = indirect(t)
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Резервируем имя до обхода полей, чтобы рекурсивные типы не зацикливались
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	return &Schema{}
+}
+
+// structSchema строит схему объекта по полям с тегом json. Встроенные структуры раскрываются
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			embedded := g.structSchema(indirect(f.Type))
+			for name, prop := range embedded.Properties {
+				s.Properties[name] = prop
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		name := tagName(f.Tag.Get("json"))
+		if name == "" || !f.IsExported() {
+			continue
+		}
+
+		prop := g.schema(f.Type)
+		if applyBinding(prop, f.Tag.Get("binding"), g.Patterns).required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// bindingRules правила binding, не отражаемые в самой схеме
+type bindingRules struct {
+	required bool
+}
+
+// applyBinding переносит правила валидации binding в схему
+func applyBinding(s *Schema, tag string, patterns map[string]string) bindingRules {
+	var rules bindingRules
+	if tag == "" {
+		return rules
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			// Правила после dive относятся к элементам массива
+			if s.Items == nil {
+				return rules
+			}
+			s = s.Items
+		case "required":
+			rules.required = true
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				continue
+			}
+			setLimit(s, name == "min", n)
+		default:
+			if pattern, ok := patterns[name]; ok {
+				s.Pattern = pattern
+			}
+		}
+	}
+
+	return rules
+}
+
+// setLimit задает ограничение min/max: длину для строк, число элементов для массивов и значение для чисел
+func setLimit(s *Schema, isMin bool, n int) {
+	if s.Type == "array" {
+		if isMin {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+		return
+	}
+	if s.Type == "string" {
+		if isMin {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+		return
+	}
+
+	v := float64(n)
+	if isMin {
+		s.Minimum = &v
+	} else {
+		s.Maximum = &v
+	}
+}
+
+// checkRoutes сверяет зарегистрированные маршруты с их описаниями
+func checkRoutes(registered []RouteKey, routes []Route) error {
+	described := make(map[RouteKey]bool, len(routes))
+	for _, r := range routes {
+		key := RouteKey{Method: r.Method, Path: r.Path}
+		if described[key] {
+			return fmt.Errorf("route %s %s is described twice", key.Method, key.Path)
+		}
+		described[key] = true
+	}
+
+	var problems []string
+	actual := make(map[RouteKey]bool, len(registered))
+	for _, key := range registered {
+		actual[key] = true
+		if !described[key] {
+			problems = append(problems, fmt.Sprintf("route %s %s is not described", key.Method, key.Path))
+		}
+	}
+	for key := range described {
+		if !actual[key] {
+			problems = append(problems, fmt.Sprintf("described route %s %s is not registered", key.Method, key.Path))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// convertPath переводит путь gin (/users/:id) в формат OpenAPI (/users/{id}) и возвращает параметры пути.
+// Параметры, имя которых оканчивается на id (id, sid, kid), считаются числовыми, остальные — строками
+func convertPath(path string) (string, []Parameter) {
+	segments := strings.Split(path, "/")
+
+	var params []Parameter
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+			schema := &Schema{Type: "string"}
+			if strings.HasSuffix(name, "id") {
+				schema = &Schema{Type: "integer", Format: "int32"}
+			}
+			params = append(params, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   schema,
+			})
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// tagName возвращает имя из тега json или form; "-" означает, что поле пропускается
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// indirect снимает указатели с типа
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,211 @@
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"user-management/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Алгоритмы хэширования паролей
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// BcryptMaxPasswordBytes максимальная длина пароля для bcrypt: более длинные пароли bcrypt не принимает
+const BcryptMaxPasswordBytes = 72
+
+// Параметры argon2id, не вынесенные в конфигурацию
+const (
+	argon2SaltLength = 16 // Размер соли в байтах
+	argon2KeyLength  = 32 // Размер хэша в байтах
+)
+
+// Ошибки разбора хранимых хэшей
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// encoding кодировка соли и хэша в формате PHC: base64 без выравнивания
+var encoding = base64.RawStdEncoding
+
+// Hasher вычисляет хэши паролей в формате PHC ($argon2id$v=19$m=...,t=...,p=...$соль$хэш) текущим алгоритмом
+// и проверяет хэши всех поддерживаемых алгоритмов. Хэши bcrypt хранятся в его собственном формате ($2a$10$...),
+// у которого тот же вид $идентификатор$параметры$...
+type Hasher struct {
+	algorithm         string
+	argon2Memory      uint32
+	argon2Iterations  uint32
+	argon2Parallelism uint8
+	bcryptCost        int
+}
+
+// New создает Hasher с алгоритмом и параметрами из конфигурации
+func New(cfg *config.ApiServer) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:         cfg.PasswordHashAlgorithm,
+		argon2Memory:      cfg.Argon2Memory,
+		argon2Iterations:  cfg.Argon2Iterations,
+		argon2Parallelism: cfg.Argon2Parallelism,
+		bcryptCost:        cfg.BcryptCost,
+	}
+
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		// Память argon2 должна быть не меньше 8 КиБ на каждый поток
+		if h.argon2Iterations < 1 || h.argon2Parallelism < 1 || h.argon2Memory < 8*uint32(h.argon2Parallelism) {
+			return nil, fmt.Errorf("invalid argon2id parameters: m=%d, t=%d, p=%d", h.argon2Memory, h.argon2Iterations, h.argon2Parallelism)
+		}
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost: %d", h.bcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, h.algorithm)
+	}
+
+	return h, nil
+}
+
+// Hash вычисляет хэш пароля текущим алгоритмом
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+
+	params := argon2Params{memory: h.argon2Memory, iterations: h.argon2Iterations, parallelism: h.argon2Parallelism}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	return params.encode(salt, key), nil
+}
+
+// Verify проверяет пароль по хранимому хэшу любого поддерживаемого алгоритма. Пустой хэш означает, что пароль
+// у пользователя не задан (учетная запись создана через внешнего провайдера), и не совпадает ни с одним паролем
+func (h *Hasher) Verify(password, encoded string) (bool, error) {
+	if encoded == "" {
+		return false, nil
+	}
+
+	switch algorithmOf(encoded) {
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return true, nil
+	case AlgorithmArgon2id:
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1, nil
+	default:
+		return false, ErrUnknownAlgorithm
+	}
+}
+
+// NeedsRehash сообщает, что хэш вычислен другим алгоритмом или с другими параметрами и его нужно пересчитать
+// при следующем входе. Пустой и нераспознанный хэш не пересчитывается
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	algorithm := algorithmOf(encoded)
+	if algorithm == "" {
+		return false
+	}
+	if algorithm != h.algorithm {
+		return true
+	}
+
+	if algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err == nil && cost != h.bcryptCost
+	}
+
+	params, _, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false
+	}
+	return params.memory != h.argon2Memory || params.iterations != h.argon2Iterations ||
+		params.parallelism != h.argon2Parallelism || len(key) != argon2KeyLength
+}
+
+// algorithmOf определяет алгоритм по идентификатору в начале хэша
+func algorithmOf(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return AlgorithmBcrypt
+	default:
+		return ""
+	}
+}
+
+// argon2Params параметры argon2id, записанные в хэше
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// encode записывает хэш в формате PHC
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		p.memory, p.iterations, p.parallelism, encoding.EncodeToString(salt), encoding.EncodeToString(key))
+}
+
+// decodeArgon2 разбирает хэш argon2id в формате PHC
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, хэш
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return params, nil, nil, fmt.Errorf("%w: unsupported argon2 version %q", ErrMalformedHash, parts[2])
+	}
+	// Sscanf не проверяет остаток строки, поэтому параметры сравниваются с записью в каноническом виде
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", params.memory, params.iterations, params.parallelism) {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 parameters %q", ErrMalformedHash, parts[3])
+	}
+	if params.iterations < 1 || params.parallelism < 1 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,254 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"user-management/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse battery staple"
+
+// argon2Config конфигурация argon2id с минимальными параметрами, чтобы тесты выполнялись быстро
+func argon2Config() *config.ApiServer {
+	return &config.ApiServer{
+		PasswordHashAlgorithm: AlgorithmArgon2id,
+		Argon2Memory:          64,
+		Argon2Iterations:      1,
+		Argon2Parallelism:     2,
+		BcryptCost:            bcrypt.MinCost,
+	}
+}
+
+func bcryptConfig() *config.ApiServer {
+	cfg := argon2Config()
+	cfg.PasswordHashAlgorithm = AlgorithmBcrypt
+	return cfg
+}
+
+func mustNew(t *testing.T, cfg *config.ApiServer) *Hasher {
+	t.Helper()
+
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return hash
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.ApiServer)
+		wantErr bool
+	}{
+		{name: "argon2id"},
+		{name: "bcrypt", modify: func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt }},
+		{name: "unknown algorithm", modify: func(c *config.ApiServer) { c.PasswordHashAlgorithm = "md5" }, wantErr: true},
+		{name: "argon2 zero iterations", modify: func(c *config.ApiServer) { c.Argon2Iterations = 0 }, wantErr: true},
+		{name: "argon2 zero parallelism", modify: func(c *config.ApiServer) { c.Argon2Parallelism = 0 }, wantErr: true},
+		{name: "argon2 memory below 8 KiB per thread", modify: func(c *config.ApiServer) { c.Argon2Memory = 15 }, wantErr: true},
+		{
+			name: "bcrypt cost too low",
+			modify: func(c *config.ApiServer) {
+				c.PasswordHashAlgorithm = AlgorithmBcrypt
+				c.BcryptCost = bcrypt.MinCost - 1
+			},
+			wantErr: true,
+		},
+		{
+			name: "bcrypt cost too high",
+			modify: func(c *config.ApiServer) {
+				c.PasswordHashAlgorithm = AlgorithmBcrypt
+				c.BcryptCost = bcrypt.MaxCost + 1
+			},
+			wantErr: true,
+		},
+		// Параметры неиспользуемого алгоритма не проверяются
+		{name: "bcrypt ignores argon2 parameters", modify: func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt; c.Argon2Iterations = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := argon2Config()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			if _, err := New(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("New error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, cfg := range []*config.ApiServer{argon2Config(), bcryptConfig()} {
+		t.Run(cfg.PasswordHashAlgorithm, func(t *testing.T) {
+			h := mustNew(t, cfg)
+			hash := mustHash(t, h, testPassword)
+
+			if got := algorithmOf(hash); got != cfg.PasswordHashAlgorithm {
+				t.Errorf("algorithm of %q = %q, want %q", hash, got, cfg.PasswordHashAlgorithm)
+			}
+			if ok, err := h.Verify(testPassword, hash); err != nil || !ok {
+				t.Errorf("Verify(correct) = %v, %v, want true", ok, err)
+			}
+			if ok, err := h.Verify(testPassword+"!", hash); err != nil || ok {
+				t.Errorf("Verify(wrong) = %v, %v, want false", ok, err)
+			}
+			// Соль случайная: хэши одного пароля различаются
+			if again := mustHash(t, h, testPassword); again == hash {
+				t.Errorf("two hashes of the same password are equal: %s", hash)
+			}
+		})
+	}
+}
+
+func TestVerifyOtherAlgorithm(t *testing.T) {
+	argon := mustNew(t, argon2Config())
+	bcryptHasher := mustNew(t, bcryptConfig())
+
+	// Хэши проверяются алгоритмом из самого хэша, а не текущим алгоритмом конфигурации
+	if ok, err := argon.Verify(testPassword, mustHash(t, bcryptHasher, testPassword)); err != nil || !ok {
+		t.Errorf("argon2id hasher Verify(bcrypt hash) = %v, %v, want true", ok, err)
+	}
+	if ok, err := bcryptHasher.Verify(testPassword, mustHash(t, argon, testPassword)); err != nil || !ok {
+		t.Errorf("bcrypt hasher Verify(argon2id hash) = %v, %v, want true", ok, err)
+	}
+
+	// Пустой хэш означает, что пароль не задан, и не совпадает даже с пустым паролем
+	if ok, err := argon.Verify("", ""); err != nil || ok {
+		t.Errorf("Verify with empty hash = %v, %v, want false", ok, err)
+	}
+}
+
+func TestDecodeArgon2(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    argon2Params
+		wantErr bool
+	}{
+		{name: "valid", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "$" + key, want: argon2Params{memory: 65536, iterations: 3, parallelism: 4}},
+		{name: "too few parts", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt, wantErr: true},
+		{name: "too many parts", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "$" + key + "$x", wantErr: true},
+		{name: "old version", encoded: "$argon2id$v=16$m=65536,t=3,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "version with suffix", encoded: "$argon2id$v=19x$m=65536,t=3,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "parameters reordered", encoded: "$argon2id$v=19$t=3,m=65536,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "parameters with suffix", encoded: "$argon2id$v=19$m=65536,t=3,p=4,k=1$" + salt + "$" + key, wantErr: true},
+		{name: "parameters with leading zero", encoded: "$argon2id$v=19$m=065536,t=3,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "negative memory", encoded: "$argon2id$v=19$m=-1,t=3,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "parallelism overflow", encoded: "$argon2id$v=19$m=65536,t=3,p=256$" + salt + "$" + key, wantErr: true},
+		{name: "zero iterations", encoded: "$argon2id$v=19$m=65536,t=0,p=4$" + salt + "$" + key, wantErr: true},
+		{name: "zero parallelism", encoded: "$argon2id$v=19$m=65536,t=3,p=0$" + salt + "$" + key, wantErr: true},
+		{name: "padded salt", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "==$" + key, wantErr: true},
+		{name: "empty salt", encoded: "$argon2id$v=19$m=65536,t=3,p=4$$" + key, wantErr: true},
+		{name: "empty key", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "$", wantErr: true},
+		{name: "invalid key", encoded: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "$not base64!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, gotSalt, gotKey, err := decodeArgon2(tt.encoded)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformedHash) {
+					t.Errorf("decodeArgon2 error = %v, want %v", err, ErrMalformedHash)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeArgon2: %v", err)
+			}
+			if params != tt.want || len(gotSalt) != 16 || len(gotKey) != 32 {
+				t.Errorf("decodeArgon2 = %+v, salt %d bytes, key %d bytes, want %+v, 16 and 32 bytes", params, len(gotSalt), len(gotKey), tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	h := mustNew(t, argon2Config())
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{name: "unknown algorithm", encoded: "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$aGFzaA", wantErr: ErrUnknownAlgorithm},
+		{name: "plain text", encoded: "password", wantErr: ErrUnknownAlgorithm},
+		{name: "malformed argon2id", encoded: "$argon2id$v=19$m=64,t=1$c2FsdA$aGFzaA", wantErr: ErrMalformedHash},
+		{name: "truncated bcrypt", encoded: "$2a$04$short", wantErr: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := h.Verify(testPassword, tt.encoded); ok || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, %v, want false, %v", ok, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argonHash := mustHash(t, mustNew(t, argon2Config()), testPassword)
+	bcryptHash := mustHash(t, mustNew(t, bcryptConfig()), testPassword)
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.ApiServer)
+		encoded string
+		want    bool
+	}{
+		{name: "argon2id same parameters", encoded: argonHash},
+		{name: "argon2id memory changed", modify: func(c *config.ApiServer) { c.Argon2Memory = 128 }, encoded: argonHash, want: true},
+		{name: "argon2id iterations changed", modify: func(c *config.ApiServer) { c.Argon2Iterations = 2 }, encoded: argonHash, want: true},
+		{name: "argon2id parallelism changed", modify: func(c *config.ApiServer) { c.Argon2Parallelism = 1 }, encoded: argonHash, want: true},
+		{name: "argon2id short key", encoded: argonHash[:strings.LastIndex(argonHash, "$")+1] + "aGFzaA", want: true},
+		{name: "argon2id bcrypt cost changed", modify: func(c *config.ApiServer) { c.BcryptCost++ }, encoded: argonHash},
+		{name: "argon2id to bcrypt", modify: func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt }, encoded: argonHash, want: true},
+		{name: "bcrypt to argon2id", encoded: bcryptHash, want: true},
+		{name: "bcrypt same cost", modify: func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt }, encoded: bcryptHash},
+		{
+			name:    "bcrypt cost changed",
+			modify:  func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt; c.BcryptCost++ },
+			encoded: bcryptHash,
+			want:    true,
+		},
+		{
+			name:    "bcrypt 2y prefix",
+			modify:  func(c *config.ApiServer) { c.PasswordHashAlgorithm = AlgorithmBcrypt },
+			encoded: "$2y$" + strings.TrimPrefix(bcryptHash, "$2a$"),
+		},
+		// Пустой и нераспознанный хэш не пересчитывается: при входе с ним пароль все равно не совпадет
+		{name: "empty", encoded: ""},
+		{name: "unknown algorithm", encoded: "$argon2i$v=19$m=64,t=1,p=2$c2FsdA$aGFzaA"},
+		{name: "malformed argon2id", encoded: "$argon2id$v=19$m=64$c2FsdA$aGFzaA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := argon2Config()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			if got := mustNew(t, cfg).NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.encoded, got, tt.want)
+			}
+		})
+	}
+}
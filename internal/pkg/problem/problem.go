@@ -0,0 +1,147 @@
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType тип содержимого ответа об ошибке (RFC 7807)
+const ContentType = "application/problem+json"
+
+// typePrefix префикс URI, идентифицирующего тип ошибки
+const typePrefix = "urn:user-management:problem:"
+
+// Code стабильный машиночитаемый код ошибки. Клиенты должны различать ошибки по коду, а не по тексту
+type Code string
+
+// Общие коды ошибок
+const (
+	CodeInvalidRequest          Code = "invalid_request"
+	CodeUnauthorized            Code = "unauthorized"
+	CodeInvalidToken            Code = "invalid_token"
+	CodeForbidden               Code = "forbidden"
+	CodeInsufficientRole        Code = "insufficient_role"
+	CodeInsufficientPermissions Code = "insufficient_permissions"
+	CodeAccessTokenRequired     Code = "access_token_required"
+	CodeNotFound                Code = "not_found"
+	CodeMethodNotAllowed        Code = "method_not_allowed"
+	CodeConflict                Code = "conflict"
+	CodeInternal                Code = "internal_error"
+)
+
+// Коды ошибок пользователей и токенов
+const (
+	CodeUserNotFound             Code = "user_not_found"
+	CodeUserAlreadyExists        Code = "user_already_exists"
+	CodeInvalidCredentials       Code = "invalid_credentials"
+	CodeInvalidReferrer          Code = "invalid_referrer"
+	CodeReferrerAlreadySet       Code = "referrer_already_set"
+	CodeNegativeBalance          Code = "negative_balance"
+	CodeInvalidRefreshToken      Code = "invalid_refresh_token"
+	CodeRefreshTokenReused       Code = "refresh_token_reused"
+	CodeInvalidResetToken        Code = "invalid_reset_token"
+	CodeWeakPassword             Code = "weak_password"
+	CodeInvalidCurrentPassword   Code = "invalid_current_password"
+	CodeSessionRequired          Code = "session_required"
+	CodeEmailRequired            Code = "email_required"
+	CodeEmailAlreadyExists       Code = "email_already_exists"
+	CodeEmailNotSet              Code = "email_not_set"
+	CodeEmailAlreadyVerified     Code = "email_already_verified"
+	CodeEmailNotVerified         Code = "email_not_verified"
+	CodeInvalidVerificationToken Code = "invalid_verification_token"
+	CodeMFAAlreadyEnabled        Code = "mfa_already_enabled"
+	CodeMFANotEnrolled           Code = "mfa_not_enrolled"
+	CodeInvalidMFACode           Code = "invalid_mfa_code"
+	CodeInvalidMFAToken          Code = "invalid_mfa_token"
+	CodeAccountLocked            Code = "account_locked"
+	CodeTooManyLoginAttempts     Code = "too_many_login_attempts"
+	CodeSessionNotFound          Code = "session_not_found"
+	CodeUnknownRole              Code = "unknown_role"
+	CodeAPIKeyNotFound           Code = "api_key_not_found"
+	CodeUnknownScope             Code = "unknown_scope"
+	CodeInvalidAPIKeyExpiry      Code = "invalid_api_key_expiry"
+	CodeUnknownProvider          Code = "unknown_provider"
+	CodeProviderUnavailable      Code = "identity_provider_unavailable"
+	CodeInvalidOIDCState         Code = "invalid_oidc_state"
+	CodeOIDCAuthFailed           Code = "oidc_authentication_failed"
+	CodeIdentityAlreadyLinked    Code = "identity_already_linked"
+	CodeIdentityNotFound         Code = "identity_not_found"
+	CodeLastLoginMethod          Code = "last_login_method"
+	CodeOAuthClientNotFound      Code = "oauth_client_not_found"
+	CodeInvalidOAuthClient       Code = "invalid_oauth_client"
+	CodeInvalidOAuthRegistration Code = "invalid_oauth_client_registration"
+	CodeScopeNotDelegable        Code = "scope_not_delegable"
+	CodeInvalidRedirectURI       Code = "invalid_redirect_uri"
+	CodeInvalidOAuthRequest      Code = "invalid_oauth_request"
+	CodeInvalidOAuthScope        Code = "invalid_oauth_scope"
+	CodeUnauthorizedOAuthClient  Code = "unauthorized_oauth_client"
+	CodeOAuthConsentNotFound     Code = "oauth_consent_not_found"
+)
+
+// Коды ошибок заданий
+const (
+	CodeTaskNotFound             Code = "task_not_found"
+	CodeTaskAlreadyCompleted     Code = "task_already_completed"
+	CodeTaskLimitReached         Code = "task_limit_reached"
+	CodeTaskNotYetAvailable      Code = "task_not_yet_available"
+	CodeTaskExpired              Code = "task_expired"
+	CodeTaskArchived             Code = "task_archived"
+	CodeInvalidTaskWindow        Code = "invalid_task_window"
+	CodeInvalidTaskRecurrence    Code = "invalid_task_recurrence"
+	CodeInvalidTaskVerification  Code = "invalid_task_verification"
+	CodeInvalidProof             Code = "invalid_proof"
+	CodeCompletionNotFound       Code = "completion_not_found"
+	CodeCompletionNotPending     Code = "completion_not_pending"
+	CodeCallbackNotAllowed       Code = "callback_not_allowed"
+	CodeInvalidCallbackSignature Code = "invalid_callback_signature"
+)
+
+// Problem тело ответа об ошибке в формате RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     Code   `json:"code"`
+}
+
+// New создает описание ошибки с указанным статусом, кодом и заголовком
+func New(status int, code Code, title string) *Problem {
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+}
+
+// CodeForStatus возвращает общий код ошибки для HTTP статуса
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	default:
+		return CodeInternal
+	}
+}
+
+// Abort отправляет описание ошибки как application/problem+json и прерывает обработку запроса
+func Abort(c *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
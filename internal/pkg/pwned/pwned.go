@@ -0,0 +1,86 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrefixLength число первых символов SHA-1 хэша пароля, передаваемых источнику (k-анонимность, как в API Have I Been Pwned)
+const PrefixLength = 5
+
+// RangeSource источник утекших паролей. По префиксу SHA-1 хэша возвращает суффиксы хэшей всех утекших паролей
+// с этим префиксом, поэтому ни пароль, ни его полный хэш источнику не передаются
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// IsBreached проверяет, встречается ли пароль среди утекших
+func IsBreached(ctx context.Context, source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:PrefixLength], hash[PrefixLength:]
+
+	suffixes, err := source.Range(ctx, prefix)
+	if err != nil {
+		return false, fmt.Errorf("failed to get breached range %s: %w", prefix, err)
+	}
+
+	for _, s := range suffixes {
+		if strings.EqualFold(s, suffix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DirSource офлайн источник: каталог с файлами диапазонов {префикс}.txt в формате выгрузки Have I Been Pwned
+// (строки СУФФИКС:ЧИСЛО_УТЕЧЕК). Файлы читаются при каждой проверке и не загружаются в память целиком
+type DirSource struct {
+	dir string
+}
+
+// NewDirSource создает источник по каталогу с файлами диапазонов
+func NewDirSource(dir string) (*DirSource, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached passwords path %s is not a directory", dir)
+	}
+	return &DirSource{dir: dir}, nil
+}
+
+// Range возвращает суффиксы хэшей из файла диапазона. Отсутствующий файл означает, что утечек с префиксом нет
+func (s *DirSource) Range(_ context.Context, prefix string) ([]string, error) {
+	f, err := os.Open(filepath.Join(s.dir, strings.ToUpper(prefix)+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var suffixes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return suffixes, nil
+}
@@ -0,0 +1,120 @@
+package rbac
+
+// Role роль пользователя
+type Role string
+
+// Permission право на выполнение действия
+type Permission string
+
+const (
+	RoleUser  Role = "user"  // Роль, которая есть у любого аутентифицированного пользователя
+	RoleAdmin Role = "admin" // Администратор
+)
+
+const (
+	PermissionTasksManage   Permission = "tasks:manage"   // Создание, изменение и архивирование заданий
+	PermissionUsersManage   Permission = "users:manage"   // Управление учетными записями пользователей
+	PermissionTasksReview   Permission = "tasks:review"   // Проверка заявок на выполнение заданий
+	PermissionClientsManage Permission = "clients:manage" // Регистрация сторонних приложений OAuth2
+)
+
+// Права пользователя на собственные данные. Они есть у любого пользователя и нужны, чтобы ограничить ими
+// API ключ или передать их стороннему приложению
+const (
+	PermissionProfileRead   Permission = "profile:read"   // Профиль, баланс, место в топе и история баланса
+	PermissionProfileWrite  Permission = "profile:write"  // Реферальный код и повторная отправка письма для подтверждения адреса
+	PermissionTasksRead     Permission = "tasks:read"     // Каталог заданий со статусом выполнения
+	PermissionTasksComplete Permission = "tasks:complete" // Выполнение заданий
+	PermissionAccountManage Permission = "account:manage" // Сессии, второй фактор, API ключи, привязанные учетные записи и смена адреса электронной почты
+)
+
+// rolePermissions права, предоставляемые каждой ролью
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {
+		PermissionProfileRead,
+		PermissionProfileWrite,
+		PermissionTasksRead,
+		PermissionTasksComplete,
+		PermissionAccountManage,
+	},
+	RoleAdmin: {
+		PermissionTasksManage,
+		PermissionUsersManage,
+		PermissionTasksReview,
+		PermissionClientsManage,
+	},
+}
+
+// nonDelegablePermissions права, которые нельзя передать стороннему приложению: они позволяют
+// закрепиться в аккаунте (выпустить API ключ, отключить второй фактор, сменить адрес, на который
+// отправляется ссылка сброса пароля) или зарегистрировать новые приложения
+var nonDelegablePermissions = []Permission{PermissionAccountManage, PermissionClientsManage}
+
+// IsValidRole проверяет, что роль известна системе
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// HasRole проверяет наличие хотя бы одной из требуемых ролей
+func HasRole(roles []string, required ...Role) bool {
+	for _, role := range roles {
+		for _, r := range required {
+			if Role(role) == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermission проверяет, предоставляет ли хотя бы одна из ролей указанное право
+func HasPermission(roles []string, permission Permission) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[Role(role)] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsValidPermission проверяет, что право известно системе
+func IsValidPermission(permission string) bool {
+	for _, permissions := range rolePermissions {
+		for _, p := range permissions {
+			if string(p) == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsDelegable проверяет, что право известно системе и может быть выдано стороннему приложению
+func IsDelegable(permission string) bool {
+	if !IsValidPermission(permission) {
+		return false
+	}
+	for _, p := range nonDelegablePermissions {
+		if string(p) == permission {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowedByScopes проверяет, что право не исключено ограничениями API ключа или токена приложения.
+// Пустой список ограничений (access токен или ключ без ограничений) разрешает все права ролей
+func AllowedByScopes(scopes []string, permission Permission) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if Permission(scope) == permission {
+			return true
+		}
+	}
+	return false
+}
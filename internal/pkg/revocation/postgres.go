@@ -0,0 +1,82 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresBus обменивается событиями через LISTEN/NOTIFY и не требует отдельной инфраструктуры.
+// Для прослушивания канала из пула забирается одно соединение
+type PostgresBus struct {
+	subscribers
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewPostgresBus(pool *pgxpool.Pool, logger *slog.Logger) *PostgresBus {
+	return &PostgresBus{pool: pool, logger: logger}
+}
+
+// Publish доставляет событие подписчикам и отправляет его остальным экземплярам через pg_notify
+func (b *PostgresBus) Publish(ctx context.Context, event Event) error {
+	b.invalidate(event)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err = b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify revocation: %w", err)
+	}
+	return nil
+}
+
+// Run слушает канал и переподключается после ошибок до отмены контекста
+func (b *PostgresBus) Run(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.logger.Error("Token revocation listener failed", "backend", BackendPostgres, "error", err)
+		if !sleep(ctx, reconnectDelay) {
+			return
+		}
+	}
+}
+
+// listen подписывается на канал и передает полученные события подписчикам
+func (b *PostgresBus) listen(ctx context.Context) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Соединение с LISTEN не возвращается в пул, чтобы уведомления не приходили в чужие запросы
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return err
+	}
+	// События, отправленные до подписки, могли быть пропущены
+	b.flush()
+	b.logger.Info("Listening for token revocations", "backend", BackendPostgres, "channel", Channel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		event, err := decode([]byte(notification.Payload))
+		if err != nil {
+			b.logger.Warn("Skipping token revocation event", "error", err)
+			continue
+		}
+		b.invalidate(event)
+	}
+}
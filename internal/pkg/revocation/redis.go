@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus обменивается событиями через Pub/Sub Redis-совместимого хранилища
+type RedisBus struct {
+	subscribers
+	client *redis.Client
+	logger *slog.Logger
+}
+
+func NewRedisBus(client *redis.Client, logger *slog.Logger) *RedisBus {
+	return &RedisBus{client: client, logger: logger}
+}
+
+// Publish доставляет событие подписчикам и публикует его для остальных экземпляров
+func (b *RedisBus) Publish(ctx context.Context, event Event) error {
+	b.invalidate(event)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err = b.client.Publish(ctx, Channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish revocation: %w", err)
+	}
+	return nil
+}
+
+// Run принимает события до отмены контекста. После обрыва соединения клиент переподписывается сам,
+// а повторное подтверждение подписки сбрасывает подписчиков, так как события могли быть пропущены
+func (b *RedisBus) Run(ctx context.Context) {
+	defer b.client.Close()
+
+	pubsub := b.client.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.Error("Token revocation listener failed", "backend", BackendRedis, "error", err)
+			if !sleep(ctx, reconnectDelay) {
+				return
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				b.flush()
+				b.logger.Info("Listening for token revocations", "backend", BackendRedis, "channel", Channel)
+			}
+		case *redis.Message:
+			event, err := decode([]byte(msg.Payload))
+			if err != nil {
+				b.logger.Warn("Skipping token revocation event", "error", err)
+				continue
+			}
+			b.invalidate(event)
+		}
+	}
+}
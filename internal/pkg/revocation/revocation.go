@@ -0,0 +1,140 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"user-management/internal/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// Способы доставки событий отзыва между экземплярами сервиса
+const (
+	BackendLocal    = "local"
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+)
+
+// Channel канал (Postgres LISTEN/NOTIFY или Redis Pub/Sub), через который экземпляры обмениваются событиями отзыва
+const Channel = "token_revocations"
+
+// reconnectDelay пауза перед повторным подключением к каналу после ошибки
+const reconnectDelay = 5 * time.Second
+
+// Event событие отзыва access токенов. Заполняется одно из полей TokenHash, SessionID, UserID или ClientID
+type Event struct {
+	TokenHash       string `json:"token_hash,omitempty"`        // Отозван один токен (хэш claim jti)
+	SessionID       int64  `json:"session_id,omitempty"`        // Отозваны все токены сессии
+	UserID          int    `json:"user_id,omitempty"`           // Отозваны все токены пользователя
+	ExceptSessionID int64  `json:"except_session_id,omitempty"` // Кроме токенов этой сессии (только вместе с UserID)
+	ClientID        string `json:"client_id,omitempty"`         // Отозваны токены стороннего приложения (вместе с UserID — только выданные от его имени)
+}
+
+// Subscriber получает события отзыва
+type Subscriber interface {
+	// Invalidate вызывается для каждого события отзыва
+	Invalidate(event Event)
+	// Flush вызывается, когда события могли быть пропущены (например, при переподключении к каналу)
+	Flush()
+}
+
+// Bus доставляет события отзыва подписчикам этого и остальных экземпляров сервиса
+type Bus interface {
+	Subscribe(sub Subscriber)
+	// Publish сразу доставляет событие подписчикам этого экземпляра и отправляет его остальным
+	Publish(ctx context.Context, event Event) error
+	// Run принимает события остальных экземпляров до отмены контекста
+	Run(ctx context.Context)
+}
+
+// New создает шину событий отзыва по способу доставки из конфигурации
+func New(cfg *config.ApiServer, pool *pgxpool.Pool, logger *slog.Logger) (Bus, error) {
+	switch cfg.TokenRevocationBackend {
+	case BackendLocal:
+		return NewLocalBus(), nil
+	case BackendPostgres:
+		return NewPostgresBus(pool, logger), nil
+	case BackendRedis:
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis url: %w", err)
+		}
+		return NewRedisBus(redis.NewClient(opts), logger), nil
+	default:
+		return nil, fmt.Errorf("unknown token revocation backend: %s", cfg.TokenRevocationBackend)
+	}
+}
+
+// subscribers подписчики шины этого экземпляра
+type subscribers struct {
+	mu   sync.RWMutex
+	subs []Subscriber
+}
+
+func (s *subscribers) Subscribe(sub Subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, sub)
+}
+
+func (s *subscribers) invalidate(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.subs {
+		sub.Invalidate(event)
+	}
+}
+
+func (s *subscribers) flush() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.subs {
+		sub.Flush()
+	}
+}
+
+// decode разбирает событие, полученное от другого экземпляра
+func decode(payload []byte) (Event, error) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("invalid revocation event: %w", err)
+	}
+	return event, nil
+}
+
+// sleep ждет перед повторным подключением и возвращает false, если контекст отменен
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// LocalBus доставляет события только подписчикам своего экземпляра. Подходит, если сервис запущен в одном экземпляре
+type LocalBus struct {
+	subscribers
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish доставляет событие подписчикам
+func (b *LocalBus) Publish(ctx context.Context, event Event) error {
+	b.invalidate(event)
+	return nil
+}
+
+// Run ничего не делает: других экземпляров нет
+func (b *LocalBus) Run(ctx context.Context) {}
@@ -0,0 +1,152 @@
+package tokencache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"user-management/internal/pkg/metrics"
+	"user-management/internal/pkg/revocation"
+)
+
+// Entry результат проверки access токена в базе данных
+type Entry struct {
+	Valid     bool
+	UserID    int
+	SessionID int64
+	ClientID  string // Публичный идентификатор приложения для токенов сторонних приложений
+}
+
+// item элемент кэша
+type item struct {
+	key       string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// Cache кэш результатов проверки access токенов ограниченного размера со сроком жизни записей.
+// При переполнении вытесняются давно не использованные записи. Кэш подписывается на события отзыва
+// и удаляет затронутые ими записи
+type Cache struct {
+	size int
+	ttl  time.Duration
+
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List // От недавно использованных к давно не использованным
+	generation uint64     // Увеличивается при каждом удалении записей по событию отзыва
+}
+
+// New создает кэш на size записей. Нулевой размер или срок жизни отключают кэширование
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Get возвращает действующую запись по ключу (хэшу идентификатора токена)
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		metrics.TokenCacheMisses.Add(1)
+		return Entry{}, false
+	}
+
+	it := elem.Value.(*item)
+	if time.Now().After(it.expiresAt) {
+		c.remove(elem)
+		metrics.TokenCacheMisses.Add(1)
+		return Entry{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	metrics.TokenCacheHits.Add(1)
+	return it.entry, true
+}
+
+// Generation возвращает номер поколения кэша. Его нужно получить до обращения к базе данных и передать в Set
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set сохраняет запись, если с момента получения generation не было событий отзыва: иначе результат,
+// прочитанный из базы данных до отзыва, мог устареть
+func (c *Cache) Set(key string, entry Entry, generation uint64) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &item{key: key, entry: entry, expiresAt: expiresAt}
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&item{key: key, entry: entry, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		metrics.TokenCacheEvictions.Add(1)
+	}
+}
+
+// Invalidate удаляет записи токенов, затронутых событием отзыва
+func (c *Cache) Invalidate(event revocation.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	metrics.TokenCacheInvalidations.Add(1)
+
+	if event.TokenHash != "" {
+		if elem, ok := c.items[event.TokenHash]; ok {
+			c.remove(elem)
+		}
+	}
+	if event.SessionID == 0 && event.UserID == 0 && event.ClientID == "" {
+		return
+	}
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*item).entry
+		if (event.SessionID != 0 && entry.SessionID == event.SessionID) ||
+			(event.UserID != 0 && entry.UserID == event.UserID && (event.ExceptSessionID == 0 || entry.SessionID != event.ExceptSessionID) &&
+				(event.ClientID == "" || entry.ClientID == event.ClientID)) ||
+			(event.UserID == 0 && event.ClientID != "" && entry.ClientID == event.ClientID) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Flush удаляет все записи
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// remove удаляет элемент. Вызывается под блокировкой
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*item).key)
+}
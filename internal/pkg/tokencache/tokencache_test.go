@@ -0,0 +1,121 @@
+package tokencache
+
+import (
+	"testing"
+	"time"
+
+	"user-management/internal/pkg/revocation"
+)
+
+// Записи кэша: токены двух сессий пользователя 1, токен приложения от его имени и токен пользователя 2
+var testEntries = map[string]Entry{
+	"session-1": {Valid: true, UserID: 1, SessionID: 10},
+	"session-2": {Valid: true, UserID: 1, SessionID: 11},
+	"client":    {Valid: true, UserID: 1, ClientID: "tracker"},
+	"other":     {Valid: true, UserID: 2, SessionID: 20},
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       revocation.Event
+		wantRemoved []string
+	}{
+		{name: "token", event: revocation.Event{TokenHash: "session-1"}, wantRemoved: []string{"session-1"}},
+		{name: "session", event: revocation.Event{SessionID: 11}, wantRemoved: []string{"session-2"}},
+		{name: "user", event: revocation.Event{UserID: 1}, wantRemoved: []string{"session-1", "session-2", "client"}},
+		{name: "user except session", event: revocation.Event{UserID: 1, ExceptSessionID: 10}, wantRemoved: []string{"session-2", "client"}},
+		{name: "client of user", event: revocation.Event{UserID: 1, ClientID: "tracker"}, wantRemoved: []string{"client"}},
+		{name: "client", event: revocation.Event{ClientID: "tracker"}, wantRemoved: []string{"client"}},
+		{name: "unknown token", event: revocation.Event{TokenHash: "unknown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := New(len(testEntries), time.Minute)
+			for key, entry := range testEntries {
+				cache.Set(key, entry, cache.Generation())
+			}
+
+			cache.Invalidate(tt.event)
+
+			removed := make(map[string]bool)
+			for _, key := range tt.wantRemoved {
+				removed[key] = true
+			}
+			for key := range testEntries {
+				if _, ok := cache.Get(key); ok == removed[key] {
+					t.Errorf("entry %s cached = %v, want %v", key, ok, !removed[key])
+				}
+			}
+		})
+	}
+}
+
+func TestCacheStaleFill(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(c *Cache)
+	}{
+		{name: "invalidate", revoke: func(c *Cache) { c.Invalidate(revocation.Event{TokenHash: "token"}) }},
+		// Событие другого токена тоже отбрасывает запись: по номеру поколения не видно, кого затронул отзыв
+		{name: "invalidate another token", revoke: func(c *Cache) { c.Invalidate(revocation.Event{TokenHash: "another"}) }},
+		{name: "flush", revoke: func(c *Cache) { c.Flush() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := New(10, time.Minute)
+
+			// Запрос прочитал из базы данных «действителен» до отзыва, а сохраняет результат после него
+			generation := cache.Generation()
+			tt.revoke(cache)
+			cache.Set("token", Entry{Valid: true, UserID: 1}, generation)
+
+			if entry, ok := cache.Get("token"); ok {
+				t.Fatalf("stale fill cached %+v, want no entry", entry)
+			}
+
+			// Результат, прочитанный после отзыва, сохраняется
+			cache.Set("token", Entry{Valid: false, UserID: 1}, cache.Generation())
+			if entry, ok := cache.Get("token"); !ok || entry.Valid {
+				t.Errorf("Get after fresh fill = %+v, %v, want invalid entry", entry, ok)
+			}
+		})
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	t.Run("expired entry", func(t *testing.T) {
+		cache := New(10, time.Nanosecond)
+		cache.Set("token", Entry{Valid: true}, cache.Generation())
+		time.Sleep(time.Millisecond)
+
+		if _, ok := cache.Get("token"); ok {
+			t.Errorf("expired entry returned")
+		}
+	})
+
+	t.Run("least recently used evicted", func(t *testing.T) {
+		cache := New(2, time.Minute)
+		cache.Set("a", Entry{Valid: true}, cache.Generation())
+		cache.Set("b", Entry{Valid: true}, cache.Generation())
+		cache.Get("a")
+		cache.Set("c", Entry{Valid: true}, cache.Generation())
+
+		for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+			if _, ok := cache.Get(key); ok != want {
+				t.Errorf("entry %s cached = %v, want %v", key, ok, want)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cache := New(0, time.Minute)
+		cache.Set("token", Entry{Valid: true}, cache.Generation())
+
+		if _, ok := cache.Get("token"); ok {
+			t.Errorf("entry cached with zero size")
+		}
+	})
+}
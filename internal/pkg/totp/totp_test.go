@@ -0,0 +1,93 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret секрет тестовых векторов RFC 6238 (приложение B) для SHA-1: ASCII "12345678901234567890" в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// В RFC приведены 8-значные коды; 6-значный код — их последние 6 цифр
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	code := func(step int64) string { return mustCode(t, rfcSecret, step) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: code(current), wantStep: current, wantOK: true},
+		{name: "previous step within skew", secret: rfcSecret, code: code(current - Skew), wantStep: current - Skew, wantOK: true},
+		{name: "next step within skew", secret: rfcSecret, code: code(current + Skew), wantStep: current + Skew, wantOK: true},
+		{name: "lowercase secret", secret: strings.ToLower(rfcSecret), code: code(current), wantStep: current, wantOK: true},
+		{name: "too old", secret: rfcSecret, code: code(current - Skew - 1)},
+		{name: "too far ahead", secret: rfcSecret, code: code(current + Skew + 1)},
+		{name: "wrong length", secret: rfcSecret, code: code(current)[:Digits-1]},
+		{name: "invalid secret", secret: "not base32!", code: "123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != SecretSize {
+		t.Fatalf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, SecretSize)
+	}
+	if _, ok := Validate(secret, mustCode(t, secret, Step(time.Now())), time.Now()); !ok {
+		t.Errorf("code of generated secret is not accepted")
+	}
+}
+
+// mustCode вычисляет код для шага и прерывает тест при ошибке
+func mustCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := Code(secret, step)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	return code
+}
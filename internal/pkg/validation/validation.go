@@ -9,6 +9,9 @@ import (
 
 var Validate *validator.Validate
 
+// UsernamePattern регулярное выражение, которому должен соответствовать username
+const UsernamePattern = "^[a-zA-Z][a-zA-Z0-9]{5,14}$"
+
 func init() {
 	// Инициализируем валидатор
 	Validate = validator.New()
@@ -24,7 +27,6 @@ func init() {
 
 // validateUsername функция валидации username
 func validateUsername(fl validator.FieldLevel) bool {
-	pattern := "^[a-zA-Z][a-zA-Z0-9]{5,14}$"
-	match, _ := regexp.MatchString(pattern, fl.Field().String())
+	match, _ := regexp.MatchString(UsernamePattern, fl.Field().String())
 	return match
 }
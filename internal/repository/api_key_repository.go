@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListActiveAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID int64, ip string, interval time.Duration) error
+	RevokeAPIKey(ctx context.Context, userID int, keyID int64) error
+}
+
+type APIKeyRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool, logger *slog.Logger) *APIKeyRepo {
+	return &APIKeyRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryCreateAPIKey = `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+	queryListActiveAPIKeys = `SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at, last_used_ip
+		FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC`
+	queryGetActiveAPIKeyByHash = `SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at, last_used_ip
+		FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+	// Время последнего использования обновляется не чаще одного раза за интервал, чтобы не писать в базу на каждый запрос
+	queryTouchAPIKey = `UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - $3::INTERVAL OR last_used_ip IS DISTINCT FROM $2)`
+	queryRevokeAPIKey = `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+)
+
+// CreateAPIKey сохраняет ключ и заполняет его ID и время создания
+func (r *APIKeyRepo) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	r.logger.Info("Executing query", "query", queryCreateAPIKey, "user_id", key.UserID, "name", key.Name)
+
+	err := r.db.QueryRow(ctx, queryCreateAPIKey, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create api key", "error", err, "user_id", key.UserID)
+		return fmt.Errorf("CreateAPIKey: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("API key created", "user_id", key.UserID, "api_key_id", key.ID)
+	return nil
+}
+
+// ListActiveAPIKeys получение действующих ключей пользователя, начиная с последнего созданного
+func (r *APIKeyRepo) ListActiveAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	r.logger.Info("Executing query", "query", queryListActiveAPIKeys, "user_id", userID)
+
+	rows, err := r.db.Query(ctx, queryListActiveAPIKeys, userID)
+	if err != nil {
+		r.logger.Error("Failed to list api keys", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListActiveAPIKeys: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err = rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP); err != nil {
+			r.logger.Error("Failed to scan api key", "error", err, "user_id", userID)
+			return nil, fmt.Errorf("ListActiveAPIKeys: %w", ErrFailedExecuteQuery)
+		}
+		keys = append(keys, k)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate api keys", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListActiveAPIKeys: %w", ErrFailedExecuteQuery)
+	}
+
+	return keys, nil
+}
+
+// GetActiveAPIKeyByHash получение неотозванного и непросроченного ключа по хэшу
+func (r *APIKeyRepo) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var k models.APIKey
+
+	r.logger.Info("Executing query", "query", queryGetActiveAPIKeyByHash)
+	err := r.db.QueryRow(ctx, queryGetActiveAPIKeyByHash, keyHash).
+		Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("GetActiveAPIKeyByHash: %w", ErrAPIKeyNotFound)
+		}
+		r.logger.Error("Failed to get api key", "error", err)
+		return nil, fmt.Errorf("GetActiveAPIKeyByHash: %w", ErrFailedExecuteQuery)
+	}
+
+	return &k, nil
+}
+
+// TouchAPIKey обновляет время и IP адрес последнего использования ключа
+func (r *APIKeyRepo) TouchAPIKey(ctx context.Context, keyID int64, ip string, interval time.Duration) error {
+	r.logger.Info("Executing query", "query", queryTouchAPIKey, "api_key_id", keyID)
+
+	if _, err := r.db.Exec(ctx, queryTouchAPIKey, keyID, ip, interval); err != nil {
+		r.logger.Error("Failed to touch api key", "error", err, "api_key_id", keyID)
+		return fmt.Errorf("TouchAPIKey: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя
+func (r *APIKeyRepo) RevokeAPIKey(ctx context.Context, userID int, keyID int64) error {
+	r.logger.Info("Executing query", "query", queryRevokeAPIKey, "user_id", userID, "api_key_id", keyID)
+
+	result, err := r.db.Exec(ctx, queryRevokeAPIKey, keyID, userID)
+	if err != nil {
+		r.logger.Error("Failed to revoke api key", "error", err, "api_key_id", keyID)
+		return fmt.Errorf("RevokeAPIKey: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("Active api key not found", "user_id", userID, "api_key_id", keyID)
+		return fmt.Errorf("RevokeAPIKey: %w", ErrAPIKeyNotFound)
+	}
+
+	r.logger.Info("API key revoked", "user_id", userID, "api_key_id", keyID)
+	return nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityAlreadyLinked = errors.New("identity already linked")
+	ErrAuthRequestNotFound   = errors.New("oidc auth request not found")
+)
+
+// Уникальные индексы привязок: учетная запись провайдера принадлежит одному пользователю,
+// а у пользователя не больше одной учетной записи каждого провайдера
+const (
+	uniqueIdentitiesSubject      = "uq_user_identities_subject"
+	uniqueIdentitiesUserProvider = "uq_user_identities_user_provider"
+)
+
+type IdentityRepository interface {
+	CreateAuthRequest(ctx context.Context, req *models.OIDCAuthRequest) error
+	ConsumeAuthRequest(ctx context.Context, stateHash string) (*models.OIDCAuthRequest, error)
+	DeleteExpiredAuthRequests(ctx context.Context, limit int) (int64, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	CreateIdentityWithTx(ctx context.Context, tx pgx.Tx, identity *models.UserIdentity) error
+	TouchIdentity(ctx context.Context, identityID int64, email *string) error
+	ListIdentities(ctx context.Context, userID int) ([]models.UserIdentity, error)
+	CountIdentitiesWithTx(ctx context.Context, tx pgx.Tx, userID int) (int, error)
+	DeleteIdentityWithTx(ctx context.Context, tx pgx.Tx, userID int, provider string) error
+}
+
+type IdentityRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewIdentityRepo(db *pgxpool.Pool, logger *slog.Logger) *IdentityRepo {
+	return &IdentityRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryCreateAuthRequest = `INSERT INTO oidc_auth_requests (state_hash, provider, nonce, code_verifier, user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	// Запрос входа одноразовый: он удаляется при первом обратном вызове, даже если обмен кода затем не удастся
+	queryConsumeAuthRequest = `DELETE FROM oidc_auth_requests WHERE state_hash = $1
+		RETURNING id, state_hash, provider, nonce, code_verifier, user_id, created_at, expires_at`
+	queryDeleteExpiredAuthRequests = `DELETE FROM oidc_auth_requests WHERE id IN (
+		SELECT id FROM oidc_auth_requests WHERE expires_at < NOW() LIMIT $1 FOR UPDATE SKIP LOCKED)`
+	queryGetIdentity = `SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE provider = $1 AND subject = $2`
+	queryCreateIdentity = `INSERT INTO user_identities (user_id, provider, subject, email, last_login_at) VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at`
+	queryTouchIdentity  = `UPDATE user_identities SET last_login_at = NOW(), email = $2 WHERE id = $1`
+	queryListIdentities = `SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	queryCountIdentities = `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`
+	queryDeleteIdentity  = `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`
+)
+
+// CreateAuthRequest сохраняет начатый вход через внешнего провайдера
+func (r *IdentityRepo) CreateAuthRequest(ctx context.Context, req *models.OIDCAuthRequest) error {
+	r.logger.Info("Executing query", "query", queryCreateAuthRequest, "provider", req.Provider)
+
+	_, err := r.db.Exec(ctx, queryCreateAuthRequest, req.StateHash, req.Provider, req.Nonce, req.CodeVerifier, req.UserID, req.ExpiresAt)
+	if err != nil {
+		r.logger.Error("Failed to create oidc auth request", "error", err, "provider", req.Provider)
+		return fmt.Errorf("CreateAuthRequest: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// ConsumeAuthRequest удаляет запрос входа по хэшу state и возвращает его. Срок действия проверяет вызывающий
+func (r *IdentityRepo) ConsumeAuthRequest(ctx context.Context, stateHash string) (*models.OIDCAuthRequest, error) {
+	var req models.OIDCAuthRequest
+
+	r.logger.Info("Executing query", "query", queryConsumeAuthRequest)
+	err := r.db.QueryRow(ctx, queryConsumeAuthRequest, stateHash).
+		Scan(&req.ID, &req.StateHash, &req.Provider, &req.Nonce, &req.CodeVerifier, &req.UserID, &req.CreatedAt, &req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("ConsumeAuthRequest: %w", ErrAuthRequestNotFound)
+		}
+		r.logger.Error("Failed to consume oidc auth request", "error", err)
+		return nil, fmt.Errorf("ConsumeAuthRequest: %w", ErrFailedExecuteQuery)
+	}
+
+	return &req, nil
+}
+
+// DeleteExpiredAuthRequests удаляет не больше limit просроченных запросов входа и возвращает число удаленных строк
+func (r *IdentityRepo) DeleteExpiredAuthRequests(ctx context.Context, limit int) (int64, error) {
+	r.logger.Info("Executing query", "query", queryDeleteExpiredAuthRequests, "limit", limit)
+
+	result, err := r.db.Exec(ctx, queryDeleteExpiredAuthRequests, limit)
+	if err != nil {
+		r.logger.Error("Failed to delete expired oidc auth requests", "error", err)
+		return 0, fmt.Errorf("DeleteExpiredAuthRequests: %w", ErrFailedExecuteQuery)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetIdentity получение привязки по учетной записи провайдера
+func (r *IdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	r.logger.Info("Executing query", "query", queryGetIdentity, "provider", provider)
+
+	identity, err := scanIdentity(r.db.QueryRow(ctx, queryGetIdentity, provider, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("GetIdentity: %w", ErrIdentityNotFound)
+		}
+		r.logger.Error("Failed to get identity", "error", err, "provider", provider)
+		return nil, fmt.Errorf("GetIdentity: %w", ErrFailedExecuteQuery)
+	}
+
+	return identity, nil
+}
+
+// CreateIdentityWithTx привязывает учетную запись провайдера к пользователю и заполняет ID и время привязки
+func (r *IdentityRepo) CreateIdentityWithTx(ctx context.Context, tx pgx.Tx, identity *models.UserIdentity) error {
+	r.logger.Info("Executing query", "query", queryCreateIdentity, "user_id", identity.UserID, "provider", identity.Provider)
+
+	err := tx.QueryRow(ctx, queryCreateIdentity, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err, uniqueIdentitiesSubject) || isUniqueViolation(err, uniqueIdentitiesUserProvider) {
+			r.logger.Info("Identity already linked", "user_id", identity.UserID, "provider", identity.Provider)
+			return fmt.Errorf("CreateIdentityWithTx: %w", ErrIdentityAlreadyLinked)
+		}
+		r.logger.Error("Failed to create identity", "error", err, "user_id", identity.UserID)
+		return fmt.Errorf("CreateIdentityWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Identity linked", "user_id", identity.UserID, "provider", identity.Provider)
+	return nil
+}
+
+// TouchIdentity обновляет время последнего входа и адрес, сообщенный провайдером
+func (r *IdentityRepo) TouchIdentity(ctx context.Context, identityID int64, email *string) error {
+	r.logger.Info("Executing query", "query", queryTouchIdentity, "identity_id", identityID)
+
+	if _, err := r.db.Exec(ctx, queryTouchIdentity, identityID, email); err != nil {
+		r.logger.Error("Failed to touch identity", "error", err, "identity_id", identityID)
+		return fmt.Errorf("TouchIdentity: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// ListIdentities получение привязанных учетных записей пользователя
+func (r *IdentityRepo) ListIdentities(ctx context.Context, userID int) ([]models.UserIdentity, error) {
+	r.logger.Info("Executing query", "query", queryListIdentities, "user_id", userID)
+
+	rows, err := r.db.Query(ctx, queryListIdentities, userID)
+	if err != nil {
+		r.logger.Error("Failed to list identities", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListIdentities: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan identity", "error", err, "user_id", userID)
+			return nil, fmt.Errorf("ListIdentities: %w", ErrFailedExecuteQuery)
+		}
+		identities = append(identities, *identity)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate identities", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListIdentities: %w", ErrFailedExecuteQuery)
+	}
+
+	return identities, nil
+}
+
+// CountIdentitiesWithTx возвращает число привязанных учетных записей пользователя
+func (r *IdentityRepo) CountIdentitiesWithTx(ctx context.Context, tx pgx.Tx, userID int) (int, error) {
+	var count int
+
+	r.logger.Info("Executing query", "query", queryCountIdentities, "user_id", userID)
+	if err := tx.QueryRow(ctx, queryCountIdentities, userID).Scan(&count); err != nil {
+		r.logger.Error("Failed to count identities", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("CountIdentitiesWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	return count, nil
+}
+
+// DeleteIdentityWithTx отвязывает учетную запись провайдера от пользователя
+func (r *IdentityRepo) DeleteIdentityWithTx(ctx context.Context, tx pgx.Tx, userID int, provider string) error {
+	r.logger.Info("Executing query", "query", queryDeleteIdentity, "user_id", userID, "provider", provider)
+
+	result, err := tx.Exec(ctx, queryDeleteIdentity, userID, provider)
+	if err != nil {
+		r.logger.Error("Failed to delete identity", "error", err, "user_id", userID)
+		return fmt.Errorf("DeleteIdentityWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteIdentityWithTx: %w", ErrIdentityNotFound)
+	}
+
+	r.logger.Info("Identity unlinked", "user_id", userID, "provider", provider)
+	return nil
+}
+
+// scanIdentity считывает строку таблицы user_identities
+func scanIdentity(row pgx.Row) (*models.UserIdentity, error) {
+	var i models.UserIdentity
+	if err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LedgerRepository interface {
+	GetPointTransactions(ctx context.Context, userID, limit, offset int) ([]models.PointTransaction, error)
+	CountPointTransactions(ctx context.Context, userID int) (int, error)
+	GetBalanceMismatches(ctx context.Context) ([]dto.BalanceMismatchDTO, error)
+}
+
+type LedgerRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewLedgerRepo(db *pgxpool.Pool, logger *slog.Logger) *LedgerRepo {
+	return &LedgerRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryGetPointTransactions = `SELECT id, user_id, amount, balance_after, reason, reference_id, created_at
+		FROM point_transactions WHERE user_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	queryCountPointTransactions = `SELECT COUNT(*) FROM point_transactions WHERE user_id = $1`
+	queryGetBalanceMismatches   = `SELECT u.id, u.balance, COALESCE(SUM(pt.amount), 0) AS ledger_sum
+		FROM users u LEFT JOIN point_transactions pt ON pt.user_id = u.id
+		GROUP BY u.id, u.balance
+		HAVING u.balance <> COALESCE(SUM(pt.amount), 0)
+		ORDER BY u.id`
+)
+
+// GetPointTransactions получение страницы истории операций пользователя
+func (r *LedgerRepo) GetPointTransactions(ctx context.Context, userID, limit, offset int) ([]models.PointTransaction, error) {
+	transactions := make([]models.PointTransaction, 0, limit)
+
+	r.logger.Info("Executing query", "query", queryGetPointTransactions, "user_id", userID, "limit", limit, "offset", offset)
+	rows, err := r.db.Query(ctx, queryGetPointTransactions, userID, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to execute query to get point transactions", "error", err, "user_id", userID)
+		return transactions, fmt.Errorf("GetPointTransactions: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pt models.PointTransaction
+		if err = rows.Scan(&pt.ID, &pt.UserID, &pt.Amount, &pt.BalanceAfter, &pt.Reason, &pt.ReferenceID, &pt.CreatedAt); err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return transactions, fmt.Errorf("GetPointTransactions: failed to parse rows: %w", err)
+		}
+		transactions = append(transactions, pt)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return transactions, fmt.Errorf("GetPointTransactions: error during rows iteration: %w", err)
+	}
+
+	r.logger.Info("Point transactions gotten", "user_id", userID, "count", len(transactions))
+	return transactions, nil
+}
+
+// CountPointTransactions получение общего количества операций пользователя
+func (r *LedgerRepo) CountPointTransactions(ctx context.Context, userID int) (int, error) {
+	var total int
+
+	r.logger.Info("Executing query", "query", queryCountPointTransactions, "user_id", userID)
+	err := r.db.QueryRow(ctx, queryCountPointTransactions, userID).Scan(&total)
+	if err != nil {
+		r.logger.Error("Failed to execute query to count point transactions", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("CountPointTransactions: %w", ErrFailedExecuteQuery)
+	}
+
+	return total, nil
+}
+
+// GetBalanceMismatches получение пользователей, чей баланс не совпадает с суммой операций в журнале
+func (r *LedgerRepo) GetBalanceMismatches(ctx context.Context) ([]dto.BalanceMismatchDTO, error) {
+	mismatches := make([]dto.BalanceMismatchDTO, 0)
+
+	r.logger.Info("Executing query", "query", queryGetBalanceMismatches)
+	rows, err := r.db.Query(ctx, queryGetBalanceMismatches)
+	if err != nil {
+		r.logger.Error("Failed to execute query to get balance mismatches", "error", err)
+		return mismatches, fmt.Errorf("GetBalanceMismatches: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m dto.BalanceMismatchDTO
+		if err = rows.Scan(&m.UserID, &m.Balance, &m.LedgerSum); err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return mismatches, fmt.Errorf("GetBalanceMismatches: failed to parse rows: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return mismatches, fmt.Errorf("GetBalanceMismatches: error during rows iteration: %w", err)
+	}
+
+	r.logger.Info("Balance reconciliation finished", "mismatches", len(mismatches))
+	return mismatches, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoginAttemptRepository interface {
+	GetLockedUntil(ctx context.Context, scope models.LoginAttemptScope, subject string) (*time.Time, error)
+	RecordFailure(ctx context.Context, scope models.LoginAttemptScope, subject string, window time.Duration) (int, error)
+	Lock(ctx context.Context, scope models.LoginAttemptScope, subject string, until time.Time) error
+	Reset(ctx context.Context, scope models.LoginAttemptScope, subject string) error
+}
+
+type LoginAttemptRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewLoginAttemptRepo(db *pgxpool.Pool, logger *slog.Logger) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryGetLockedUntil = `SELECT locked_until FROM login_attempts WHERE scope = $1 AND subject = $2 AND locked_until > NOW()`
+	// Если неудачных попыток не было дольше окна учета (считая от конца последней блокировки), счет начинается заново
+	queryRecordLoginFailure = `INSERT INTO login_attempts (scope, subject, failures, last_failure_at) VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			failures = CASE WHEN GREATEST(login_attempts.last_failure_at, login_attempts.locked_until) < NOW() - $3::INTERVAL THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = NOW()
+		RETURNING failures`
+	queryLockLogin  = `UPDATE login_attempts SET locked_until = $3 WHERE scope = $1 AND subject = $2`
+	queryResetLogin = `DELETE FROM login_attempts WHERE scope = $1 AND subject = $2`
+)
+
+// GetLockedUntil возвращает время окончания действующей блокировки или nil, если блокировки нет
+func (r *LoginAttemptRepo) GetLockedUntil(ctx context.Context, scope models.LoginAttemptScope, subject string) (*time.Time, error) {
+	var lockedUntil time.Time
+
+	r.logger.Info("Executing query", "query", queryGetLockedUntil, "scope", scope, "subject", subject)
+	err := r.db.QueryRow(ctx, queryGetLockedUntil, scope, subject).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get login lock", "error", err, "scope", scope, "subject", subject)
+		return nil, fmt.Errorf("GetLockedUntil: %w", ErrFailedExecuteQuery)
+	}
+
+	return &lockedUntil, nil
+}
+
+// RecordFailure засчитывает неудачную попытку входа и возвращает число неудачных попыток подряд
+func (r *LoginAttemptRepo) RecordFailure(ctx context.Context, scope models.LoginAttemptScope, subject string, window time.Duration) (int, error) {
+	var failures int
+
+	r.logger.Info("Executing query", "query", queryRecordLoginFailure, "scope", scope, "subject", subject)
+	err := r.db.QueryRow(ctx, queryRecordLoginFailure, scope, subject, window).Scan(&failures)
+	if err != nil {
+		r.logger.Error("Failed to record login failure", "error", err, "scope", scope, "subject", subject)
+		return 0, fmt.Errorf("RecordFailure: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Login failure recorded", "scope", scope, "subject", subject, "failures", failures)
+	return failures, nil
+}
+
+// Lock блокирует вход до указанного времени
+func (r *LoginAttemptRepo) Lock(ctx context.Context, scope models.LoginAttemptScope, subject string, until time.Time) error {
+	r.logger.Info("Executing query", "query", queryLockLogin, "scope", scope, "subject", subject, "locked_until", until)
+
+	if _, err := r.db.Exec(ctx, queryLockLogin, scope, subject, until); err != nil {
+		r.logger.Error("Failed to lock login", "error", err, "scope", scope, "subject", subject)
+		return fmt.Errorf("Lock: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Warn("Login locked", "scope", scope, "subject", subject, "locked_until", until)
+	return nil
+}
+
+// Reset сбрасывает счетчик неудачных попыток и снимает блокировку
+func (r *LoginAttemptRepo) Reset(ctx context.Context, scope models.LoginAttemptScope, subject string) error {
+	r.logger.Info("Executing query", "query", queryResetLogin, "scope", scope, "subject", subject)
+
+	if _, err := r.db.Exec(ctx, queryResetLogin, scope, subject); err != nil {
+		r.logger.Error("Failed to reset login attempts", "error", err, "scope", scope, "subject", subject)
+		return fmt.Errorf("Reset: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
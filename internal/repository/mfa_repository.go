@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrMFANotFound          = errors.New("mfa not enrolled")
+	ErrMFAAlreadyConfirmed  = errors.New("mfa already confirmed")
+	ErrMFAStepUsed          = errors.New("totp code already used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrMFAChallengeNotFound = errors.New("mfa challenge not found")
+)
+
+type MFARepository interface {
+	SaveTOTPSecret(ctx context.Context, userID int, secret string) error
+	GetMFA(ctx context.Context, userID int) (*models.UserMFA, error)
+	ConfirmTOTPWithTx(ctx context.Context, tx pgx.Tx, userID int, step int64) error
+	ReplaceRecoveryCodesWithTx(ctx context.Context, tx pgx.Tx, userID int, codeHashes []string) error
+	UseTOTPStepWithTx(ctx context.Context, tx pgx.Tx, userID int, step int64) error
+	UseRecoveryCodeWithTx(ctx context.Context, tx pgx.Tx, userID int, codeHash string) error
+	CreateChallenge(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) (int64, int, error)
+	CompleteChallengeWithTx(ctx context.Context, tx pgx.Tx, challengeID int64) error
+}
+
+type MFARepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewMFARepo(db *pgxpool.Pool, logger *slog.Logger) *MFARepo {
+	return &MFARepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	// Неподтвержденный секрет заменяется при повторном подключении, подтвержденный не изменяется
+	querySaveTOTPSecret = `INSERT INTO user_mfa (user_id, totp_secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET totp_secret = EXCLUDED.totp_secret, last_used_step = NULL, created_at = NOW()
+		WHERE user_mfa.confirmed_at IS NULL`
+	queryGetMFA              = `SELECT user_id, totp_secret, confirmed_at, last_used_step, created_at FROM user_mfa WHERE user_id = $1`
+	queryConfirmTOTP         = `UPDATE user_mfa SET confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`
+	queryDeleteRecoveryCodes = `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+	queryInsertRecoveryCode  = `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	queryUseTOTPStep         = `UPDATE user_mfa SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND (last_used_step IS NULL OR last_used_step < $2)`
+	queryUseRecoveryCode = `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	queryCreateChallenge = `INSERT INTO mfa_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	// Попытка засчитывается до проверки кода, поэтому перебор ограничен даже при откате транзакции проверки
+	queryAttemptChallenge = `UPDATE mfa_challenges SET attempts = attempts + 1
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < $2
+		RETURNING id, user_id`
+	queryCompleteChallenge = `UPDATE mfa_challenges SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+)
+
+// SaveTOTPSecret сохраняет новый, еще не подтвержденный секрет TOTP пользователя
+func (r *MFARepo) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	r.logger.Info("Executing query", "query", querySaveTOTPSecret, "user_id", userID)
+
+	result, err := r.db.Exec(ctx, querySaveTOTPSecret, userID, secret)
+	if err != nil {
+		r.logger.Error("Failed to save totp secret", "error", err, "user_id", userID)
+		return fmt.Errorf("SaveTOTPSecret: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("MFA already confirmed", "user_id", userID)
+		return fmt.Errorf("SaveTOTPSecret: %w", ErrMFAAlreadyConfirmed)
+	}
+
+	r.logger.Info("TOTP secret saved", "user_id", userID)
+	return nil
+}
+
+// GetMFA получение настроек второго фактора пользователя
+func (r *MFARepo) GetMFA(ctx context.Context, userID int) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+
+	r.logger.Info("Executing query", "query", queryGetMFA, "user_id", userID)
+	err := r.db.QueryRow(ctx, queryGetMFA, userID).Scan(&mfa.UserID, &mfa.TOTPSecret, &mfa.ConfirmedAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Info("MFA not enrolled", "user_id", userID)
+			return nil, fmt.Errorf("GetMFA: %w", ErrMFANotFound)
+		}
+		r.logger.Error("Failed to get mfa", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("GetMFA: %w", ErrFailedExecuteQuery)
+	}
+
+	return &mfa, nil
+}
+
+// ConfirmTOTPWithTx подтверждение подключения TOTP с запоминанием шага кода, которым оно подтверждено
+func (r *MFARepo) ConfirmTOTPWithTx(ctx context.Context, tx pgx.Tx, userID int, step int64) error {
+	r.logger.Info("Executing query", "query", queryConfirmTOTP, "user_id", userID)
+
+	result, err := tx.Exec(ctx, queryConfirmTOTP, userID, step)
+	if err != nil {
+		r.logger.Error("Failed to confirm totp", "error", err, "user_id", userID)
+		return fmt.Errorf("ConfirmTOTPWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("MFA already confirmed", "user_id", userID)
+		return fmt.Errorf("ConfirmTOTPWithTx: %w", ErrMFAAlreadyConfirmed)
+	}
+
+	r.logger.Info("TOTP confirmed", "user_id", userID)
+	return nil
+}
+
+// ReplaceRecoveryCodesWithTx заменяет коды восстановления пользователя новыми
+func (r *MFARepo) ReplaceRecoveryCodesWithTx(ctx context.Context, tx pgx.Tx, userID int, codeHashes []string) error {
+	r.logger.Info("Executing query", "query", queryDeleteRecoveryCodes, "user_id", userID)
+	if _, err := tx.Exec(ctx, queryDeleteRecoveryCodes, userID); err != nil {
+		r.logger.Error("Failed to delete recovery codes", "error", err, "user_id", userID)
+		return fmt.Errorf("ReplaceRecoveryCodesWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	batch := &pgx.Batch{}
+	for _, codeHash := range codeHashes {
+		batch.Queue(queryInsertRecoveryCode, userID, codeHash)
+	}
+
+	r.logger.Info("Executing query", "query", queryInsertRecoveryCode, "user_id", userID, "count", len(codeHashes))
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		r.logger.Error("Failed to insert recovery codes", "error", err, "user_id", userID)
+		return fmt.Errorf("ReplaceRecoveryCodesWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Recovery codes replaced", "user_id", userID)
+	return nil
+}
+
+// UseTOTPStepWithTx запоминает шаг использованного кода. Код того же или более раннего шага повторно не принимается
+func (r *MFARepo) UseTOTPStepWithTx(ctx context.Context, tx pgx.Tx, userID int, step int64) error {
+	r.logger.Info("Executing query", "query", queryUseTOTPStep, "user_id", userID)
+
+	result, err := tx.Exec(ctx, queryUseTOTPStep, userID, step)
+	if err != nil {
+		r.logger.Error("Failed to use totp step", "error", err, "user_id", userID)
+		return fmt.Errorf("UseTOTPStepWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("TOTP code already used", "user_id", userID)
+		return fmt.Errorf("UseTOTPStepWithTx: %w", ErrMFAStepUsed)
+	}
+
+	return nil
+}
+
+// UseRecoveryCodeWithTx погашает неиспользованный код восстановления
+func (r *MFARepo) UseRecoveryCodeWithTx(ctx context.Context, tx pgx.Tx, userID int, codeHash string) error {
+	r.logger.Info("Executing query", "query", queryUseRecoveryCode, "user_id", userID)
+
+	result, err := tx.Exec(ctx, queryUseRecoveryCode, userID, codeHash)
+	if err != nil {
+		r.logger.Error("Failed to use recovery code", "error", err, "user_id", userID)
+		return fmt.Errorf("UseRecoveryCodeWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("Recovery code not found", "user_id", userID)
+		return fmt.Errorf("UseRecoveryCodeWithTx: %w", ErrRecoveryCodeNotFound)
+	}
+
+	r.logger.Info("Recovery code used", "user_id", userID)
+	return nil
+}
+
+// CreateChallenge сохраняет хэш токена запроса второго фактора
+func (r *MFARepo) CreateChallenge(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	r.logger.Info("Executing query", "query", queryCreateChallenge, "user_id", userID, "expires_at", expiresAt)
+
+	if _, err := r.db.Exec(ctx, queryCreateChallenge, userID, tokenHash, expiresAt); err != nil {
+		r.logger.Error("Failed to create mfa challenge", "error", err, "user_id", userID)
+		return fmt.Errorf("CreateChallenge: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("MFA challenge created", "user_id", userID)
+	return nil
+}
+
+// AttemptChallenge засчитывает попытку ввода кода для действующего запроса и возвращает ID запроса и пользователя
+func (r *MFARepo) AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) (int64, int, error) {
+	var (
+		challengeID int64
+		userID      int
+	)
+
+	r.logger.Info("Executing query", "query", queryAttemptChallenge)
+	err := r.db.QueryRow(ctx, queryAttemptChallenge, tokenHash, maxAttempts).Scan(&challengeID, &userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Info("Active mfa challenge not found")
+			return 0, 0, fmt.Errorf("AttemptChallenge: %w", ErrMFAChallengeNotFound)
+		}
+		r.logger.Error("Failed to attempt mfa challenge", "error", err)
+		return 0, 0, fmt.Errorf("AttemptChallenge: %w", ErrFailedExecuteQuery)
+	}
+
+	return challengeID, userID, nil
+}
+
+// CompleteChallengeWithTx отмечает запрос второго фактора как выполненный
+func (r *MFARepo) CompleteChallengeWithTx(ctx context.Context, tx pgx.Tx, challengeID int64) error {
+	r.logger.Info("Executing query", "query", queryCompleteChallenge, "challenge_id", challengeID)
+
+	result, err := tx.Exec(ctx, queryCompleteChallenge, challengeID)
+	if err != nil {
+		r.logger.Error("Failed to complete mfa challenge", "error", err, "challenge_id", challengeID)
+		return fmt.Errorf("CompleteChallengeWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("MFA challenge already completed", "challenge_id", challengeID)
+		return fmt.Errorf("CompleteChallengeWithTx: %w", ErrMFAChallengeNotFound)
+	}
+
+	r.logger.Info("MFA challenge completed", "challenge_id", challengeID)
+	return nil
+}
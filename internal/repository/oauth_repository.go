@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOAuthClientNotFound  = errors.New("oauth client not found")
+	ErrOAuthConsentNotFound = errors.New("oauth consent not found")
+	ErrOAuthCodeNotFound    = errors.New("oauth authorization code not found")
+)
+
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	GetClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	DeleteClient(ctx context.Context, clientID string) error
+	GetConsent(ctx context.Context, userID int, clientID int64) (*models.OAuthConsent, error)
+	SaveConsent(ctx context.Context, userID int, clientID int64, scopes []string) error
+	ListConsents(ctx context.Context, userID int) ([]models.OAuthConsent, error)
+	DeleteConsentWithTx(ctx context.Context, tx pgx.Tx, userID int, clientID int64) error
+	CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.OAuthAuthorizationCode, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context, limit int) (int64, error)
+}
+
+type OAuthRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewOAuthRepo(db *pgxpool.Pool, logger *slog.Logger) *OAuthRepo {
+	return &OAuthRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryCreateOAuthClient = `INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris, grant_types, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	queryListOAuthClients = `SELECT id, client_id, secret_hash, name, redirect_uris, grant_types, scopes, created_by, created_at
+		FROM oauth_clients ORDER BY created_at DESC`
+	queryGetOAuthClient = `SELECT id, client_id, secret_hash, name, redirect_uris, grant_types, scopes, created_by, created_at
+		FROM oauth_clients WHERE client_id = $1`
+	queryDeleteOAuthClient = `DELETE FROM oauth_clients WHERE client_id = $1`
+
+	queryGetOAuthConsent = `SELECT c.id, c.user_id, c.client_id, oc.client_id, oc.name, c.scopes, c.created_at, c.updated_at
+		FROM oauth_consents c JOIN oauth_clients oc ON oc.id = c.client_id WHERE c.user_id = $1 AND c.client_id = $2`
+	// Повторное согласие расширяет ранее переданные права, а не заменяет их
+	querySaveOAuthConsent = `INSERT INTO oauth_consents (user_id, client_id, scopes) VALUES ($1, $2, $3)
+		ON CONFLICT ON CONSTRAINT uq_oauth_consents_user_client DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT s FROM unnest(oauth_consents.scopes || EXCLUDED.scopes) AS s ORDER BY s), updated_at = NOW()`
+	queryListOAuthConsents = `SELECT c.id, c.user_id, c.client_id, oc.client_id, oc.name, c.scopes, c.created_at, c.updated_at
+		FROM oauth_consents c JOIN oauth_clients oc ON oc.id = c.client_id WHERE c.user_id = $1 ORDER BY c.created_at`
+	queryDeleteOAuthConsent = `DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`
+	// Коды, выданные до отзыва согласия, не должны обмениваться на токены после него
+	queryDeleteUserClientCodes = `DELETE FROM oauth_authorization_codes WHERE user_id = $1 AND client_id = $2`
+
+	queryCreateOAuthCode = `INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	// Код одноразовый: он удаляется при первом предъявлении, даже если обмен затем не удастся
+	queryConsumeOAuthCode = `DELETE FROM oauth_authorization_codes WHERE code_hash = $1
+		RETURNING id, code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at`
+	queryDeleteExpiredOAuthCodes = `DELETE FROM oauth_authorization_codes WHERE id IN (
+		SELECT id FROM oauth_authorization_codes WHERE expires_at < NOW() LIMIT $1 FOR UPDATE SKIP LOCKED)`
+)
+
+// CreateClient сохраняет приложение и заполняет его ID и время регистрации
+func (r *OAuthRepo) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	r.logger.Info("Executing query", "query", queryCreateOAuthClient, "client_id", client.ClientID, "name", client.Name)
+
+	err := r.db.QueryRow(ctx, queryCreateOAuthClient, client.ClientID, client.SecretHash, client.Name, client.RedirectURIs,
+		client.GrantTypes, client.Scopes, client.CreatedBy).Scan(&client.ID, &client.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create oauth client", "error", err, "client_id", client.ClientID)
+		return fmt.Errorf("CreateClient: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("OAuth client created", "id", client.ID, "client_id", client.ClientID)
+	return nil
+}
+
+// ListClients получение зарегистрированных приложений, начиная с последнего
+func (r *OAuthRepo) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	r.logger.Info("Executing query", "query", queryListOAuthClients)
+
+	rows, err := r.db.Query(ctx, queryListOAuthClients)
+	if err != nil {
+		r.logger.Error("Failed to list oauth clients", "error", err)
+		return nil, fmt.Errorf("ListClients: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan oauth client", "error", err)
+			return nil, fmt.Errorf("ListClients: %w", ErrFailedExecuteQuery)
+		}
+		clients = append(clients, *client)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate oauth clients", "error", err)
+		return nil, fmt.Errorf("ListClients: %w", ErrFailedExecuteQuery)
+	}
+
+	return clients, nil
+}
+
+// GetClientByClientID получение приложения по публичному идентификатору
+func (r *OAuthRepo) GetClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	r.logger.Info("Executing query", "query", queryGetOAuthClient, "client_id", clientID)
+
+	client, err := scanOAuthClient(r.db.QueryRow(ctx, queryGetOAuthClient, clientID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("GetClientByClientID: %w", ErrOAuthClientNotFound)
+		}
+		r.logger.Error("Failed to get oauth client", "error", err, "client_id", clientID)
+		return nil, fmt.Errorf("GetClientByClientID: %w", ErrFailedExecuteQuery)
+	}
+
+	return client, nil
+}
+
+// DeleteClient удаляет приложение вместе с его согласиями, кодами и токенами
+func (r *OAuthRepo) DeleteClient(ctx context.Context, clientID string) error {
+	r.logger.Info("Executing query", "query", queryDeleteOAuthClient, "client_id", clientID)
+
+	result, err := r.db.Exec(ctx, queryDeleteOAuthClient, clientID)
+	if err != nil {
+		r.logger.Error("Failed to delete oauth client", "error", err, "client_id", clientID)
+		return fmt.Errorf("DeleteClient: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteClient: %w", ErrOAuthClientNotFound)
+	}
+
+	r.logger.Info("OAuth client deleted", "client_id", clientID)
+	return nil
+}
+
+// GetConsent получение согласия пользователя на доступ приложения
+func (r *OAuthRepo) GetConsent(ctx context.Context, userID int, clientID int64) (*models.OAuthConsent, error) {
+	r.logger.Info("Executing query", "query", queryGetOAuthConsent, "user_id", userID, "client_id", clientID)
+
+	consent, err := scanOAuthConsent(r.db.QueryRow(ctx, queryGetOAuthConsent, userID, clientID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("GetConsent: %w", ErrOAuthConsentNotFound)
+		}
+		r.logger.Error("Failed to get oauth consent", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("GetConsent: %w", ErrFailedExecuteQuery)
+	}
+
+	return consent, nil
+}
+
+// SaveConsent сохраняет согласие пользователя или добавляет права к ранее данному
+func (r *OAuthRepo) SaveConsent(ctx context.Context, userID int, clientID int64, scopes []string) error {
+	r.logger.Info("Executing query", "query", querySaveOAuthConsent, "user_id", userID, "client_id", clientID)
+
+	if _, err := r.db.Exec(ctx, querySaveOAuthConsent, userID, clientID, scopes); err != nil {
+		r.logger.Error("Failed to save oauth consent", "error", err, "user_id", userID, "client_id", clientID)
+		return fmt.Errorf("SaveConsent: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// ListConsents получение согласий пользователя с названиями приложений
+func (r *OAuthRepo) ListConsents(ctx context.Context, userID int) ([]models.OAuthConsent, error) {
+	r.logger.Info("Executing query", "query", queryListOAuthConsents, "user_id", userID)
+
+	rows, err := r.db.Query(ctx, queryListOAuthConsents, userID)
+	if err != nil {
+		r.logger.Error("Failed to list oauth consents", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListConsents: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	var consents []models.OAuthConsent
+	for rows.Next() {
+		consent, err := scanOAuthConsent(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan oauth consent", "error", err, "user_id", userID)
+			return nil, fmt.Errorf("ListConsents: %w", ErrFailedExecuteQuery)
+		}
+		consents = append(consents, *consent)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate oauth consents", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListConsents: %w", ErrFailedExecuteQuery)
+	}
+
+	return consents, nil
+}
+
+// DeleteConsentWithTx удаляет согласие пользователя и еще не обмененные коды авторизации приложения
+func (r *OAuthRepo) DeleteConsentWithTx(ctx context.Context, tx pgx.Tx, userID int, clientID int64) error {
+	r.logger.Info("Executing query", "query", queryDeleteOAuthConsent, "user_id", userID, "client_id", clientID)
+
+	result, err := tx.Exec(ctx, queryDeleteOAuthConsent, userID, clientID)
+	if err != nil {
+		r.logger.Error("Failed to delete oauth consent", "error", err, "user_id", userID)
+		return fmt.Errorf("DeleteConsentWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteConsentWithTx: %w", ErrOAuthConsentNotFound)
+	}
+
+	r.logger.Info("Executing query", "query", queryDeleteUserClientCodes, "user_id", userID, "client_id", clientID)
+	if _, err = tx.Exec(ctx, queryDeleteUserClientCodes, userID, clientID); err != nil {
+		r.logger.Error("Failed to delete oauth authorization codes", "error", err, "user_id", userID)
+		return fmt.Errorf("DeleteConsentWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("OAuth consent deleted", "user_id", userID, "client_id", clientID)
+	return nil
+}
+
+// CreateAuthorizationCode сохраняет выданный код авторизации
+func (r *OAuthRepo) CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode) error {
+	r.logger.Info("Executing query", "query", queryCreateOAuthCode, "user_id", code.UserID, "client_id", code.ClientID)
+
+	_, err := r.db.Exec(ctx, queryCreateOAuthCode, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scopes,
+		code.CodeChallenge, code.ExpiresAt)
+	if err != nil {
+		r.logger.Error("Failed to create oauth authorization code", "error", err, "user_id", code.UserID)
+		return fmt.Errorf("CreateAuthorizationCode: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode удаляет код авторизации по хэшу и возвращает его. Срок действия проверяет вызывающий
+func (r *OAuthRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+
+	r.logger.Info("Executing query", "query", queryConsumeOAuthCode)
+	err := r.db.QueryRow(ctx, queryConsumeOAuthCode, codeHash).Scan(&code.ID, &code.CodeHash, &code.ClientID, &code.UserID,
+		&code.RedirectURI, &code.Scopes, &code.CodeChallenge, &code.CreatedAt, &code.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("ConsumeAuthorizationCode: %w", ErrOAuthCodeNotFound)
+		}
+		r.logger.Error("Failed to consume oauth authorization code", "error", err)
+		return nil, fmt.Errorf("ConsumeAuthorizationCode: %w", ErrFailedExecuteQuery)
+	}
+
+	return &code, nil
+}
+
+// DeleteExpiredAuthorizationCodes удаляет не больше limit просроченных кодов и возвращает число удаленных строк
+func (r *OAuthRepo) DeleteExpiredAuthorizationCodes(ctx context.Context, limit int) (int64, error) {
+	r.logger.Info("Executing query", "query", queryDeleteExpiredOAuthCodes, "limit", limit)
+
+	result, err := r.db.Exec(ctx, queryDeleteExpiredOAuthCodes, limit)
+	if err != nil {
+		r.logger.Error("Failed to delete expired oauth authorization codes", "error", err)
+		return 0, fmt.Errorf("DeleteExpiredAuthorizationCodes: %w", ErrFailedExecuteQuery)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// scanOAuthClient считывает строку таблицы oauth_clients
+func scanOAuthClient(row pgx.Row) (*models.OAuthClient, error) {
+	var c models.OAuthClient
+	err := row.Scan(&c.ID, &c.ClientID, &c.SecretHash, &c.Name, &c.RedirectURIs, &c.GrantTypes, &c.Scopes, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// scanOAuthConsent считывает строку таблицы oauth_consents с названием приложения
+func scanOAuthConsent(row pgx.Row) (*models.OAuthConsent, error) {
+	var c models.OAuthConsent
+	if err := row.Scan(&c.ID, &c.UserID, &c.ClientID, &c.ClientKey, &c.ClientName, &c.Scopes, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrResetTokenNotFound = errors.New("password reset token not found")
+
+type PasswordResetRepository interface {
+	StoreResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	UseResetTokenWithTx(ctx context.Context, tx pgx.Tx, tokenHash string) (int, error)
+}
+
+type PasswordResetRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewPasswordResetRepo(db *pgxpool.Pool, logger *slog.Logger) *PasswordResetRepo {
+	return &PasswordResetRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryInvalidateResetTokens = `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	queryStoreResetToken       = `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	queryUseResetToken         = `UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+)
+
+// StoreResetToken сохраняет хэш токена сброса пароля. Ранее выданные неиспользованные токены пользователя
+// погашаются, чтобы действовала только последняя ссылка
+func (r *PasswordResetRepo) StoreResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("StoreResetToken: %w", ErrFailedBeginTx)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	r.logger.Info("Executing query", "query", queryInvalidateResetTokens, "user_id", userID)
+	if _, err = tx.Exec(ctx, queryInvalidateResetTokens, userID); err != nil {
+		r.logger.Error("Failed to invalidate previous reset tokens", "error", err, "user_id", userID)
+		return fmt.Errorf("StoreResetToken: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Executing query", "query", queryStoreResetToken, "user_id", userID, "expires_at", expiresAt)
+	if _, err = tx.Exec(ctx, queryStoreResetToken, userID, tokenHash, expiresAt); err != nil {
+		r.logger.Error("Failed to store reset token", "error", err, "user_id", userID)
+		return fmt.Errorf("StoreResetToken: %w", ErrFailedExecuteQuery)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err)
+		return fmt.Errorf("StoreResetToken: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Reset token stored", "user_id", userID)
+	return nil
+}
+
+// UseResetTokenWithTx атомарно погашает действующий токен сброса пароля и возвращает ID его владельца
+func (r *PasswordResetRepo) UseResetTokenWithTx(ctx context.Context, tx pgx.Tx, tokenHash string) (int, error) {
+	var userID int
+
+	r.logger.Info("Executing query", "query", queryUseResetToken)
+	err := tx.QueryRow(ctx, queryUseResetToken, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Info("Active reset token not found")
+			return 0, fmt.Errorf("UseResetTokenWithTx: %w", ErrResetTokenNotFound)
+		}
+		r.logger.Error("Failed to use reset token", "error", err)
+		return 0, fmt.Errorf("UseResetTokenWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Reset token used", "user_id", userID)
+	return userID, nil
+}
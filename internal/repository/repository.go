@@ -10,6 +10,8 @@ import (
 	"user-management/internal/models"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
@@ -17,6 +19,10 @@ var (
 	ErrFailedExecuteQuery = errors.New("failed to execute query")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrTaskNotFound       = errors.New("task not found")
+	ErrCompletionNotFound = errors.New("task completion not found")
+	ErrCompletionExists   = errors.New("task completion already exists")
+	ErrEmailAlreadyExists = errors.New("email already in use")
+	ErrEmailMismatch      = errors.New("email does not match")
 )
 
 type UserRepository interface {
@@ -24,38 +30,66 @@ type UserRepository interface {
 	GetUserByNameWithTx(ctx context.Context, tx pgx.Tx, name string) (*models.User, error)
 	CreateUserWithTx(ctx context.Context, tx pgx.Tx, user *models.User) (int, error)
 	GetUserByName(ctx context.Context, name string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	GetUserLeaderboard(ctx context.Context) ([]dto.UserLeaderDTO, error)
 	SetReferrer(ctx context.Context, tx pgx.Tx, userID, referrer int) error
 	GetUserByIDWithTx(ctx context.Context, tx pgx.Tx, id int) (*models.User, error)
-	AddPoint(ctx context.Context, tx pgx.Tx, userID int, points int) error
+	AddPoint(ctx context.Context, tx pgx.Tx, userID int, points int, reason models.PointReason, referenceID *int64) error
 	GetTask(ctx context.Context, taskID int) (*models.Task, error)
-	IsCompletedTask(ctx context.Context, tx pgx.Tx, userID, taskID int) (bool, error)
-	AddCompletedTask(ctx context.Context, tx pgx.Tx, userID, taskID int) error
+	CountCompletedTasks(ctx context.Context, tx pgx.Tx, userID, taskID int, periodKey string) (int, error)
+	LastCompletionNumber(ctx context.Context, tx pgx.Tx, userID, taskID int) (int, error)
+	AddCompletedTask(ctx context.Context, tx pgx.Tx, completion *models.TaskCompletion) (int64, error)
+	GetCompletionWithTx(ctx context.Context, tx pgx.Tx, completionID int64) (*models.TaskCompletion, error)
+	SetCompletionStatusWithTx(ctx context.Context, tx pgx.Tx, completionID int64, status models.CompletionStatus, reviewerID *int) error
+	ListCompletions(ctx context.Context, status models.CompletionStatus, limit, offset int) ([]models.TaskCompletion, error)
+	CountCompletions(ctx context.Context, status models.CompletionStatus) (int, error)
+	UpdatePasswordWithTx(ctx context.Context, tx pgx.Tx, userID int, passwordHash string) error
+	RehashPassword(ctx context.Context, userID int, oldHash, newHash string) (bool, error)
+	AddUserAuditWithTx(ctx context.Context, tx pgx.Tx, entry *models.UserAuditEntry) error
+	UpdateEmail(ctx context.Context, userID int, email string) error
+	MarkEmailVerified(ctx context.Context, userID int, email string) error
 }
 
 type UserRepo struct {
-	db     *pgx.Conn
+	db     *pgxpool.Pool
 	logger *slog.Logger
 }
 
-func NewUserRepository(db *pgx.Conn, logger *slog.Logger) *UserRepo {
+func NewUserRepository(db *pgxpool.Pool, logger *slog.Logger) *UserRepo {
 	return &UserRepo{db: db, logger: logger}
 }
 
+// Колонки выполнения задания в порядке, ожидаемом scanCompletion
+const completionColumns = `id, user_id, task_id, period_key, status, proof, completed_at, reviewed_at, reviewed_by`
+
 // SQL запросы
 const (
-	queryCreateUser             = `INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id`
+	queryCreateUser             = `INSERT INTO users (username, password, email, verified_at) VALUES ($1, $2, $3, $4) RETURNING id`
 	queryGetUserByNameForUpdate = `SELECT id, username FROM users WHERE username = $1 FOR UPDATE`
-	queryGetUserByName          = `SELECT id, username, password FROM users WHERE username = $1`
-	queryGetUserByID            = `SELECT id, username, password, balance, updated_balance, referrer, created_at FROM users WHERE id = $1`
-	queryGetUserByIDForUpdate   = `SELECT id, username, password, balance, referrer FROM users WHERE id = $1 FOR UPDATE`
+	queryGetUserByName          = `SELECT id, username, password, email, verified_at FROM users WHERE username = $1`
+	queryGetUserByEmail         = `SELECT id, username, password, email, verified_at FROM users WHERE LOWER(email) = LOWER($1)`
+	queryGetUserByID            = `SELECT id, username, password, balance, updated_balance, referrer, created_at, email, verified_at FROM users WHERE id = $1`
+	queryGetUserByIDForUpdate   = `SELECT id, username, password, balance, referrer, verified_at FROM users WHERE id = $1 FOR UPDATE`
 	queryGetLeaderboard         = `SELECT id, username, balance FROM users ORDER BY balance DESC LIMIT 10`
 	queryUpdateReferrer         = `UPDATE users SET referrer = $1 WHERE id = $2`
-	queryUpdatePoints           = `UPDATE users SET balance = balance + $1, updated_balance = NOW() WHERE id = $2`
-	queryGetTask                = `SELECT id, description, reward FROM tasks WHERE id = $1`
-	queryIsCompletedTask        = `SELECT EXISTS (SELECT 1 FROM completed_tasks WHERE user_id = $1 AND task_id = $2)`
-	queryCompletedTask          = `INSERT INTO completed_tasks (user_id, task_id) VALUES ($1, $2)`
+	queryUpdatePoints           = `UPDATE users SET balance = balance + $1, updated_balance = NOW() WHERE id = $2 RETURNING balance`
+	queryAddPointTransaction    = `INSERT INTO point_transactions (user_id, amount, balance_after, reason, reference_id) VALUES ($1, $2, $3, $4, $5)`
+	queryGetTask                = `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1`
+	queryCountCompletedTasks    = `SELECT COUNT(*) FROM completed_tasks
+		WHERE user_id = $1 AND task_id = $2 AND status <> 'rejected' AND ($3::VARCHAR = '' OR period_key = $3)`
+	queryLastCompletionNumber = `SELECT COALESCE(MAX(SUBSTRING(period_key FROM 2)::INT), 0) FROM completed_tasks
+		WHERE user_id = $1 AND task_id = $2 AND period_key ~ '^#[0-9]+$'`
+	queryCompletedTask          = `INSERT INTO completed_tasks (user_id, task_id, period_key, status, proof) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	queryGetCompletionForUpdate = `SELECT ` + completionColumns + ` FROM completed_tasks WHERE id = $1 FOR UPDATE`
+	querySetCompletionStatus    = `UPDATE completed_tasks SET status = $1, reviewed_by = $2, reviewed_at = NOW() WHERE id = $3`
+	queryListCompletions        = `SELECT ` + completionColumns + ` FROM completed_tasks WHERE status = $1 ORDER BY id LIMIT $2 OFFSET $3`
+	queryCountCompletions       = `SELECT COUNT(*) FROM completed_tasks WHERE status = $1`
+	queryUpdatePassword         = `UPDATE users SET password = $1 WHERE id = $2`
+	queryRehashPassword         = `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+	queryAddUserAudit           = `INSERT INTO user_audit_log (user_id, action, ip, user_agent) VALUES ($1, $2, $3, $4)`
+	queryUpdateEmail            = `UPDATE users SET email = $1, verified_at = NULL WHERE id = $2`
+	queryMarkEmailVerified      = `UPDATE users SET verified_at = COALESCE(verified_at, NOW()) WHERE id = $1 AND email = $2`
 )
 
 // BeginTransaction начало транзакции
@@ -94,8 +128,12 @@ func (r *UserRepo) CreateUserWithTx(ctx context.Context, tx pgx.Tx, user *models
 
 	r.logger.Info("Executing query", "query", queryCreateUser, "username", user.UserName)
 
-	err := tx.QueryRow(ctx, queryCreateUser, user.UserName, user.Password).Scan(&userID)
+	err := tx.QueryRow(ctx, queryCreateUser, user.UserName, user.Password, user.Email, user.VerifiedAt).Scan(&userID)
 	if err != nil {
+		if isUniqueViolation(err, uniqueUsersEmail) {
+			r.logger.Info("Email already in use", "username", user.UserName)
+			return 0, fmt.Errorf("CreateUser: %w", ErrEmailAlreadyExists)
+		}
 		r.logger.Error("Failed to execute query create user", "error", err, "username", user.UserName)
 		return 0, fmt.Errorf("CreateUser:  %w", ErrFailedExecuteQuery)
 	}
@@ -109,7 +147,7 @@ func (r *UserRepo) GetUserByIDWithTx(ctx context.Context, tx pgx.Tx, id int) (*m
 	var user models.User
 
 	r.logger.Info("Executing query", "query", queryGetUserByIDForUpdate, "user_id", id)
-	err := tx.QueryRow(ctx, queryGetUserByIDForUpdate, id).Scan(&user.ID, &user.UserName, &user.Password, &user.Balance, &user.Referrer)
+	err := tx.QueryRow(ctx, queryGetUserByIDForUpdate, id).Scan(&user.ID, &user.UserName, &user.Password, &user.Balance, &user.Referrer, &user.VerifiedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -129,7 +167,7 @@ func (r *UserRepo) GetUserByName(ctx context.Context, name string) (*models.User
 	var user models.User
 
 	r.logger.Info("Executing query", "query", queryGetUserByName, "username", name)
-	err := r.db.QueryRow(ctx, queryGetUserByName, name).Scan(&user.ID, &user.UserName, &user.Password)
+	err := r.db.QueryRow(ctx, queryGetUserByName, name).Scan(&user.ID, &user.UserName, &user.Password, &user.Email, &user.VerifiedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			r.logger.Info("User not found", "username", name)
@@ -144,13 +182,36 @@ func (r *UserRepo) GetUserByName(ctx context.Context, name string) (*models.User
 	return &user, nil
 }
 
+// GetUserByEmail получение данных пользователя по адресу электронной почты без учета регистра
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+
+	r.logger.Info("Executing query", "query", queryGetUserByEmail)
+	err := r.db.QueryRow(ctx, queryGetUserByEmail, email).Scan(&user.ID, &user.UserName, &user.Password, &user.Email, &user.VerifiedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("User not found by email")
+			return nil, fmt.Errorf("GetUserByEmail: %w", ErrUserNotFound)
+		}
+		r.logger.Error("Failed to execute query to get user by email", "error", err)
+		return nil, fmt.Errorf("GetUserByEmail: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("User found", "user_id", user.ID)
+	return &user, nil
+}
+
 // GetUserByID получение пользователя по id
 func (r *UserRepo) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	var user models.User
 
 	r.logger.Info("Executing query", "query", queryGetUserByID, "user_id", id)
-	err := r.db.QueryRow(ctx, queryGetUserByID, id).Scan(&user.ID, &user.UserName, &user.Password, &user.Balance, &user.UpdateBalance, &user.Referrer, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, queryGetUserByID, id).Scan(&user.ID, &user.UserName, &user.Password, &user.Balance, &user.UpdateBalance, &user.Referrer, &user.CreatedAt, &user.Email, &user.VerifiedAt)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("User not found", "user_id", id)
+			return nil, fmt.Errorf("GetUserByID: %w", ErrUserNotFound)
+		}
 		r.logger.Error("Failed to execute query to get user by id", "error", err, "user_id", id)
 		return nil, fmt.Errorf("GetUserByID: %w", ErrFailedExecuteQuery)
 	}
@@ -202,25 +263,36 @@ func (r *UserRepo) SetReferrer(ctx context.Context, tx pgx.Tx, userID, referrer
 	return nil
 }
 
-// AddPoint добавление поинтов за выполенение задания
-func (r *UserRepo) AddPoint(ctx context.Context, tx pgx.Tx, userID int, points int) error {
+// AddPoint изменение баланса пользователя с записью операции в журнал в рамках той же транзакции
+func (r *UserRepo) AddPoint(ctx context.Context, tx pgx.Tx, userID int, points int, reason models.PointReason, referenceID *int64) error {
 	r.logger.Info("Executing query", "query", queryUpdatePoints, "user_id", userID)
 
-	_, err := tx.Exec(ctx, queryUpdatePoints, points, userID)
+	var balance int
+	err := tx.QueryRow(ctx, queryUpdatePoints, points, userID).Scan(&balance)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("User not found", "user_id", userID)
+			return fmt.Errorf("AddPoint:  %w", ErrUserNotFound)
+		}
 		r.logger.Error("Failed to add points", "error", err, "user_id", userID)
 		return fmt.Errorf("AddPoint:  %w", ErrFailedExecuteQuery)
 	}
-	r.logger.Info("Points added and updated_at updated", "user_id", userID)
+
+	r.logger.Info("Executing query", "query", queryAddPointTransaction, "user_id", userID, "reason", reason)
+	_, err = tx.Exec(ctx, queryAddPointTransaction, userID, points, balance, reason, referenceID)
+	if err != nil {
+		r.logger.Error("Failed to add point transaction", "error", err, "user_id", userID, "reason", reason)
+		return fmt.Errorf("AddPoint:  %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Points added and updated_at updated", "user_id", userID, "reason", reason)
 	return nil
 }
 
 // GetTask получение данных о задании
 func (r *UserRepo) GetTask(ctx context.Context, taskID int) (*models.Task, error) {
-	var storedTask models.Task
-
 	r.logger.Info("Executing query", "query", queryGetTask, "taskID", taskID)
-	err := r.db.QueryRow(ctx, queryGetTask, taskID).Scan(&storedTask.ID, &storedTask.Description, &storedTask.Reward)
+	storedTask, err := scanTask(r.db.QueryRow(ctx, queryGetTask, taskID))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			r.logger.Info("Task not found", "taskID", taskID)
@@ -232,34 +304,235 @@ func (r *UserRepo) GetTask(ctx context.Context, taskID int) (*models.Task, error
 	}
 
 	r.logger.Info("Task found", "task_id", storedTask.ID, "description", storedTask.Description)
-	return &storedTask, nil
+	return storedTask, nil
 }
 
-// IsCompletedTask проверка выполнения задания
-func (r *UserRepo) IsCompletedTask(ctx context.Context, tx pgx.Tx, userID, taskID int) (bool, error) {
-	r.logger.Info("Executing query", "query", queryIsCompletedTask, "userID", userID, "taskID", taskID)
+// CountCompletedTasks подсчет выполнений задания пользователем в периоде periodKey (пустой ключ — за все время)
+func (r *UserRepo) CountCompletedTasks(ctx context.Context, tx pgx.Tx, userID, taskID int, periodKey string) (int, error) {
+	r.logger.Info("Executing query", "query", queryCountCompletedTasks, "userID", userID, "taskID", taskID, "period_key", periodKey)
 
-	var exist bool
-	err := tx.QueryRow(ctx, queryIsCompletedTask, userID, taskID).Scan(&exist)
-	if err != nil && err != pgx.ErrNoRows {
-		r.logger.Error("Failed to execute query to check completed task", "error", err, "userID", userID, "taskID", taskID)
-		return false, fmt.Errorf("IsCompletedTask:  %w", ErrFailedExecuteQuery)
+	var count int
+	err := tx.QueryRow(ctx, queryCountCompletedTasks, userID, taskID, periodKey).Scan(&count)
+	if err != nil {
+		r.logger.Error("Failed to execute query to count completed tasks", "error", err, "userID", userID, "taskID", taskID)
+		return 0, fmt.Errorf("CountCompletedTasks:  %w", ErrFailedExecuteQuery)
 	}
 
-	r.logger.Info("Task already completed", "user_id", userID, "taskID", taskID)
-	return exist, nil
+	r.logger.Info("Completed tasks counted", "user_id", userID, "taskID", taskID, "count", count)
+	return count, nil
 }
 
-// AddCompletedTask добавление выполненной задачи
-func (r *UserRepo) AddCompletedTask(ctx context.Context, tx pgx.Tx, userID, taskID int) error {
-	r.logger.Info("Executing query", "query", queryCompletedTask, "taskID", taskID)
+// LastCompletionNumber наибольший номер выполнения задания без периода среди всех заявок пользователя, включая
+// отклоненные (0, если заявок нет). Номера не используются повторно, поэтому новая заявка не совпадет с отклоненной
+func (r *UserRepo) LastCompletionNumber(ctx context.Context, tx pgx.Tx, userID, taskID int) (int, error) {
+	r.logger.Info("Executing query", "query", queryLastCompletionNumber, "userID", userID, "taskID", taskID)
 
-	_, err := tx.Exec(ctx, queryCompletedTask, userID, taskID)
+	var number int
+	err := tx.QueryRow(ctx, queryLastCompletionNumber, userID, taskID).Scan(&number)
 	if err != nil {
-		r.logger.Error("Failed to execute query to add completed task", "error", err, "userID", userID, "taskID", taskID)
-		return fmt.Errorf("AddCompletedTask:  %w", ErrFailedExecuteQuery)
+		r.logger.Error("Failed to execute query to get last completion number", "error", err, "userID", userID, "taskID", taskID)
+		return 0, fmt.Errorf("LastCompletionNumber:  %w", ErrFailedExecuteQuery)
 	}
 
-	r.logger.Info("Completed task added", "user_id", userID, "taskID", taskID)
+	return number, nil
+}
+
+// AddCompletedTask добавление заявки на выполнение задачи
+func (r *UserRepo) AddCompletedTask(ctx context.Context, tx pgx.Tx, completion *models.TaskCompletion) (int64, error) {
+	r.logger.Info("Executing query", "query", queryCompletedTask, "taskID", completion.TaskID, "period_key", completion.PeriodKey, "status", completion.Status)
+
+	var completionID int64
+	err := tx.QueryRow(ctx, queryCompletedTask, completion.UserID, completion.TaskID, completion.PeriodKey, completion.Status, completion.Proof).Scan(&completionID)
+	if err != nil {
+		if isUniqueViolation(err, uniqueCompletedTasksPeriod) {
+			r.logger.Info("Task completion already exists", "userID", completion.UserID, "taskID", completion.TaskID, "period_key", completion.PeriodKey)
+			return 0, fmt.Errorf("AddCompletedTask:  %w", ErrCompletionExists)
+		}
+		r.logger.Error("Failed to execute query to add completed task", "error", err, "userID", completion.UserID, "taskID", completion.TaskID)
+		return 0, fmt.Errorf("AddCompletedTask:  %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Completed task added", "user_id", completion.UserID, "taskID", completion.TaskID, "completion_id", completionID)
+	return completionID, nil
+}
+
+// GetCompletionWithTx получение заявки на выполнение задачи с блокировкой
+func (r *UserRepo) GetCompletionWithTx(ctx context.Context, tx pgx.Tx, completionID int64) (*models.TaskCompletion, error) {
+	r.logger.Info("Executing query", "query", queryGetCompletionForUpdate, "completion_id", completionID)
+
+	completion, err := scanCompletion(tx.QueryRow(ctx, queryGetCompletionForUpdate, completionID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("Completion not found", "completion_id", completionID)
+			return nil, fmt.Errorf("GetCompletionWithTx:  %w", ErrCompletionNotFound)
+		}
+		r.logger.Error("Failed to execute query to get completion", "error", err, "completion_id", completionID)
+		return nil, fmt.Errorf("GetCompletionWithTx:  %w", ErrFailedExecuteQuery)
+	}
+
+	return completion, nil
+}
+
+// SetCompletionStatusWithTx изменение статуса проверки заявки на выполнение задачи
+func (r *UserRepo) SetCompletionStatusWithTx(ctx context.Context, tx pgx.Tx, completionID int64, status models.CompletionStatus, reviewerID *int) error {
+	r.logger.Info("Executing query", "query", querySetCompletionStatus, "completion_id", completionID, "status", status)
+
+	_, err := tx.Exec(ctx, querySetCompletionStatus, status, reviewerID, completionID)
+	if err != nil {
+		r.logger.Error("Failed to execute query to set completion status", "error", err, "completion_id", completionID)
+		return fmt.Errorf("SetCompletionStatusWithTx:  %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Completion status updated", "completion_id", completionID, "status", status)
 	return nil
 }
+
+// ListCompletions получение страницы заявок на выполнение задач с указанным статусом
+func (r *UserRepo) ListCompletions(ctx context.Context, status models.CompletionStatus, limit, offset int) ([]models.TaskCompletion, error) {
+	completions := make([]models.TaskCompletion, 0, limit)
+
+	r.logger.Info("Executing query", "query", queryListCompletions, "status", status, "limit", limit, "offset", offset)
+	rows, err := r.db.Query(ctx, queryListCompletions, status, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to execute query to list completions", "error", err)
+		return completions, fmt.Errorf("ListCompletions: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		completion, err := scanCompletion(rows)
+		if err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return completions, fmt.Errorf("ListCompletions: failed to parse rows: %w", err)
+		}
+		completions = append(completions, *completion)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return completions, fmt.Errorf("ListCompletions: error during rows iteration: %w", err)
+	}
+
+	return completions, nil
+}
+
+// CountCompletions получение количества заявок на выполнение задач с указанным статусом
+func (r *UserRepo) CountCompletions(ctx context.Context, status models.CompletionStatus) (int, error) {
+	var total int
+
+	r.logger.Info("Executing query", "query", queryCountCompletions, "status", status)
+	err := r.db.QueryRow(ctx, queryCountCompletions, status).Scan(&total)
+	if err != nil {
+		r.logger.Error("Failed to execute query to count completions", "error", err)
+		return 0, fmt.Errorf("CountCompletions: %w", ErrFailedExecuteQuery)
+	}
+
+	return total, nil
+}
+
+// UpdatePasswordWithTx изменение хэша пароля пользователя
+func (r *UserRepo) UpdatePasswordWithTx(ctx context.Context, tx pgx.Tx, userID int, passwordHash string) error {
+	r.logger.Info("Executing query", "query", queryUpdatePassword, "user_id", userID)
+
+	result, err := tx.Exec(ctx, queryUpdatePassword, passwordHash, userID)
+	if err != nil {
+		r.logger.Error("Failed to update password", "error", err, "user_id", userID)
+		return fmt.Errorf("UpdatePasswordWithTx: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("User not found", "user_id", userID)
+		return fmt.Errorf("UpdatePasswordWithTx: %w", ErrUserNotFound)
+	}
+
+	r.logger.Info("Password updated", "user_id", userID)
+	return nil
+}
+
+// RehashPassword замена хэша пароля пересчитанным, если пароль не изменился с момента чтения.
+// Возвращает false, если хэш уже заменен параллельным входом или сменой пароля
+func (r *UserRepo) RehashPassword(ctx context.Context, userID int, oldHash, newHash string) (bool, error) {
+	r.logger.Info("Executing query", "query", queryRehashPassword, "user_id", userID)
+
+	result, err := r.db.Exec(ctx, queryRehashPassword, newHash, userID, oldHash)
+	if err != nil {
+		r.logger.Error("Failed to rehash password", "error", err, "user_id", userID)
+		return false, fmt.Errorf("RehashPassword: %w", ErrFailedExecuteQuery)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// AddUserAuditWithTx запись события учетной записи пользователя в журнал аудита
+func (r *UserRepo) AddUserAuditWithTx(ctx context.Context, tx pgx.Tx, entry *models.UserAuditEntry) error {
+	r.logger.Info("Executing query", "query", queryAddUserAudit, "user_id", entry.UserID, "action", entry.Action)
+
+	if _, err := tx.Exec(ctx, queryAddUserAudit, entry.UserID, entry.Action, entry.IP, entry.UserAgent); err != nil {
+		r.logger.Error("Failed to add user audit entry", "error", err, "user_id", entry.UserID)
+		return fmt.Errorf("AddUserAuditWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// UpdateEmail изменение адреса электронной почты пользователя со сбросом подтверждения
+func (r *UserRepo) UpdateEmail(ctx context.Context, userID int, email string) error {
+	r.logger.Info("Executing query", "query", queryUpdateEmail, "user_id", userID)
+
+	result, err := r.db.Exec(ctx, queryUpdateEmail, email, userID)
+	if err != nil {
+		if isUniqueViolation(err, uniqueUsersEmail) {
+			r.logger.Info("Email already in use", "user_id", userID)
+			return fmt.Errorf("UpdateEmail: %w", ErrEmailAlreadyExists)
+		}
+		r.logger.Error("Failed to update email", "error", err, "user_id", userID)
+		return fmt.Errorf("UpdateEmail: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("User not found", "user_id", userID)
+		return fmt.Errorf("UpdateEmail: %w", ErrUserNotFound)
+	}
+
+	r.logger.Info("Email updated", "user_id", userID)
+	return nil
+}
+
+// MarkEmailVerified отметка о подтверждении адреса. Адрес должен совпадать с текущим адресом пользователя,
+// чтобы ссылка, выданная для прежнего адреса, не подтверждала новый
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, userID int, email string) error {
+	r.logger.Info("Executing query", "query", queryMarkEmailVerified, "user_id", userID)
+
+	result, err := r.db.Exec(ctx, queryMarkEmailVerified, userID, email)
+	if err != nil {
+		r.logger.Error("Failed to mark email verified", "error", err, "user_id", userID)
+		return fmt.Errorf("MarkEmailVerified: %w", ErrFailedExecuteQuery)
+	}
+	if result.RowsAffected() == 0 {
+		r.logger.Info("Email does not match", "user_id", userID)
+		return fmt.Errorf("MarkEmailVerified: %w", ErrEmailMismatch)
+	}
+
+	r.logger.Info("Email verified", "user_id", userID)
+	return nil
+}
+
+// Нарушение уникальности в PostgreSQL и имена уникальных индексов адресов электронной почты и выполнений заданий
+const (
+	pgUniqueViolation          = "23505"
+	uniqueUsersEmail           = "uq_users_email"
+	uniqueCompletedTasksPeriod = "uq_completed_tasks_user_task_period"
+)
+
+// isUniqueViolation проверяет, что ошибка вызвана нарушением указанного уникального индекса
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == constraint
+}
+
+// scanCompletion считывает строку таблицы completed_tasks, выбранную с колонками completionColumns
+func scanCompletion(row pgx.Row) (*models.TaskCompletion, error) {
+	var c models.TaskCompletion
+	err := row.Scan(&c.ID, &c.UserID, &c.TaskID, &c.PeriodKey, &c.Status, &c.Proof, &c.CompletedAt, &c.ReviewedAt, &c.ReviewedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RoleRepository interface {
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	GrantRole(ctx context.Context, userID int, role string) error
+	RevokeRole(ctx context.Context, userID int, role string) error
+}
+
+type RoleRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewRoleRepo(db *pgxpool.Pool, logger *slog.Logger) *RoleRepo {
+	return &RoleRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	queryGetUserRoles = `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`
+	queryGrantRole    = `INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	queryRevokeRole   = `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`
+)
+
+// GetUserRoles получение ролей, выданных пользователю
+func (r *RoleRepo) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	roles := make([]string, 0)
+
+	r.logger.Info("Executing query", "query", queryGetUserRoles, "user_id", userID)
+	rows, err := r.db.Query(ctx, queryGetUserRoles, userID)
+	if err != nil {
+		r.logger.Error("Failed to execute query to get user roles", "error", err, "user_id", userID)
+		return roles, fmt.Errorf("GetUserRoles: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err = rows.Scan(&role); err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return roles, fmt.Errorf("GetUserRoles: failed to parse rows: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return roles, fmt.Errorf("GetUserRoles: error during rows iteration: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GrantRole выдача роли пользователю
+func (r *RoleRepo) GrantRole(ctx context.Context, userID int, role string) error {
+	r.logger.Info("Executing query", "query", queryGrantRole, "user_id", userID, "role", role)
+
+	_, err := r.db.Exec(ctx, queryGrantRole, userID, role)
+	if err != nil {
+		r.logger.Error("Failed to grant role", "error", err, "user_id", userID, "role", role)
+		return fmt.Errorf("GrantRole: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Role granted", "user_id", userID, "role", role)
+	return nil
+}
+
+// RevokeRole отзыв роли у пользователя
+func (r *RoleRepo) RevokeRole(ctx context.Context, userID int, role string) error {
+	r.logger.Info("Executing query", "query", queryRevokeRole, "user_id", userID, "role", role)
+
+	_, err := r.db.Exec(ctx, queryRevokeRole, userID, role)
+	if err != nil {
+		r.logger.Error("Failed to revoke role", "error", err, "user_id", userID, "role", role)
+		return fmt.Errorf("RevokeRole: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Role revoked", "user_id", userID, "role", role)
+	return nil
+}
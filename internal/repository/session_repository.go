@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	SaveSession(ctx context.Context, session *models.Session) (int64, error)
+	TouchSession(ctx context.Context, sessionID int64, interval time.Duration) error
+	ListActiveSessions(ctx context.Context, userID int) ([]models.Session, error)
+	RevokeSessionWithTx(ctx context.Context, tx pgx.Tx, userID int, sessionID int64) error
+	RevokeUserSessionsWithTx(ctx context.Context, tx pgx.Tx, userID int, exceptSessionID *int64) (int64, error)
+	DeleteExpiredSessions(ctx context.Context, retention time.Duration, limit int) (int64, error)
+}
+
+type SessionRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewSessionRepo(db *pgxpool.Pool, logger *slog.Logger) *SessionRepo {
+	return &SessionRepo{db: db, logger: logger}
+}
+
+// SQL запросы
+const (
+	// Сессия создается при входе и обновляется при каждой ротации refresh токена; завершенная сессия не возобновляется
+	querySaveSession = `INSERT INTO sessions (user_id, family_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (family_id) DO UPDATE SET user_agent = EXCLUDED.user_agent, ip = EXCLUDED.ip, expires_at = EXCLUDED.expires_at, last_seen_at = NOW()
+		WHERE sessions.revoked_at IS NULL
+		RETURNING id`
+	// Время последнего обращения обновляется не чаще одного раза за интервал, чтобы не писать в базу на каждый запрос
+	queryTouchSession = `UPDATE sessions SET last_seen_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL AND last_seen_at < NOW() - $2::INTERVAL`
+	queryListActiveSessions = `SELECT id, user_id, family_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC`
+	queryRevokeSession = `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING family_id`
+	queryRevokeSessionTokens        = `UPDATE tokens SET is_revoked = TRUE WHERE session_id = $1 AND is_revoked = FALSE`
+	queryRevokeSessionRefreshTokens = `UPDATE refresh_tokens SET is_revoked = TRUE WHERE family_id = $1 AND is_revoked = FALSE`
+	// Токены без сессии (выпущенные до появления сессий) отзываются вместе со всеми остальными
+	queryRevokeUserSessions = `UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND id IS DISTINCT FROM $2`
+	queryRevokeUserSessionTokens = `UPDATE tokens SET is_revoked = TRUE
+		WHERE user_id = $1 AND is_revoked = FALSE AND ($2::BIGINT IS NULL OR session_id IS DISTINCT FROM $2)`
+	queryRevokeUserSessionRefreshTokens = `UPDATE refresh_tokens SET is_revoked = TRUE
+		WHERE user_id = $1 AND is_revoked = FALSE AND family_id NOT IN (SELECT family_id FROM sessions WHERE id = $2)`
+	queryDeleteExpiredSessions = `DELETE FROM sessions WHERE id IN (
+		SELECT id FROM sessions WHERE expires_at < NOW() - $1::INTERVAL OR revoked_at < NOW() - $1::INTERVAL
+		LIMIT $2 FOR UPDATE SKIP LOCKED)`
+)
+
+// SaveSession создает сессию или обновляет действующую сессию того же семейства refresh токенов и возвращает ее ID
+func (r *SessionRepo) SaveSession(ctx context.Context, session *models.Session) (int64, error) {
+	var sessionID int64
+
+	r.logger.Info("Executing query", "query", querySaveSession, "user_id", session.UserID, "ip", session.IP)
+	err := r.db.QueryRow(ctx, querySaveSession, session.UserID, session.FamilyID, session.UserAgent, session.IP, session.ExpiresAt).Scan(&sessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Info("Session already revoked", "user_id", session.UserID)
+			return 0, fmt.Errorf("SaveSession: %w", ErrSessionNotFound)
+		}
+		r.logger.Error("Failed to save session", "error", err, "user_id", session.UserID)
+		return 0, fmt.Errorf("SaveSession: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Session saved", "user_id", session.UserID, "session_id", sessionID)
+	return sessionID, nil
+}
+
+// TouchSession обновляет время последнего обращения в рамках сессии
+func (r *SessionRepo) TouchSession(ctx context.Context, sessionID int64, interval time.Duration) error {
+	r.logger.Info("Executing query", "query", queryTouchSession, "session_id", sessionID)
+
+	if _, err := r.db.Exec(ctx, queryTouchSession, sessionID, interval); err != nil {
+		r.logger.Error("Failed to touch session", "error", err, "session_id", sessionID)
+		return fmt.Errorf("TouchSession: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// ListActiveSessions получение действующих сессий пользователя, начиная с последней активной
+func (r *SessionRepo) ListActiveSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	r.logger.Info("Executing query", "query", queryListActiveSessions, "user_id", userID)
+
+	rows, err := r.db.Query(ctx, queryListActiveSessions, userID)
+	if err != nil {
+		r.logger.Error("Failed to list sessions", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListActiveSessions: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		if err = rows.Scan(&s.ID, &s.UserID, &s.FamilyID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt); err != nil {
+			r.logger.Error("Failed to scan session", "error", err, "user_id", userID)
+			return nil, fmt.Errorf("ListActiveSessions: %w", ErrFailedExecuteQuery)
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate sessions", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("ListActiveSessions: %w", ErrFailedExecuteQuery)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSessionWithTx завершает действующую сессию пользователя и отзывает все ее access и refresh токены
+func (r *SessionRepo) RevokeSessionWithTx(ctx context.Context, tx pgx.Tx, userID int, sessionID int64) error {
+	var familyID string
+
+	r.logger.Info("Executing query", "query", queryRevokeSession, "user_id", userID, "session_id", sessionID)
+	err := tx.QueryRow(ctx, queryRevokeSession, sessionID, userID).Scan(&familyID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Info("Active session not found", "user_id", userID, "session_id", sessionID)
+			return fmt.Errorf("RevokeSessionWithTx: %w", ErrSessionNotFound)
+		}
+		r.logger.Error("Failed to revoke session", "error", err, "session_id", sessionID)
+		return fmt.Errorf("RevokeSessionWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Executing query", "query", queryRevokeSessionTokens, "session_id", sessionID)
+	if _, err = tx.Exec(ctx, queryRevokeSessionTokens, sessionID); err != nil {
+		r.logger.Error("Failed to revoke session tokens", "error", err, "session_id", sessionID)
+		return fmt.Errorf("RevokeSessionWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Executing query", "query", queryRevokeSessionRefreshTokens, "session_id", sessionID)
+	if _, err = tx.Exec(ctx, queryRevokeSessionRefreshTokens, familyID); err != nil {
+		r.logger.Error("Failed to revoke session refresh tokens", "error", err, "session_id", sessionID)
+		return fmt.Errorf("RevokeSessionWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Session revoked", "user_id", userID, "session_id", sessionID)
+	return nil
+}
+
+// RevokeUserSessionsWithTx завершает все сессии пользователя, кроме exceptSessionID (если он задан),
+// отзывает их токены и возвращает число завершенных сессий
+func (r *SessionRepo) RevokeUserSessionsWithTx(ctx context.Context, tx pgx.Tx, userID int, exceptSessionID *int64) (int64, error) {
+	r.logger.Info("Executing query", "query", queryRevokeUserSessions, "user_id", userID)
+	result, err := tx.Exec(ctx, queryRevokeUserSessions, userID, exceptSessionID)
+	if err != nil {
+		r.logger.Error("Failed to revoke user sessions", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("RevokeUserSessionsWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Executing query", "query", queryRevokeUserSessionTokens, "user_id", userID)
+	if _, err = tx.Exec(ctx, queryRevokeUserSessionTokens, userID, exceptSessionID); err != nil {
+		r.logger.Error("Failed to revoke user session tokens", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("RevokeUserSessionsWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Executing query", "query", queryRevokeUserSessionRefreshTokens, "user_id", userID)
+	if _, err = tx.Exec(ctx, queryRevokeUserSessionRefreshTokens, userID, exceptSessionID); err != nil {
+		r.logger.Error("Failed to revoke user session refresh tokens", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("RevokeUserSessionsWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("User sessions revoked", "user_id", userID, "sessions", result.RowsAffected())
+	return result.RowsAffected(), nil
+}
+
+// DeleteExpiredSessions удаляет не больше limit сессий, истекших или завершенных раньше чем retention назад,
+// и возвращает число удаленных строк
+func (r *SessionRepo) DeleteExpiredSessions(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	r.logger.Info("Executing query", "query", queryDeleteExpiredSessions, "retention", retention, "limit", limit)
+
+	result, err := r.db.Exec(ctx, queryDeleteExpiredSessions, retention, limit)
+	if err != nil {
+		r.logger.Error("Failed to delete expired sessions", "error", err)
+		return 0, fmt.Errorf("DeleteExpiredSessions: %w", ErrFailedExecuteQuery)
+	}
+
+	return result.RowsAffected(), nil
+}
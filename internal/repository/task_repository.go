@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TaskRepository interface {
+	BeginTransaction(ctx context.Context) (pgx.Tx, error)
+	CreateTaskWithTx(ctx context.Context, tx pgx.Tx, task *models.Task) (*models.Task, error)
+	GetTaskWithTx(ctx context.Context, tx pgx.Tx, taskID int) (*models.Task, error)
+	UpdateTaskWithTx(ctx context.Context, tx pgx.Tx, task *models.Task) (*models.Task, error)
+	ArchiveTaskWithTx(ctx context.Context, tx pgx.Tx, taskID int) (*models.Task, error)
+	ListTasks(ctx context.Context, includeArchived bool, limit, offset int) ([]models.Task, error)
+	CountTasks(ctx context.Context, includeArchived bool) (int, error)
+	AddTaskAuditWithTx(ctx context.Context, tx pgx.Tx, entry *models.TaskAuditEntry) error
+	GetTaskAudit(ctx context.Context, taskID int) ([]models.TaskAuditEntry, error)
+	ListUserTasks(ctx context.Context, userID int, now time.Time, completed *bool, limit, offset int) ([]models.UserTask, error)
+	CountUserTasks(ctx context.Context, userID int, now time.Time, completed *bool) (int, error)
+}
+
+type TaskRepo struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewTaskRepo(db *pgxpool.Pool, logger *slog.Logger) *TaskRepo {
+	return &TaskRepo{db: db, logger: logger}
+}
+
+// Колонки задания в порядке, ожидаемом scanTask
+const taskColumns = `id, description, reward, starts_at, ends_at, is_archived, recurrence, max_completions,
+	verification, verification_code_hash, created_at, updated_at`
+
+// SQL запросы
+const (
+	queryCreateTask = `INSERT INTO tasks (description, reward, starts_at, ends_at, recurrence, max_completions, verification, verification_code_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING ` + taskColumns
+	queryGetTaskForUpdate = `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1 FOR UPDATE`
+	queryUpdateTask       = `UPDATE tasks SET description = $1, reward = $2, starts_at = $3, ends_at = $4,
+		recurrence = $5, max_completions = $6, verification = $7,
+		verification_code_hash = CASE WHEN $7 = 'proof_code' THEN COALESCE($8, verification_code_hash) END, updated_at = NOW()
+		WHERE id = $9 RETURNING ` + taskColumns
+	queryArchiveTask = `UPDATE tasks SET is_archived = TRUE, updated_at = NOW() WHERE id = $1 RETURNING ` + taskColumns
+	queryListTasks   = `SELECT ` + taskColumns + ` FROM tasks WHERE ($1 OR is_archived = FALSE) ORDER BY id LIMIT $2 OFFSET $3`
+	queryCountTasks  = `SELECT COUNT(*) FROM tasks WHERE ($1 OR is_archived = FALSE)`
+	queryAddAudit    = `INSERT INTO task_audit_log (task_id, actor_id, action, before, after) VALUES ($1, $2, $3, $4, $5)`
+	queryGetAudit    = `SELECT id, task_id, actor_id, action, before, after, created_at FROM task_audit_log WHERE task_id = $1 ORDER BY id DESC`
+
+	// Каталог активных заданий с числом выполнений пользователем $1 в текущем периоде.
+	// $2 и $3 — ключи текущих суток и недели, $4 фильтрует по факту выполнения (NULL — без фильтра)
+	queryUserTasksSource = `SELECT t.id, t.description, t.reward, t.starts_at, t.ends_at, t.is_archived, t.recurrence, t.max_completions,
+			t.verification, t.created_at, t.updated_at, c.completions, c.pending,
+			c.completions >= COALESCE(t.max_completions, 1) AS completed, c.completed_at
+		FROM tasks t LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE ct.status = 'approved') AS completions,
+				COUNT(*) FILTER (WHERE ct.status = 'pending') AS pending,
+				MAX(ct.completed_at) FILTER (WHERE ct.status = 'approved') AS completed_at
+			FROM completed_tasks ct
+			WHERE ct.task_id = t.id AND ct.user_id = $1 AND CASE t.recurrence
+				WHEN 'daily' THEN ct.period_key = $2
+				WHEN 'weekly' THEN ct.period_key = $3
+				ELSE TRUE END
+		) c ON TRUE
+		WHERE t.is_archived = FALSE`
+	queryListUserTasks = `SELECT * FROM (` + queryUserTasksSource + `) ut
+		WHERE ($4::BOOLEAN IS NULL OR ut.completed = $4) ORDER BY ut.id LIMIT $5 OFFSET $6`
+	queryCountUserTasks = `SELECT COUNT(*) FROM (` + queryUserTasksSource + `) ut
+		WHERE ($4::BOOLEAN IS NULL OR ut.completed = $4)`
+)
+
+// BeginTransaction начало транзакции
+func (r *TaskRepo) BeginTransaction(ctx context.Context) (pgx.Tx, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("BeginTransaction: %w", ErrFailedBeginTx)
+	}
+	r.logger.Info("Transaction started")
+	return tx, nil
+}
+
+// CreateTaskWithTx создание нового задания
+func (r *TaskRepo) CreateTaskWithTx(ctx context.Context, tx pgx.Tx, task *models.Task) (*models.Task, error) {
+	r.logger.Info("Executing query", "query", queryCreateTask, "description", task.Description)
+
+	created, err := scanTask(tx.QueryRow(ctx, queryCreateTask, task.Description, task.Reward, task.StartsAt, task.EndsAt, task.Recurrence, task.MaxCompletions, task.Verification, task.VerificationCodeHash))
+	if err != nil {
+		r.logger.Error("Failed to execute query to create task", "error", err)
+		return nil, fmt.Errorf("CreateTaskWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Task created", "task_id", created.ID)
+	return created, nil
+}
+
+// GetTaskWithTx получение задания с блокировкой для не атомарных операций
+func (r *TaskRepo) GetTaskWithTx(ctx context.Context, tx pgx.Tx, taskID int) (*models.Task, error) {
+	r.logger.Info("Executing query", "query", queryGetTaskForUpdate, "task_id", taskID)
+
+	task, err := scanTask(tx.QueryRow(ctx, queryGetTaskForUpdate, taskID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("Task not found", "task_id", taskID)
+			return nil, fmt.Errorf("GetTaskWithTx: %w", ErrTaskNotFound)
+		}
+		r.logger.Error("Failed to execute query to get task", "error", err, "task_id", taskID)
+		return nil, fmt.Errorf("GetTaskWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	return task, nil
+}
+
+// UpdateTaskWithTx изменение описания, награды и периода доступности задания
+func (r *TaskRepo) UpdateTaskWithTx(ctx context.Context, tx pgx.Tx, task *models.Task) (*models.Task, error) {
+	r.logger.Info("Executing query", "query", queryUpdateTask, "task_id", task.ID)
+
+	updated, err := scanTask(tx.QueryRow(ctx, queryUpdateTask, task.Description, task.Reward, task.StartsAt, task.EndsAt, task.Recurrence, task.MaxCompletions, task.Verification, task.VerificationCodeHash, task.ID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("Task not found", "task_id", task.ID)
+			return nil, fmt.Errorf("UpdateTaskWithTx: %w", ErrTaskNotFound)
+		}
+		r.logger.Error("Failed to execute query to update task", "error", err, "task_id", task.ID)
+		return nil, fmt.Errorf("UpdateTaskWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Task updated", "task_id", updated.ID)
+	return updated, nil
+}
+
+// ArchiveTaskWithTx перенос задания в архив
+func (r *TaskRepo) ArchiveTaskWithTx(ctx context.Context, tx pgx.Tx, taskID int) (*models.Task, error) {
+	r.logger.Info("Executing query", "query", queryArchiveTask, "task_id", taskID)
+
+	archived, err := scanTask(tx.QueryRow(ctx, queryArchiveTask, taskID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			r.logger.Info("Task not found", "task_id", taskID)
+			return nil, fmt.Errorf("ArchiveTaskWithTx: %w", ErrTaskNotFound)
+		}
+		r.logger.Error("Failed to execute query to archive task", "error", err, "task_id", taskID)
+		return nil, fmt.Errorf("ArchiveTaskWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	r.logger.Info("Task archived", "task_id", archived.ID)
+	return archived, nil
+}
+
+// ListTasks получение страницы списка заданий
+func (r *TaskRepo) ListTasks(ctx context.Context, includeArchived bool, limit, offset int) ([]models.Task, error) {
+	tasks := make([]models.Task, 0, limit)
+
+	r.logger.Info("Executing query", "query", queryListTasks, "include_archived", includeArchived, "limit", limit, "offset", offset)
+	rows, err := r.db.Query(ctx, queryListTasks, includeArchived, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to execute query to list tasks", "error", err)
+		return tasks, fmt.Errorf("ListTasks: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return tasks, fmt.Errorf("ListTasks: failed to parse rows: %w", err)
+		}
+		tasks = append(tasks, *task)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return tasks, fmt.Errorf("ListTasks: error during rows iteration: %w", err)
+	}
+
+	r.logger.Info("Tasks listed", "count", len(tasks))
+	return tasks, nil
+}
+
+// CountTasks получение общего количества заданий
+func (r *TaskRepo) CountTasks(ctx context.Context, includeArchived bool) (int, error) {
+	var total int
+
+	r.logger.Info("Executing query", "query", queryCountTasks, "include_archived", includeArchived)
+	err := r.db.QueryRow(ctx, queryCountTasks, includeArchived).Scan(&total)
+	if err != nil {
+		r.logger.Error("Failed to execute query to count tasks", "error", err)
+		return 0, fmt.Errorf("CountTasks: %w", ErrFailedExecuteQuery)
+	}
+
+	return total, nil
+}
+
+// AddTaskAuditWithTx запись изменения задания в журнал аудита
+func (r *TaskRepo) AddTaskAuditWithTx(ctx context.Context, tx pgx.Tx, entry *models.TaskAuditEntry) error {
+	r.logger.Info("Executing query", "query", queryAddAudit, "task_id", entry.TaskID, "actor_id", entry.ActorID, "action", entry.Action)
+
+	_, err := tx.Exec(ctx, queryAddAudit, entry.TaskID, entry.ActorID, entry.Action, entry.Before, entry.After)
+	if err != nil {
+		r.logger.Error("Failed to execute query to add task audit", "error", err, "task_id", entry.TaskID)
+		return fmt.Errorf("AddTaskAuditWithTx: %w", ErrFailedExecuteQuery)
+	}
+
+	return nil
+}
+
+// GetTaskAudit получение журнала изменений задания
+func (r *TaskRepo) GetTaskAudit(ctx context.Context, taskID int) ([]models.TaskAuditEntry, error) {
+	entries := make([]models.TaskAuditEntry, 0)
+
+	r.logger.Info("Executing query", "query", queryGetAudit, "task_id", taskID)
+	rows, err := r.db.Query(ctx, queryGetAudit, taskID)
+	if err != nil {
+		r.logger.Error("Failed to execute query to get task audit", "error", err, "task_id", taskID)
+		return entries, fmt.Errorf("GetTaskAudit: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.TaskAuditEntry
+		if err = rows.Scan(&e.ID, &e.TaskID, &e.ActorID, &e.Action, &e.Before, &e.After, &e.CreatedAt); err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return entries, fmt.Errorf("GetTaskAudit: failed to parse rows: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return entries, fmt.Errorf("GetTaskAudit: error during rows iteration: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ListUserTasks получение страницы каталога заданий со статусом выполнения пользователем в текущем периоде
+func (r *TaskRepo) ListUserTasks(ctx context.Context, userID int, now time.Time, completed *bool, limit, offset int) ([]models.UserTask, error) {
+	tasks := make([]models.UserTask, 0, limit)
+
+	r.logger.Info("Executing query", "query", queryListUserTasks, "user_id", userID, "limit", limit, "offset", offset)
+	rows, err := r.db.Query(ctx, queryListUserTasks, userID, models.DailyPeriodKey(now), models.WeeklyPeriodKey(now), completed, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to execute query to list user tasks", "error", err, "user_id", userID)
+		return tasks, fmt.Errorf("ListUserTasks: %w", ErrFailedExecuteQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.UserTask
+		err = rows.Scan(&t.ID, &t.Description, &t.Reward, &t.StartsAt, &t.EndsAt, &t.IsArchived, &t.Recurrence, &t.MaxCompletions,
+			&t.Verification, &t.CreatedAt, &t.UpdatedAt, &t.Completions, &t.Pending, &t.Completed, &t.CompletedAt)
+		if err != nil {
+			r.logger.Error("Failed to parse row", "error", err)
+			return tasks, fmt.Errorf("ListUserTasks: failed to parse rows: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error during rows iteration", "error", err)
+		return tasks, fmt.Errorf("ListUserTasks: error during rows iteration: %w", err)
+	}
+
+	r.logger.Info("User tasks listed", "user_id", userID, "count", len(tasks))
+	return tasks, nil
+}
+
+// CountUserTasks получение количества заданий в каталоге с учетом фильтра по выполнению
+func (r *TaskRepo) CountUserTasks(ctx context.Context, userID int, now time.Time, completed *bool) (int, error) {
+	var total int
+
+	r.logger.Info("Executing query", "query", queryCountUserTasks, "user_id", userID)
+	err := r.db.QueryRow(ctx, queryCountUserTasks, userID, models.DailyPeriodKey(now), models.WeeklyPeriodKey(now), completed).Scan(&total)
+	if err != nil {
+		r.logger.Error("Failed to execute query to count user tasks", "error", err, "user_id", userID)
+		return 0, fmt.Errorf("CountUserTasks: %w", ErrFailedExecuteQuery)
+	}
+
+	return total, nil
+}
+
+// scanTask считывает строку таблицы tasks, выбранную с колонками taskColumns
+func scanTask(row pgx.Row) (*models.Task, error) {
+	var t models.Task
+	err := row.Scan(&t.ID, &t.Description, &t.Reward, &t.StartsAt, &t.EndsAt, &t.IsArchived, &t.Recurrence, &t.MaxCompletions,
+		&t.Verification, &t.VerificationCodeHash, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
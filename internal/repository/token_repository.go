@@ -2,25 +2,40 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"user-management/internal/models"
+
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
 type TokenRepository interface {
-	StoreToken(ctx context.Context, userID int, token string, expiresAt time.Time) error
-	IsTokenValid(ctx context.Context, token string) (bool, error)
-	RevokeToken(ctx context.Context, token string) error
+	StoreToken(ctx context.Context, userID int, sessionID *int64, tokenHash string, expiresAt time.Time) error
+	StoreClientToken(ctx context.Context, userID *int, clientID int64, tokenHash string, expiresAt time.Time) error
+	IsTokenValid(ctx context.Context, tokenHash string) (bool, error)
+	RevokeToken(ctx context.Context, tokenHash string) error
+	StoreRefreshToken(ctx context.Context, userID int, tokenHash, familyID string, expiresAt time.Time) error
+	UseRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error)
+	RevokeUserTokensWithTx(ctx context.Context, tx pgx.Tx, userID int) error
+	RevokeClientTokensWithTx(ctx context.Context, tx pgx.Tx, userID int, clientID int64) error
+	DeleteExpiredTokens(ctx context.Context, retention time.Duration, limit int) (int64, error)
+	DeleteExpiredRefreshTokens(ctx context.Context, retention time.Duration, limit int) (int64, error)
 }
 
 type TokenRepo struct {
-	db     *pgx.Conn
+	db     *pgxpool.Pool
 	logger *slog.Logger
 }
 
-func NewTokenRepo(db *pgx.Conn, logger *slog.Logger) *TokenRepo {
+func NewTokenRepo(db *pgxpool.Pool, logger *slog.Logger) *TokenRepo {
 	return &TokenRepo{
 		db:     db,
 		logger: logger,
@@ -29,56 +44,238 @@ func NewTokenRepo(db *pgx.Conn, logger *slog.Logger) *TokenRepo {
 
 // SQL запросы
 const (
-	queryStoreToken        = `INSERT INTO tokens(user_id, token, expires_at, is_revoked) VALUES ($1, $2, $3, FALSE)`
-	queryIsTokenValid      = `SELECT EXISTS (SELECT 1 FROM tokens WHERE token = $1 AND expires_at > NOW() AND is_revoked = FALSE)`
-	queryUpdateTokenRevoke = `UPDATE tokens SET is_revoked = TRUE WHERE token = $1`
+	queryStoreToken        = `INSERT INTO tokens(user_id, session_id, token_hash, expires_at, is_revoked) VALUES ($1, $2, $3, $4, FALSE)`
+	queryIsTokenValid      = `SELECT EXISTS (SELECT 1 FROM tokens WHERE token_hash = $1 AND expires_at > NOW() AND is_revoked = FALSE)`
+	queryUpdateTokenRevoke = `UPDATE tokens SET is_revoked = TRUE WHERE token_hash = $1`
+	// Токен стороннего приложения привязан к приложению; пользователя у токена client_credentials нет
+	queryStoreClientToken   = `INSERT INTO tokens(user_id, client_id, token_hash, expires_at, is_revoked) VALUES ($1, $2, $3, $4, FALSE)`
+	queryRevokeClientTokens = `UPDATE tokens SET is_revoked = TRUE WHERE user_id = $1 AND client_id = $2 AND is_revoked = FALSE`
+
+	queryStoreRefreshToken = `INSERT INTO refresh_tokens(user_id, token_hash, family_id, expires_at, is_revoked) VALUES ($1, $2, $3, $4, FALSE)`
+	queryUseRefreshToken   = `UPDATE refresh_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND is_revoked = FALSE AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, family_id, created_at, expires_at, used_at, is_revoked`
+	queryGetRefreshToken         = `SELECT id, user_id, token_hash, family_id, created_at, expires_at, used_at, is_revoked FROM refresh_tokens WHERE token_hash = $1`
+	queryRevokeUserTokens        = `UPDATE tokens SET is_revoked = TRUE WHERE user_id = $1 AND is_revoked = FALSE`
+	queryRevokeUserRefreshTokens = `UPDATE refresh_tokens SET is_revoked = TRUE WHERE user_id = $1 AND is_revoked = FALSE`
+	queryRevokeUserAllSessions   = `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	// Семейство отзывается одним запросом вместе с сессией и ее access токенами. Токены отзываются и у уже
+	// завершенной сессии: ее могли завершить раньше, не отозвав токены. Запрос возвращает ID сессии семейства
+	queryRevokeRefreshTokenFamily = `WITH revoked_refresh AS (
+			UPDATE refresh_tokens SET is_revoked = TRUE WHERE family_id = $1 AND is_revoked = FALSE
+		), family_session AS (
+			UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW()) WHERE family_id = $1 RETURNING id
+		), revoked_access AS (
+			UPDATE tokens SET is_revoked = TRUE WHERE session_id IN (SELECT id FROM family_session) AND is_revoked = FALSE
+		)
+		SELECT id FROM family_session`
+
+	// Токены удаляются пачками; строки, заблокированные параллельной очисткой другого экземпляра, пропускаются.
+	// Отозванный токен без записи отклоняется так же, как с записью, поэтому его возраст считается от выпуска
+	queryDeleteExpiredTokens = `DELETE FROM tokens WHERE id IN (
+		SELECT id FROM tokens WHERE expires_at < NOW() - $1::INTERVAL OR (is_revoked AND created_at < NOW() - $1::INTERVAL)
+		LIMIT $2 FOR UPDATE SKIP LOCKED)`
+	// Использованный, но не отозванный refresh токен хранится до истечения срока действия, иначе его повторное
+	// использование не будет обнаружено
+	queryDeleteExpiredRefreshTokens = `DELETE FROM refresh_tokens WHERE id IN (
+		SELECT id FROM refresh_tokens WHERE expires_at < NOW() - $1::INTERVAL OR (is_revoked AND created_at < NOW() - $1::INTERVAL)
+		LIMIT $2 FOR UPDATE SKIP LOCKED)`
 )
 
-// StoreToken сохраняет токен в базе данных
-func (tr *TokenRepo) StoreToken(ctx context.Context, userID int, token string, expiresAt time.Time) error {
-	tr.logger.Info("Executing query", "method", "StoreToken", "query", queryStoreToken, "token", token, "user_id", userID, "expires_at", expiresAt)
+// StoreToken сохраняет хэш идентификатора access токена в базе данных
+func (tr *TokenRepo) StoreToken(ctx context.Context, userID int, sessionID *int64, tokenHash string, expiresAt time.Time) error {
+	tr.logger.Info("Executing query", "method", "StoreToken", "query", queryStoreToken, "user_id", userID, "expires_at", expiresAt)
 
-	result, err := tr.db.Exec(ctx, queryStoreToken, userID, token, expiresAt)
+	result, err := tr.db.Exec(ctx, queryStoreToken, userID, sessionID, tokenHash, expiresAt)
 	if err != nil {
 		return tr.handleError("StoreToken", "Failed to execute query to store token", err)
 	}
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		tr.logger.Warn("No rows affected, token may already be revoked", "method", "StoreToken", "token", token)
+		tr.logger.Warn("No rows affected, token may already be revoked", "method", "StoreToken", "user_id", userID)
 		return fmt.Errorf("token not found or already revoked")
 	}
 
-	tr.logger.Info("Token successfully stored", "token", token)
+	tr.logger.Info("Token successfully stored", "user_id", userID)
+	return nil
+}
+
+// StoreClientToken сохраняет хэш идентификатора access токена, выданного стороннему приложению
+func (tr *TokenRepo) StoreClientToken(ctx context.Context, userID *int, clientID int64, tokenHash string, expiresAt time.Time) error {
+	tr.logger.Info("Executing query", "method", "StoreClientToken", "query", queryStoreClientToken, "client_id", clientID, "expires_at", expiresAt)
+
+	if _, err := tr.db.Exec(ctx, queryStoreClientToken, userID, clientID, tokenHash, expiresAt); err != nil {
+		return tr.handleError("StoreClientToken", "Failed to execute query to store client token", err)
+	}
+
+	tr.logger.Info("Client token successfully stored", "client_id", clientID)
 	return nil
 }
 
-// IsTokenValid проверяет валидность токена
-func (tr *TokenRepo) IsTokenValid(ctx context.Context, token string) (bool, error) {
-	tr.logger.Info("Executing query", "method", "IsTokenValid", "query", queryIsTokenValid, "token", token)
+// IsTokenValid проверяет валидность токена по хэшу его идентификатора
+func (tr *TokenRepo) IsTokenValid(ctx context.Context, tokenHash string) (bool, error) {
+	tr.logger.Info("Executing query", "method", "IsTokenValid", "query", queryIsTokenValid)
 
 	var isValid bool
-	err := tr.db.QueryRow(ctx, queryIsTokenValid, token).Scan(&isValid)
+	err := tr.db.QueryRow(ctx, queryIsTokenValid, tokenHash).Scan(&isValid)
 	if err != nil {
 		return false, tr.handleError("IsTokenValid", "Failed to execute query to validate token", err)
 	}
 
-	tr.logger.Info("Token validation result", "isValid", isValid, "token", token)
+	tr.logger.Info("Token validation result", "isValid", isValid)
 	return isValid, nil
 }
 
-// RevokeToken отзывает токен
-func (tr *TokenRepo) RevokeToken(ctx context.Context, token string) error {
-	tr.logger.Info("Executing query", "method", "RevokeToken", "query", queryUpdateTokenRevoke, "token", token)
+// RevokeToken отзывает токен по хэшу его идентификатора
+func (tr *TokenRepo) RevokeToken(ctx context.Context, tokenHash string) error {
+	tr.logger.Info("Executing query", "method", "RevokeToken", "query", queryUpdateTokenRevoke)
 
-	_, err := tr.db.Exec(ctx, queryUpdateTokenRevoke, token)
+	_, err := tr.db.Exec(ctx, queryUpdateTokenRevoke, tokenHash)
 	if err != nil {
 		return tr.handleError("RevokeToken", "Failed to execute query to revoke token", err)
 	}
-	tr.logger.Info("Token successfully revoked", "token", token)
+	tr.logger.Info("Token successfully revoked")
+	return nil
+}
+
+// StoreRefreshToken сохраняет хэш refresh токена в базе данных
+func (tr *TokenRepo) StoreRefreshToken(ctx context.Context, userID int, tokenHash, familyID string, expiresAt time.Time) error {
+	tr.logger.Info("Executing query", "method", "StoreRefreshToken", "query", queryStoreRefreshToken, "user_id", userID, "family_id", familyID, "expires_at", expiresAt)
+
+	_, err := tr.db.Exec(ctx, queryStoreRefreshToken, userID, tokenHash, familyID, expiresAt)
+	if err != nil {
+		return tr.handleError("StoreRefreshToken", "Failed to execute query to store refresh token", err)
+	}
+
+	tr.logger.Info("Refresh token successfully stored", "user_id", userID, "family_id", familyID)
+	return nil
+}
+
+// UseRefreshToken атомарно помечает активный refresh токен как использованный и возвращает его
+func (tr *TokenRepo) UseRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	tr.logger.Info("Executing query", "method", "UseRefreshToken", "query", queryUseRefreshToken)
+
+	rt, err := scanRefreshToken(tr.db.QueryRow(ctx, queryUseRefreshToken, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			tr.logger.Info("Active refresh token not found", "method", "UseRefreshToken")
+			return nil, fmt.Errorf("UseRefreshToken: %w", ErrRefreshTokenNotFound)
+		}
+		return nil, tr.handleError("UseRefreshToken", "Failed to execute query to use refresh token", err)
+	}
+
+	tr.logger.Info("Refresh token marked as used", "user_id", rt.UserID, "family_id", rt.FamilyID)
+	return rt, nil
+}
+
+// GetRefreshToken возвращает refresh токен по хэшу независимо от его состояния
+func (tr *TokenRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	tr.logger.Info("Executing query", "method", "GetRefreshToken", "query", queryGetRefreshToken)
+
+	rt, err := scanRefreshToken(tr.db.QueryRow(ctx, queryGetRefreshToken, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			tr.logger.Info("Refresh token not found", "method", "GetRefreshToken")
+			return nil, fmt.Errorf("GetRefreshToken: %w", ErrRefreshTokenNotFound)
+		}
+		return nil, tr.handleError("GetRefreshToken", "Failed to execute query to get refresh token", err)
+	}
+
+	return rt, nil
+}
+
+// RevokeRefreshTokenFamily отзывает все refresh токены семейства, завершает сессию, в рамках которой они выпущены,
+// и отзывает access токены этой сессии. Возвращает ID сессии или 0, если семейство выпущено без сессии
+func (tr *TokenRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error) {
+	var sessionID int64
+
+	tr.logger.Info("Executing query", "method", "RevokeRefreshTokenFamily", "query", queryRevokeRefreshTokenFamily, "family_id", familyID)
+
+	err := tr.db.QueryRow(ctx, queryRevokeRefreshTokenFamily, familyID).Scan(&sessionID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, tr.handleError("RevokeRefreshTokenFamily", "Failed to execute query to revoke refresh token family", err)
+	}
+
+	tr.logger.Info("Refresh token family revoked", "family_id", familyID, "session_id", sessionID)
+	return sessionID, nil
+}
+
+// RevokeUserTokensWithTx отзывает все access и refresh токены пользователя и завершает все его сессии
+func (tr *TokenRepo) RevokeUserTokensWithTx(ctx context.Context, tx pgx.Tx, userID int) error {
+	tr.logger.Info("Executing query", "method", "RevokeUserTokensWithTx", "query", queryRevokeUserTokens, "user_id", userID)
+
+	accessResult, err := tx.Exec(ctx, queryRevokeUserTokens, userID)
+	if err != nil {
+		return tr.handleError("RevokeUserTokensWithTx", "Failed to execute query to revoke user tokens", err)
+	}
+
+	tr.logger.Info("Executing query", "method", "RevokeUserTokensWithTx", "query", queryRevokeUserRefreshTokens, "user_id", userID)
+
+	refreshResult, err := tx.Exec(ctx, queryRevokeUserRefreshTokens, userID)
+	if err != nil {
+		return tr.handleError("RevokeUserTokensWithTx", "Failed to execute query to revoke user refresh tokens", err)
+	}
+
+	tr.logger.Info("Executing query", "method", "RevokeUserTokensWithTx", "query", queryRevokeUserAllSessions, "user_id", userID)
+
+	if _, err = tx.Exec(ctx, queryRevokeUserAllSessions, userID); err != nil {
+		return tr.handleError("RevokeUserTokensWithTx", "Failed to execute query to revoke user sessions", err)
+	}
+
+	tr.logger.Info("User tokens revoked", "user_id", userID, "access", accessResult.RowsAffected(), "refresh", refreshResult.RowsAffected())
+	return nil
+}
+
+// RevokeClientTokensWithTx отзывает access токены, выданные приложению от имени пользователя
+func (tr *TokenRepo) RevokeClientTokensWithTx(ctx context.Context, tx pgx.Tx, userID int, clientID int64) error {
+	tr.logger.Info("Executing query", "method", "RevokeClientTokensWithTx", "query", queryRevokeClientTokens, "user_id", userID, "client_id", clientID)
+
+	result, err := tx.Exec(ctx, queryRevokeClientTokens, userID, clientID)
+	if err != nil {
+		return tr.handleError("RevokeClientTokensWithTx", "Failed to execute query to revoke client tokens", err)
+	}
+
+	tr.logger.Info("Client tokens revoked", "user_id", userID, "client_id", clientID, "access", result.RowsAffected())
 	return nil
 }
 
+// DeleteExpiredTokens удаляет не больше limit access токенов, истекших или отозванных раньше чем retention назад,
+// и возвращает число удаленных строк
+func (tr *TokenRepo) DeleteExpiredTokens(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	tr.logger.Info("Executing query", "method", "DeleteExpiredTokens", "query", queryDeleteExpiredTokens, "retention", retention, "limit", limit)
+
+	result, err := tr.db.Exec(ctx, queryDeleteExpiredTokens, retention, limit)
+	if err != nil {
+		return 0, tr.handleError("DeleteExpiredTokens", "Failed to execute query to delete expired tokens", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// DeleteExpiredRefreshTokens удаляет не больше limit refresh токенов, истекших или отозванных раньше чем retention назад,
+// и возвращает число удаленных строк
+func (tr *TokenRepo) DeleteExpiredRefreshTokens(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	tr.logger.Info("Executing query", "method", "DeleteExpiredRefreshTokens", "query", queryDeleteExpiredRefreshTokens, "retention", retention, "limit", limit)
+
+	result, err := tr.db.Exec(ctx, queryDeleteExpiredRefreshTokens, retention, limit)
+	if err != nil {
+		return 0, tr.handleError("DeleteExpiredRefreshTokens", "Failed to execute query to delete expired refresh tokens", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// scanRefreshToken считывает строку таблицы refresh_tokens
+func scanRefreshToken(row pgx.Row) (*models.RefreshToken, error) {
+	var rt models.RefreshToken
+	err := row.Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.FamilyID, &rt.CreatedAt, &rt.ExpiresAt, &rt.UsedAt, &rt.IsRevoked)
+	if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
 // handleError служит для обработки ошибок и логирования
 func (tr *TokenRepo) handleError(method, message string, err error) error {
 	tr.logger.Error("Error", "method", method, "error", err)
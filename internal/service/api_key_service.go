@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/rbac"
+	"user-management/internal/repository"
+)
+
+// Ошибки работы с API ключами
+var (
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrUnknownScope        = errors.New("unknown scope")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiry must be in the future")
+)
+
+const (
+	// apiKeyPrefix префикс, по которому API ключ отличается от других секретов (например, при поиске утечек в коде)
+	apiKeyPrefix = "umk_"
+	// apiKeyDisplayLength сколько первых символов ключа сохраняется для отображения в списке
+	apiKeyDisplayLength = 12
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID int, req *dto.CreateAPIKeyDTO) (*dto.CreatedAPIKeyDTO, error)
+	ListAPIKeys(ctx context.Context, userID int) (*dto.APIKeysDTO, error)
+	RevokeAPIKey(ctx context.Context, userID int, keyID int64) error
+	ValidateAPIKey(ctx context.Context, key string, clientIP string) (*AccessClaims, error)
+}
+
+type DefaultAPIKeyService struct {
+	repo     repository.APIKeyRepository
+	roleRepo repository.RoleRepository
+	logger   *slog.Logger
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, roleRepo repository.RoleRepository, logger *slog.Logger) *DefaultAPIKeyService {
+	return &DefaultAPIKeyService{repo: repo, roleRepo: roleRepo, logger: logger}
+}
+
+// CreateAPIKey создает API ключ пользователя. Ключ возвращается только в ответе, в базе данных хранится его хэш
+func (s *DefaultAPIKeyService) CreateAPIKey(ctx context.Context, userID int, req *dto.CreateAPIKeyDTO) (*dto.CreatedAPIKeyDTO, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !rbac.IsValidPermission(scope) {
+			s.logger.Warn("Unknown api key scope", "user_id", userID, "scope", scope)
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		s.logger.Warn("API key expiry in the past", "user_id", userID, "expires_at", req.ExpiresAt)
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	secret, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate api key", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("CreateAPIKey: %w", err)
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   hashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err = s.repo.CreateAPIKey(ctx, apiKey); err != nil {
+		s.logger.Error("Failed to create api key", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("CreateAPIKey: %w", err)
+	}
+
+	s.logger.Info("API key created", "user_id", userID, "api_key_id", apiKey.ID, "scopes", scopes)
+	return &dto.CreatedAPIKeyDTO{APIKeyDTO: apiKeyDTO(apiKey), Key: key}, nil
+}
+
+// ListAPIKeys возвращает действующие API ключи пользователя без самих ключей
+func (s *DefaultAPIKeyService) ListAPIKeys(ctx context.Context, userID int) (*dto.APIKeysDTO, error) {
+	keys, err := s.repo.ListActiveAPIKeys(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list api keys", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ListAPIKeys: %w", err)
+	}
+
+	items := make([]dto.APIKeyDTO, 0, len(keys))
+	for i := range keys {
+		items = append(items, apiKeyDTO(&keys[i]))
+	}
+
+	return &dto.APIKeysDTO{Items: items}, nil
+}
+
+// RevokeAPIKey отзывает API ключ пользователя
+func (s *DefaultAPIKeyService) RevokeAPIKey(ctx context.Context, userID int, keyID int64) error {
+	if err := s.repo.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			s.logger.Warn("API key not found", "user_id", userID, "api_key_id", keyID)
+			return ErrAPIKeyNotFound
+		}
+		s.logger.Error("Failed to revoke api key", "user_id", userID, "api_key_id", keyID, "error", err)
+		return fmt.Errorf("RevokeAPIKey: %w", err)
+	}
+
+	s.logger.Info("API key revoked", "user_id", userID, "api_key_id", keyID)
+	return nil
+}
+
+// ValidateAPIKey проверяет API ключ и возвращает данные владельца с его текущими ролями и ограничениями ключа
+func (s *DefaultAPIKeyService) ValidateAPIKey(ctx context.Context, key string, clientIP string) (*AccessClaims, error) {
+	apiKey, err := s.repo.GetActiveAPIKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			s.logger.Warn("API key is unknown, expired or revoked", "method", "ValidateAPIKey")
+			return nil, ErrInvalidAPIKey
+		}
+		s.logger.Error("Failed to get api key", "method", "ValidateAPIKey", "error", err)
+		return nil, fmt.Errorf("ValidateAPIKey: %w", err)
+	}
+
+	// Роли читаются при каждом запросе: в отличие от access токена, ключ живет долго и не должен сохранять отобранные роли
+	storedRoles, err := s.roleRepo.GetUserRoles(ctx, apiKey.UserID)
+	if err != nil {
+		s.logger.Error("Failed to get user roles", "method", "ValidateAPIKey", "user_id", apiKey.UserID, "error", err)
+		return nil, fmt.Errorf("ValidateAPIKey: %w", err)
+	}
+	roles := append([]string{string(rbac.RoleUser)}, storedRoles...)
+
+	// Ошибка обновления времени последнего использования не мешает выполнению запроса
+	if err = s.repo.TouchAPIKey(ctx, apiKey.ID, clientIP, sessionTouchInterval); err != nil {
+		s.logger.Error("Failed to touch api key", "method", "ValidateAPIKey", "api_key_id", apiKey.ID, "error", err)
+	}
+
+	s.logger.Info("API key validated successfully", "method", "ValidateAPIKey", "user_id", apiKey.UserID, "api_key_id", apiKey.ID)
+	return &AccessClaims{UserID: apiKey.UserID, Roles: roles, APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
+
+// apiKeyDTO преобразует API ключ в представление для ответа
+func apiKeyDTO(key *models.APIKey) dto.APIKeyDTO {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return dto.APIKeyDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+	}
+}
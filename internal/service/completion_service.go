@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/repository"
+)
+
+// Ошибки проверки заявок на выполнение заданий
+var (
+	ErrCompletionNotPending     = errors.New("task completion is not pending review")
+	ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+	ErrCallbackNotAllowed       = errors.New("task is not verified by callback")
+)
+
+type CompletionService interface {
+	ListCompletions(ctx context.Context, query *dto.CompletionListQueryDTO) (*dto.TaskCompletionsDTO, error)
+	ReviewCompletion(ctx context.Context, reviewerID int, completionID int64, approve bool) (*dto.TaskCompletionDTO, error)
+	HandleCallback(ctx context.Context, payload []byte, signature string, callback *dto.TaskCallbackDTO) (*dto.TaskCompletionDTO, error)
+}
+
+type DefaultCompletionService struct {
+	repo     repository.UserRepository
+	callback *CallbackVerifier
+	logger   *slog.Logger
+}
+
+func NewCompletionService(repo repository.UserRepository, callback *CallbackVerifier, logger *slog.Logger) *DefaultCompletionService {
+	return &DefaultCompletionService{repo: repo, callback: callback, logger: logger}
+}
+
+// ListCompletions предоставляет страницу заявок с указанным статусом (по умолчанию ожидающих проверки)
+func (s *DefaultCompletionService) ListCompletions(ctx context.Context, query *dto.CompletionListQueryDTO) (*dto.TaskCompletionsDTO, error) {
+	status := models.CompletionStatus(query.Status)
+	if status == "" {
+		status = models.CompletionStatusPending
+	}
+	s.logger.Info("Fetching task completions", "status", status)
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	total, err := s.repo.CountCompletions(ctx, status)
+	if err != nil {
+		s.logger.Error("Failed to count completions", "error", err)
+		return nil, fmt.Errorf("ListCompletions: error counting completions: %w", err)
+	}
+
+	completions, err := s.repo.ListCompletions(ctx, status, limit, query.Offset)
+	if err != nil {
+		s.logger.Error("Failed to list completions", "error", err)
+		return nil, fmt.Errorf("ListCompletions: error listing completions: %w", err)
+	}
+
+	items := make([]dto.TaskCompletionDTO, 0, len(completions))
+	for i := range completions {
+		items = append(items, *toTaskCompletionDTO(&completions[i]))
+	}
+
+	return &dto.TaskCompletionsDTO{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// ReviewCompletion подтверждает или отклоняет заявку по решению администратора
+func (s *DefaultCompletionService) ReviewCompletion(ctx context.Context, reviewerID int, completionID int64, approve bool) (*dto.TaskCompletionDTO, error) {
+	return s.review(ctx, &reviewerID, completionID, approve, false)
+}
+
+// HandleCallback применяет результат проверки, присланный внешней системой.
+// Тело запроса должно быть подписано HMAC-SHA256 с общим секретом
+func (s *DefaultCompletionService) HandleCallback(ctx context.Context, payload []byte, signature string, callback *dto.TaskCallbackDTO) (*dto.TaskCompletionDTO, error) {
+	if !s.callback.CheckSignature(payload, signature) {
+		s.logger.Warn("Invalid callback signature", "completion_id", callback.CompletionID)
+		return nil, ErrInvalidCallbackSignature
+	}
+
+	return s.review(ctx, nil, callback.CompletionID, *callback.Approved, true)
+}
+
+// review переводит ожидающую заявку в итоговый статус и при подтверждении начисляет баллы
+func (s *DefaultCompletionService) review(ctx context.Context, reviewerID *int, completionID int64, approve, viaCallback bool) (result *dto.TaskCompletionDTO, err error) {
+	s.logger.Info("Starting to review task completion", "completion_id", completionID, "approve", approve)
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	completion, err := s.repo.GetCompletionWithTx(ctx, tx, completionID)
+	if err != nil {
+		s.logger.Error("Failed to get completion", "error", err)
+		return nil, fmt.Errorf("error getting completion: %w", err)
+	}
+	if completion.Status != models.CompletionStatusPending {
+		s.logger.Warn("Completion already reviewed", "completion_id", completionID, "status", completion.Status)
+		return nil, ErrCompletionNotPending
+	}
+
+	task, err := s.repo.GetTask(ctx, completion.TaskID)
+	if err != nil {
+		s.logger.Error("Failed to get task", "error", err)
+		return nil, fmt.Errorf("error getting task: %w", err)
+	}
+	if viaCallback && task.Verification != models.TaskVerificationCallback {
+		s.logger.Warn("Callback for task without callback verification", "completion_id", completionID, "task_id", task.ID)
+		return nil, ErrCallbackNotAllowed
+	}
+
+	status := models.CompletionStatusRejected
+	if approve {
+		status = models.CompletionStatusApproved
+	}
+
+	if err = s.repo.SetCompletionStatusWithTx(ctx, tx, completionID, status, reviewerID); err != nil {
+		s.logger.Error("Failed to set completion status", "error", err)
+		return nil, fmt.Errorf("error setting completion status: %w", err)
+	}
+
+	if approve {
+		err = s.repo.AddPoint(ctx, tx, completion.UserID, task.Reward, models.PointReasonTaskReward, &completionID)
+		if err != nil {
+			s.logger.Error("Failed to add points for task", "error", err)
+			return nil, fmt.Errorf("error adding points: %w", err)
+		}
+	}
+
+	reviewedAt := time.Now()
+	completion.Status = status
+	completion.ReviewedAt = &reviewedAt
+	completion.ReviewedBy = reviewerID
+	s.logger.Info("Task completion reviewed", "completion_id", completionID, "status", status)
+	return toTaskCompletionDTO(completion), nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+)
+
+const (
+	testUserID     = 1
+	testReviewerID = 2
+	testTaskReward = 50
+	testSecret     = "callback-secret"
+)
+
+// newCompletionFixture создает сервис проверки заявок и ожидающую заявку на задание с указанным способом проверки
+func newCompletionFixture(verification models.TaskVerification) (*DefaultCompletionService, *fakeUserRepo, int64) {
+	repo := newFakeUserRepo()
+	repo.users[testUserID] = &models.User{ID: testUserID, UserName: "user"}
+	repo.tasks[1] = &models.Task{ID: 1, Reward: testTaskReward, Recurrence: models.TaskRecurrenceOnce, Verification: verification}
+	completionID := repo.addCompletion(models.TaskCompletion{
+		UserID: testUserID, TaskID: 1, PeriodKey: "#1", Status: models.CompletionStatusPending,
+	})
+
+	return NewCompletionService(repo, NewCallbackVerifier(testSecret), discardLogger()), repo, completionID
+}
+
+// sign подписывает тело обратного вызова так же, как внешняя система
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestReviewCompletion(t *testing.T) {
+	tests := []struct {
+		name       string
+		approve    bool
+		wantStatus models.CompletionStatus
+		wantPoints int
+	}{
+		{name: "approve credits points", approve: true, wantStatus: models.CompletionStatusApproved, wantPoints: testTaskReward},
+		{name: "reject credits nothing", approve: false, wantStatus: models.CompletionStatusRejected, wantPoints: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, completionID := newCompletionFixture(models.TaskVerificationManual)
+			ctx := context.Background()
+
+			result, err := svc.ReviewCompletion(ctx, testReviewerID, completionID, tt.approve)
+			if err != nil {
+				t.Fatalf("ReviewCompletion: %v", err)
+			}
+			if result.Status != string(tt.wantStatus) {
+				t.Errorf("status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			if result.ReviewedBy == nil || *result.ReviewedBy != testReviewerID {
+				t.Errorf("reviewed_by = %v, want %d", result.ReviewedBy, testReviewerID)
+			}
+
+			// Повторная проверка той же заявки не меняет статус и не начисляет баллы повторно
+			_, err = svc.ReviewCompletion(ctx, testReviewerID, completionID, !tt.approve)
+			if !errors.Is(err, ErrCompletionNotPending) {
+				t.Fatalf("second review error = %v, want %v", err, ErrCompletionNotPending)
+			}
+
+			if got := repo.completions[completionID].Status; got != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", got, tt.wantStatus)
+			}
+			if got := repo.users[testUserID].Balance; got != tt.wantPoints {
+				t.Errorf("balance = %d, want %d", got, tt.wantPoints)
+			}
+			// Начисление ссылается на заявку, а не на задание: по журналу видно, за какое выполнение начислены баллы
+			if entries := repo.pointsOf(testUserID); tt.approve && (len(entries) != 1 || entries[0].ReferenceID == nil || *entries[0].ReferenceID != completionID) {
+				t.Errorf("point entries = %+v, want one referencing completion %d", entries, completionID)
+			}
+		})
+	}
+}
+
+func TestHandleCallback(t *testing.T) {
+	approved := true
+	callback := &dto.TaskCallbackDTO{CompletionID: firstCompletionID, Approved: &approved}
+	payload := []byte(fmt.Sprintf(`{"completion_id":%d,"approved":true}`, firstCompletionID))
+
+	tests := []struct {
+		name         string
+		verification models.TaskVerification
+		signature    string
+		wantErr      error
+		wantPoints   int
+	}{
+		{
+			name: "valid signature approves", verification: models.TaskVerificationCallback,
+			signature: sign(testSecret, payload), wantPoints: testTaskReward,
+		},
+		{
+			name: "wrong secret", verification: models.TaskVerificationCallback,
+			signature: sign("other-secret", payload), wantErr: ErrInvalidCallbackSignature,
+		},
+		{
+			name: "signature of another payload", verification: models.TaskVerificationCallback,
+			signature: sign(testSecret, []byte(fmt.Sprintf(`{"completion_id":%d,"approved":true}`, firstCompletionID+1))), wantErr: ErrInvalidCallbackSignature,
+		},
+		{
+			name: "task without callback verification", verification: models.TaskVerificationManual,
+			signature: sign(testSecret, payload), wantErr: ErrCallbackNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, _ := newCompletionFixture(tt.verification)
+
+			result, err := svc.HandleCallback(context.Background(), payload, tt.signature, callback)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HandleCallback error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (result.Status != string(models.CompletionStatusApproved) || result.ReviewedBy != nil) {
+				t.Errorf("result = %+v, want approved without reviewer", result)
+			}
+			if got := repo.users[testUserID].Balance; got != tt.wantPoints {
+				t.Errorf("balance = %d, want %d", got, tt.wantPoints)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/mailer"
+	"user-management/internal/repository"
+)
+
+// Ошибки работы с адресом электронной почты
+var (
+	ErrEmailRequired                  = errors.New("email is required")
+	ErrEmailNotSet                    = errors.New("user has no email")
+	ErrEmailAlreadyVerified           = errors.New("email already verified")
+	ErrEmailNotVerified               = errors.New("email is not verified")
+	ErrInvalidVerificationToken       = errors.New("invalid or expired email verification token")
+	ErrUnknownEmailVerificationPolicy = errors.New("unknown email verification policy")
+)
+
+// EmailVerificationPolicy определяет, какие действия недоступны пользователю до подтверждения адреса
+type EmailVerificationPolicy string
+
+const (
+	EmailVerificationNone  EmailVerificationPolicy = "none"  // Подтверждение не требуется
+	EmailVerificationTasks EmailVerificationPolicy = "tasks" // Без подтверждения нельзя выполнять задания
+	EmailVerificationLogin EmailVerificationPolicy = "login" // Без подтверждения нельзя войти (и, следовательно, выполнять задания)
+)
+
+// ParseEmailVerificationPolicy проверяет значение политики из конфигурации
+func ParseEmailVerificationPolicy(value string) (EmailVerificationPolicy, error) {
+	switch policy := EmailVerificationPolicy(value); policy {
+	case EmailVerificationNone, EmailVerificationTasks, EmailVerificationLogin:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownEmailVerificationPolicy, value)
+	}
+}
+
+// blocksTasks сообщает, запрещено ли выполнение заданий без подтвержденного адреса
+func (p EmailVerificationPolicy) blocksTasks() bool {
+	return p == EmailVerificationTasks || p == EmailVerificationLogin
+}
+
+// blocksLogin сообщает, запрещен ли вход без подтвержденного адреса
+func (p EmailVerificationPolicy) blocksLogin() bool {
+	return p == EmailVerificationLogin
+}
+
+// EmailPolicy требования к адресу электронной почты пользователя
+type EmailPolicy struct {
+	Required     bool                    // Адрес обязателен при регистрации
+	Verification EmailVerificationPolicy // Действия, недоступные до подтверждения адреса
+}
+
+type EmailService interface {
+	SendVerification(ctx context.Context, userID int) error
+	ChangeEmail(ctx context.Context, userID int, req *dto.EmailDTO, client ClientInfo) error
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+type DefaultEmailService struct {
+	repo            repository.UserRepository
+	hasher          PasswordHasher
+	throttle        LoginThrottle
+	mailer          mailer.Mailer
+	secret          []byte
+	verificationTTL time.Duration
+	verificationURL string
+	logger          *slog.Logger
+}
+
+func NewEmailService(repo repository.UserRepository, hasher PasswordHasher, throttle LoginThrottle, mailer mailer.Mailer, cfg *config.ApiServer,
+	logger *slog.Logger) *DefaultEmailService {
+	secret := cfg.EmailVerificationSecret
+	if secret == "" {
+		secret = cfg.AuthSecretKey
+	}
+
+	return &DefaultEmailService{
+		repo:            repo,
+		hasher:          hasher,
+		throttle:        throttle,
+		mailer:          mailer,
+		secret:          []byte(secret),
+		verificationTTL: cfg.EmailVerificationTTL,
+		verificationURL: cfg.EmailVerificationURL,
+		logger:          logger,
+	}
+}
+
+// SendVerification отправляет на текущий адрес пользователя письмо с подписанной ссылкой подтверждения
+func (s *DefaultEmailService) SendVerification(ctx context.Context, userID int) error {
+	storedUser, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "error", err)
+		return fmt.Errorf("SendVerification: error getting user: %w", err)
+	}
+
+	if storedUser.Email == nil {
+		s.logger.Warn("User has no email", "user_id", userID)
+		return ErrEmailNotSet
+	}
+	if storedUser.VerifiedAt != nil {
+		s.logger.Warn("Email already verified", "user_id", userID)
+		return ErrEmailAlreadyVerified
+	}
+
+	expiresAt := time.Now().Add(s.verificationTTL)
+	token := s.signVerificationToken(userID, *storedUser.Email, expiresAt)
+
+	msg := mailer.Message{
+		To:      *storedUser.Email,
+		Subject: "Подтверждение адреса электронной почты",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nДля подтверждения адреса перейдите по ссылке:\n%s\n\nСсылка действует до %s.\n",
+			storedUser.UserName, linkWithToken(s.verificationURL, token), expiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err = s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error("Failed to send verification mail", "user_id", userID, "error", err)
+		return fmt.Errorf("SendVerification: error sending mail: %w", err)
+	}
+
+	s.logger.Info("Verification mail sent", "user_id", userID)
+	return nil
+}
+
+// ChangeEmail изменяет адрес пользователя после проверки текущего пароля и отправляет письмо для подтверждения нового адреса.
+// На прежний подтвержденный адрес отправляется уведомление о смене. Неверный пароль засчитывается как неудачная попытка
+// входа, как и при смене пароля: по адресу восстанавливается пароль, поэтому его смена не должна быть доступна
+// одному лишь украденному токену
+func (s *DefaultEmailService) ChangeEmail(ctx context.Context, userID int, req *dto.EmailDTO, client ClientInfo) error {
+	storedUser, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("ChangeEmail: error getting user: %w", err)
+	}
+
+	if err = s.throttle.Check(ctx, storedUser.UserName, client.IP); err != nil {
+		return err
+	}
+
+	ok, err := s.hasher.Verify(req.CurrentPassword, storedUser.Password)
+	if err != nil {
+		s.logger.Error("Failed to verify password", "user_id", userID, "error", err)
+		return fmt.Errorf("ChangeEmail: error verifying password: %w", err)
+	}
+	if !ok {
+		s.logger.Warn("Incorrect current password", "user_id", userID)
+		if err = s.throttle.RecordFailure(ctx, storedUser.UserName, client.IP); err != nil {
+			return fmt.Errorf("ChangeEmail: %w", err)
+		}
+		return ErrInvalidCurrentPassword
+	}
+
+	email := normalizeEmail(req.Email)
+
+	if err = s.repo.UpdateEmail(ctx, userID, email); err != nil {
+		s.logger.Error("Failed to update email", "user_id", userID, "error", err)
+		return fmt.Errorf("ChangeEmail: error updating email: %w", err)
+	}
+
+	s.logger.Info("Email changed", "user_id", userID)
+	if storedUser.Email != nil && storedUser.VerifiedAt != nil && *storedUser.Email != email {
+		s.notifyEmailChanged(ctx, storedUser)
+	}
+	return s.SendVerification(ctx, userID)
+}
+
+// notifyEmailChanged сообщает на прежний адрес о его смене. Ошибка отправки только записывается в журнал:
+// адрес уже изменен, и письмо на новый адрес должно быть отправлено
+func (s *DefaultEmailService) notifyEmailChanged(ctx context.Context, user *models.User) {
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: "Адрес электронной почты изменен",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nАдрес электронной почты вашей учетной записи изменен. Если вы этого не делали, "+
+			"смените пароль и завершите все сессии.\n", user.UserName),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error("Failed to send email change notice", "user_id", user.ID, "error", err)
+	}
+}
+
+// VerifyEmail проверяет подпись и срок действия ссылки и отмечает адрес как подтвержденный
+func (s *DefaultEmailService) VerifyEmail(ctx context.Context, token string) error {
+	userID, email, ok := s.parseVerificationToken(token, time.Now())
+	if !ok {
+		s.logger.Warn("Invalid email verification token")
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, userID, email); err != nil {
+		if errors.Is(err, repository.ErrEmailMismatch) {
+			s.logger.Warn("Verification token issued for another email", "user_id", userID)
+			return ErrInvalidVerificationToken
+		}
+		s.logger.Error("Failed to mark email verified", "user_id", userID, "error", err)
+		return fmt.Errorf("VerifyEmail: error marking email verified: %w", err)
+	}
+
+	s.logger.Info("Email verified", "user_id", userID)
+	return nil
+}
+
+// signVerificationToken формирует токен подтверждения: данные и их HMAC-SHA256 подпись в base64url, разделенные точкой.
+// Адрес входит в подписанные данные, поэтому после смены адреса старые ссылки перестают действовать
+func (s *DefaultEmailService) signVerificationToken(userID int, email string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d:%d:%s", userID, expiresAt.Unix(), email)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// parseVerificationToken проверяет подпись и срок действия токена и возвращает ID пользователя и адрес
+func (s *DefaultEmailService) parseVerificationToken(token string, now time.Time) (int, string, bool) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return 0, "", false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, "", false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(string(payload))) {
+		return 0, "", false
+	}
+
+	parts := strings.SplitN(string(payload), ":", 3)
+	if len(parts) != 3 {
+		return 0, "", false
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", false
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return 0, "", false
+	}
+
+	return userID, parts[2], true
+}
+
+func (s *DefaultEmailService) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// normalizeEmail приводит адрес к виду, в котором он хранится в базе данных
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// linkWithToken добавляет токен параметром token к адресу страницы
+func linkWithToken(rawURL, token string) string {
+	link, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
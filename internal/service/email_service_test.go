@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/mailer"
+	"user-management/internal/pkg/passhash"
+)
+
+// emailFixture сервис адресов электронной почты с пользователем testUserID, у которого задан пароль testCurrentPassword
+type emailFixture struct {
+	svc      *DefaultEmailService
+	users    *fakeUserRepo
+	attempts *fakeLoginAttemptRepo
+	mail     *mailer.MemoryMailer
+}
+
+func newEmailFixture(t *testing.T) *emailFixture {
+	t.Helper()
+
+	cfg := &config.ApiServer{
+		PasswordHashAlgorithm:   passhash.AlgorithmArgon2id,
+		Argon2Memory:            64,
+		Argon2Iterations:        1,
+		Argon2Parallelism:       1,
+		LoginMaxFailures:        5,
+		LoginIPMaxFailures:      20,
+		LoginLockoutBase:        time.Minute,
+		LoginLockoutMax:         time.Hour,
+		LoginFailureWindow:      time.Hour,
+		EmailVerificationSecret: "email-secret",
+		EmailVerificationTTL:    time.Hour,
+		EmailVerificationURL:    "https://example.com/verify",
+	}
+	hasher, err := passhash.New(cfg)
+	if err != nil {
+		t.Fatalf("passhash.New: %v", err)
+	}
+	hash, err := hasher.Hash(testCurrentPassword)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	users := newFakeUserRepo()
+	users.users[testUserID] = &models.User{ID: testUserID, UserName: "alice", Password: hash}
+	attempts := newFakeLoginAttemptRepo()
+	mail := mailer.NewMemoryMailer()
+
+	return &emailFixture{
+		svc:      NewEmailService(users, hasher, NewLoginThrottle(attempts, users, cfg, discardLogger()), mail, cfg, discardLogger()),
+		users:    users,
+		attempts: attempts,
+		mail:     mail,
+	}
+}
+
+// linkToken извлекает токен из ссылки в тексте письма
+func linkToken(t *testing.T, body string) string {
+	t.Helper()
+
+	for _, field := range strings.Fields(body) {
+		if link, err := url.Parse(field); err == nil && link.Query().Has("token") {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link with token in mail body %q", body)
+	return ""
+}
+
+func TestChangeEmail(t *testing.T) {
+	const (
+		oldEmail = "alice@example.com"
+		newEmail = "alice@example.org"
+	)
+
+	tests := []struct {
+		name        string
+		oldEmail    string // Пустая строка — адрес не задан
+		oldVerified bool
+		password    string
+		wantErr     error
+		wantTo      []string // Получатели писем по порядку
+	}{
+		{name: "notifies verified old address", oldEmail: oldEmail, oldVerified: true, password: testCurrentPassword, wantTo: []string{oldEmail, newEmail}},
+		{name: "unverified old address", oldEmail: oldEmail, password: testCurrentPassword, wantTo: []string{newEmail}},
+		{name: "no old address", password: testCurrentPassword, wantTo: []string{newEmail}},
+		// Без текущего пароля украденный токен не позволяет перенаправить ссылку сброса пароля на чужой адрес
+		{name: "wrong password", oldEmail: oldEmail, oldVerified: true, password: "wrong-passw0rd", wantErr: ErrInvalidCurrentPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEmailFixture(t)
+			user := f.users.users[testUserID]
+			if tt.oldEmail != "" {
+				email := tt.oldEmail
+				user.Email = &email
+			}
+			if tt.oldVerified {
+				verifiedAt := time.Now()
+				user.VerifiedAt = &verifiedAt
+			}
+
+			err := f.svc.ChangeEmail(context.Background(), testUserID, &dto.EmailDTO{Email: " Alice@Example.org ", CurrentPassword: tt.password},
+				ClientInfo{IP: "192.0.2.1"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangeEmail error = %v, want %v", err, tt.wantErr)
+			}
+
+			var to []string
+			for _, msg := range f.mail.Messages() {
+				to = append(to, msg.To)
+			}
+			if len(to) != len(tt.wantTo) {
+				t.Fatalf("mail sent to %v, want %v", to, tt.wantTo)
+			}
+			for i := range to {
+				if to[i] != tt.wantTo[i] {
+					t.Errorf("mail sent to %v, want %v", to, tt.wantTo)
+				}
+			}
+
+			if tt.wantErr != nil {
+				if user.Email == nil || *user.Email != tt.oldEmail || user.VerifiedAt == nil {
+					t.Errorf("email = %v, verified_at = %v, want unchanged", user.Email, user.VerifiedAt)
+				}
+				if got := f.attempts.failures[loginAttemptKey(models.LoginAttemptScopeAccount, "alice")]; got != 1 {
+					t.Errorf("login failures = %d, want 1", got)
+				}
+				return
+			}
+			if user.Email == nil || *user.Email != newEmail || user.VerifiedAt != nil {
+				t.Errorf("email = %v, verified_at = %v, want unverified %s", user.Email, user.VerifiedAt, newEmail)
+			}
+		})
+	}
+}
+
+func TestParseVerificationToken(t *testing.T) {
+	svc := &DefaultEmailService{secret: []byte("email-secret")}
+	now := time.Now()
+	valid := svc.signVerificationToken(testUserID, "alice@example.com", now.Add(time.Hour))
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	// encode подписывает произвольные данные тем же ключом, чтобы проверить разбор подписанного, но некорректного токена
+	encode := func(data string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(data)) + "." + base64.RawURLEncoding.EncodeToString(svc.sign(data))
+	}
+	decodedPayload, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		now       time.Time
+		wantOK    bool
+		wantEmail string
+	}{
+		{name: "valid", token: valid, now: now, wantOK: true, wantEmail: "alice@example.com"},
+		{name: "expired", token: valid, now: now.Add(2 * time.Hour)},
+		{name: "expires at the last second", token: valid, now: now.Add(time.Hour), wantOK: true, wantEmail: "alice@example.com"},
+		{
+			name:  "tampered payload",
+			token: base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(decodedPayload), "alice@", "mallory@", 1))) + "." + signature,
+			now:   now,
+		},
+		{name: "tampered signature", token: payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged-signature-of-32-bytes!!!!")), now: now},
+		{name: "signed with another secret", token: (&DefaultEmailService{secret: []byte("other")}).signVerificationToken(testUserID, "alice@example.com", now.Add(time.Hour)), now: now},
+		{name: "no separator", token: payload + signature, now: now},
+		{name: "empty", token: "", now: now},
+		{name: "extra separator", token: valid + ".x", now: now},
+		{name: "invalid payload base64", token: "!!!." + signature, now: now},
+		{name: "invalid signature base64", token: payload + ".!!!", now: now},
+		{name: "padded base64", token: payload + "=." + signature, now: now},
+		{name: "missing fields", token: encode(fmt.Sprintf("%d:%d", testUserID, now.Add(time.Hour).Unix())), now: now},
+		{name: "non-numeric user", token: encode(fmt.Sprintf("alice:%d:alice@example.com", now.Add(time.Hour).Unix())), now: now},
+		{name: "non-numeric expiry", token: encode(fmt.Sprintf("%d:soon:alice@example.com", testUserID)), now: now},
+		// Адрес может содержать двоеточие: поле адреса последнее и разбирается целиком
+		{
+			name: "email with colon", token: encode(fmt.Sprintf("%d:%d:a:b@example.com", testUserID, now.Add(time.Hour).Unix())), now: now,
+			wantOK: true, wantEmail: "a:b@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, email, ok := svc.parseVerificationToken(tt.token, tt.now)
+			if ok != tt.wantOK {
+				t.Fatalf("parseVerificationToken ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (userID != testUserID || email != tt.wantEmail) {
+				t.Errorf("parseVerificationToken = %d, %q, want %d, %q", userID, email, testUserID, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	f := newEmailFixture(t)
+	user := f.users.users[testUserID]
+	oldEmail := "alice@example.com"
+	user.Email = &oldEmail
+
+	// Ссылка, выданная для прежнего адреса, после смены адреса не подтверждает новый
+	oldLink := f.svc.signVerificationToken(testUserID, oldEmail, time.Now().Add(time.Hour))
+	if err := f.svc.ChangeEmail(context.Background(), testUserID, &dto.EmailDTO{Email: "alice@example.org", CurrentPassword: testCurrentPassword},
+		ClientInfo{}); err != nil {
+		t.Fatalf("ChangeEmail: %v", err)
+	}
+	if err := f.svc.VerifyEmail(context.Background(), oldLink); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("VerifyEmail with link for the previous address = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	if user.VerifiedAt != nil {
+		t.Fatalf("new address verified with the link for the previous one")
+	}
+
+	// Ссылка из письма на новый адрес подтверждает его
+	messages := f.mail.Messages()
+	if err := f.svc.VerifyEmail(context.Background(), linkToken(t, messages[len(messages)-1].Body)); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if user.VerifiedAt == nil || *user.Email != "alice@example.org" {
+		t.Errorf("email = %v, verified_at = %v, want verified alice@example.org", *user.Email, user.VerifiedAt)
+	}
+}
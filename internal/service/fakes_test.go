@@ -0,0 +1,964 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-management/internal/models"
+	"user-management/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// discardLogger логгер, который ничего не выводит
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeTx транзакция фейкового хранилища: изменения копятся и применяются только при фиксации,
+// поэтому откат транзакции в сервисе не оставляет следов, как и в PostgreSQL
+type fakeTx struct {
+	pgx.Tx
+
+	store      *fakeStore
+	pending    []func()
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.store.mu.Lock()
+	defer tx.store.mu.Unlock()
+
+	for _, op := range tx.pending {
+		op()
+	}
+	tx.pending = nil
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.pending = nil
+	tx.rolledBack = true
+	return nil
+}
+
+// fakeStore общее состояние фейковых репозиториев
+type fakeStore struct {
+	mu  sync.Mutex
+	txs []*fakeTx
+}
+
+func (s *fakeStore) begin() *fakeTx {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &fakeTx{store: s}
+	s.txs = append(s.txs, tx)
+	return tx
+}
+
+// stage откладывает изменение до фиксации транзакции
+func stage(tx pgx.Tx, op func()) {
+	ftx := tx.(*fakeTx)
+	ftx.pending = append(ftx.pending, op)
+}
+
+// pointEntry запись журнала начисления баллов
+type pointEntry struct {
+	UserID      int
+	Points      int
+	Reason      models.PointReason
+	ReferenceID *int64
+}
+
+// fakeUserRepo хранилище пользователей, заданий и заявок в памяти. Методы, которые тесты не используют,
+// не реализованы: вызов такого метода паникует через nil встроенного интерфейса
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	store       *fakeStore
+	users       map[int]*models.User
+	tasks       map[int]*models.Task
+	completions map[int64]*models.TaskCompletion
+	points      []pointEntry
+	audit       []models.UserAuditEntry
+	nextID      int64
+
+	createdUsers int
+}
+
+// firstCompletionID ID первой заявки; не совпадает с ID заданий в тестах, чтобы их нельзя было перепутать
+const firstCompletionID = 101
+
+// createdUserIDBase начало идентификаторов пользователей, созданных через CreateUserWithTx
+const createdUserIDBase = 1000
+
+func newFakeUserRepo() *fakeUserRepo {
+	return &fakeUserRepo{
+		store:       &fakeStore{},
+		users:       make(map[int]*models.User),
+		tasks:       make(map[int]*models.Task),
+		completions: make(map[int64]*models.TaskCompletion),
+		nextID:      firstCompletionID - 1,
+	}
+}
+
+func (r *fakeUserRepo) BeginTransaction(context.Context) (pgx.Tx, error) {
+	return r.store.begin(), nil
+}
+
+func (r *fakeUserRepo) GetUserByIDWithTx(_ context.Context, _ pgx.Tx, id int) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	stored := *user
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) GetTask(_ context.Context, taskID int) (*models.Task, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok {
+		return nil, repository.ErrTaskNotFound
+	}
+	stored := *task
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) AddPoint(_ context.Context, tx pgx.Tx, userID int, points int, reason models.PointReason, referenceID *int64) error {
+	stage(tx, func() {
+		r.points = append(r.points, pointEntry{UserID: userID, Points: points, Reason: reason, ReferenceID: referenceID})
+		if user, ok := r.users[userID]; ok {
+			user.Balance += points
+		}
+	})
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePasswordWithTx(_ context.Context, tx pgx.Tx, userID int, passwordHash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	stage(tx, func() { user.Password = passwordHash })
+	return nil
+}
+
+func (r *fakeUserRepo) AddUserAuditWithTx(_ context.Context, tx pgx.Tx, entry *models.UserAuditEntry) error {
+	stored := *entry
+	stage(tx, func() { r.audit = append(r.audit, stored) })
+	return nil
+}
+
+func (r *fakeUserRepo) CountCompletedTasks(_ context.Context, _ pgx.Tx, userID, taskID int, periodKey string) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var count int
+	for _, c := range r.completions {
+		if c.UserID == userID && c.TaskID == taskID && c.Status != models.CompletionStatusRejected &&
+			(periodKey == "" || c.PeriodKey == periodKey) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeUserRepo) LastCompletionNumber(_ context.Context, _ pgx.Tx, userID, taskID int) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var last int
+	for _, c := range r.completions {
+		var number int
+		if c.UserID == userID && c.TaskID == taskID && parseCompletionNumber(c.PeriodKey, &number) && number > last {
+			last = number
+		}
+	}
+	return last, nil
+}
+
+// AddCompletedTask повторяет частичный уникальный индекс uq_completed_tasks_user_task_period
+func (r *fakeUserRepo) AddCompletedTask(_ context.Context, tx pgx.Tx, completion *models.TaskCompletion) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, c := range r.completions {
+		if c.UserID == completion.UserID && c.TaskID == completion.TaskID && c.PeriodKey == completion.PeriodKey &&
+			c.Status != models.CompletionStatusRejected {
+			return 0, repository.ErrCompletionExists
+		}
+	}
+
+	r.nextID++
+	stored := *completion
+	stored.ID = r.nextID
+	stage(tx, func() { r.completions[stored.ID] = &stored })
+	return stored.ID, nil
+}
+
+func (r *fakeUserRepo) GetCompletionWithTx(_ context.Context, _ pgx.Tx, completionID int64) (*models.TaskCompletion, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	completion, ok := r.completions[completionID]
+	if !ok {
+		return nil, repository.ErrCompletionNotFound
+	}
+	stored := *completion
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) SetCompletionStatusWithTx(_ context.Context, tx pgx.Tx, completionID int64, status models.CompletionStatus, reviewerID *int) error {
+	stage(tx, func() {
+		completion := r.completions[completionID]
+		completion.Status = status
+		completion.ReviewedBy = reviewerID
+	})
+	return nil
+}
+
+func (r *fakeUserRepo) GetUserByID(_ context.Context, id int) (*models.User, error) {
+	return r.GetUserByIDWithTx(context.Background(), nil, id)
+}
+
+func (r *fakeUserRepo) GetUserByNameWithTx(_ context.Context, _ pgx.Tx, name string) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.UserName == name {
+			stored := *user
+			return &stored, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *fakeUserRepo) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email != nil && *user.Email == email {
+			stored := *user
+			return &stored, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) GetUserByName(_ context.Context, name string) (*models.User, error) {
+	user, err := r.GetUserByNameWithTx(context.Background(), nil, name)
+	if err != nil {
+		return nil, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// UpdateEmail повторяет сброс подтверждения при смене адреса
+func (r *fakeUserRepo) UpdateEmail(_ context.Context, userID int, email string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.Email = &email
+	user.VerifiedAt = nil
+	return nil
+}
+
+// MarkEmailVerified подтверждает адрес, только если он совпадает с адресом из ссылки
+func (r *fakeUserRepo) MarkEmailVerified(_ context.Context, userID int, email string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if user.Email == nil || *user.Email != email {
+		return repository.ErrEmailMismatch
+	}
+	now := time.Now()
+	user.VerifiedAt = &now
+	return nil
+}
+
+// CreateUserWithTx выдает идентификаторы начиная с createdUserIDBase, чтобы они не совпали с пользователями,
+// добавленными тестом напрямую
+func (r *fakeUserRepo) CreateUserWithTx(_ context.Context, tx pgx.Tx, user *models.User) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.createdUsers++
+	stored := *user
+	stored.ID = createdUserIDBase + r.createdUsers
+	stage(tx, func() { r.users[stored.ID] = &stored })
+	return stored.ID, nil
+}
+
+// addCompletion добавляет заявку в обход сервиса
+func (r *fakeUserRepo) addCompletion(completion models.TaskCompletion) int64 {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.nextID++
+	completion.ID = r.nextID
+	r.completions[completion.ID] = &completion
+	return completion.ID
+}
+
+// pointsOf возвращает зафиксированные начисления пользователю
+func (r *fakeUserRepo) pointsOf(userID int) []pointEntry {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var entries []pointEntry
+	for _, p := range r.points {
+		if p.UserID == userID {
+			entries = append(entries, p)
+		}
+	}
+	return entries
+}
+
+// parseCompletionNumber разбирает ключ выполнения задания без периода вида #N
+func parseCompletionNumber(key string, number *int) bool {
+	digits, ok := strings.CutPrefix(key, "#")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return false
+	}
+	*number = n
+	return true
+}
+
+// fakeTaskVerifier проверка выполнения заданий: возвращает заданный статус или ошибку
+// и запоминает полученные доказательства выполнения
+type fakeTaskVerifier struct {
+	Status models.CompletionStatus
+	Err    error
+
+	mu     sync.Mutex
+	proofs []string
+}
+
+func (v *fakeTaskVerifier) Verify(_ context.Context, _ *models.Task, _ int, proof string) (models.CompletionStatus, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.proofs = append(v.proofs, proof)
+	if v.Err != nil {
+		return "", v.Err
+	}
+	if v.Status == "" {
+		return models.CompletionStatusApproved, nil
+	}
+	return v.Status, nil
+}
+
+// Proofs возвращает доказательства выполнения, переданные в проверку
+func (v *fakeTaskVerifier) Proofs() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return append([]string(nil), v.proofs...)
+}
+
+// fakeIdentityRepo хранилище запросов входа и привязок учетных записей провайдеров в памяти
+type fakeIdentityRepo struct {
+	repository.IdentityRepository
+
+	store      *fakeStore
+	requests   map[string]*models.OIDCAuthRequest
+	identities []*models.UserIdentity
+}
+
+// newFakeIdentityRepo создает хранилище, транзакции которого общие с users
+func newFakeIdentityRepo(users *fakeUserRepo) *fakeIdentityRepo {
+	return &fakeIdentityRepo{store: users.store, requests: make(map[string]*models.OIDCAuthRequest)}
+}
+
+func (r *fakeIdentityRepo) CreateAuthRequest(_ context.Context, req *models.OIDCAuthRequest) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored := *req
+	r.requests[req.StateHash] = &stored
+	return nil
+}
+
+func (r *fakeIdentityRepo) ConsumeAuthRequest(_ context.Context, stateHash string) (*models.OIDCAuthRequest, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	req, ok := r.requests[stateHash]
+	if !ok {
+		return nil, repository.ErrAuthRequestNotFound
+	}
+	delete(r.requests, stateHash)
+	return req, nil
+}
+
+func (r *fakeIdentityRepo) GetIdentity(_ context.Context, provider, subject string) (*models.UserIdentity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			stored := *identity
+			return &stored, nil
+		}
+	}
+	return nil, repository.ErrIdentityNotFound
+}
+
+// CreateIdentityWithTx повторяет уникальные индексы привязок: по subject провайдера и по паре пользователь—провайдер
+func (r *fakeIdentityRepo) CreateIdentityWithTx(_ context.Context, tx pgx.Tx, identity *models.UserIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && (existing.Subject == identity.Subject || existing.UserID == identity.UserID) {
+			return repository.ErrIdentityAlreadyLinked
+		}
+	}
+
+	stored := *identity
+	stored.ID = int64(len(r.identities) + 1)
+	stage(tx, func() { r.identities = append(r.identities, &stored) })
+	return nil
+}
+
+func (r *fakeIdentityRepo) TouchIdentity(context.Context, int64, *string) error {
+	return nil
+}
+
+func (r *fakeIdentityRepo) CountIdentitiesWithTx(_ context.Context, _ pgx.Tx, userID int) (int, error) {
+	return len(r.identitiesOf(userID)), nil
+}
+
+func (r *fakeIdentityRepo) DeleteIdentityWithTx(_ context.Context, tx pgx.Tx, userID int, provider string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, identity := range r.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			stage(tx, func() { r.identities = append(r.identities[:i:i], r.identities[i+1:]...) })
+			return nil
+		}
+	}
+	return repository.ErrIdentityNotFound
+}
+
+// identitiesOf возвращает зафиксированные привязки пользователя
+func (r *fakeIdentityRepo) identitiesOf(userID int) []models.UserIdentity {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var result []models.UserIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			result = append(result, *identity)
+		}
+	}
+	return result
+}
+
+// fakeAuthRepo хранилище access и refresh токенов, сессий и ролей в памяти
+type fakeAuthRepo struct {
+	repository.TokenRepository
+	repository.SessionRepository
+	repository.RoleRepository
+
+	mu            sync.Mutex
+	tokens        map[string]*fakeAccessToken
+	refreshTokens map[string]*models.RefreshToken
+	sessions      []*models.Session
+	roles         map[int][]string // Роли пользователей сверх базовой роли user
+}
+
+// fakeAccessToken сохраненный access токен
+type fakeAccessToken struct {
+	UserID    int
+	SessionID *int64
+	ClientID  *int64 // Приложение OAuth2, которому выдан токен
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+func newFakeAuthRepo() *fakeAuthRepo {
+	return &fakeAuthRepo{
+		tokens:        make(map[string]*fakeAccessToken),
+		refreshTokens: make(map[string]*models.RefreshToken),
+		roles:         make(map[int][]string),
+	}
+}
+
+func (r *fakeAuthRepo) GetUserRoles(_ context.Context, userID int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.roles[userID]), nil
+}
+
+func (r *fakeAuthRepo) StoreToken(_ context.Context, userID int, sessionID *int64, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[tokenHash] = &fakeAccessToken{UserID: userID, SessionID: sessionID, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r *fakeAuthRepo) StoreClientToken(_ context.Context, userID *int, clientID int64, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := &fakeAccessToken{ClientID: &clientID, ExpiresAt: expiresAt}
+	if userID != nil {
+		token.UserID = *userID
+	}
+	r.tokens[tokenHash] = token
+	return nil
+}
+
+func (r *fakeAuthRepo) IsTokenValid(_ context.Context, tokenHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[tokenHash]
+	return ok && !token.Revoked && time.Now().Before(token.ExpiresAt), nil
+}
+
+func (r *fakeAuthRepo) RevokeToken(_ context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token, ok := r.tokens[tokenHash]; ok {
+		token.Revoked = true
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) StoreRefreshToken(_ context.Context, userID int, tokenHash, familyID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refreshTokens[tokenHash] = &models.RefreshToken{
+		ID: int64(len(r.refreshTokens) + 1), UserID: userID, TokenHash: tokenHash, FamilyID: familyID,
+		CreatedAt: time.Now(), ExpiresAt: expiresAt,
+	}
+	return nil
+}
+
+// UseRefreshToken отмечает использование активного токена, как queryUseRefreshToken
+func (r *fakeAuthRepo) UseRefreshToken(_ context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok || token.UsedAt != nil || token.IsRevoked || !time.Now().Before(token.ExpiresAt) {
+		return nil, repository.ErrRefreshTokenNotFound
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	stored := *token
+	return &stored, nil
+}
+
+func (r *fakeAuthRepo) GetRefreshToken(_ context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok {
+		return nil, repository.ErrRefreshTokenNotFound
+	}
+	stored := *token
+	return &stored, nil
+}
+
+// RevokeRefreshTokenFamily отзывает refresh токены семейства, его сессию и access токены сессии
+func (r *fakeAuthRepo) RevokeRefreshTokenFamily(_ context.Context, familyID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.refreshTokens {
+		if token.FamilyID == familyID {
+			token.IsRevoked = true
+		}
+	}
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.FamilyID != familyID {
+			continue
+		}
+		if session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+		for _, token := range r.tokens {
+			if token.SessionID != nil && *token.SessionID == session.ID {
+				token.Revoked = true
+			}
+		}
+		return session.ID, nil
+	}
+	return 0, nil
+}
+
+// RevokeUserSessionsWithTx завершает сессии пользователя, кроме exceptSessionID, и отзывает их токены
+// при фиксации транзакции, как запросы queryRevokeUserSession*
+func (r *fakeAuthRepo) RevokeUserSessionsWithTx(_ context.Context, tx pgx.Tx, userID int, exceptSessionID *int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	except := func(sessionID *int64) bool {
+		return exceptSessionID != nil && sessionID != nil && *sessionID == *exceptSessionID
+	}
+
+	var revoked []*models.Session
+	keepFamily := ""
+	for _, session := range r.sessions {
+		switch {
+		case session.UserID != userID:
+		case except(&session.ID):
+			keepFamily = session.FamilyID
+		case session.RevokedAt == nil && session.ExpiresAt.After(time.Now()):
+			revoked = append(revoked, session)
+		}
+	}
+
+	stage(tx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		now := time.Now()
+		for _, session := range revoked {
+			session.RevokedAt = &now
+		}
+		for _, token := range r.tokens {
+			if token.UserID == userID && !except(token.SessionID) {
+				token.Revoked = true
+			}
+		}
+		for _, token := range r.refreshTokens {
+			if token.UserID == userID && token.FamilyID != keepFamily {
+				token.IsRevoked = true
+			}
+		}
+	})
+	return int64(len(revoked)), nil
+}
+
+// SaveSession создает сессию семейства или продлевает ее; отозванная сессия не продлевается, как querySaveSession
+func (r *fakeAuthRepo) SaveSession(_ context.Context, session *models.Session) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.sessions {
+		if existing.FamilyID != session.FamilyID {
+			continue
+		}
+		if existing.RevokedAt != nil {
+			return 0, repository.ErrSessionNotFound
+		}
+		existing.ExpiresAt = session.ExpiresAt
+		return existing.ID, nil
+	}
+
+	stored := *session
+	stored.ID = int64(len(r.sessions) + 1)
+	r.sessions = append(r.sessions, &stored)
+	return stored.ID, nil
+}
+
+func (r *fakeAuthRepo) TouchSession(context.Context, int64, time.Duration) error {
+	return nil
+}
+
+// session возвращает сессию семейства
+func (r *fakeAuthRepo) session(familyID string) *models.Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			stored := *session
+			return &stored
+		}
+	}
+	return nil
+}
+
+// fakeMFARepo хранилище второго фактора, кодов восстановления и запросов второго фактора в памяти
+type fakeMFARepo struct {
+	repository.MFARepository
+
+	store         *fakeStore
+	mfa           map[int]*models.UserMFA
+	recoveryCodes map[int]map[string]bool // Хэш кода восстановления -> код использован
+	challenges    []*fakeMFAChallenge
+}
+
+// fakeMFAChallenge запрос второго фактора при входе
+type fakeMFAChallenge struct {
+	ID        int64
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	Attempts  int
+	Used      bool
+}
+
+// newFakeMFARepo создает хранилище, транзакции которого общие с users
+func newFakeMFARepo(users *fakeUserRepo) *fakeMFARepo {
+	return &fakeMFARepo{store: users.store, mfa: make(map[int]*models.UserMFA), recoveryCodes: make(map[int]map[string]bool)}
+}
+
+func (r *fakeMFARepo) GetMFA(_ context.Context, userID int) (*models.UserMFA, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	mfa, ok := r.mfa[userID]
+	if !ok {
+		return nil, repository.ErrMFANotFound
+	}
+	stored := *mfa
+	return &stored, nil
+}
+
+func (r *fakeMFARepo) CreateChallenge(_ context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.challenges = append(r.challenges, &fakeMFAChallenge{
+		ID: int64(len(r.challenges) + 1), UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt,
+	})
+	return nil
+}
+
+// AttemptChallenge засчитывает попытку вне транзакции проверки кода, как queryAttemptChallenge
+func (r *fakeMFARepo) AttemptChallenge(_ context.Context, tokenHash string, maxAttempts int) (int64, int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, c := range r.challenges {
+		if c.TokenHash == tokenHash && !c.Used && time.Now().Before(c.ExpiresAt) && c.Attempts < maxAttempts {
+			c.Attempts++
+			return c.ID, c.UserID, nil
+		}
+	}
+	return 0, 0, repository.ErrMFAChallengeNotFound
+}
+
+// UseTOTPStepWithTx принимает только шаг новее последнего использованного, как queryUseTOTPStep
+func (r *fakeMFARepo) UseTOTPStepWithTx(_ context.Context, tx pgx.Tx, userID int, step int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	mfa, ok := r.mfa[userID]
+	if !ok || mfa.ConfirmedAt == nil || (mfa.LastUsedStep != nil && *mfa.LastUsedStep >= step) {
+		return repository.ErrMFAStepUsed
+	}
+	stage(tx, func() { mfa.LastUsedStep = &step })
+	return nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCodeWithTx(_ context.Context, tx pgx.Tx, userID int, codeHash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if used, ok := r.recoveryCodes[userID][codeHash]; !ok || used {
+		return repository.ErrRecoveryCodeNotFound
+	}
+	stage(tx, func() { r.recoveryCodes[userID][codeHash] = true })
+	return nil
+}
+
+func (r *fakeMFARepo) CompleteChallengeWithTx(_ context.Context, tx pgx.Tx, challengeID int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, c := range r.challenges {
+		if c.ID == challengeID && !c.Used {
+			stage(tx, func() { c.Used = true })
+			return nil
+		}
+	}
+	return repository.ErrMFAChallengeNotFound
+}
+
+// fakeLoginAttemptRepo счетчики неудачных попыток входа в памяти.
+// Окно учета не моделируется: счет сбрасывается только через Reset
+type fakeLoginAttemptRepo struct {
+	mu          sync.Mutex
+	failures    map[string]int
+	lockedUntil map[string]time.Time
+}
+
+func newFakeLoginAttemptRepo() *fakeLoginAttemptRepo {
+	return &fakeLoginAttemptRepo{failures: make(map[string]int), lockedUntil: make(map[string]time.Time)}
+}
+
+func loginAttemptKey(scope models.LoginAttemptScope, subject string) string {
+	return string(scope) + ":" + subject
+}
+
+func (r *fakeLoginAttemptRepo) GetLockedUntil(_ context.Context, scope models.LoginAttemptScope, subject string) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, ok := r.lockedUntil[loginAttemptKey(scope, subject)]
+	if !ok || !until.After(time.Now()) {
+		return nil, nil
+	}
+	return &until, nil
+}
+
+func (r *fakeLoginAttemptRepo) RecordFailure(_ context.Context, scope models.LoginAttemptScope, subject string, _ time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures[loginAttemptKey(scope, subject)]++
+	return r.failures[loginAttemptKey(scope, subject)], nil
+}
+
+func (r *fakeLoginAttemptRepo) Lock(_ context.Context, scope models.LoginAttemptScope, subject string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lockedUntil[loginAttemptKey(scope, subject)] = until
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) Reset(_ context.Context, scope models.LoginAttemptScope, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.failures, loginAttemptKey(scope, subject))
+	delete(r.lockedUntil, loginAttemptKey(scope, subject))
+	return nil
+}
+
+// fakeOAuthRepo приложения, согласия и коды авторизации OAuth2 в памяти
+type fakeOAuthRepo struct {
+	repository.OAuthRepository
+
+	mu       sync.Mutex
+	clients  map[string]*models.OAuthClient
+	consents map[string][]string // "user_id:client_id" -> переданные права
+	codes    map[string]*models.OAuthAuthorizationCode
+}
+
+func newFakeOAuthRepo(clients ...*models.OAuthClient) *fakeOAuthRepo {
+	r := &fakeOAuthRepo{
+		clients:  make(map[string]*models.OAuthClient),
+		consents: make(map[string][]string),
+		codes:    make(map[string]*models.OAuthAuthorizationCode),
+	}
+	for _, client := range clients {
+		r.clients[client.ClientID] = client
+	}
+	return r
+}
+
+func oauthConsentKey(userID int, clientID int64) string {
+	return strconv.Itoa(userID) + ":" + strconv.FormatInt(clientID, 10)
+}
+
+func (r *fakeOAuthRepo) GetClientByClientID(_ context.Context, clientID string) (*models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, repository.ErrOAuthClientNotFound
+	}
+	stored := *client
+	return &stored, nil
+}
+
+func (r *fakeOAuthRepo) GetConsent(_ context.Context, userID int, clientID int64) (*models.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes, ok := r.consents[oauthConsentKey(userID, clientID)]
+	if !ok {
+		return nil, repository.ErrOAuthConsentNotFound
+	}
+	return &models.OAuthConsent{UserID: userID, ClientID: clientID, Scopes: slices.Clone(scopes)}, nil
+}
+
+// SaveConsent объединяет права с ранее переданными, как querySaveOAuthConsent
+func (r *fakeOAuthRepo) SaveConsent(_ context.Context, userID int, clientID int64, scopes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := oauthConsentKey(userID, clientID)
+	for _, scope := range scopes {
+		if !slices.Contains(r.consents[key], scope) {
+			r.consents[key] = append(r.consents[key], scope)
+		}
+	}
+	return nil
+}
+
+func (r *fakeOAuthRepo) CreateAuthorizationCode(_ context.Context, code *models.OAuthAuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *code
+	r.codes[code.CodeHash] = &stored
+	return nil
+}
+
+func (r *fakeOAuthRepo) ConsumeAuthorizationCode(_ context.Context, codeHash string) (*models.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return nil, repository.ErrOAuthCodeNotFound
+	}
+	delete(r.codes, codeHash)
+	return code, nil
+}
+
+// fakeResetRepo токены сброса пароля: хранит пользователя для каждого хэша токена
+type fakeResetRepo struct {
+	repository.PasswordResetRepository
+
+	tokens map[string]int
+}
+
+func newFakeResetRepo() *fakeResetRepo {
+	return &fakeResetRepo{tokens: make(map[string]int)}
+}
+
+func (r *fakeResetRepo) StoreResetToken(_ context.Context, userID int, tokenHash string, _ time.Time) error {
+	r.tokens[tokenHash] = userID
+	return nil
+}
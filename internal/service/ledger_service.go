@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/dto"
+	"user-management/internal/repository"
+)
+
+// Размер страницы истории операций по умолчанию
+const defaultPageLimit = 20
+
+type LedgerService interface {
+	PointTransactions(ctx context.Context, userID int, page *dto.PaginationDTO) (*dto.PointTransactionsDTO, error)
+	ReconcileBalances(ctx context.Context) ([]dto.BalanceMismatchDTO, error)
+}
+
+type DefaultLedgerService struct {
+	repo   repository.LedgerRepository
+	logger *slog.Logger
+}
+
+func NewLedgerService(repo repository.LedgerRepository, logger *slog.Logger) *DefaultLedgerService {
+	return &DefaultLedgerService{repo: repo, logger: logger}
+}
+
+// PointTransactions предоставляет страницу истории изменений баланса пользователя
+func (s *DefaultLedgerService) PointTransactions(ctx context.Context, userID int, page *dto.PaginationDTO) (*dto.PointTransactionsDTO, error) {
+	s.logger.Info("Fetching point transactions", "userID", userID)
+
+	limit := page.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	total, err := s.repo.CountPointTransactions(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to count point transactions", "error", err)
+		return nil, fmt.Errorf("PointTransactions: error counting transactions: %w", err)
+	}
+
+	transactions, err := s.repo.GetPointTransactions(ctx, userID, limit, page.Offset)
+	if err != nil {
+		s.logger.Error("Failed to get point transactions", "error", err)
+		return nil, fmt.Errorf("PointTransactions: error getting transactions: %w", err)
+	}
+
+	items := make([]dto.PointTransactionDTO, 0, len(transactions))
+	for _, pt := range transactions {
+		items = append(items, dto.PointTransactionDTO{
+			ID:           pt.ID,
+			Amount:       pt.Amount,
+			BalanceAfter: pt.BalanceAfter,
+			Reason:       string(pt.Reason),
+			ReferenceID:  pt.ReferenceID,
+			CreatedAt:    pt.CreatedAt,
+		})
+	}
+
+	return &dto.PointTransactionsDTO{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: page.Offset,
+	}, nil
+}
+
+// ReconcileBalances сверяет балансы пользователей с журналом операций и возвращает расхождения
+func (s *DefaultLedgerService) ReconcileBalances(ctx context.Context) ([]dto.BalanceMismatchDTO, error) {
+	s.logger.Info("Starting balance reconciliation")
+
+	mismatches, err := s.repo.GetBalanceMismatches(ctx)
+	if err != nil {
+		s.logger.Error("Failed to reconcile balances", "error", err)
+		return nil, fmt.Errorf("ReconcileBalances: %w", err)
+	}
+
+	for _, m := range mismatches {
+		s.logger.Warn("Balance mismatch", "user_id", m.UserID, "balance", m.Balance, "ledger_sum", m.LedgerSum)
+	}
+
+	return mismatches, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/models"
+	"user-management/internal/repository"
+)
+
+// Ошибки защиты от перебора паролей
+var (
+	ErrAccountLocked        = errors.New("account temporarily locked")
+	ErrTooManyLoginAttempts = errors.New("too many login attempts")
+)
+
+// maxLockoutShift ограничивает показатель степени при удвоении блокировки, чтобы длительность не переполнялась
+const maxLockoutShift = 30
+
+// LockoutError ошибка временной блокировки входа с временем, через которое можно повторить попытку
+type LockoutError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Err
+}
+
+type LoginThrottle interface {
+	Check(ctx context.Context, username, clientIP string) error
+	RecordFailure(ctx context.Context, username, clientIP string) error
+	RecordSuccess(ctx context.Context, username string) error
+	Unlock(ctx context.Context, userID int) error
+}
+
+type DefaultLoginThrottle struct {
+	repo          repository.LoginAttemptRepository
+	userRepo      repository.UserRepository
+	maxFailures   int
+	ipMaxFailures int
+	lockoutBase   time.Duration
+	lockoutMax    time.Duration
+	failureWindow time.Duration
+	logger        *slog.Logger
+}
+
+func NewLoginThrottle(repo repository.LoginAttemptRepository, userRepo repository.UserRepository, cfg *config.ApiServer, logger *slog.Logger) *DefaultLoginThrottle {
+	return &DefaultLoginThrottle{
+		repo:          repo,
+		userRepo:      userRepo,
+		maxFailures:   cfg.LoginMaxFailures,
+		ipMaxFailures: cfg.LoginIPMaxFailures,
+		lockoutBase:   cfg.LoginLockoutBase,
+		lockoutMax:    cfg.LoginLockoutMax,
+		failureWindow: cfg.LoginFailureWindow,
+		logger:        logger,
+	}
+}
+
+// Check проверяет, не заблокирован ли вход с IP адреса клиента или под именем пользователя.
+// Вызывается до проверки пароля, чтобы во время блокировки перебор не продолжался
+func (t *DefaultLoginThrottle) Check(ctx context.Context, username, clientIP string) error {
+	if clientIP != "" {
+		if err := t.checkScope(ctx, models.LoginAttemptScopeIP, clientIP, ErrTooManyLoginAttempts); err != nil {
+			return err
+		}
+	}
+
+	return t.checkScope(ctx, models.LoginAttemptScopeAccount, username, ErrAccountLocked)
+}
+
+// RecordFailure засчитывает неудачную попытку входа для имени пользователя и IP адреса клиента.
+// При достижении порога вход блокируется, каждая следующая блокировка вдвое дольше предыдущей
+func (t *DefaultLoginThrottle) RecordFailure(ctx context.Context, username, clientIP string) error {
+	if clientIP != "" {
+		if err := t.recordScope(ctx, models.LoginAttemptScopeIP, clientIP, t.ipMaxFailures); err != nil {
+			return err
+		}
+	}
+
+	return t.recordScope(ctx, models.LoginAttemptScopeAccount, username, t.maxFailures)
+}
+
+// RecordSuccess сбрасывает счетчик неудачных попыток для имени пользователя.
+// Счетчик IP адреса не сбрасывается, чтобы успешный вход в свою учетную запись не открывал перебор чужих
+func (t *DefaultLoginThrottle) RecordSuccess(ctx context.Context, username string) error {
+	if err := t.repo.Reset(ctx, models.LoginAttemptScopeAccount, username); err != nil {
+		t.logger.Error("Failed to reset login attempts", "username", username, "error", err)
+		return fmt.Errorf("RecordSuccess: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock снимает блокировку входа с учетной записи пользователя и сбрасывает счетчик неудачных попыток
+func (t *DefaultLoginThrottle) Unlock(ctx context.Context, userID int) error {
+	storedUser, err := t.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		t.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("Unlock: error getting user: %w", err)
+	}
+
+	if err = t.repo.Reset(ctx, models.LoginAttemptScopeAccount, storedUser.UserName); err != nil {
+		t.logger.Error("Failed to reset login attempts", "user_id", userID, "error", err)
+		return fmt.Errorf("Unlock: %w", err)
+	}
+
+	t.logger.Info("Account unlocked", "user_id", userID)
+	return nil
+}
+
+// checkScope возвращает LockoutError, если для subject действует блокировка
+func (t *DefaultLoginThrottle) checkScope(ctx context.Context, scope models.LoginAttemptScope, subject string, lockErr error) error {
+	lockedUntil, err := t.repo.GetLockedUntil(ctx, scope, subject)
+	if err != nil {
+		t.logger.Error("Failed to check login lock", "scope", scope, "error", err)
+		return fmt.Errorf("Check: %w", err)
+	}
+	if lockedUntil == nil {
+		return nil
+	}
+
+	t.logger.Warn("Login attempt while locked", "scope", scope, "subject", subject, "locked_until", *lockedUntil)
+	return &LockoutError{Err: lockErr, RetryAfter: time.Until(*lockedUntil)}
+}
+
+// recordScope засчитывает неудачную попытку для subject и блокирует вход при достижении порога
+func (t *DefaultLoginThrottle) recordScope(ctx context.Context, scope models.LoginAttemptScope, subject string, maxFailures int) error {
+	failures, err := t.repo.RecordFailure(ctx, scope, subject, t.failureWindow)
+	if err != nil {
+		t.logger.Error("Failed to record login failure", "scope", scope, "error", err)
+		return fmt.Errorf("RecordFailure: %w", err)
+	}
+	if failures < maxFailures {
+		return nil
+	}
+
+	if err = t.repo.Lock(ctx, scope, subject, time.Now().Add(t.lockoutDuration(failures-maxFailures))); err != nil {
+		t.logger.Error("Failed to lock login", "scope", scope, "error", err)
+		return fmt.Errorf("RecordFailure: %w", err)
+	}
+
+	return nil
+}
+
+// lockoutDuration длительность блокировки после excess попыток сверх порога: base * 2^excess, но не более max
+func (t *DefaultLoginThrottle) lockoutDuration(excess int) time.Duration {
+	if excess > maxLockoutShift {
+		excess = maxLockoutShift
+	}
+
+	duration := t.lockoutBase << excess
+	if duration <= 0 || duration > t.lockoutMax {
+		return t.lockoutMax
+	}
+	return duration
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/models"
+)
+
+const (
+	testLoginMaxFailures   = 3
+	testLoginIPMaxFailures = 5
+	testLockoutBase        = time.Minute
+	testLockoutMax         = 30 * time.Minute
+	testClientIP           = "192.0.2.1"
+)
+
+// newTestLoginThrottle создает защиту от перебора с хранилищем попыток в памяти
+func newTestLoginThrottle() (*DefaultLoginThrottle, *fakeLoginAttemptRepo, *fakeUserRepo) {
+	repo := newFakeLoginAttemptRepo()
+	users := newFakeUserRepo()
+	cfg := &config.ApiServer{
+		LoginMaxFailures:   testLoginMaxFailures,
+		LoginIPMaxFailures: testLoginIPMaxFailures,
+		LoginLockoutBase:   testLockoutBase,
+		LoginLockoutMax:    testLockoutMax,
+		LoginFailureWindow: time.Hour,
+	}
+
+	return NewLoginThrottle(repo, users, cfg, discardLogger()), repo, users
+}
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   time.Duration
+		max    time.Duration
+		excess int
+		want   time.Duration
+	}{
+		{name: "first lockout", base: time.Minute, max: time.Hour, excess: 0, want: time.Minute},
+		{name: "doubles", base: time.Minute, max: time.Hour, excess: 1, want: 2 * time.Minute},
+		{name: "doubles again", base: time.Minute, max: time.Hour, excess: 4, want: 16 * time.Minute},
+		{name: "last below cap", base: time.Minute, max: time.Hour, excess: 5, want: 32 * time.Minute},
+		{name: "capped", base: time.Minute, max: time.Hour, excess: 6, want: time.Hour},
+		{name: "equal to cap", base: 15 * time.Minute, max: time.Hour, excess: 2, want: time.Hour},
+		{name: "shift limited", base: time.Second, max: 24 * time.Hour, excess: 1000, want: 24 * time.Hour},
+		{name: "overflow capped", base: time.Hour, max: 365 * 24 * time.Hour, excess: maxLockoutShift, want: 365 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := &DefaultLoginThrottle{lockoutBase: tt.base, lockoutMax: tt.max}
+			if got := throttle.lockoutDuration(tt.excess); got != tt.want {
+				t.Errorf("lockoutDuration(%d) = %s, want %s", tt.excess, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	throttle, repo, _ := newTestLoginThrottle()
+	ctx := context.Background()
+
+	// Неудачи до порога не блокируют вход, каждая следующая продлевает блокировку вдвое до предела
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	for i, wantLock := range want {
+		if err := throttle.RecordFailure(ctx, "alice", ""); err != nil {
+			t.Fatalf("RecordFailure #%d: %v", i+1, err)
+		}
+
+		err := throttle.Check(ctx, "alice", "")
+		if wantLock == 0 {
+			if err != nil {
+				t.Fatalf("Check after %d failures = %v, want nil", i+1, err)
+			}
+			continue
+		}
+
+		var lockout *LockoutError
+		if !errors.As(err, &lockout) || !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("Check after %d failures = %v, want %v", i+1, err, ErrAccountLocked)
+		}
+		if lockout.RetryAfter > wantLock || lockout.RetryAfter < wantLock-time.Minute/2 {
+			t.Errorf("RetryAfter after %d failures = %s, want about %s", i+1, lockout.RetryAfter, wantLock)
+		}
+	}
+
+	// Успешный вход сбрасывает счетчик: следующая неудача снова не блокирует вход
+	if err := throttle.RecordSuccess(ctx, "alice"); err != nil {
+		t.Fatalf("RecordSuccess: %v", err)
+	}
+	if err := throttle.RecordFailure(ctx, "alice", ""); err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if err := throttle.Check(ctx, "alice", ""); err != nil {
+		t.Errorf("Check after reset = %v, want nil", err)
+	}
+	if got := repo.failures[loginAttemptKey(models.LoginAttemptScopeAccount, "alice")]; got != 1 {
+		t.Errorf("failures after reset = %d, want 1", got)
+	}
+}
+
+func TestLoginThrottleIPScope(t *testing.T) {
+	throttle, _, _ := newTestLoginThrottle()
+	ctx := context.Background()
+
+	// Перебор разных имен с одного адреса блокирует адрес, но не учетные записи
+	for i := 0; i < testLoginIPMaxFailures; i++ {
+		if err := throttle.RecordFailure(ctx, "user"+string(rune('a'+i)), testClientIP); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+	}
+
+	if err := throttle.Check(ctx, "alice", testClientIP); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("Check from blocked ip = %v, want %v", err, ErrTooManyLoginAttempts)
+	}
+	if err := throttle.Check(ctx, "alice", "198.51.100.1"); err != nil {
+		t.Errorf("Check from another ip = %v, want nil", err)
+	}
+
+	// Успешный вход не сбрасывает счетчик адреса
+	if err := throttle.RecordSuccess(ctx, "alice"); err != nil {
+		t.Fatalf("RecordSuccess: %v", err)
+	}
+	if err := throttle.Check(ctx, "bob", testClientIP); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("Check after success = %v, want %v", err, ErrTooManyLoginAttempts)
+	}
+}
+
+func TestLoginThrottleUnlock(t *testing.T) {
+	throttle, _, users := newTestLoginThrottle()
+	ctx := context.Background()
+	users.users[testUserID] = &models.User{ID: testUserID, UserName: "alice"}
+
+	for i := 0; i < testLoginMaxFailures; i++ {
+		if err := throttle.RecordFailure(ctx, "alice", ""); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+	}
+	if err := throttle.Check(ctx, "alice", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Check = %v, want %v", err, ErrAccountLocked)
+	}
+
+	if err := throttle.Unlock(ctx, testUserID); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := throttle.Check(ctx, "alice", ""); err != nil {
+		t.Errorf("Check after unlock = %v, want nil", err)
+	}
+}
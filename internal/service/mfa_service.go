@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/pkg/totp"
+	"user-management/internal/repository"
+)
+
+// Ошибки двухфакторной аутентификации
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode      = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa token")
+)
+
+// Параметры второго шага входа и кодов восстановления
+const (
+	mfaMaxAttempts    = 5  // Число попыток ввода кода на один запрос второго фактора
+	recoveryCodeCount = 10 // Число выдаваемых кодов восстановления
+	recoveryCodeBytes = 5  // Размер кода восстановления в байтах (8 символов base32)
+)
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, userID int) (*dto.MFAEnrollmentDTO, error)
+	ConfirmTOTP(ctx context.Context, userID int, req *dto.MFACodeDTO) (*dto.RecoveryCodesDTO, error)
+	BeginLogin(ctx context.Context, userID int) (*dto.MFAChallengeDTO, error)
+	CompleteLogin(ctx context.Context, req *dto.MFALoginDTO, clientIP string) (int, error)
+}
+
+type DefaultMFAService struct {
+	repo         repository.MFARepository
+	userRepo     repository.UserRepository
+	throttle     LoginThrottle
+	issuer       string
+	challengeTTL time.Duration
+	logger       *slog.Logger
+}
+
+func NewMFAService(repo repository.MFARepository, userRepo repository.UserRepository, throttle LoginThrottle, cfg *config.ApiServer,
+	logger *slog.Logger) *DefaultMFAService {
+	return &DefaultMFAService{
+		repo:         repo,
+		userRepo:     userRepo,
+		throttle:     throttle,
+		issuer:       cfg.MFAIssuer,
+		challengeTTL: cfg.MFAChallengeTTL,
+		logger:       logger,
+	}
+}
+
+// EnrollTOTP создает новый секрет TOTP. Второй фактор начинает действовать только после подтверждения кодом
+func (s *DefaultMFAService) EnrollTOTP(ctx context.Context, userID int) (*dto.MFAEnrollmentDTO, error) {
+	storedUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "error", err)
+		return nil, fmt.Errorf("EnrollTOTP: error getting user: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error("Failed to generate totp secret", "error", err)
+		return nil, err
+	}
+
+	if err = s.repo.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyConfirmed) {
+			s.logger.Warn("MFA already enabled", "user_id", userID)
+			return nil, ErrMFAAlreadyEnabled
+		}
+		s.logger.Error("Failed to save totp secret", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("EnrollTOTP: error saving secret: %w", err)
+	}
+
+	s.logger.Info("TOTP enrollment started", "user_id", userID)
+	return &dto.MFAEnrollmentDTO{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.issuer, storedUser.UserName, secret),
+	}, nil
+}
+
+// ConfirmTOTP подтверждает подключение кодом из приложения и выдает коды восстановления
+func (s *DefaultMFAService) ConfirmTOTP(ctx context.Context, userID int, req *dto.MFACodeDTO) (result *dto.RecoveryCodesDTO, err error) {
+	mfa, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		s.logger.Error("Failed to get mfa", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ConfirmTOTP: error getting mfa: %w", err)
+	}
+	if mfa.ConfirmedAt != nil {
+		s.logger.Warn("MFA already enabled", "user_id", userID)
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(mfa.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		s.logger.Warn("Invalid totp code on confirmation", "user_id", userID)
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		s.logger.Error("Failed to generate recovery codes", "error", err)
+		return nil, err
+	}
+
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	if err = s.repo.ConfirmTOTPWithTx(ctx, tx, userID, step); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyConfirmed) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		s.logger.Error("Failed to confirm totp", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ConfirmTOTP: error confirming totp: %w", err)
+	}
+
+	if err = s.repo.ReplaceRecoveryCodesWithTx(ctx, tx, userID, hashes); err != nil {
+		s.logger.Error("Failed to store recovery codes", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ConfirmTOTP: error storing recovery codes: %w", err)
+	}
+
+	s.logger.Info("TOTP enabled", "user_id", userID)
+	return &dto.RecoveryCodesDTO{
+		Status:        "Двухфакторная аутентификация включена",
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// BeginLogin выпускает токен запроса второго фактора после успешной проверки пароля.
+// Возвращает nil, если второй фактор у пользователя не подключен: вход на этом завершен, и счетчик неудачных
+// попыток учетной записи сбрасывается. Иначе счетчик сбрасывается только после ввода верного кода
+func (s *DefaultMFAService) BeginLogin(ctx context.Context, userID int) (*dto.MFAChallengeDTO, error) {
+	mfa, err := s.repo.GetMFA(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrMFANotFound) {
+		s.logger.Error("Failed to get mfa", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("BeginLogin: error getting mfa: %w", err)
+	}
+	if err != nil || mfa.ConfirmedAt == nil {
+		if err = s.resetLoginFailures(ctx, userID); err != nil {
+			return nil, fmt.Errorf("BeginLogin: %w", err)
+		}
+		return nil, nil
+	}
+
+	token, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate mfa token", "error", err)
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.challengeTTL)
+	if err = s.repo.CreateChallenge(ctx, userID, hashToken(token), expiresAt); err != nil {
+		s.logger.Error("Failed to create mfa challenge", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("BeginLogin: error creating challenge: %w", err)
+	}
+
+	s.logger.Info("MFA challenge issued", "user_id", userID)
+	return &dto.MFAChallengeDTO{
+		Status:      "Требуется код второго фактора",
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// CompleteLogin проверяет код второго фактора (TOTP или код восстановления) и возвращает ID пользователя,
+// для которого можно выпускать токены. Токен запроса одноразовый и допускает ограниченное число попыток.
+// Неверный код засчитывается как неудачная попытка входа: иначе, зная пароль, можно было бы запрашивать новые
+// токены и перебирать коды без ограничений. Успешный вход сбрасывает счетчик учетной записи
+func (s *DefaultMFAService) CompleteLogin(ctx context.Context, req *dto.MFALoginDTO, clientIP string) (int, error) {
+	challengeID, userID, err := s.repo.AttemptChallenge(ctx, hashToken(req.MFAToken), mfaMaxAttempts)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFAChallengeNotFound) {
+			s.logger.Warn("Invalid mfa token")
+			return 0, ErrInvalidMFAChallenge
+		}
+		s.logger.Error("Failed to attempt mfa challenge", "error", err)
+		return 0, fmt.Errorf("CompleteLogin: error checking challenge: %w", err)
+	}
+
+	storedUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("CompleteLogin: error getting user: %w", err)
+	}
+
+	if err = s.throttle.Check(ctx, storedUser.UserName, clientIP); err != nil {
+		return 0, err
+	}
+
+	err = s.useCode(ctx, challengeID, userID, req.Code)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if recordErr := s.throttle.RecordFailure(ctx, storedUser.UserName, clientIP); recordErr != nil {
+			return 0, fmt.Errorf("CompleteLogin: %w", recordErr)
+		}
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.throttle.RecordSuccess(ctx, storedUser.UserName); err != nil {
+		return 0, fmt.Errorf("CompleteLogin: %w", err)
+	}
+
+	s.logger.Info("MFA login completed", "user_id", userID)
+	return userID, nil
+}
+
+// useCode погашает код второго фактора и токен запроса в одной транзакции
+func (s *DefaultMFAService) useCode(ctx context.Context, challengeID int64, userID int, code string) (err error) {
+	mfa, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get mfa", "user_id", userID, "error", err)
+		return fmt.Errorf("CompleteLogin: error getting mfa: %w", err)
+	}
+
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	if step, ok := totp.Validate(mfa.TOTPSecret, code, time.Now()); ok {
+		err = s.repo.UseTOTPStepWithTx(ctx, tx, userID, step)
+		if errors.Is(err, repository.ErrMFAStepUsed) {
+			s.logger.Warn("TOTP code reused", "user_id", userID)
+			return ErrInvalidMFACode
+		}
+	} else {
+		err = s.repo.UseRecoveryCodeWithTx(ctx, tx, userID, hashToken(normalizeRecoveryCode(code)))
+		if errors.Is(err, repository.ErrRecoveryCodeNotFound) {
+			s.logger.Warn("Invalid mfa code", "user_id", userID)
+			return ErrInvalidMFACode
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to use mfa code", "user_id", userID, "error", err)
+		return fmt.Errorf("CompleteLogin: error using code: %w", err)
+	}
+
+	if err = s.repo.CompleteChallengeWithTx(ctx, tx, challengeID); err != nil {
+		if errors.Is(err, repository.ErrMFAChallengeNotFound) {
+			return ErrInvalidMFAChallenge
+		}
+		s.logger.Error("Failed to complete mfa challenge", "user_id", userID, "error", err)
+		return fmt.Errorf("CompleteLogin: error completing challenge: %w", err)
+	}
+
+	return nil
+}
+
+// resetLoginFailures сбрасывает счетчик неудачных попыток входа учетной записи после завершенного входа
+func (s *DefaultMFAService) resetLoginFailures(ctx context.Context, userID int) error {
+	storedUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("error getting user: %w", err)
+	}
+	return s.throttle.RecordSuccess(ctx, storedUser.UserName)
+}
+
+// generateRecoveryCodes создает коды восстановления вида abcd-efgh и их хэши для хранения
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode приводит введенный код восстановления к виду, от которого считается хэш
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
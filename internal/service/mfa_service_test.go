@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/totp"
+)
+
+const (
+	testTOTPSecret   = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	testRecoveryCode = "abcd-efgh"
+)
+
+// testMFALoginMaxFailures порог блокировки учетной записи: неверные коды двух токенов запроса второго фактора
+const testMFALoginMaxFailures = 2 * mfaMaxAttempts
+
+// newMFAFixture создает сервис второго фактора для пользователя alice с подтвержденным TOTP и одним кодом восстановления
+func newMFAFixture() (*DefaultMFAService, *fakeMFARepo, *fakeLoginAttemptRepo) {
+	users := newFakeUserRepo()
+	users.users[testUserID] = &models.User{ID: testUserID, UserName: "alice"}
+	repo := newFakeMFARepo(users)
+
+	confirmedAt := time.Now()
+	repo.mfa[testUserID] = &models.UserMFA{UserID: testUserID, TOTPSecret: testTOTPSecret, ConfirmedAt: &confirmedAt}
+	repo.recoveryCodes[testUserID] = map[string]bool{hashToken(normalizeRecoveryCode(testRecoveryCode)): false}
+
+	cfg := &config.ApiServer{
+		MFAIssuer:          "test",
+		MFAChallengeTTL:    time.Minute,
+		LoginMaxFailures:   testMFALoginMaxFailures,
+		LoginIPMaxFailures: 10 * testMFALoginMaxFailures,
+		LoginLockoutBase:   time.Minute,
+		LoginLockoutMax:    time.Hour,
+		LoginFailureWindow: time.Hour,
+	}
+	attempts := newFakeLoginAttemptRepo()
+	throttle := NewLoginThrottle(attempts, users, cfg, discardLogger())
+	return NewMFAService(repo, users, throttle, cfg, discardLogger()), repo, attempts
+}
+
+// completeLogin выпускает токен запроса второго фактора и предъявляет его с кодом
+func completeLogin(t *testing.T, svc *DefaultMFAService, code string) (int, error) {
+	t.Helper()
+
+	challenge, err := svc.BeginLogin(context.Background(), testUserID)
+	if err != nil || challenge == nil {
+		t.Fatalf("BeginLogin = %+v, %v, want challenge", challenge, err)
+	}
+	return svc.CompleteLogin(context.Background(), &dto.MFALoginDTO{MFAToken: challenge.MFAToken, Code: code}, testClientIP)
+}
+
+func TestMFACompleteLogin(t *testing.T) {
+	currentCode, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		first      string // Код первого входа
+		second     string // Код второго входа после успешного первого
+		wantSecond error
+	}{
+		{name: "reused totp code", first: currentCode, second: currentCode, wantSecond: ErrInvalidMFACode},
+		{name: "reused recovery code", first: testRecoveryCode, second: testRecoveryCode, wantSecond: ErrInvalidMFACode},
+		{name: "recovery code in upper case after totp", first: currentCode, second: "ABCD-EFGH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, _ := newMFAFixture()
+
+			userID, err := completeLogin(t, svc, tt.first)
+			if err != nil || userID != testUserID {
+				t.Fatalf("first CompleteLogin = %d, %v, want %d", userID, err, testUserID)
+			}
+
+			if _, err = completeLogin(t, svc, tt.second); !errors.Is(err, tt.wantSecond) {
+				t.Fatalf("second CompleteLogin error = %v, want %v", err, tt.wantSecond)
+			}
+		})
+	}
+}
+
+func TestMFACompleteLoginRejectsOldStep(t *testing.T) {
+	svc, repo, _ := newMFAFixture()
+
+	// Код предыдущего шага еще в пределах расхождения часов, но шаг не новее уже использованного
+	current := totp.Step(time.Now())
+	repo.mfa[testUserID].LastUsedStep = &current
+	previous, err := totp.Code(testTOTPSecret, current-1)
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+
+	if _, err = completeLogin(t, svc, previous); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("CompleteLogin error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	svc, repo, _ := newMFAFixture()
+	ctx := context.Background()
+
+	challenge, err := svc.BeginLogin(ctx, testUserID)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	// Неверные коды исчерпывают попытки, после чего токен не принимается и с верным кодом
+	for i := 0; i < mfaMaxAttempts; i++ {
+		_, err = svc.CompleteLogin(ctx, &dto.MFALoginDTO{MFAToken: challenge.MFAToken, Code: "000000"}, testClientIP)
+		if !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want %v", i+1, err, ErrInvalidMFACode)
+		}
+	}
+	_, err = svc.CompleteLogin(ctx, &dto.MFALoginDTO{MFAToken: challenge.MFAToken, Code: testRecoveryCode}, testClientIP)
+	if !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("CompleteLogin after attempts error = %v, want %v", err, ErrInvalidMFAChallenge)
+	}
+
+	// Пользователю без подтвержденного второго фактора токен запроса не выдается
+	repo.mfa[testUserID].ConfirmedAt = nil
+	if challenge, err = svc.BeginLogin(ctx, testUserID); err != nil || challenge != nil {
+		t.Errorf("BeginLogin without confirmed mfa = %+v, %v, want nil", challenge, err)
+	}
+}
+
+func TestMFACompleteLoginThrottle(t *testing.T) {
+	svc, repo, attempts := newMFAFixture()
+	ctx := context.Background()
+	accountKey := loginAttemptKey(models.LoginAttemptScopeAccount, "alice")
+
+	// Верный код сбрасывает неудачные попытки учетной записи
+	if _, err := completeLogin(t, svc, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("CompleteLogin error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if _, err := completeLogin(t, svc, testRecoveryCode); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if got := attempts.failures[accountKey]; got != 0 {
+		t.Fatalf("login failures after success = %d, want 0", got)
+	}
+
+	// Неверные коды засчитываются во всех токенах запроса: новый токен не дает новых попыток сверх порога учетной записи
+	for i := 0; i < testMFALoginMaxFailures; i++ {
+		if _, err := completeLogin(t, svc, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want %v", i+1, err, ErrInvalidMFACode)
+		}
+	}
+	currentCode, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	if _, err = completeLogin(t, svc, currentCode); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("CompleteLogin after %d failures error = %v, want %v", testMFALoginMaxFailures, err, ErrAccountLocked)
+	}
+
+	// Без второго фактора вход завершается на пароле, и BeginLogin сбрасывает счетчик
+	attempts.lockedUntil = make(map[string]time.Time)
+	repo.mfa[testUserID].ConfirmedAt = nil
+	if challenge, err := svc.BeginLogin(ctx, testUserID); err != nil || challenge != nil {
+		t.Fatalf("BeginLogin without confirmed mfa = %+v, %v, want nil", challenge, err)
+	}
+	if got := attempts.failures[accountKey]; got != 0 {
+		t.Errorf("login failures after login without mfa = %d, want 0", got)
+	}
+}
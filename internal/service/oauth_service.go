@@ -0,0 +1,578 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/pkg/rbac"
+	"user-management/internal/pkg/revocation"
+	"user-management/internal/repository"
+)
+
+// Ошибки сервера авторизации OAuth2 для сторонних приложений
+var (
+	ErrOAuthClientNotFound      = errors.New("oauth client not found")
+	ErrInvalidOAuthClient       = errors.New("invalid oauth client")
+	ErrInvalidOAuthRegistration = errors.New("invalid oauth client registration")
+	ErrScopeNotDelegable        = errors.New("scope cannot be granted to third-party applications")
+	ErrInvalidRedirectURI       = errors.New("invalid redirect uri")
+	ErrInvalidOAuthRequest      = errors.New("invalid oauth request")
+	ErrInvalidOAuthScope        = errors.New("invalid oauth scope")
+	ErrUnauthorizedClient       = errors.New("client is not allowed to use this grant type")
+	ErrInvalidOAuthGrant        = errors.New("invalid or expired authorization code")
+	ErrUnsupportedGrantType     = errors.New("unsupported grant type")
+	ErrOAuthConsentNotFound     = errors.New("oauth consent not found")
+)
+
+const (
+	// oauthClientSecretPrefix префикс, по которому секрет приложения отличается от других секретов
+	oauthClientSecretPrefix = "umcs_"
+	// pkceChallengeLength длина code_challenge S256: SHA-256 в base64url без дополнения
+	pkceChallengeLength = 43
+	// oauthAccessDenied код ошибки, с которым приложение получает отказ пользователя (RFC 6749, раздел 4.1.2.1)
+	oauthAccessDenied = "access_denied"
+)
+
+// OAuthClientAuth учетные данные приложения из заголовка Authorization (Basic) или тела запроса
+type OAuthClientAuth struct {
+	ClientID     string
+	ClientSecret string
+}
+
+type OAuthService interface {
+	RegisterClient(ctx context.Context, adminID int, req *dto.CreateOAuthClientDTO) (*dto.CreatedOAuthClientDTO, error)
+	ListClients(ctx context.Context) (*dto.OAuthClientsDTO, error)
+	DeleteClient(ctx context.Context, clientID string) error
+	PrepareAuthorization(ctx context.Context, userID int, req *dto.OAuthAuthorizeDTO) (*dto.OAuthAuthorizationDTO, error)
+	Authorize(ctx context.Context, userID int, req *dto.OAuthConsentDecisionDTO) (*dto.OAuthRedirectDTO, error)
+	Token(ctx context.Context, auth OAuthClientAuth, req *dto.OAuthTokenRequestDTO) (*dto.OAuthTokenDTO, error)
+	Revoke(ctx context.Context, auth OAuthClientAuth, token string) error
+	ListConsents(ctx context.Context, userID int) (*dto.OAuthConsentsDTO, error)
+	RevokeConsent(ctx context.Context, userID int, clientID string) error
+}
+
+type DefaultOAuthService struct {
+	repo         repository.OAuthRepository
+	tokenRepo    repository.TokenRepository
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	tokenService TokenService
+	revocations  revocation.Bus
+	codeTTL      time.Duration
+	logger       *slog.Logger
+}
+
+func NewOAuthService(repo repository.OAuthRepository, tokenRepo repository.TokenRepository, userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository, tokenService TokenService, revocations revocation.Bus, cfg *config.ApiServer, logger *slog.Logger) *DefaultOAuthService {
+	return &DefaultOAuthService{
+		repo:         repo,
+		tokenRepo:    tokenRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		tokenService: tokenService,
+		revocations:  revocations,
+		codeTTL:      cfg.OAuthCodeTTL,
+		logger:       logger,
+	}
+}
+
+// RegisterClient регистрирует стороннее приложение. Секрет конфиденциального приложения возвращается только
+// в ответе, в базе данных хранится его хэш
+func (s *DefaultOAuthService) RegisterClient(ctx context.Context, adminID int, req *dto.CreateOAuthClientDTO) (*dto.CreatedOAuthClientDTO, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !rbac.IsValidPermission(scope) {
+			s.logger.Warn("Unknown oauth client scope", "admin_id", adminID, "scope", scope)
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !rbac.IsDelegable(scope) {
+			s.logger.Warn("Non-delegable oauth client scope", "admin_id", adminID, "scope", scope)
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotDelegable, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	client := &models.OAuthClient{
+		Name:         req.Name,
+		RedirectURIs: make([]string, 0, len(req.RedirectURIs)),
+		Scopes:       scopes,
+		CreatedBy:    &adminID,
+	}
+	for _, grant := range req.GrantTypes {
+		if !slices.Contains(client.GrantTypes, grant) {
+			client.GrantTypes = append(client.GrantTypes, grant)
+		}
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		// Адрес возврата сравнивается целиком, поэтому он должен быть абсолютным и без фрагмента (RFC 6749, раздел 3.1.2)
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			s.logger.Warn("Invalid oauth client redirect uri", "admin_id", adminID, "redirect_uri", redirectURI)
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRedirectURI, redirectURI)
+		}
+		if !slices.Contains(client.RedirectURIs, redirectURI) {
+			client.RedirectURIs = append(client.RedirectURIs, redirectURI)
+		}
+	}
+
+	if client.AllowsGrant(models.OAuthGrantAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return nil, fmt.Errorf("%w: authorization_code requires redirect_uris", ErrInvalidOAuthRegistration)
+	}
+	// Без секрета любой, кто знает client_id, получил бы токен от имени приложения
+	if client.AllowsGrant(models.OAuthGrantClientCredentials) && !req.Confidential {
+		return nil, fmt.Errorf("%w: client_credentials requires a confidential client", ErrInvalidOAuthRegistration)
+	}
+
+	clientID, err := generateOpaqueToken(16)
+	if err != nil {
+		s.logger.Error("Failed to generate oauth client id", "admin_id", adminID, "error", err)
+		return nil, fmt.Errorf("RegisterClient: %w", err)
+	}
+	client.ClientID = clientID
+
+	var secret string
+	if req.Confidential {
+		random, err := generateOpaqueToken(32)
+		if err != nil {
+			s.logger.Error("Failed to generate oauth client secret", "admin_id", adminID, "error", err)
+			return nil, fmt.Errorf("RegisterClient: %w", err)
+		}
+		secret = oauthClientSecretPrefix + random
+		secretHash := hashToken(secret)
+		client.SecretHash = &secretHash
+	}
+
+	if err = s.repo.CreateClient(ctx, client); err != nil {
+		s.logger.Error("Failed to create oauth client", "admin_id", adminID, "error", err)
+		return nil, fmt.Errorf("RegisterClient: %w", err)
+	}
+
+	s.logger.Info("OAuth client registered", "admin_id", adminID, "client_id", client.ClientID, "grant_types", client.GrantTypes, "scopes", scopes)
+	return &dto.CreatedOAuthClientDTO{OAuthClientDTO: oauthClientDTO(client), ClientSecret: secret}, nil
+}
+
+// ListClients возвращает зарегистрированные приложения без секретов
+func (s *DefaultOAuthService) ListClients(ctx context.Context) (*dto.OAuthClientsDTO, error) {
+	clients, err := s.repo.ListClients(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list oauth clients", "error", err)
+		return nil, fmt.Errorf("ListClients: %w", err)
+	}
+
+	items := make([]dto.OAuthClientDTO, 0, len(clients))
+	for i := range clients {
+		items = append(items, oauthClientDTO(&clients[i]))
+	}
+
+	return &dto.OAuthClientsDTO{Items: items}, nil
+}
+
+// DeleteClient удаляет приложение; его согласия, коды авторизации и токены удаляются вместе с ним
+func (s *DefaultOAuthService) DeleteClient(ctx context.Context, clientID string) error {
+	if err := s.repo.DeleteClient(ctx, clientID); err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			s.logger.Warn("OAuth client not found", "client_id", clientID)
+			return ErrOAuthClientNotFound
+		}
+		s.logger.Error("Failed to delete oauth client", "client_id", clientID, "error", err)
+		return fmt.Errorf("DeleteClient: %w", err)
+	}
+
+	// Токены уже удалены: экземпляры, не получившие событие, перестанут их принимать по истечении срока жизни кэша
+	if err := s.revocations.Publish(ctx, revocation.Event{ClientID: clientID}); err != nil {
+		s.logger.Error("Failed to publish token revocation", "client_id", clientID, "error", err)
+	}
+
+	s.logger.Info("OAuth client deleted", "client_id", clientID)
+	return nil
+}
+
+// PrepareAuthorization проверяет запрос авторизации и возвращает данные для экрана согласия. Код не выдается
+func (s *DefaultOAuthService) PrepareAuthorization(ctx context.Context, userID int, req *dto.OAuthAuthorizeDTO) (*dto.OAuthAuthorizationDTO, error) {
+	client, scopes, err := s.validateAuthorization(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired := true
+	consent, err := s.repo.GetConsent(ctx, userID, client.ID)
+	switch {
+	case err == nil:
+		consentRequired = !containsAll(consent.Scopes, scopes)
+	case !errors.Is(err, repository.ErrOAuthConsentNotFound):
+		s.logger.Error("Failed to get oauth consent", "user_id", userID, "client_id", client.ClientID, "error", err)
+		return nil, fmt.Errorf("PrepareAuthorization: %w", err)
+	}
+
+	return &dto.OAuthAuthorizationDTO{
+		ClientID:        client.ClientID,
+		ClientName:      client.Name,
+		Scopes:          scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+// Authorize применяет решение пользователя: при согласии сохраняет его и выдает код авторизации,
+// при отказе возвращает в приложение ошибку access_denied. В обоих случаях возвращается адрес возврата
+func (s *DefaultOAuthService) Authorize(ctx context.Context, userID int, req *dto.OAuthConsentDecisionDTO) (*dto.OAuthRedirectDTO, error) {
+	client, scopes, err := s.validateAuthorization(ctx, userID, &req.OAuthAuthorizeDTO)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	if !req.Approve {
+		s.logger.Info("OAuth authorization denied", "user_id", userID, "client_id", client.ClientID)
+		params.Set("error", oauthAccessDenied)
+		return &dto.OAuthRedirectDTO{RedirectURI: withQuery(req.RedirectURI, params)}, nil
+	}
+
+	if err = s.repo.SaveConsent(ctx, userID, client.ID, scopes); err != nil {
+		s.logger.Error("Failed to save oauth consent", "user_id", userID, "client_id", client.ClientID, "error", err)
+		return nil, fmt.Errorf("Authorize: %w", err)
+	}
+
+	// Код возвращается только приложению, в базе данных хранится его хэш
+	code, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate authorization code", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("Authorize: %w", err)
+	}
+
+	err = s.repo.CreateAuthorizationCode(ctx, &models.OAuthAuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.codeTTL),
+	})
+	if err != nil {
+		s.logger.Error("Failed to create authorization code", "user_id", userID, "client_id", client.ClientID, "error", err)
+		return nil, fmt.Errorf("Authorize: %w", err)
+	}
+
+	s.logger.Info("OAuth authorization granted", "user_id", userID, "client_id", client.ClientID, "scopes", scopes)
+	params.Set("code", code)
+	return &dto.OAuthRedirectDTO{RedirectURI: withQuery(req.RedirectURI, params)}, nil
+}
+
+// validateAuthorization проверяет приложение, адрес возврата, PKCE и запрошенные права. Права, которых нет
+// у ролей пользователя, не передаются; если не остается ни одного права, запрос отклоняется
+func (s *DefaultOAuthService) validateAuthorization(ctx context.Context, userID int, req *dto.OAuthAuthorizeDTO) (*models.OAuthClient, []string, error) {
+	client, err := s.repo.GetClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			s.logger.Warn("Unknown oauth client", "client_id", req.ClientID)
+			return nil, nil, ErrInvalidOAuthClient
+		}
+		s.logger.Error("Failed to get oauth client", "client_id", req.ClientID, "error", err)
+		return nil, nil, fmt.Errorf("validateAuthorization: %w", err)
+	}
+
+	// Адрес возврата проверяется до остальных параметров: на непроверенный адрес нельзя отправлять даже ошибку
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		s.logger.Warn("Unregistered oauth redirect uri", "client_id", client.ClientID, "redirect_uri", req.RedirectURI)
+		return nil, nil, ErrInvalidRedirectURI
+	}
+	if !client.AllowsGrant(models.OAuthGrantAuthorizationCode) {
+		return nil, nil, ErrUnauthorizedClient
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, fmt.Errorf("%w: unsupported response_type %q", ErrInvalidOAuthRequest, req.ResponseType)
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != pkceChallengeLength {
+		return nil, nil, fmt.Errorf("%w: PKCE with code_challenge_method S256 is required", ErrInvalidOAuthRequest)
+	}
+
+	scopes, err := requestedScopes(req.Scope, client)
+	if err != nil {
+		s.logger.Warn("Invalid oauth scope", "client_id", client.ClientID, "scope", req.Scope)
+		return nil, nil, err
+	}
+
+	storedRoles, err := s.roleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user roles", "user_id", userID, "error", err)
+		return nil, nil, fmt.Errorf("validateAuthorization: %w", err)
+	}
+	roles := append([]string{string(rbac.RoleUser)}, storedRoles...)
+
+	granted := scopes[:0]
+	for _, scope := range scopes {
+		if rbac.HasPermission(roles, rbac.Permission(scope)) {
+			granted = append(granted, scope)
+		}
+	}
+	if len(granted) == 0 {
+		return nil, nil, fmt.Errorf("%w: none of the requested scopes is available to the user", ErrInvalidOAuthScope)
+	}
+
+	return client, granted, nil
+}
+
+// Token выдает приложению access token по коду авторизации с PKCE или по client credentials.
+// Refresh токены приложениям не выдаются: по истечении срока токена приложение снова запрашивает авторизацию
+func (s *DefaultOAuthService) Token(ctx context.Context, auth OAuthClientAuth, req *dto.OAuthTokenRequestDTO) (*dto.OAuthTokenDTO, error) {
+	client, err := s.authenticateClient(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID *int
+	var scopes []string
+	switch models.OAuthGrantType(req.GrantType) {
+	case models.OAuthGrantAuthorizationCode:
+		if !client.AllowsGrant(models.OAuthGrantAuthorizationCode) {
+			return nil, ErrUnauthorizedClient
+		}
+		code, err := s.exchangeCode(ctx, client, req)
+		if err != nil {
+			return nil, err
+		}
+		userID, scopes = &code.UserID, code.Scopes
+	case models.OAuthGrantClientCredentials:
+		if !client.AllowsGrant(models.OAuthGrantClientCredentials) || client.SecretHash == nil {
+			return nil, ErrUnauthorizedClient
+		}
+		if scopes, err = requestedScopes(req.Scope, client); err != nil {
+			s.logger.Warn("Invalid oauth scope", "client_id", client.ClientID, "scope", req.Scope)
+			return nil, err
+		}
+	default:
+		s.logger.Warn("Unsupported oauth grant type", "client_id", client.ClientID, "grant_type", req.GrantType)
+		return nil, ErrUnsupportedGrantType
+	}
+
+	accessToken, expiresAt, err := s.tokenService.GenerateClientToken(ctx, client, userID, scopes)
+	if err != nil {
+		s.logger.Error("Failed to generate client token", "client_id", client.ClientID, "error", err)
+		return nil, fmt.Errorf("Token: %w", err)
+	}
+
+	s.logger.Info("OAuth token issued", "client_id", client.ClientID, "grant_type", req.GrantType, "scopes", scopes)
+	return &dto.OAuthTokenDTO{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// exchangeCode погашает код авторизации и проверяет, что он выдан этому приложению для того же адреса возврата,
+// а code_verifier соответствует code_challenge запроса авторизации
+func (s *DefaultOAuthService) exchangeCode(ctx context.Context, client *models.OAuthClient, req *dto.OAuthTokenRequestDTO) (*models.OAuthAuthorizationCode, error) {
+	if req.Code == "" || req.CodeVerifier == "" || req.RedirectURI == "" {
+		return nil, fmt.Errorf("%w: code, code_verifier and redirect_uri are required", ErrInvalidOAuthRequest)
+	}
+
+	code, err := s.repo.ConsumeAuthorizationCode(ctx, hashToken(req.Code))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthCodeNotFound) {
+			s.logger.Warn("Unknown or used authorization code", "client_id", client.ClientID)
+			return nil, ErrInvalidOAuthGrant
+		}
+		s.logger.Error("Failed to consume authorization code", "client_id", client.ClientID, "error", err)
+		return nil, fmt.Errorf("exchangeCode: %w", err)
+	}
+
+	challenge := oidc.CodeChallenge(req.CodeVerifier)
+	switch {
+	case code.ClientID != client.ID:
+		s.logger.Warn("Authorization code issued to another client", "client_id", client.ClientID)
+		return nil, ErrInvalidOAuthGrant
+	case time.Now().After(code.ExpiresAt):
+		s.logger.Warn("Authorization code expired", "client_id", client.ClientID, "user_id", code.UserID)
+		return nil, ErrInvalidOAuthGrant
+	case code.RedirectURI != req.RedirectURI:
+		s.logger.Warn("Authorization code redirect uri mismatch", "client_id", client.ClientID, "user_id", code.UserID)
+		return nil, ErrInvalidOAuthGrant
+	case subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1:
+		s.logger.Warn("PKCE verification failed", "client_id", client.ClientID, "user_id", code.UserID)
+		return nil, ErrInvalidOAuthGrant
+	}
+
+	return code, nil
+}
+
+// Revoke отзывает токен, выданный приложению (RFC 7009)
+func (s *DefaultOAuthService) Revoke(ctx context.Context, auth OAuthClientAuth, token string) error {
+	client, err := s.authenticateClient(ctx, auth)
+	if err != nil {
+		return err
+	}
+
+	if err = s.tokenService.RevokeClientToken(ctx, token, client.ClientID); err != nil {
+		return fmt.Errorf("Revoke: %w", err)
+	}
+	return nil
+}
+
+// authenticateClient проверяет учетные данные приложения. Публичное приложение передает только client_id,
+// конфиденциальное — еще и секрет
+func (s *DefaultOAuthService) authenticateClient(ctx context.Context, auth OAuthClientAuth) (*models.OAuthClient, error) {
+	if auth.ClientID == "" {
+		return nil, ErrInvalidOAuthClient
+	}
+
+	client, err := s.repo.GetClientByClientID(ctx, auth.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			s.logger.Warn("Unknown oauth client", "client_id", auth.ClientID)
+			return nil, ErrInvalidOAuthClient
+		}
+		s.logger.Error("Failed to get oauth client", "client_id", auth.ClientID, "error", err)
+		return nil, fmt.Errorf("authenticateClient: %w", err)
+	}
+
+	if client.SecretHash == nil {
+		if auth.ClientSecret != "" {
+			s.logger.Warn("Secret presented for public oauth client", "client_id", client.ClientID)
+			return nil, ErrInvalidOAuthClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(auth.ClientSecret)), []byte(*client.SecretHash)) != 1 {
+		s.logger.Warn("Invalid oauth client secret", "client_id", client.ClientID)
+		return nil, ErrInvalidOAuthClient
+	}
+
+	return client, nil
+}
+
+// ListConsents возвращает приложения, которым пользователь разрешил доступ
+func (s *DefaultOAuthService) ListConsents(ctx context.Context, userID int) (*dto.OAuthConsentsDTO, error) {
+	consents, err := s.repo.ListConsents(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list oauth consents", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ListConsents: %w", err)
+	}
+
+	items := make([]dto.OAuthConsentDTO, 0, len(consents))
+	for _, consent := range consents {
+		items = append(items, dto.OAuthConsentDTO{
+			ClientID:   consent.ClientKey,
+			ClientName: consent.ClientName,
+			Scopes:     consent.Scopes,
+			CreatedAt:  consent.CreatedAt,
+			UpdatedAt:  consent.UpdatedAt,
+		})
+	}
+
+	return &dto.OAuthConsentsDTO{Items: items}, nil
+}
+
+// RevokeConsent отзывает согласие пользователя вместе со всеми токенами, выданными приложению от его имени
+func (s *DefaultOAuthService) RevokeConsent(ctx context.Context, userID int, clientID string) (err error) {
+	client, err := s.repo.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return ErrOAuthConsentNotFound
+		}
+		s.logger.Error("Failed to get oauth client", "client_id", clientID, "error", err)
+		return fmt.Errorf("RevokeConsent: %w", err)
+	}
+
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	revoked := revocation.Event{UserID: userID, ClientID: client.ClientID}
+	defer publishRevocation(ctx, s.logger, s.revocations, &revoked, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	if err = s.repo.DeleteConsentWithTx(ctx, tx, userID, client.ID); err != nil {
+		if errors.Is(err, repository.ErrOAuthConsentNotFound) {
+			s.logger.Warn("OAuth consent not found", "user_id", userID, "client_id", clientID)
+			return ErrOAuthConsentNotFound
+		}
+		s.logger.Error("Failed to delete oauth consent", "user_id", userID, "client_id", clientID, "error", err)
+		return fmt.Errorf("RevokeConsent: %w", err)
+	}
+
+	if err = s.tokenRepo.RevokeClientTokensWithTx(ctx, tx, userID, client.ID); err != nil {
+		s.logger.Error("Failed to revoke client tokens", "user_id", userID, "client_id", clientID, "error", err)
+		return fmt.Errorf("RevokeConsent: %w", err)
+	}
+
+	s.logger.Info("OAuth consent revoked", "user_id", userID, "client_id", clientID)
+	return nil
+}
+
+// requestedScopes разбирает параметр scope. Без параметра приложение получает все свои права,
+// а права, не разрешенные приложению при регистрации, отклоняются
+func requestedScopes(scope string, client *models.OAuthClient) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return slices.Clone(client.Scopes), nil
+	}
+
+	scopes := make([]string, 0, len(requested))
+	for _, s := range requested {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOAuthScope, s)
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+// containsAll проверяет, что set содержит все элементы items
+func containsAll(set, items []string) bool {
+	for _, item := range items {
+		if !slices.Contains(set, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// withQuery добавляет параметры к адресу возврата, сохраняя его собственные параметры
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// oauthClientDTO преобразует приложение в представление без секрета
+func oauthClientDTO(client *models.OAuthClient) dto.OAuthClientDTO {
+	return dto.OAuthClientDTO{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		GrantTypes:   client.GrantTypes,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash != nil,
+		CreatedAt:    client.CreatedAt,
+	}
+}
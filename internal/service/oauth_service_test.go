@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/pkg/rbac"
+	"user-management/internal/pkg/revocation"
+)
+
+const (
+	testOAuthClientID = "app"
+	testRedirectURI   = "https://app.example.com/callback"
+)
+
+// newTestOAuthService создает сервис OAuth2 с одним публичным приложением, которому разрешены
+// чтение профиля и управление заданиями
+func newTestOAuthService() (*DefaultOAuthService, *fakeOAuthRepo, *fakeAuthRepo) {
+	tokens, authRepo := newTestTokenService()
+	repo := newFakeOAuthRepo(&models.OAuthClient{
+		ID:           1,
+		ClientID:     testOAuthClientID,
+		Name:         "App",
+		RedirectURIs: []string{testRedirectURI, "http://127.0.0.1:8080/callback?app=1"},
+		GrantTypes:   []string{string(models.OAuthGrantAuthorizationCode)},
+		Scopes:       []string{string(rbac.PermissionProfileRead), string(rbac.PermissionTasksManage)},
+	})
+	cfg := &config.ApiServer{OAuthCodeTTL: time.Minute}
+
+	return NewOAuthService(repo, authRepo, newFakeUserRepo(), authRepo, tokens, revocation.NewLocalBus(), cfg, discardLogger()), repo, authRepo
+}
+
+// authorizeRequest возвращает корректный запрос авторизации с PKCE S256 для code_verifier
+func authorizeRequest(verifier string) dto.OAuthAuthorizeDTO {
+	return dto.OAuthAuthorizeDTO{
+		ResponseType:        "code",
+		ClientID:            testOAuthClientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               string(rbac.PermissionProfileRead),
+		State:               "xyz",
+		CodeChallenge:       oidc.CodeChallenge(verifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestOAuthValidateAuthorization(t *testing.T) {
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(req *dto.OAuthAuthorizeDTO)
+		admin      bool
+		wantErr    error
+		wantScopes []string
+	}{
+		{name: "valid", wantScopes: []string{"profile:read"}},
+		{name: "registered uri with query", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = "http://127.0.0.1:8080/callback?app=1" }, wantScopes: []string{"profile:read"}},
+		{name: "unknown client", modify: func(r *dto.OAuthAuthorizeDTO) { r.ClientID = "other" }, wantErr: ErrInvalidOAuthClient},
+
+		// Адрес возврата сравнивается целиком, без нормализации и сопоставления по префиксу
+		{name: "redirect trailing slash", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = testRedirectURI + "/" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect sub path", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = testRedirectURI + "/evil" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect extra query", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = testRedirectURI + "?next=evil" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect without query", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = "http://127.0.0.1:8080/callback" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect host case", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = "https://APP.example.com/callback" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect other scheme", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = "http://app.example.com/callback" }, wantErr: ErrInvalidRedirectURI},
+		{name: "redirect fragment", modify: func(r *dto.OAuthAuthorizeDTO) { r.RedirectURI = testRedirectURI + "#x" }, wantErr: ErrInvalidRedirectURI},
+		{
+			name: "redirect checked before other parameters",
+			modify: func(r *dto.OAuthAuthorizeDTO) {
+				r.RedirectURI = "https://evil.example.com/"
+				r.CodeChallengeMethod = "plain"
+			},
+			wantErr: ErrInvalidRedirectURI,
+		},
+
+		{name: "response type token", modify: func(r *dto.OAuthAuthorizeDTO) { r.ResponseType = "token" }, wantErr: ErrInvalidOAuthRequest},
+
+		// Принимается только PKCE S256
+		{name: "pkce plain", modify: func(r *dto.OAuthAuthorizeDTO) { r.CodeChallengeMethod = "plain"; r.CodeChallenge = verifier }, wantErr: ErrInvalidOAuthRequest},
+		{name: "pkce method lower case", modify: func(r *dto.OAuthAuthorizeDTO) { r.CodeChallengeMethod = "s256" }, wantErr: ErrInvalidOAuthRequest},
+		{name: "pkce method missing", modify: func(r *dto.OAuthAuthorizeDTO) { r.CodeChallengeMethod = "" }, wantErr: ErrInvalidOAuthRequest},
+		{name: "pkce challenge missing", modify: func(r *dto.OAuthAuthorizeDTO) { r.CodeChallenge = "" }, wantErr: ErrInvalidOAuthRequest},
+		{name: "pkce challenge short", modify: func(r *dto.OAuthAuthorizeDTO) { r.CodeChallenge = r.CodeChallenge[:42] }, wantErr: ErrInvalidOAuthRequest},
+
+		// Права сужаются до разрешенных приложению и доступных ролям пользователя
+		{name: "scope not registered", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "profile:read tasks:complete" }, wantErr: ErrInvalidOAuthScope},
+		{name: "scope duplicates", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "profile:read  profile:read" }, wantScopes: []string{"profile:read"}},
+		{name: "scope empty narrowed to user", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "" }, wantScopes: []string{"profile:read"}},
+		{name: "scope empty for admin", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "" }, admin: true, wantScopes: []string{"profile:read", "tasks:manage"}},
+		{name: "scope above user role dropped", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "tasks:manage profile:read" }, wantScopes: []string{"profile:read"}},
+		{name: "scope above user role only", modify: func(r *dto.OAuthAuthorizeDTO) { r.Scope = "tasks:manage" }, wantErr: ErrInvalidOAuthScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, authRepo := newTestOAuthService()
+			if tt.admin {
+				authRepo.roles[testUserID] = []string{string(rbac.RoleAdmin)}
+			}
+
+			req := authorizeRequest(verifier)
+			if tt.modify != nil {
+				tt.modify(&req)
+			}
+
+			got, err := svc.PrepareAuthorization(context.Background(), testUserID, &req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PrepareAuthorization error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(got.Scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", got.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	svc, repo, _ := newTestOAuthService()
+	ctx := context.Background()
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	req := authorizeRequest(verifier)
+
+	prepared, err := svc.PrepareAuthorization(ctx, testUserID, &req)
+	if err != nil || !prepared.ConsentRequired {
+		t.Fatalf("PrepareAuthorization = %+v, %v, want consent required", prepared, err)
+	}
+
+	redirect, err := svc.Authorize(ctx, testUserID, &dto.OAuthConsentDecisionDTO{OAuthAuthorizeDTO: req, Approve: true})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	back, err := url.Parse(redirect.RedirectURI)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	code := back.Query().Get("code")
+	if code == "" || back.Query().Get("state") != req.State {
+		t.Fatalf("redirect = %s, want code and state", redirect.RedirectURI)
+	}
+
+	// Повторный запрос тех же прав не требует согласия
+	if prepared, err = svc.PrepareAuthorization(ctx, testUserID, &req); err != nil || prepared.ConsentRequired {
+		t.Errorf("PrepareAuthorization after consent = %+v, %v, want no consent required", prepared, err)
+	}
+
+	auth := OAuthClientAuth{ClientID: testOAuthClientID}
+	exchange := &dto.OAuthTokenRequestDTO{
+		GrantType: string(models.OAuthGrantAuthorizationCode), Code: code, RedirectURI: testRedirectURI, CodeVerifier: verifier,
+	}
+
+	// Неверный code_verifier погашает код, и верный после этого уже не принимается
+	other, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	wrong := *exchange
+	wrong.CodeVerifier = other
+	if _, err = svc.Token(ctx, auth, &wrong); !errors.Is(err, ErrInvalidOAuthGrant) {
+		t.Fatalf("Token with wrong verifier error = %v, want %v", err, ErrInvalidOAuthGrant)
+	}
+	if _, err = svc.Token(ctx, auth, exchange); !errors.Is(err, ErrInvalidOAuthGrant) {
+		t.Fatalf("Token after failed exchange error = %v, want %v", err, ErrInvalidOAuthGrant)
+	}
+
+	// Новый код обменивается на токен с суженными правами ровно один раз
+	redirect, err = svc.Authorize(ctx, testUserID, &dto.OAuthConsentDecisionDTO{OAuthAuthorizeDTO: req, Approve: true})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	back, _ = url.Parse(redirect.RedirectURI)
+	exchange.Code = back.Query().Get("code")
+
+	token, err := svc.Token(ctx, auth, exchange)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token.Scope != "profile:read" || token.AccessToken == "" {
+		t.Errorf("token = %+v, want access token with scope profile:read", token)
+	}
+	if _, err = svc.Token(ctx, auth, exchange); !errors.Is(err, ErrInvalidOAuthGrant) {
+		t.Errorf("second Token error = %v, want %v", err, ErrInvalidOAuthGrant)
+	}
+	if len(repo.codes) != 0 {
+		t.Errorf("codes left = %d, want 0", len(repo.codes))
+	}
+}
+
+func TestOAuthAuthorizeDenied(t *testing.T) {
+	svc, repo, _ := newTestOAuthService()
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	req := authorizeRequest(verifier)
+
+	redirect, err := svc.Authorize(context.Background(), testUserID, &dto.OAuthConsentDecisionDTO{OAuthAuthorizeDTO: req})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	back, err := url.Parse(redirect.RedirectURI)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if back.Query().Get("error") != oauthAccessDenied || back.Query().Get("code") != "" {
+		t.Errorf("redirect = %s, want access_denied without code", redirect.RedirectURI)
+	}
+	if len(repo.consents) != 0 || len(repo.codes) != 0 {
+		t.Errorf("consents = %d, codes = %d, want none after denial", len(repo.consents), len(repo.codes))
+	}
+}
@@ -0,0 +1,483 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Ошибки входа через внешних провайдеров OpenID Connect
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrProviderUnavailable   = errors.New("identity provider is unavailable")
+	ErrInvalidOIDCState      = errors.New("invalid or expired oidc state")
+	ErrOIDCAuthFailed        = errors.New("identity provider authentication failed")
+	ErrIdentityAlreadyLinked = errors.New("identity already linked")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("cannot unlink the last login method")
+)
+
+// Параметры имени пользователя, созданного при первом входе через провайдера.
+// Имя должно проходить те же правила, что и при регистрации: латинская буква, затем 5–14 латинских букв или цифр
+const (
+	oidcUsernameMaxBase  = 11 // Длина основы имени, к которой при совпадении добавляется случайный суффикс
+	oidcUsernameSuffix   = 4  // Число цифр случайного суффикса
+	oidcUsernameAttempts = 10 // Число попыток подобрать свободное имя
+)
+
+// OIDCResult результат возврата от провайдера
+type OIDCResult struct {
+	UserID  int
+	Created bool // Пользователь создан при этом входе
+	Linked  bool // Учетная запись провайдера привязана по запросу пользователя, вход не выполнялся
+}
+
+type OIDCService interface {
+	BeginLogin(ctx context.Context, provider string) (string, error)
+	BeginLink(ctx context.Context, userID int, provider string) (string, error)
+	CompleteLogin(ctx context.Context, provider string, req *dto.OIDCCallbackDTO) (*OIDCResult, error)
+	ListIdentities(ctx context.Context, userID int) (*dto.IdentitiesDTO, error)
+	UnlinkIdentity(ctx context.Context, userID int, provider string) error
+}
+
+type DefaultOIDCService struct {
+	providers   map[string]*oidc.Provider
+	repo        repository.IdentityRepository
+	userRepo    repository.UserRepository
+	emailPolicy EmailPolicy
+	stateTTL    time.Duration
+	logger      *slog.Logger
+}
+
+func NewOIDCService(providers []*oidc.Provider, repo repository.IdentityRepository, userRepo repository.UserRepository, emailPolicy EmailPolicy, cfg *config.ApiServer, logger *slog.Logger) *DefaultOIDCService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return &DefaultOIDCService{
+		providers:   byName,
+		repo:        repo,
+		userRepo:    userRepo,
+		emailPolicy: emailPolicy,
+		stateTTL:    cfg.OIDCStateTTL,
+		logger:      logger,
+	}
+}
+
+// BeginLogin начинает вход через провайдера и возвращает адрес его страницы входа
+func (s *DefaultOIDCService) BeginLogin(ctx context.Context, provider string) (string, error) {
+	return s.begin(ctx, provider, nil)
+}
+
+// BeginLink начинает привязку учетной записи провайдера к пользователю и возвращает адрес страницы входа провайдера
+func (s *DefaultOIDCService) BeginLink(ctx context.Context, userID int, provider string) (string, error) {
+	return s.begin(ctx, provider, &userID)
+}
+
+// begin сохраняет state, nonce и code_verifier запроса входа. State возвращается только провайдеру, в базе хранится его хэш
+func (s *DefaultOIDCService) begin(ctx context.Context, name string, userID *int) (string, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		s.logger.Warn("Unknown identity provider", "provider", name)
+		return "", ErrUnknownProvider
+	}
+
+	state, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate oidc state", "error", err)
+		return "", err
+	}
+	nonce, err := generateOpaqueToken(16)
+	if err != nil {
+		s.logger.Error("Failed to generate oidc nonce", "error", err)
+		return "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		s.logger.Error("Failed to generate pkce verifier", "error", err)
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		s.logger.Error("Failed to build authorization url", "provider", name, "error", err)
+		return "", fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	err = s.repo.CreateAuthRequest(ctx, &models.OIDCAuthRequest{
+		StateHash:    hashToken(state),
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(s.stateTTL),
+	})
+	if err != nil {
+		s.logger.Error("Failed to save oidc auth request", "provider", name, "error", err)
+		return "", fmt.Errorf("begin: %w", err)
+	}
+
+	s.logger.Info("OIDC login started", "provider", name, "link", userID != nil)
+	return authURL, nil
+}
+
+// CompleteLogin обрабатывает возврат от провайдера: проверяет state, обменивает код на ID токен, проверяет его и
+// находит, привязывает или создает пользователя
+func (s *DefaultOIDCService) CompleteLogin(ctx context.Context, name string, req *dto.OIDCCallbackDTO) (*OIDCResult, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		s.logger.Warn("Unknown identity provider", "provider", name)
+		return nil, ErrUnknownProvider
+	}
+
+	// Запрос входа одноразовый: он удаляется и тогда, когда провайдер вернул ошибку
+	authReq, err := s.repo.ConsumeAuthRequest(ctx, hashToken(req.State))
+	if err != nil {
+		if errors.Is(err, repository.ErrAuthRequestNotFound) {
+			s.logger.Warn("Unknown oidc state", "provider", name)
+			return nil, ErrInvalidOIDCState
+		}
+		s.logger.Error("Failed to consume oidc auth request", "provider", name, "error", err)
+		return nil, fmt.Errorf("CompleteLogin: %w", err)
+	}
+	if authReq.Provider != name || time.Now().After(authReq.ExpiresAt) {
+		s.logger.Warn("OIDC state expired or issued for another provider", "provider", name)
+		return nil, ErrInvalidOIDCState
+	}
+
+	if req.Error != "" || req.Code == "" {
+		s.logger.Warn("Identity provider returned an error", "provider", name, "error", req.Error, "description", req.ErrorDescription)
+		return nil, fmt.Errorf("%w: %s", ErrOIDCAuthFailed, req.Error)
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, req.Code, authReq.CodeVerifier)
+	if err != nil {
+		s.logger.Warn("Failed to exchange authorization code", "provider", name, "error", err)
+		if errors.Is(err, oidc.ErrDiscoveryFailed) {
+			return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+		}
+		return nil, ErrOIDCAuthFailed
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, authReq.Nonce)
+	if err != nil {
+		s.logger.Warn("Invalid id token", "provider", name, "error", err)
+		if errors.Is(err, oidc.ErrDiscoveryFailed) {
+			return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+		}
+		return nil, ErrOIDCAuthFailed
+	}
+
+	if authReq.UserID != nil {
+		if err = s.link(ctx, *authReq.UserID, name, claims); err != nil {
+			return nil, err
+		}
+		return &OIDCResult{UserID: *authReq.UserID, Linked: true}, nil
+	}
+
+	return s.login(ctx, name, claims)
+}
+
+// login выполняет вход по учетной записи провайдера. Если она еще не привязана, привязывает ее к пользователю с тем же
+// подтвержденным адресом или создает нового пользователя без пароля
+func (s *DefaultOIDCService) login(ctx context.Context, provider string, claims *oidc.Claims) (*OIDCResult, error) {
+	email := verifiedEmail(claims)
+
+	identity, err := s.repo.GetIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		if err = s.repo.TouchIdentity(ctx, identity.ID, email); err != nil {
+			s.logger.Warn("Failed to update identity last login", "identity_id", identity.ID, "error", err)
+		}
+		if err = s.checkCanLogin(ctx, identity.UserID); err != nil {
+			return nil, err
+		}
+		s.logger.Info("User logged in with identity provider", "provider", provider, "user_id", identity.UserID)
+		return &OIDCResult{UserID: identity.UserID}, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		s.logger.Error("Failed to get identity", "provider", provider, "error", err)
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	// Учетная запись провайдера привязывается к существующему пользователю, только если адрес подтвержден и
+	// провайдером, и у нас: иначе владелец чужого адреса у провайдера получил бы доступ к аккаунту
+	if email != nil {
+		existing, err := s.userRepo.GetUserByEmail(ctx, *email)
+		switch {
+		case err == nil && existing.VerifiedAt != nil:
+			if err = s.link(ctx, existing.ID, provider, claims); err != nil {
+				return nil, err
+			}
+			if err = s.checkCanLogin(ctx, existing.ID); err != nil {
+				return nil, err
+			}
+			s.logger.Info("Identity linked by verified email", "provider", provider, "user_id", existing.ID)
+			return &OIDCResult{UserID: existing.ID}, nil
+		case err == nil:
+			// Адрес занят пользователем, который его не подтвердил: новый пользователь создается без адреса
+			email = nil
+		case !errors.Is(err, repository.ErrUserNotFound):
+			s.logger.Error("Failed to get user by email", "error", err)
+			return nil, fmt.Errorf("login: %w", err)
+		}
+	}
+
+	if s.emailPolicy.Required && email == nil {
+		s.logger.Warn("Identity provider did not return a verified email", "provider", provider)
+		return nil, ErrEmailRequired
+	}
+
+	userID, err := s.createUser(ctx, provider, claims, email)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User created with identity provider", "provider", provider, "user_id", userID)
+	return &OIDCResult{UserID: userID, Created: true}, nil
+}
+
+// createUser создает пользователя без пароля вместе с привязкой учетной записи провайдера
+func (s *DefaultOIDCService) createUser(ctx context.Context, provider string, claims *oidc.Claims, email *string) (userID int, err error) {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	username, err := s.availableUsername(ctx, tx, claims)
+	if err != nil {
+		return 0, err
+	}
+
+	user := &models.User{UserName: username, Email: email}
+	if email != nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	userID, err = s.userRepo.CreateUserWithTx(ctx, tx, user)
+	if err != nil {
+		s.logger.Error("Failed to create user", "error", err)
+		return 0, fmt.Errorf("error creating user: %w", err)
+	}
+
+	err = s.repo.CreateIdentityWithTx(ctx, tx, &models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrIdentityAlreadyLinked) {
+			return 0, ErrIdentityAlreadyLinked
+		}
+		return 0, fmt.Errorf("createUser: %w", err)
+	}
+
+	return userID, nil
+}
+
+// link привязывает учетную запись провайдера к пользователю
+func (s *DefaultOIDCService) link(ctx context.Context, userID int, provider string, claims *oidc.Claims) (err error) {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	// Блокировка строки пользователя упорядочивает привязку с отвязкой последнего способа входа
+	if _, err = s.userRepo.GetUserByIDWithTx(ctx, tx, userID); err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("link: %w", err)
+	}
+
+	err = s.repo.CreateIdentityWithTx(ctx, tx, &models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    verifiedEmail(claims),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrIdentityAlreadyLinked) {
+			s.logger.Warn("Identity already linked", "provider", provider, "user_id", userID)
+			return ErrIdentityAlreadyLinked
+		}
+		return fmt.Errorf("link: %w", err)
+	}
+
+	return nil
+}
+
+// checkCanLogin применяет к входу через провайдера ту же политику подтверждения адреса, что и к входу по паролю
+func (s *DefaultOIDCService) checkCanLogin(ctx context.Context, userID int) error {
+	if !s.emailPolicy.Verification.blocksLogin() {
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("checkCanLogin: %w", err)
+	}
+	if user.VerifiedAt == nil {
+		s.logger.Warn("Login rejected: email is not verified", "user_id", userID)
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// ListIdentities возвращает привязанные учетные записи провайдеров
+func (s *DefaultOIDCService) ListIdentities(ctx context.Context, userID int) (*dto.IdentitiesDTO, error) {
+	identities, err := s.repo.ListIdentities(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list identities", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ListIdentities: %w", err)
+	}
+
+	items := make([]dto.IdentityDTO, 0, len(identities))
+	for _, identity := range identities {
+		items = append(items, dto.IdentityDTO{
+			Provider:    identity.Provider,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			CreatedAt:   identity.CreatedAt,
+			LastLoginAt: identity.LastLoginAt,
+		})
+	}
+
+	return &dto.IdentitiesDTO{Items: items}, nil
+}
+
+// UnlinkIdentity отвязывает учетную запись провайдера. Последнюю привязку пользователя без пароля отвязать нельзя:
+// он потерял бы доступ к аккаунту
+func (s *DefaultOIDCService) UnlinkIdentity(ctx context.Context, userID int, provider string) (err error) {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	user, err := s.userRepo.GetUserByIDWithTx(ctx, tx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("UnlinkIdentity: %w", err)
+	}
+
+	if user.Password == "" {
+		count, err := s.repo.CountIdentitiesWithTx(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("UnlinkIdentity: %w", err)
+		}
+		if count <= 1 {
+			s.logger.Warn("Refusing to unlink the last login method", "user_id", userID, "provider", provider)
+			return ErrLastLoginMethod
+		}
+	}
+
+	if err = s.repo.DeleteIdentityWithTx(ctx, tx, userID, provider); err != nil {
+		if errors.Is(err, repository.ErrIdentityNotFound) {
+			return ErrIdentityNotFound
+		}
+		return fmt.Errorf("UnlinkIdentity: %w", err)
+	}
+
+	s.logger.Info("Identity unlinked", "user_id", userID, "provider", provider)
+	return nil
+}
+
+// verifiedEmail возвращает адрес из ID токена, если провайдер его подтвердил
+func verifiedEmail(claims *oidc.Claims) *string {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil
+	}
+	email := normalizeEmail(claims.Email)
+	return &email
+}
+
+// availableUsername подбирает свободное имя пользователя по данным провайдера
+func (s *DefaultOIDCService) availableUsername(ctx context.Context, tx pgx.Tx, claims *oidc.Claims) (string, error) {
+	base := usernameBase(claims)
+
+	for attempt := 0; attempt < oidcUsernameAttempts; attempt++ {
+		candidate := base
+		if attempt > 0 || len(candidate) < 6 {
+			suffix, err := randomDigits(oidcUsernameSuffix)
+			if err != nil {
+				return "", err
+			}
+			candidate = base + suffix
+		}
+
+		_, err := s.userRepo.GetUserByNameWithTx(ctx, tx, candidate)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Error("Failed to check username", "error", err)
+			return "", fmt.Errorf("availableUsername: %w", err)
+		}
+		return candidate, nil
+	}
+
+	s.logger.Error("Failed to find a free username", "base", base)
+	return "", fmt.Errorf("availableUsername: no free username for %q", base)
+}
+
+// usernameBase строит основу имени из preferred_username, адреса или имени: только латинские буквы и цифры,
+// первая — буква
+func usernameBase(claims *oidc.Claims) string {
+	local, _, _ := strings.Cut(claims.Email, "@")
+
+	for _, source := range []string{claims.PreferredUsername, local, claims.Name} {
+		var b strings.Builder
+		for _, r := range source {
+			if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				continue
+			}
+			if b.Len() == 0 && !unicode.IsLetter(r) {
+				continue
+			}
+			b.WriteRune(r)
+			if b.Len() == oidcUsernameMaxBase {
+				break
+			}
+		}
+		if b.Len() >= 2 {
+			return b.String()
+		}
+	}
+
+	return "user"
+}
+
+// randomDigits возвращает строку из n случайных цифр
+func randomDigits(n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		b.WriteByte(byte('0' + d.Int64()))
+	}
+	return b.String(), nil
+}
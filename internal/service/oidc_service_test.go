@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/oidc"
+	"user-management/internal/pkg/oidc/oidctest"
+)
+
+const (
+	testProvider    = "fake"
+	testOIDCClient  = "client"
+	testOIDCSecret  = "secret"
+	testRedirectURL = "http://localhost/api/v1/auth/oidc/fake/callback"
+)
+
+// oidcFixture сервис входа через фиктивного провайдера, запущенного на локальном адресе
+type oidcFixture struct {
+	svc        *DefaultOIDCService
+	fake       *oidctest.Provider
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+}
+
+func newOIDCFixture(t *testing.T, stateTTL time.Duration) *oidcFixture {
+	t.Helper()
+
+	fake, srv, err := oidctest.NewServer(testOIDCClient, testOIDCSecret)
+	if err != nil {
+		t.Fatalf("oidctest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	provider := oidc.NewProvider(oidc.ProviderConfig{
+		Name: testProvider, Issuer: fake.Issuer, ClientID: testOIDCClient, ClientSecret: testOIDCSecret,
+		Scopes: []string{"openid", "email", "profile"},
+	}, testRedirectURL)
+
+	users := newFakeUserRepo()
+	identities := newFakeIdentityRepo(users)
+	cfg := &config.ApiServer{OIDCStateTTL: stateTTL}
+
+	return &oidcFixture{
+		svc:        NewOIDCService([]*oidc.Provider{provider}, identities, users, EmailPolicy{}, cfg, discardLogger()),
+		fake:       fake,
+		users:      users,
+		identities: identities,
+	}
+}
+
+// authorize проходит страницу входа провайдера по адресу authURL и возвращает параметры обратного вызова
+func (f *oidcFixture) authorize(t *testing.T, authURL string) *dto.OIDCCallbackDTO {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return &dto.OIDCCallbackDTO{Code: back.Query().Get("code"), State: back.Query().Get("state")}
+}
+
+// login выполняет вход через провайдера от начала до обратного вызова
+func (f *oidcFixture) login(t *testing.T) (*OIDCResult, error) {
+	t.Helper()
+
+	authURL, err := f.svc.BeginLogin(context.Background(), testProvider)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	return f.svc.CompleteLogin(context.Background(), testProvider, f.authorize(t, authURL))
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	f := newOIDCFixture(t, time.Minute)
+	ctx := context.Background()
+
+	authURL, err := f.svc.BeginLogin(ctx, testProvider)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	// Провайдеру передается только code_challenge, code_verifier хранится у нас вместе с хэшем state
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	q := u.Query()
+	stored, ok := f.identities.requests[hashToken(q.Get("state"))]
+	if !ok {
+		t.Fatalf("auth request for state is not stored")
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") != oidc.CodeChallenge(stored.CodeVerifier) {
+		t.Errorf("code_challenge = %q (%s), want S256 of stored verifier", q.Get("code_challenge"), q.Get("code_challenge_method"))
+	}
+	if q.Get("nonce") != stored.Nonce || q.Get("redirect_uri") != testRedirectURL {
+		t.Errorf("nonce = %q, redirect_uri = %q", q.Get("nonce"), q.Get("redirect_uri"))
+	}
+
+	result, err := f.svc.CompleteLogin(ctx, testProvider, f.authorize(t, authURL))
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if !result.Created || result.Linked {
+		t.Errorf("result = %+v, want created user", result)
+	}
+
+	user := f.users.users[result.UserID]
+	if user == nil || user.UserName != f.fake.Identity.PreferredUsername || user.Password != "" {
+		t.Fatalf("created user = %+v, want passwordless %q", user, f.fake.Identity.PreferredUsername)
+	}
+	if user.Email == nil || *user.Email != f.fake.Identity.Email || user.VerifiedAt == nil {
+		t.Errorf("created user email = %v, verified = %v, want verified %s", user.Email, user.VerifiedAt, f.fake.Identity.Email)
+	}
+
+	// Повторный вход находит привязанную учетную запись
+	again, err := f.login(t)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.Created || again.UserID != result.UserID {
+		t.Errorf("second login = %+v, want existing user %d", again, result.UserID)
+	}
+}
+
+func TestOIDCCompleteLoginState(t *testing.T) {
+	tests := []struct {
+		name     string
+		stateTTL time.Duration
+		callback func(t *testing.T, f *oidcFixture, cb *dto.OIDCCallbackDTO) *dto.OIDCCallbackDTO
+		wantErr  error
+	}{
+		{
+			name:     "expired state",
+			stateTTL: -time.Second,
+			wantErr:  ErrInvalidOIDCState,
+		},
+		{
+			name:     "unknown state",
+			stateTTL: time.Minute,
+			callback: func(_ *testing.T, _ *oidcFixture, cb *dto.OIDCCallbackDTO) *dto.OIDCCallbackDTO {
+				cb.State = "forged-state"
+				return cb
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name:     "reused state",
+			stateTTL: time.Minute,
+			callback: func(t *testing.T, f *oidcFixture, cb *dto.OIDCCallbackDTO) *dto.OIDCCallbackDTO {
+				if _, err := f.svc.CompleteLogin(context.Background(), testProvider, cb); err != nil {
+					t.Fatalf("first CompleteLogin: %v", err)
+				}
+				return cb
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name:     "provider returned an error",
+			stateTTL: time.Minute,
+			callback: func(_ *testing.T, _ *oidcFixture, cb *dto.OIDCCallbackDTO) *dto.OIDCCallbackDTO {
+				return &dto.OIDCCallbackDTO{State: cb.State, Error: "access_denied"}
+			},
+			wantErr: ErrOIDCAuthFailed,
+		},
+		{
+			name:     "code of another login",
+			stateTTL: time.Minute,
+			callback: func(t *testing.T, f *oidcFixture, cb *dto.OIDCCallbackDTO) *dto.OIDCCallbackDTO {
+				// Код выдан для другого code_challenge, поэтому обмен с нашим code_verifier отклоняется
+				authURL, err := f.svc.BeginLogin(context.Background(), testProvider)
+				if err != nil {
+					t.Fatalf("BeginLogin: %v", err)
+				}
+				cb.Code = f.authorize(t, authURL).Code
+				return cb
+			},
+			wantErr: ErrOIDCAuthFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t, tt.stateTTL)
+
+			authURL, err := f.svc.BeginLogin(context.Background(), testProvider)
+			if err != nil {
+				t.Fatalf("BeginLogin: %v", err)
+			}
+			cb := f.authorize(t, authURL)
+			if tt.callback != nil {
+				cb = tt.callback(t, f, cb)
+			}
+
+			if _, err = f.svc.CompleteLogin(context.Background(), testProvider, cb); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteLogin error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCCompleteLoginRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		override map[string]any
+	}{
+		{name: "nonce mismatch", override: map[string]any{"nonce": "other-nonce"}},
+		{name: "nonce missing", override: map[string]any{"nonce": nil}},
+		{name: "foreign audience", override: map[string]any{"aud": "other-client"}},
+		{name: "foreign issuer", override: map[string]any{"iss": "https://evil.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t, time.Minute)
+			f.fake.Override = tt.override
+
+			if _, err := f.login(t); !errors.Is(err, ErrOIDCAuthFailed) {
+				t.Fatalf("CompleteLogin error = %v, want %v", err, ErrOIDCAuthFailed)
+			}
+			if len(f.users.users) != 0 || len(f.identities.identities) != 0 {
+				t.Errorf("users = %d, identities = %d, want none", len(f.users.users), len(f.identities.identities))
+			}
+		})
+	}
+}
+
+func TestOIDCLoginLinksExistingAccount(t *testing.T) {
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name        string
+		verifiedAt  *time.Time
+		wantLinked  bool
+		wantCreated bool
+	}{
+		{name: "verified email links identity", verifiedAt: &verifiedAt, wantLinked: true},
+		{name: "unverified email creates new user", verifiedAt: nil, wantCreated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t, time.Minute)
+			email := f.fake.Identity.Email
+			f.users.users[testUserID] = &models.User{ID: testUserID, UserName: "existing", Password: "hash", Email: &email, VerifiedAt: tt.verifiedAt}
+
+			result, err := f.login(t)
+			if err != nil {
+				t.Fatalf("CompleteLogin: %v", err)
+			}
+			if result.Created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", result.Created, tt.wantCreated)
+			}
+			if linked := result.UserID == testUserID; linked != tt.wantLinked {
+				t.Errorf("user_id = %d, linked to existing = %v, want %v", result.UserID, linked, tt.wantLinked)
+			}
+			// Адрес, занятый пользователем без подтверждения, новому пользователю не достается
+			if tt.wantCreated && f.users.users[result.UserID].Email != nil {
+				t.Errorf("created user email = %v, want none", *f.users.users[result.UserID].Email)
+			}
+		})
+	}
+}
+
+func TestOIDCBeginLink(t *testing.T) {
+	f := newOIDCFixture(t, time.Minute)
+	ctx := context.Background()
+	f.users.users[testUserID] = &models.User{ID: testUserID, UserName: "existing", Password: "hash"}
+	f.users.users[testReviewerID] = &models.User{ID: testReviewerID, UserName: "another", Password: "hash"}
+
+	link := func(userID int) (*OIDCResult, error) {
+		authURL, err := f.svc.BeginLink(ctx, userID, testProvider)
+		if err != nil {
+			t.Fatalf("BeginLink: %v", err)
+		}
+		return f.svc.CompleteLogin(ctx, testProvider, f.authorize(t, authURL))
+	}
+
+	result, err := link(testUserID)
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if !result.Linked || result.UserID != testUserID {
+		t.Errorf("result = %+v, want linked to %d", result, testUserID)
+	}
+	if got := f.identities.identitiesOf(testUserID); len(got) != 1 || got[0].Subject != f.fake.Identity.Subject {
+		t.Errorf("identities = %+v, want %s", got, f.fake.Identity.Subject)
+	}
+
+	// Та же учетная запись провайдера не может принадлежать двум пользователям
+	if _, err = link(testReviewerID); !errors.Is(err, ErrIdentityAlreadyLinked) {
+		t.Fatalf("second link error = %v, want %v", err, ErrIdentityAlreadyLinked)
+	}
+}
+
+func TestOIDCUnlinkIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		identities []string
+		wantErr    error
+	}{
+		{name: "last login method of passwordless user", identities: []string{testProvider}, wantErr: ErrLastLoginMethod},
+		{name: "passwordless user with another provider", identities: []string{testProvider, "other"}},
+		{name: "user with password", password: "hash", identities: []string{testProvider}},
+		{name: "identity not linked", password: "hash", wantErr: ErrIdentityNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t, time.Minute)
+			f.users.users[testUserID] = &models.User{ID: testUserID, UserName: "existing", Password: tt.password}
+			for i, provider := range tt.identities {
+				f.identities.identities = append(f.identities.identities, &models.UserIdentity{
+					ID: int64(i + 1), UserID: testUserID, Provider: provider, Subject: "subject-" + provider,
+				})
+			}
+
+			err := f.svc.UnlinkIdentity(context.Background(), testUserID, testProvider)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnlinkIdentity error = %v, want %v", err, tt.wantErr)
+			}
+
+			want := len(tt.identities)
+			if err == nil {
+				want--
+			}
+			if got := len(f.identities.identitiesOf(testUserID)); got != want {
+				t.Errorf("identities = %d, want %d", got, want)
+			}
+		})
+	}
+}
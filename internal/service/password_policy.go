@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"user-management/internal/config"
+	"user-management/internal/pkg/passhash"
+	"user-management/internal/pkg/pwned"
+)
+
+// ErrWeakPassword пароль не соответствует политике паролей
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// Классы символов, которые политика может требовать в пароле
+const (
+	PasswordClassUpper  = "upper"
+	PasswordClassLower  = "lower"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+)
+
+// Нарушения политики паролей, которые сообщаются клиенту
+const (
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordMissingUpper     = "missing_upper"
+	PasswordMissingLower     = "missing_lower"
+	PasswordMissingDigit     = "missing_digit"
+	PasswordMissingSymbol    = "missing_symbol"
+	PasswordContainsUsername = "contains_username"
+	PasswordCommon           = "common_password"
+	PasswordBreached         = "breached_password"
+)
+
+// passwordClasses проверки классов символов и нарушение, соответствующее отсутствию класса
+var passwordClasses = map[string]struct {
+	matches   func(r rune) bool
+	violation string
+}{
+	PasswordClassUpper:  {unicode.IsUpper, PasswordMissingUpper},
+	PasswordClassLower:  {unicode.IsLower, PasswordMissingLower},
+	PasswordClassDigit:  {unicode.IsDigit, PasswordMissingDigit},
+	PasswordClassSymbol: {isPasswordSymbol, PasswordMissingSymbol},
+}
+
+// PasswordPolicyError перечисляет правила политики, которым не соответствует пароль
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(e.Violations, ", "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+type PasswordPolicy interface {
+	// Check проверяет новый пароль пользователя и возвращает *PasswordPolicyError, если пароль не соответствует политике
+	Check(ctx context.Context, username, password string) error
+}
+
+type DefaultPasswordPolicy struct {
+	minLength       int
+	maxLength       int
+	maxBytes        int
+	requiredClasses []string
+	forbidUsername  bool
+	denylist        map[string]struct{}
+	breaches        pwned.RangeSource
+	logger          *slog.Logger
+}
+
+// NewPasswordPolicy создает политику паролей из конфигурации и загружает список распространенных паролей.
+// breaches может быть nil: тогда пароли не проверяются по утечкам
+func NewPasswordPolicy(cfg *config.ApiServer, breaches pwned.RangeSource, logger *slog.Logger) (*DefaultPasswordPolicy, error) {
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMaxLength < cfg.PasswordMinLength {
+		return nil, fmt.Errorf("invalid password length limits: min=%d, max=%d", cfg.PasswordMinLength, cfg.PasswordMaxLength)
+	}
+
+	p := &DefaultPasswordPolicy{
+		minLength:      cfg.PasswordMinLength,
+		maxLength:      cfg.PasswordMaxLength,
+		forbidUsername: cfg.PasswordForbidUsername,
+		breaches:       breaches,
+		logger:         logger,
+	}
+
+	for _, class := range cfg.PasswordRequiredClasses {
+		// Значение вида "upper, lower" допускает пробелы после запятой
+		class = strings.TrimSpace(class)
+		if class == "" {
+			continue
+		}
+		if _, ok := passwordClasses[class]; !ok {
+			return nil, fmt.Errorf("unknown password character class: %s", class)
+		}
+		p.requiredClasses = append(p.requiredClasses, class)
+	}
+
+	// Более длинный пароль bcrypt отклонит при хэшировании
+	if cfg.PasswordHashAlgorithm == passhash.AlgorithmBcrypt {
+		p.maxBytes = passhash.BcryptMaxPasswordBytes
+	}
+
+	if cfg.PasswordDenylistFile != "" {
+		denylist, err := loadPasswordDenylist(cfg.PasswordDenylistFile)
+		if err != nil {
+			return nil, err
+		}
+		p.denylist = denylist
+		logger.Info("Password denylist loaded", "count", len(denylist))
+	}
+
+	return p, nil
+}
+
+// Check проверяет пароль по всем правилам и возвращает все нарушения сразу, чтобы клиент мог исправить пароль за одну попытку
+func (p *DefaultPasswordPolicy) Check(ctx context.Context, username, password string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		violations = append(violations, PasswordTooShort)
+	}
+	if length > p.maxLength || (p.maxBytes > 0 && len(password) > p.maxBytes) {
+		violations = append(violations, PasswordTooLong)
+	}
+
+	for _, class := range p.requiredClasses {
+		if !strings.ContainsFunc(password, passwordClasses[class].matches) {
+			violations = append(violations, passwordClasses[class].violation)
+		}
+	}
+
+	lower := strings.ToLower(password)
+	if p.forbidUsername && username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		violations = append(violations, PasswordContainsUsername)
+	}
+	if _, ok := p.denylist[lower]; ok {
+		violations = append(violations, PasswordCommon)
+	}
+
+	// Проверка по утечкам не должна мешать регистрации и смене пароля, если источник недоступен
+	if p.breaches != nil {
+		breached, err := pwned.IsBreached(ctx, p.breaches, password)
+		if err != nil {
+			p.logger.Error("Failed to check password against breaches", "error", err)
+		} else if breached {
+			violations = append(violations, PasswordBreached)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// loadPasswordDenylist читает файл распространенных паролей: по одному в строке, пустые строки и строки с # пропускаются.
+// Пароли сравниваются без учета регистра
+func loadPasswordDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password denylist: %w", err)
+	}
+	defer f.Close()
+
+	denylist := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password denylist: %w", err)
+	}
+
+	return denylist, nil
+}
+
+// isPasswordSymbol относит к специальным символам все, что не является буквой или цифрой, включая пробел
+func isPasswordSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
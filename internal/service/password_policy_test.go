@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"user-management/internal/config"
+	"user-management/internal/pkg/passhash"
+	"user-management/internal/pkg/pwned"
+)
+
+// Файл диапазона 87457 в формате выгрузки Have I Been Pwned. Вторая строка — суффикс SHA-1 пароля "Tr0ub4dor&3",
+// записанный строчными буквами: суффиксы сравниваются без учета регистра
+const testBreachRange = "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" +
+	"2e7a5ae6a49466a6ac578b98adba78c6aa6:3645804\r\n" +
+	"00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2\r\n"
+
+// errBreachSource источник утечек, который всегда недоступен
+type errBreachSource struct{}
+
+func (errBreachSource) Range(context.Context, string) ([]string, error) {
+	return nil, errors.New("source unavailable")
+}
+
+// newTestPasswordPolicy создает политику с длиной 8–16 символов, списком распространенных паролей и каталогом утечек
+// из фикстур. change изменяет конфигурацию перед созданием политики
+func newTestPasswordPolicy(t *testing.T, change func(cfg *config.ApiServer)) *DefaultPasswordPolicy {
+	t.Helper()
+
+	dir := t.TempDir()
+	denylistFile := filepath.Join(dir, "denylist.txt")
+	if err := os.WriteFile(denylistFile, []byte("#Password1\n\nQwerty123!\n  Letmein-2024  \n"), 0o600); err != nil {
+		t.Fatalf("write denylist: %v", err)
+	}
+	breachDir := filepath.Join(dir, "breaches")
+	if err := os.Mkdir(breachDir, 0o700); err != nil {
+		t.Fatalf("create breaches dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(breachDir, "87457.txt"), []byte(testBreachRange), 0o600); err != nil {
+		t.Fatalf("write breach range: %v", err)
+	}
+
+	cfg := &config.ApiServer{
+		PasswordHashAlgorithm:  passhash.AlgorithmArgon2id,
+		PasswordMinLength:      8,
+		PasswordMaxLength:      16,
+		PasswordForbidUsername: true,
+		PasswordDenylistFile:   denylistFile,
+	}
+	if change != nil {
+		change(cfg)
+	}
+
+	breaches, err := pwned.NewDirSource(breachDir)
+	if err != nil {
+		t.Fatalf("NewDirSource: %v", err)
+	}
+	policy, err := NewPasswordPolicy(cfg, breaches, discardLogger())
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+	return policy
+}
+
+// violations возвращает нарушения из ошибки Check или nil, если пароль принят
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("Check error = %v, want *PasswordPolicyError", err)
+	}
+	return policyErr.Violations
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	allClasses := func(cfg *config.ApiServer) {
+		cfg.PasswordRequiredClasses = []string{"upper", " lower", "digit ", "symbol", ""}
+	}
+
+	tests := []struct {
+		name     string
+		change   func(cfg *config.ApiServer)
+		username string
+		password string
+		want     []string
+	}{
+		{name: "acceptable", username: "alice", password: "Correct-Horse-9"},
+		{name: "too short", password: "Sh0rt!", want: []string{PasswordTooShort}},
+		{name: "too long", password: "Much-Too-Long-Password-1", want: []string{PasswordTooLong}},
+		// Длина считается в символах, а не в байтах
+		{name: "length in runes", password: "Пароль-Секрет-1"},
+		{
+			name: "bcrypt byte limit",
+			change: func(cfg *config.ApiServer) {
+				cfg.PasswordHashAlgorithm = passhash.AlgorithmBcrypt
+				cfg.PasswordMaxLength = 128
+			},
+			password: "Пароль-Пароль-Пароль-Пароль-Пароль-Пароль-Пароль-1",
+			want:     []string{PasswordTooLong},
+		},
+		{name: "all classes", change: allClasses, password: "Correct-Horse-9"},
+		{name: "unicode classes", change: allClasses, password: "Пароль-Секрет-1"},
+		{
+			name:     "missing classes",
+			change:   allClasses,
+			password: "lowercase only",
+			want:     []string{PasswordMissingUpper, PasswordMissingDigit},
+		},
+		{
+			name:     "missing symbol",
+			change:   allClasses,
+			password: "NoSymbols123",
+			want:     []string{PasswordMissingSymbol},
+		},
+		{name: "contains username", username: "Alice", password: "my-aLiCe-2024", want: []string{PasswordContainsUsername}},
+		{
+			name:     "username allowed",
+			change:   func(cfg *config.ApiServer) { cfg.PasswordForbidUsername = false },
+			username: "alice",
+			password: "my-alice-2024",
+		},
+		{name: "common password", password: "qWERTY123!", want: []string{PasswordCommon}},
+		{name: "common password trimmed in file", password: "LETMEIN-2024", want: []string{PasswordCommon}},
+		{name: "denylist comment ignored", password: "#Password1"},
+		{name: "breached password", password: "Tr0ub4dor&3", want: []string{PasswordBreached}},
+		// Суффикс диапазона совпадает только с полным хэшем пароля
+		{name: "breached password in other case", password: "tr0ub4dor&3"},
+		{
+			name:     "all violations at once",
+			change:   allClasses,
+			username: "bob",
+			password: "bob",
+			want:     []string{PasswordTooShort, PasswordMissingUpper, PasswordMissingDigit, PasswordMissingSymbol, PasswordContainsUsername},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPasswordPolicy(t, tt.change)
+
+			got := violations(t, policy.Check(context.Background(), tt.username, tt.password))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBreachSourceUnavailable(t *testing.T) {
+	policy := newTestPasswordPolicy(t, nil)
+	policy.breaches = errBreachSource{}
+
+	// Недоступный источник утечек не мешает смене пароля
+	if err := policy.Check(context.Background(), "alice", "Tr0ub4dor&3"); err != nil {
+		t.Errorf("Check with unavailable breach source = %v, want nil", err)
+	}
+}
+
+func TestNewPasswordPolicyInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.ApiServer
+	}{
+		{name: "zero min length", cfg: config.ApiServer{PasswordMinLength: 0, PasswordMaxLength: 16}},
+		{name: "max below min", cfg: config.ApiServer{PasswordMinLength: 8, PasswordMaxLength: 4}},
+		{name: "unknown class", cfg: config.ApiServer{PasswordMinLength: 8, PasswordMaxLength: 16, PasswordRequiredClasses: []string{"emoji"}}},
+		{name: "missing denylist", cfg: config.ApiServer{PasswordMinLength: 8, PasswordMaxLength: 16, PasswordDenylistFile: "missing.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if _, err := NewPasswordPolicy(&cfg, nil, discardLogger()); err == nil {
+				t.Errorf("NewPasswordPolicy succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/mailer"
+	"user-management/internal/pkg/revocation"
+	"user-management/internal/repository"
+)
+
+// Ошибки смены пароля
+var (
+	ErrInvalidResetToken      = errors.New("invalid or expired password reset token")
+	ErrInvalidCurrentPassword = errors.New("invalid current password")
+	ErrSessionRequired        = errors.New("access token is not bound to a session")
+)
+
+// PasswordHasher вычисляет и проверяет хэши паролей. Хэши разных алгоритмов хранятся в одной колонке и различаются
+// по идентификатору в начале строки
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify проверяет пароль по хэшу любого поддерживаемого алгоритма; пустой хэш не совпадает ни с одним паролем
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash сообщает, что хэш вычислен не текущим алгоритмом или не с текущими параметрами
+	NeedsRehash(encoded string) bool
+}
+
+type PasswordService interface {
+	ForgotPassword(ctx context.Context, req *dto.ForgotPasswordDTO) error
+	ResetPassword(ctx context.Context, req *dto.ResetPasswordDTO) error
+	ChangePassword(ctx context.Context, userID int, sessionID int64, req *dto.ChangePasswordDTO, client ClientInfo) (int64, error)
+}
+
+type DefaultPasswordService struct {
+	userRepo    repository.UserRepository
+	resetRepo   repository.PasswordResetRepository
+	tokenRepo   repository.TokenRepository
+	sessionRepo repository.SessionRepository
+	hasher      PasswordHasher
+	policy      PasswordPolicy
+	throttle    LoginThrottle
+	mailer      mailer.Mailer
+	revocations revocation.Bus
+	resetTTL    time.Duration
+	resetURL    string
+	logger      *slog.Logger
+}
+
+func NewPasswordService(userRepo repository.UserRepository, resetRepo repository.PasswordResetRepository, tokenRepo repository.TokenRepository,
+	sessionRepo repository.SessionRepository, hasher PasswordHasher, policy PasswordPolicy, throttle LoginThrottle, mailer mailer.Mailer,
+	revocations revocation.Bus, cfg *config.ApiServer, logger *slog.Logger) *DefaultPasswordService {
+	return &DefaultPasswordService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		hasher:      hasher,
+		policy:      policy,
+		throttle:    throttle,
+		mailer:      mailer,
+		revocations: revocations,
+		resetTTL:    cfg.PasswordResetTTL,
+		resetURL:    cfg.PasswordResetURL,
+		logger:      logger,
+	}
+}
+
+// ForgotPassword выпускает одноразовый токен сброса пароля и отправляет ссылку на подтвержденный адрес пользователя.
+// Для неизвестного пользователя и пользователя без подтвержденного адреса ничего не происходит, чтобы ответ не раскрывал
+// существование учетной записи. Неподтвержденный адрес мог установить кто угодно с доступом к аккаунту, поэтому ссылка
+// на него не отправляется
+func (s *DefaultPasswordService) ForgotPassword(ctx context.Context, req *dto.ForgotPasswordDTO) error {
+	s.logger.Info("Password reset requested", "username", req.UserName)
+
+	storedUser, err := s.userRepo.GetUserByName(ctx, req.UserName)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.logger.Warn("Password reset requested for unknown user", "username", req.UserName)
+			return nil
+		}
+		s.logger.Error("Failed to get user", "error", err)
+		return fmt.Errorf("ForgotPassword: error getting user: %w", err)
+	}
+
+	if storedUser.Email == nil {
+		s.logger.Warn("Password reset requested for user without email", "user_id", storedUser.ID)
+		return nil
+	}
+	if storedUser.VerifiedAt == nil {
+		s.logger.Warn("Password reset requested for user with unverified email", "user_id", storedUser.ID)
+		return nil
+	}
+
+	token, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate reset token", "error", err)
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.resetTTL)
+	if err = s.resetRepo.StoreResetToken(ctx, storedUser.ID, hashToken(token), expiresAt); err != nil {
+		s.logger.Error("Failed to store reset token", "user_id", storedUser.ID, "error", err)
+		return fmt.Errorf("ForgotPassword: error storing reset token: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      *storedUser.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Для сброса пароля перейдите по ссылке:\n%s\n\nСсылка действует до %s. Если вы не запрашивали сброс, проигнорируйте это письмо.\n",
+			linkWithToken(s.resetURL, token), expiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err = s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error("Failed to send reset mail", "user_id", storedUser.ID, "error", err)
+		return fmt.Errorf("ForgotPassword: error sending mail: %w", err)
+	}
+
+	s.logger.Info("Password reset mail sent", "user_id", storedUser.ID)
+	return nil
+}
+
+// ResetPassword погашает токен сброса, устанавливает новый пароль и отзывает все токены пользователя.
+// Пароль, не соответствующий политике, не погашает токен
+func (s *DefaultPasswordService) ResetPassword(ctx context.Context, req *dto.ResetPasswordDTO) (err error) {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var revoked revocation.Event
+	defer publishRevocation(ctx, s.logger, s.revocations, &revoked, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	userID, err := s.resetRepo.UseResetTokenWithTx(ctx, tx, hashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, repository.ErrResetTokenNotFound) {
+			s.logger.Warn("Invalid password reset token")
+			return ErrInvalidResetToken
+		}
+		s.logger.Error("Failed to use reset token", "error", err)
+		return fmt.Errorf("ResetPassword: error using reset token: %w", err)
+	}
+
+	user, err := s.userRepo.GetUserByIDWithTx(ctx, tx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return fmt.Errorf("ResetPassword: error getting user: %w", err)
+	}
+
+	if err = s.policy.Check(ctx, user.UserName, req.Password); err != nil {
+		s.logger.Warn("Password does not meet the policy", "user_id", userID, "error", err)
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		return err
+	}
+
+	if err = s.userRepo.UpdatePasswordWithTx(ctx, tx, userID, hashedPassword); err != nil {
+		s.logger.Error("Failed to update password", "user_id", userID, "error", err)
+		return fmt.Errorf("ResetPassword: error updating password: %w", err)
+	}
+
+	if err = s.tokenRepo.RevokeUserTokensWithTx(ctx, tx, userID); err != nil {
+		s.logger.Error("Failed to revoke user tokens", "user_id", userID, "error", err)
+		return fmt.Errorf("ResetPassword: error revoking tokens: %w", err)
+	}
+	revoked.UserID = userID
+
+	s.logger.Info("Password reset successfully", "user_id", userID)
+	return nil
+}
+
+// ChangePassword меняет пароль вошедшего пользователя после проверки текущего пароля, завершает все остальные
+// сессии пользователя с их токенами и записывает событие в журнал аудита. Текущая сессия sessionID сохраняется.
+// Токен без сессии отклоняется: его нельзя исключить из отзыва, и запрос отозвал бы собственный токен.
+// API ключи не отзываются: пользователь управляет ими отдельно. Неверный текущий пароль засчитывается как
+// неудачная попытка входа, чтобы его нельзя было подобрать с украденным access токеном. Возвращает число
+// завершенных сессий
+func (s *DefaultPasswordService) ChangePassword(ctx context.Context, userID int, sessionID int64, req *dto.ChangePasswordDTO,
+	client ClientInfo) (revoked int64, err error) {
+	if sessionID == 0 {
+		s.logger.Warn("Password change with a token without session", "user_id", userID)
+		return 0, ErrSessionRequired
+	}
+
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var event revocation.Event
+	defer publishRevocation(ctx, s.logger, s.revocations, &event, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	user, err := s.userRepo.GetUserByIDWithTx(ctx, tx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("ChangePassword: error getting user: %w", err)
+	}
+
+	if err = s.throttle.Check(ctx, user.UserName, client.IP); err != nil {
+		return 0, err
+	}
+
+	// У пользователя без пароля (созданного через внешнего провайдера) текущий пароль не совпадет: он задает пароль через сброс
+	ok, err := s.hasher.Verify(req.CurrentPassword, user.Password)
+	if err != nil {
+		s.logger.Error("Failed to verify password", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("ChangePassword: error verifying password: %w", err)
+	}
+	if !ok {
+		s.logger.Warn("Incorrect current password", "user_id", userID)
+		if err = s.throttle.RecordFailure(ctx, user.UserName, client.IP); err != nil {
+			return 0, fmt.Errorf("ChangePassword: %w", err)
+		}
+		return 0, ErrInvalidCurrentPassword
+	}
+
+	if err = s.policy.Check(ctx, user.UserName, req.NewPassword); err != nil {
+		s.logger.Warn("Password does not meet the policy", "user_id", userID, "error", err)
+		return 0, err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		return 0, err
+	}
+
+	if err = s.userRepo.UpdatePasswordWithTx(ctx, tx, userID, hashedPassword); err != nil {
+		s.logger.Error("Failed to update password", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("ChangePassword: error updating password: %w", err)
+	}
+
+	revoked, err = s.sessionRepo.RevokeUserSessionsWithTx(ctx, tx, userID, &sessionID)
+	if err != nil {
+		s.logger.Error("Failed to revoke sessions", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("ChangePassword: error revoking sessions: %w", err)
+	}
+	event = revocation.Event{UserID: userID, ExceptSessionID: sessionID}
+
+	entry := &models.UserAuditEntry{
+		UserID:    userID,
+		Action:    models.UserAuditActionPasswordChange,
+		IP:        client.IP,
+		UserAgent: client.userAgent(),
+	}
+	if err = s.userRepo.AddUserAuditWithTx(ctx, tx, entry); err != nil {
+		s.logger.Error("Failed to record password change", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("ChangePassword: error recording audit: %w", err)
+	}
+
+	s.logger.Info("Password changed successfully", "user_id", userID, "kept_session_id", sessionID, "revoked", revoked)
+	return revoked, nil
+}
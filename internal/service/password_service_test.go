@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/mailer"
+	"user-management/internal/pkg/passhash"
+	"user-management/internal/pkg/revocation"
+)
+
+const (
+	testCurrentPassword = "Current-passw0rd"
+	testNewPassword     = "Another-passw0rd"
+)
+
+// passwordFixture сервис смены пароля с пользователем testUserID и двумя его сессиями
+type passwordFixture struct {
+	svc      *DefaultPasswordService
+	users    *fakeUserRepo
+	auth     *fakeAuthRepo
+	attempts *fakeLoginAttemptRepo
+	resets   *fakeResetRepo
+	mail     *mailer.MemoryMailer
+	hasher   *passhash.Hasher
+	current  int64 // Сессия, из которой меняется пароль
+	other    int64 // Другая сессия пользователя
+}
+
+func newPasswordFixture(t *testing.T) *passwordFixture {
+	t.Helper()
+
+	cfg := &config.ApiServer{
+		PasswordHashAlgorithm: passhash.AlgorithmArgon2id,
+		Argon2Memory:          64,
+		Argon2Iterations:      1,
+		Argon2Parallelism:     1,
+		PasswordMinLength:     8,
+		PasswordMaxLength:     128,
+		LoginMaxFailures:      5,
+		LoginIPMaxFailures:    20,
+		LoginLockoutBase:      time.Minute,
+		LoginLockoutMax:       time.Hour,
+		LoginFailureWindow:    time.Hour,
+	}
+	hasher, err := passhash.New(cfg)
+	if err != nil {
+		t.Fatalf("passhash.New: %v", err)
+	}
+	policy, err := NewPasswordPolicy(cfg, nil, discardLogger())
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+	hash, err := hasher.Hash(testCurrentPassword)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	users := newFakeUserRepo()
+	users.users[testUserID] = &models.User{ID: testUserID, UserName: "alice", Password: hash}
+	attempts := newFakeLoginAttemptRepo()
+	throttle := NewLoginThrottle(attempts, users, cfg, discardLogger())
+	tokens, auth := newTestTokenService()
+	resets := newFakeResetRepo()
+	mail := mailer.NewMemoryMailer()
+
+	f := &passwordFixture{
+		svc:      NewPasswordService(users, resets, auth, auth, hasher, policy, throttle, mail, revocation.NewLocalBus(), cfg, discardLogger()),
+		users:    users,
+		auth:     auth,
+		attempts: attempts,
+		resets:   resets,
+		mail:     mail,
+		hasher:   hasher,
+	}
+	f.current = f.login(t, tokens)
+	f.other = f.login(t, tokens)
+	return f
+}
+
+// login начинает сессию пользователя и возвращает ее ID
+func (f *passwordFixture) login(t *testing.T, tokens *DefaultTokenService) int64 {
+	t.Helper()
+
+	pair, err := tokens.GenerateTokenPair(context.Background(), testUserID, ClientInfo{})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	claims, err := tokens.ValidateToken(context.Background(), pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	return claims.SessionID
+}
+
+// sessionRevoked сообщает, завершена ли сессия и отозваны ли все ее access токены
+func (f *passwordFixture) sessionRevoked(id int64) bool {
+	for _, session := range f.auth.sessions {
+		if session.ID != id {
+			continue
+		}
+		for _, token := range f.auth.tokens {
+			if token.SessionID != nil && *token.SessionID == id && token.Revoked != (session.RevokedAt != nil) {
+				return false
+			}
+		}
+		return session.RevokedAt != nil
+	}
+	return false
+}
+
+func (f *passwordFixture) passwordIs(t *testing.T, password string) bool {
+	t.Helper()
+
+	ok, err := f.hasher.Verify(password, f.users.users[testUserID].Password)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return ok
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		session   func(f *passwordFixture) int64
+		current   string
+		newPass   string
+		wantErr   error
+		wantFails int // Неудачные попытки входа, засчитанные учетной записи
+	}{
+		{name: "keeps current session", session: func(f *passwordFixture) int64 { return f.current }, current: testCurrentPassword, newPass: testNewPassword},
+		// Токен без сессии нельзя исключить из отзыва, поэтому смена пароля с ним отклоняется до проверки пароля
+		{name: "token without session", session: func(*passwordFixture) int64 { return 0 }, current: testCurrentPassword, newPass: testNewPassword, wantErr: ErrSessionRequired},
+		{
+			name: "wrong current password", session: func(f *passwordFixture) int64 { return f.current },
+			current: "wrong-passw0rd", newPass: testNewPassword, wantErr: ErrInvalidCurrentPassword, wantFails: 1,
+		},
+		{name: "weak new password", session: func(f *passwordFixture) int64 { return f.current }, current: testCurrentPassword, newPass: "short", wantErr: ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPasswordFixture(t)
+
+			revoked, err := f.svc.ChangePassword(context.Background(), testUserID, tt.session(f),
+				&dto.ChangePasswordDTO{CurrentPassword: tt.current, NewPassword: tt.newPass}, ClientInfo{IP: "192.0.2.1"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword error = %v, want %v", err, tt.wantErr)
+			}
+			if got := f.attempts.failures[loginAttemptKey(models.LoginAttemptScopeAccount, "alice")]; got != tt.wantFails {
+				t.Errorf("login failures = %d, want %d", got, tt.wantFails)
+			}
+
+			if tt.wantErr != nil {
+				// Отклоненная смена не меняет пароль и не завершает сессии
+				if !f.passwordIs(t, testCurrentPassword) || f.sessionRevoked(f.current) || f.sessionRevoked(f.other) || len(f.users.audit) != 0 {
+					t.Errorf("state changed after rejected password change")
+				}
+				return
+			}
+
+			if revoked != 1 {
+				t.Errorf("revoked = %d, want 1", revoked)
+			}
+			if !f.passwordIs(t, tt.newPass) {
+				t.Errorf("password was not changed")
+			}
+			if f.sessionRevoked(f.current) || !f.sessionRevoked(f.other) {
+				t.Errorf("current revoked = %v, other revoked = %v, want only other session revoked",
+					f.sessionRevoked(f.current), f.sessionRevoked(f.other))
+			}
+			if len(f.users.audit) != 1 || f.users.audit[0].Action != models.UserAuditActionPasswordChange {
+				t.Errorf("audit = %+v, want one password change entry", f.users.audit)
+			}
+		})
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	email := "alice@example.com"
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name       string
+		username   string
+		email      *string
+		verifiedAt *time.Time
+		wantSent   bool
+	}{
+		{name: "verified email", username: "alice", email: &email, verifiedAt: &verifiedAt, wantSent: true},
+		// Неподтвержденный адрес мог установить владелец украденного токена: ссылка сброса на него не отправляется
+		{name: "unverified email", username: "alice", email: &email},
+		{name: "no email", username: "alice"},
+		{name: "unknown user", username: "bob", email: &email, verifiedAt: &verifiedAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPasswordFixture(t)
+			f.users.users[testUserID].Email = tt.email
+			f.users.users[testUserID].VerifiedAt = tt.verifiedAt
+
+			if err := f.svc.ForgotPassword(context.Background(), &dto.ForgotPasswordDTO{UserName: tt.username}); err != nil {
+				t.Fatalf("ForgotPassword: %v", err)
+			}
+
+			messages := f.mail.Messages()
+			if !tt.wantSent {
+				if len(messages) != 0 || len(f.resets.tokens) != 0 {
+					t.Errorf("messages = %d, reset tokens = %d, want none", len(messages), len(f.resets.tokens))
+				}
+				return
+			}
+			if len(messages) != 1 || messages[0].To != email || len(f.resets.tokens) != 1 {
+				t.Errorf("messages = %+v, reset tokens = %d, want one reset link to %s", messages, len(f.resets.tokens), email)
+			}
+		})
+	}
+}
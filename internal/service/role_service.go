@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/pkg/rbac"
+	"user-management/internal/repository"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+type RoleService interface {
+	GrantRole(ctx context.Context, username, role string) error
+	RevokeRole(ctx context.Context, username, role string) error
+	UserRoles(ctx context.Context, username string) ([]string, error)
+}
+
+type DefaultRoleService struct {
+	userRepo repository.UserRepository
+	roleRepo repository.RoleRepository
+	logger   *slog.Logger
+}
+
+func NewRoleService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, logger *slog.Logger) *DefaultRoleService {
+	return &DefaultRoleService{userRepo: userRepo, roleRepo: roleRepo, logger: logger}
+}
+
+// GrantRole выдает роль пользователю. Новая роль попадет в claims при следующем выпуске access токена
+func (s *DefaultRoleService) GrantRole(ctx context.Context, username, role string) error {
+	if !rbac.IsValidRole(role) || role == string(rbac.RoleUser) {
+		return fmt.Errorf("GrantRole: %w: %s", ErrUnknownRole, role)
+	}
+
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GrantRole: error getting user: %w", err)
+	}
+
+	if err = s.roleRepo.GrantRole(ctx, user.ID, role); err != nil {
+		return fmt.Errorf("GrantRole: %w", err)
+	}
+
+	s.logger.Info("Role granted", "user_id", user.ID, "role", role)
+	return nil
+}
+
+// RevokeRole отзывает роль у пользователя
+func (s *DefaultRoleService) RevokeRole(ctx context.Context, username, role string) error {
+	if !rbac.IsValidRole(role) {
+		return fmt.Errorf("RevokeRole: %w: %s", ErrUnknownRole, role)
+	}
+
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		return fmt.Errorf("RevokeRole: error getting user: %w", err)
+	}
+
+	if err = s.roleRepo.RevokeRole(ctx, user.ID, role); err != nil {
+		return fmt.Errorf("RevokeRole: %w", err)
+	}
+
+	s.logger.Info("Role revoked", "user_id", user.ID, "role", role)
+	return nil
+}
+
+// UserRoles возвращает роли, выданные пользователю
+func (s *DefaultRoleService) UserRoles(ctx context.Context, username string) ([]string, error) {
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("UserRoles: error getting user: %w", err)
+	}
+
+	return s.roleRepo.GetUserRoles(ctx, user.ID)
+}
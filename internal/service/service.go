@@ -5,44 +5,68 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"user-management/internal/dto"
 	"user-management/internal/models"
+	"user-management/internal/pkg/revocation"
 	"user-management/internal/repository"
 
 	"github.com/jackc/pgx/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Ошибки сервисного слоя
 var (
-	ErrInvalidReferrer = errors.New("invalid referrer")
-	ErrSetReferrer     = errors.New("user has referrer")
-	ErrIsCompletedTask = errors.New("task already completed")
+	ErrInvalidReferrer     = errors.New("invalid referrer")
+	ErrSetReferrer         = errors.New("user has referrer")
+	ErrIsCompletedTask     = errors.New("task already completed")
+	ErrTaskNotYetAvailable = errors.New("task is not yet available")
+	ErrTaskExpired         = errors.New("task has expired")
+	ErrTaskLimitReached    = errors.New("task completion limit reached")
+	ErrUserAlreadyExists   = errors.New("user already exists")
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrNegativeBalance     = errors.New("balance cannot become negative")
 )
 
 type UserService interface {
 	Register(ctx context.Context, user *dto.UserRegLogDTO) (int, error)
-	Login(ctx context.Context, user *dto.UserRegLogDTO) (*dto.UserLoginDTO, error)
+	Login(ctx context.Context, user *dto.UserRegLogDTO, clientIP string) (*dto.UserLoginDTO, error)
 	UserStatus(ctx context.Context, userID int) (*dto.UserStatusDTO, error)
 	UserLeaderboard(ctx context.Context) ([]dto.UserLeaderDTO, error)
 	AddReferrer(ctx context.Context, userID int, referrer *dto.ReferrerDTO) error
-	TaskComplete(ctx context.Context, userID int, task *dto.TaskDTO) error
+	TaskComplete(ctx context.Context, userID int, task *dto.TaskDTO) (*dto.TaskCompletionDTO, error)
+	AdjustBalance(ctx context.Context, adminID, userID int, req *dto.BalanceAdjustmentDTO) (*dto.BalanceDTO, error)
 }
 
 type DefaultUserService struct {
-	repo   repository.UserRepository
-	logger *slog.Logger
+	repo        repository.UserRepository
+	hasher      PasswordHasher
+	policy      PasswordPolicy
+	verifiers   TaskVerifiers
+	emailPolicy EmailPolicy
+	throttle    LoginThrottle
+	logger      *slog.Logger
 }
 
-func NewUserService(repo repository.UserRepository, logger *slog.Logger) *DefaultUserService {
-	return &DefaultUserService{repo: repo, logger: logger}
+func NewUserService(repo repository.UserRepository, hasher PasswordHasher, policy PasswordPolicy, verifiers TaskVerifiers, emailPolicy EmailPolicy,
+	throttle LoginThrottle, logger *slog.Logger) *DefaultUserService {
+	return &DefaultUserService{repo: repo, hasher: hasher, policy: policy, verifiers: verifiers, emailPolicy: emailPolicy, throttle: throttle, logger: logger}
 }
 
 // Register регистрирует нового пользователя
 func (s *DefaultUserService) Register(ctx context.Context, userDTO *dto.UserRegLogDTO) (userID int, err error) {
 	s.logger.Info("Starting user registration", "username", userDTO.UserName)
 
+	if s.emailPolicy.Required && userDTO.Email == "" {
+		s.logger.Warn("Email is required", "username", userDTO.UserName)
+		return 0, ErrEmailRequired
+	}
+
+	if err = s.policy.Check(ctx, userDTO.UserName, userDTO.Password); err != nil {
+		s.logger.Warn("Password does not meet the policy", "username", userDTO.UserName, "error", err)
+		return 0, err
+	}
+
 	tx, err := s.repo.BeginTransaction(ctx)
 	if err != nil {
 		s.logger.Error("Failed to begin transaction", "error", err)
@@ -50,17 +74,17 @@ func (s *DefaultUserService) Register(ctx context.Context, userDTO *dto.UserRegL
 	}
 	s.logger.Info("Transaction started")
 
-	defer s.handleTransaction(ctx, tx, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
 
 	existingUser, err := s.repo.GetUserByNameWithTx(ctx, tx, userDTO.UserName)
 
 	if existingUser != nil {
 		s.logger.Warn("User already exists", "username", userDTO.UserName)
 		tx.Rollback(ctx)
-		return 0, fmt.Errorf("user with username %s already exists", userDTO.UserName)
+		return 0, fmt.Errorf("user with username %s: %w", userDTO.UserName, ErrUserAlreadyExists)
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userDTO.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(userDTO.Password)
 	if err != nil {
 		s.logger.Error("Failed to hash password", "error", err)
 		return 0, err
@@ -68,7 +92,11 @@ func (s *DefaultUserService) Register(ctx context.Context, userDTO *dto.UserRegL
 
 	user := &models.User{
 		UserName: userDTO.UserName,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+	}
+	if userDTO.Email != "" {
+		email := normalizeEmail(userDTO.Email)
+		user.Email = &email
 	}
 
 	userID, err = s.repo.CreateUserWithTx(ctx, tx, user)
@@ -81,8 +109,9 @@ func (s *DefaultUserService) Register(ctx context.Context, userDTO *dto.UserRegL
 	return userID, nil
 }
 
-// handleTransaction управляет коммитом или откатом транзакции
-func (s *DefaultUserService) handleTransaction(ctx context.Context, tx pgx.Tx, err *error) {
+// handleTransaction управляет коммитом или откатом транзакции.
+// Вызывается через defer сервисами, работающими с транзакциями репозиториев.
+func handleTransaction(ctx context.Context, logger *slog.Logger, tx pgx.Tx, err *error) {
 	if p := recover(); p != nil {
 		tx.Rollback(ctx)
 		panic(p)
@@ -93,30 +122,98 @@ func (s *DefaultUserService) handleTransaction(ctx context.Context, tx pgx.Tx, e
 		if commitErr != nil {
 			*err = fmt.Errorf("failed to commit transaction: %w", commitErr)
 		} else {
-			s.logger.Info("Transaction committed")
+			logger.Info("Transaction committed")
 		}
 	}
 }
 
-// Login производит вход пользователя
-func (s *DefaultUserService) Login(ctx context.Context, userDTO *dto.UserRegLogDTO) (*dto.UserLoginDTO, error) {
-	s.logger.Info("User login attempt", "username", userDTO.UserName)
+// publishRevocation после успешной фиксации транзакции сообщает экземплярам сервиса об отзыве токенов, чтобы они
+// удалили их из кэша проверки. Откладывается до handleTransaction, чтобы выполниться после фиксации: иначе
+// параллельный запрос успел бы закэшировать еще не отозванный токен. Событие заполняется внутри транзакции
+func publishRevocation(ctx context.Context, logger *slog.Logger, bus revocation.Bus, event *revocation.Event, err *error) {
+	if *err != nil || *event == (revocation.Event{}) {
+		return
+	}
+
+	// Отзыв уже зафиксирован: экземпляры, не получившие событие, перестанут принимать токены по истечении срока жизни кэша
+	if pubErr := bus.Publish(ctx, *event); pubErr != nil {
+		logger.Error("Failed to publish token revocation", "error", pubErr)
+	}
+}
+
+// Login производит вход пользователя.
+// Неудачные попытки учитываются по имени пользователя и IP адресу клиента; при превышении порога вход временно блокируется.
+// Счетчик имени пользователя сбрасывается не здесь, а после завершения входа (MFAService.BeginLogin или CompleteLogin):
+// иначе знающий пароль мог бы чередовать вход по паролю с подбором кода второго фактора.
+// Хэш пароля, вычисленный устаревшим алгоритмом или с устаревшими параметрами, пересчитывается после успешной проверки
+func (s *DefaultUserService) Login(ctx context.Context, userDTO *dto.UserRegLogDTO, clientIP string) (*dto.UserLoginDTO, error) {
+	s.logger.Info("User login attempt", "username", userDTO.UserName, "client_ip", clientIP)
+
+	if err := s.throttle.Check(ctx, userDTO.UserName, clientIP); err != nil {
+		return nil, err
+	}
 
 	storedUser, err := s.repo.GetUserByName(ctx, userDTO.UserName)
 	if err != nil {
+		// Неизвестный пользователь и неверный пароль неотличимы для клиента, попытка засчитывается так же
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.logger.Warn("User not found", "username", userDTO.UserName)
+			return nil, s.loginFailed(ctx, userDTO.UserName, clientIP)
+		}
 		s.logger.Error("Failed to get user", "error", err)
 		return nil, fmt.Errorf("Login: error getting user: %w", err)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(userDTO.Password)); err != nil {
+	// У пользователя, созданного через внешнего провайдера, пароля нет, и вход по паролю для него невозможен
+	ok, err := s.hasher.Verify(userDTO.Password, storedUser.Password)
+	if err != nil {
+		s.logger.Error("Failed to verify password", "user_id", storedUser.ID, "error", err)
+		return nil, fmt.Errorf("Login: error verifying password: %w", err)
+	}
+	if !ok {
 		s.logger.Warn("Incorrect password", "username", userDTO.UserName)
-		return nil, fmt.Errorf("incorrect password: %w", err)
+		return nil, s.loginFailed(ctx, userDTO.UserName, clientIP)
+	}
+
+	if s.hasher.NeedsRehash(storedUser.Password) {
+		s.rehashPassword(ctx, storedUser, userDTO.Password)
+	}
+
+	if s.emailPolicy.Verification.blocksLogin() && storedUser.VerifiedAt == nil {
+		s.logger.Warn("Login with unverified email", "user_id", storedUser.ID)
+		return nil, ErrEmailNotVerified
 	}
 
 	s.logger.Info("User logged in successfully", "user_id", storedUser.ID)
 	return &dto.UserLoginDTO{ID: storedUser.ID, UserName: storedUser.UserName}, nil
 }
 
+// rehashPassword пересчитывает хэш пароля текущим алгоритмом. Ошибка не мешает входу: хэш будет пересчитан при следующем
+func (s *DefaultUserService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		s.logger.Error("Failed to rehash password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	updated, err := s.repo.RehashPassword(ctx, user.ID, user.Password, hashedPassword)
+	if err != nil {
+		s.logger.Error("Failed to store rehashed password", "user_id", user.ID, "error", err)
+		return
+	}
+	if updated {
+		s.logger.Info("Password rehashed", "user_id", user.ID)
+	}
+}
+
+// loginFailed засчитывает неудачную попытку входа и возвращает ошибку для клиента
+func (s *DefaultUserService) loginFailed(ctx context.Context, username, clientIP string) error {
+	if err := s.throttle.RecordFailure(ctx, username, clientIP); err != nil {
+		return fmt.Errorf("Login: %w", err)
+	}
+	return ErrInvalidCredentials
+}
+
 // UserStatus предоставляет информацию о пользователе
 func (s *DefaultUserService) UserStatus(ctx context.Context, userID int) (*dto.UserStatusDTO, error) {
 	s.logger.Info("Fetching user status", "userID", userID)
@@ -134,6 +231,9 @@ func (s *DefaultUserService) UserStatus(ctx context.Context, userID int) (*dto.U
 		UpdatedBalance: storedUser.UpdateBalance,
 		Referrer:       storedUser.Referrer,
 		CreatedAt:      storedUser.CreatedAt,
+		Email:          storedUser.Email,
+		EmailVerified:  storedUser.VerifiedAt != nil,
+		VerifiedAt:     storedUser.VerifiedAt,
 	}, nil
 }
 
@@ -167,7 +267,7 @@ func (s *DefaultUserService) AddReferrer(ctx context.Context, userID int, ref *d
 	}
 	s.logger.Info("Transaction started")
 
-	defer s.handleTransaction(ctx, tx, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
 
 	storedUser, err := s.repo.GetUserByIDWithTx(ctx, tx, userID)
 	if err != nil {
@@ -200,7 +300,8 @@ func (s *DefaultUserService) AddReferrer(ctx context.Context, userID int, ref *d
 	}
 
 	pointsForRef := 80
-	err = s.repo.AddPoint(ctx, tx, referrerID, pointsForRef)
+	invitedID := int64(userID)
+	err = s.repo.AddPoint(ctx, tx, referrerID, pointsForRef, models.PointReasonReferralBonus, &invitedID)
 	if err != nil {
 		s.logger.Error("Failed to add points for referral", "error", err)
 		return fmt.Errorf("error adding points: %w", err)
@@ -210,47 +311,170 @@ func (s *DefaultUserService) AddReferrer(ctx context.Context, userID int, ref *d
 	return nil
 }
 
-// TaskComplete определяет выполнение задания
-func (s *DefaultUserService) TaskComplete(ctx context.Context, userID int, task *dto.TaskDTO) error {
+// TaskComplete определяет выполнение задания. Заявка проверяется способом, выбранным для задания;
+// баллы начисляются сразу только при подтвержденном выполнении, иначе заявка ожидает проверки
+func (s *DefaultUserService) TaskComplete(ctx context.Context, userID int, task *dto.TaskDTO) (result *dto.TaskCompletionDTO, err error) {
 	s.logger.Info("Starting to complete task")
 
 	storedTask, err := s.repo.GetTask(ctx, task.ID)
 	if err != nil {
 		s.logger.Error("Failed to get task", "error", err)
-		return fmt.Errorf("error getting task: %w", err)
+		return nil, fmt.Errorf("error getting task: %w", err)
+	}
+
+	now := time.Now()
+	if err = checkTaskAvailability(storedTask, now); err != nil {
+		s.logger.Warn("Task is not available", "task_id", storedTask.ID, "reason", err)
+		return nil, err
+	}
+
+	status, err := s.verifiers.Verify(ctx, storedTask, userID, task.Proof)
+	if err != nil {
+		s.logger.Warn("Task verification failed", "task_id", storedTask.ID, "verification", storedTask.Verification, "reason", err)
+		return nil, err
 	}
 
 	tx, err := s.repo.BeginTransaction(ctx)
 	if err != nil {
 		s.logger.Error("Failed to begin transaction", "error", err)
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	s.logger.Info("Transaction started")
 
-	defer s.handleTransaction(ctx, tx, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	// Блокируем строку пользователя, чтобы параллельные запросы на выполнение заданий выполнялись последовательно
+	storedUser, err := s.repo.GetUserByIDWithTx(ctx, tx, userID)
+	if err != nil {
+		s.logger.Error("Failed to lock user", "error", err)
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	if s.emailPolicy.Verification.blocksTasks() && storedUser.VerifiedAt == nil {
+		s.logger.Warn("Task completion with unverified email", "user_id", userID)
+		return nil, ErrEmailNotVerified
+	}
 
-	isCompleted, err := s.repo.IsCompletedTask(ctx, tx, userID, storedTask.ID)
+	// Заявки, ожидающие проверки, учитываются в лимите, чтобы их нельзя было отправить повторно
+	completions, err := s.repo.CountCompletedTasks(ctx, tx, userID, storedTask.ID, storedTask.PeriodKey(now))
 	if err != nil {
 		s.logger.Error("Failed to check completed task", "error", err)
-		return fmt.Errorf("error checking completed task: %w", err)
+		return nil, fmt.Errorf("error checking completed task: %w", err)
 	}
-	if isCompleted {
-		s.logger.Warn("Task already completed by user", "user_id", userID, "task_id", storedTask.ID)
-		return ErrIsCompletedTask
+	if completions >= storedTask.CompletionLimit() {
+		s.logger.Warn("Task completion limit reached", "user_id", userID, "task_id", storedTask.ID, "recurrence", storedTask.Recurrence)
+		return nil, completionLimitError(storedTask)
+	}
+
+	// Задания без периода нумеруют выполнения; отклоненные заявки не учитываются в лимите, но их номера заняты
+	var last int
+	if storedTask.PeriodKey(now) == "" {
+		if last, err = s.repo.LastCompletionNumber(ctx, tx, userID, storedTask.ID); err != nil {
+			s.logger.Error("Failed to get last completion number", "error", err)
+			return nil, fmt.Errorf("error getting last completion number: %w", err)
+		}
+	}
+
+	completion := &models.TaskCompletion{
+		UserID:      userID,
+		TaskID:      storedTask.ID,
+		PeriodKey:   storedTask.CompletionKey(now, last),
+		Status:      status,
+		CompletedAt: now,
+	}
+	// Код подтверждения не сохраняется, остальные доказательства нужны проверяющему
+	if task.Proof != "" && storedTask.Verification != models.TaskVerificationProofCode {
+		completion.Proof = &task.Proof
 	}
 
-	err = s.repo.AddPoint(ctx, tx, userID, storedTask.Reward)
+	if completion.ID, err = s.repo.AddCompletedTask(ctx, tx, completion); err != nil {
+		if errors.Is(err, repository.ErrCompletionExists) {
+			s.logger.Warn("Task completion already exists", "user_id", userID, "task_id", storedTask.ID, "period_key", completion.PeriodKey)
+			return nil, completionLimitError(storedTask)
+		}
+		s.logger.Error("Failed to add completed task", "error", err)
+		return nil, fmt.Errorf("error adding completed task: %w", err)
+	}
+
+	if status == models.CompletionStatusApproved {
+		err = s.repo.AddPoint(ctx, tx, userID, storedTask.Reward, models.PointReasonTaskReward, &completion.ID)
+		if err != nil {
+			s.logger.Error("Failed to add points for task", "error", err)
+			return nil, fmt.Errorf("error adding points: %w", err)
+		}
+	}
+
+	s.logger.Info("Task completed successful", "completion_id", completion.ID, "status", status)
+	return toTaskCompletionDTO(completion), nil
+}
+
+// AdjustBalance изменяет баланс пользователя по решению администратора. Операция записывается в журнал
+// с причиной admin_adjustment и ID администратора; баланс не может стать отрицательным
+func (s *DefaultUserService) AdjustBalance(ctx context.Context, adminID, userID int, req *dto.BalanceAdjustmentDTO) (result *dto.BalanceDTO, err error) {
+	s.logger.Info("Starting balance adjustment", "admin_id", adminID, "user_id", userID, "amount", req.Amount)
+
+	tx, err := s.repo.BeginTransaction(ctx)
 	if err != nil {
-		s.logger.Error("Failed to add points for task", "error", err)
-		return fmt.Errorf("error adding points: %w", err)
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	err = s.repo.AddCompletedTask(ctx, tx, userID, storedTask.ID)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	// Строка пользователя блокируется, чтобы проверка баланса не разошлась с параллельными начислениями
+	storedUser, err := s.repo.GetUserByIDWithTx(ctx, tx, userID)
 	if err != nil {
-		s.logger.Error("Failed to add completed task", "error", err)
-		return fmt.Errorf("error adding completed task: %w", err)
+		s.logger.Error("Failed to get user", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("AdjustBalance: error getting user: %w", err)
+	}
+
+	balance := storedUser.Balance + req.Amount
+	if balance < 0 {
+		s.logger.Warn("Balance adjustment below zero", "user_id", userID, "balance", storedUser.Balance, "amount", req.Amount)
+		return nil, ErrNegativeBalance
+	}
+
+	adminRef := int64(adminID)
+	if err = s.repo.AddPoint(ctx, tx, userID, req.Amount, models.PointReasonAdminAdjustment, &adminRef); err != nil {
+		s.logger.Error("Failed to adjust balance", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("AdjustBalance: %w", err)
 	}
 
-	s.logger.Info("Task completed successful")
+	s.logger.Info("Balance adjusted", "admin_id", adminID, "user_id", userID, "amount", req.Amount, "balance", balance)
+	return &dto.BalanceDTO{UserID: userID, Balance: balance}, nil
+}
+
+// toTaskCompletionDTO преобразует модель заявки на выполнение задания в DTO
+func toTaskCompletionDTO(c *models.TaskCompletion) *dto.TaskCompletionDTO {
+	return &dto.TaskCompletionDTO{
+		ID:          c.ID,
+		UserID:      c.UserID,
+		TaskID:      c.TaskID,
+		Status:      string(c.Status),
+		Proof:       c.Proof,
+		CompletedAt: c.CompletedAt,
+		ReviewedAt:  c.ReviewedAt,
+		ReviewedBy:  c.ReviewedBy,
+	}
+}
+
+// completionLimitError ошибка исчерпанного лимита выполнений: однократное задание считается уже выполненным
+func completionLimitError(task *models.Task) error {
+	if task.Recurrence == models.TaskRecurrenceOnce {
+		return ErrIsCompletedTask
+	}
+	return ErrTaskLimitReached
+}
+
+// checkTaskAvailability проверяет, что задание не в архиве и находится в периоде доступности
+func checkTaskAvailability(task *models.Task, now time.Time) error {
+	switch {
+	case task.IsArchived:
+		return ErrTaskArchived
+	case task.StartsAt != nil && now.Before(*task.StartsAt):
+		return ErrTaskNotYetAvailable
+	case task.EndsAt != nil && !now.Before(*task.EndsAt):
+		return ErrTaskExpired
+	}
 	return nil
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/repository"
+)
+
+func TestTaskCompleteVerification(t *testing.T) {
+	tests := []struct {
+		name       string
+		verifier   *fakeTaskVerifier
+		wantStatus models.CompletionStatus
+		wantErr    error
+		wantPoints int
+	}{
+		{
+			name: "approved completion credits points", verifier: &fakeTaskVerifier{Status: models.CompletionStatusApproved},
+			wantStatus: models.CompletionStatusApproved, wantPoints: testTaskReward,
+		},
+		{
+			name: "pending completion waits for review", verifier: &fakeTaskVerifier{Status: models.CompletionStatusPending},
+			wantStatus: models.CompletionStatusPending,
+		},
+		{
+			name: "rejected proof stores nothing", verifier: &fakeTaskVerifier{Err: ErrInvalidProof},
+			wantErr: ErrInvalidProof,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepo()
+			repo.users[testUserID] = &models.User{ID: testUserID, UserName: "user"}
+			repo.tasks[1] = &models.Task{ID: 1, Reward: testTaskReward, Recurrence: models.TaskRecurrenceOnce, Verification: models.TaskVerificationManual}
+			verifiers := TaskVerifiers{models.TaskVerificationManual: tt.verifier}
+			svc := NewUserService(repo, nil, nil, verifiers, EmailPolicy{}, nil, discardLogger())
+
+			result, err := svc.TaskComplete(context.Background(), testUserID, &dto.TaskDTO{ID: 1, Proof: "https://example.com/proof"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TaskComplete error = %v, want %v", err, tt.wantErr)
+			}
+			if got := tt.verifier.Proofs(); len(got) != 1 || got[0] != "https://example.com/proof" {
+				t.Errorf("verifier proofs = %q, want the submitted proof", got)
+			}
+			if got := repo.users[testUserID].Balance; got != tt.wantPoints {
+				t.Errorf("balance = %d, want %d", got, tt.wantPoints)
+			}
+			if err != nil {
+				if len(repo.completions) != 0 {
+					t.Errorf("completions = %d, want none", len(repo.completions))
+				}
+				return
+			}
+
+			if result.Status != string(tt.wantStatus) {
+				t.Errorf("status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			if stored := repo.completions[result.ID]; stored == nil || stored.Status != tt.wantStatus {
+				t.Errorf("stored completion = %+v, want status %s", stored, tt.wantStatus)
+			}
+			if entries := repo.pointsOf(testUserID); tt.wantPoints != 0 &&
+				(len(entries) != 1 || entries[0].Reason != models.PointReasonTaskReward || entries[0].ReferenceID == nil || *entries[0].ReferenceID != result.ID) {
+				t.Errorf("point entries = %+v, want one task reward referencing completion %d", entries, result.ID)
+			}
+		})
+	}
+}
+
+func TestAdjustBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int
+		userID      int
+		wantErr     error
+		wantBalance int
+	}{
+		{name: "credit", amount: 25, userID: testUserID, wantBalance: 125},
+		{name: "debit", amount: -40, userID: testUserID, wantBalance: 60},
+		{name: "debit to zero", amount: -100, userID: testUserID, wantBalance: 0},
+		{name: "debit below zero", amount: -101, userID: testUserID, wantErr: ErrNegativeBalance, wantBalance: 100},
+		{name: "unknown user", amount: 10, userID: 42, wantErr: repository.ErrUserNotFound, wantBalance: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepo()
+			repo.users[testUserID] = &models.User{ID: testUserID, UserName: "user", Balance: 100}
+			svc := NewUserService(repo, nil, nil, nil, EmailPolicy{}, nil, discardLogger())
+
+			result, err := svc.AdjustBalance(context.Background(), testReviewerID, tt.userID, &dto.BalanceAdjustmentDTO{Amount: tt.amount})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AdjustBalance error = %v, want %v", err, tt.wantErr)
+			}
+			if got := repo.users[testUserID].Balance; got != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", got, tt.wantBalance)
+			}
+
+			entries := repo.pointsOf(testUserID)
+			if err != nil {
+				if len(entries) != 0 {
+					t.Errorf("point entries = %+v, want none", entries)
+				}
+				return
+			}
+			if result.Balance != tt.wantBalance {
+				t.Errorf("result balance = %d, want %d", result.Balance, tt.wantBalance)
+			}
+			// Операция записывается в журнал с ID администратора
+			if len(entries) != 1 || entries[0].Reason != models.PointReasonAdminAdjustment || entries[0].Points != tt.amount ||
+				entries[0].ReferenceID == nil || *entries[0].ReferenceID != testReviewerID {
+				t.Errorf("point entries = %+v, want one admin adjustment by %d", entries, testReviewerID)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"user-management/internal/dto"
+	"user-management/internal/pkg/revocation"
+	"user-management/internal/repository"
+)
+
+// ErrSessionNotFound ошибка завершения неизвестной, чужой или уже завершенной сессии
+var ErrSessionNotFound = errors.New("session not found")
+
+// maxUserAgentLength максимальная длина сохраняемого User-Agent клиента
+const maxUserAgentLength = 512
+
+// ClientInfo данные клиента, с которого выполнен вход или обновление токенов
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// userAgent возвращает User-Agent, обрезанный до размера поля в базе данных
+func (c ClientInfo) userAgent() string {
+	if len(c.UserAgent) > maxUserAgentLength {
+		return c.UserAgent[:maxUserAgentLength]
+	}
+	return c.UserAgent
+}
+
+type SessionService interface {
+	ListSessions(ctx context.Context, userID int, currentSessionID int64) (*dto.SessionsDTO, error)
+	RevokeSession(ctx context.Context, userID int, sessionID int64) error
+	RevokeAllSessions(ctx context.Context, userID int, keepSessionID int64) (int64, error)
+}
+
+type DefaultSessionService struct {
+	repo        repository.SessionRepository
+	userRepo    repository.UserRepository
+	revocations revocation.Bus
+	logger      *slog.Logger
+}
+
+func NewSessionService(repo repository.SessionRepository, userRepo repository.UserRepository, revocations revocation.Bus, logger *slog.Logger) *DefaultSessionService {
+	return &DefaultSessionService{repo: repo, userRepo: userRepo, revocations: revocations, logger: logger}
+}
+
+// ListSessions возвращает действующие сессии пользователя с отметкой текущей
+func (s *DefaultSessionService) ListSessions(ctx context.Context, userID int, currentSessionID int64) (*dto.SessionsDTO, error) {
+	sessions, err := s.repo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list sessions", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("ListSessions: %w", err)
+	}
+
+	items := make([]dto.SessionDTO, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, dto.SessionDTO{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	return &dto.SessionsDTO{Items: items}, nil
+}
+
+// RevokeSession завершает сессию пользователя и отзывает все ее токены
+func (s *DefaultSessionService) RevokeSession(ctx context.Context, userID int, sessionID int64) (err error) {
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	revoked := revocation.Event{SessionID: sessionID}
+	defer publishRevocation(ctx, s.logger, s.revocations, &revoked, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	if err = s.repo.RevokeSessionWithTx(ctx, tx, userID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.logger.Warn("Session not found", "user_id", userID, "session_id", sessionID)
+			return ErrSessionNotFound
+		}
+		s.logger.Error("Failed to revoke session", "user_id", userID, "session_id", sessionID, "error", err)
+		return fmt.Errorf("RevokeSession: %w", err)
+	}
+
+	s.logger.Info("Session revoked", "user_id", userID, "session_id", sessionID)
+	return nil
+}
+
+// RevokeAllSessions завершает все сессии пользователя, кроме keepSessionID (0 — завершить все),
+// и возвращает число завершенных сессий
+func (s *DefaultSessionService) RevokeAllSessions(ctx context.Context, userID int, keepSessionID int64) (revoked int64, err error) {
+	var except *int64
+	if keepSessionID != 0 {
+		except = &keepSessionID
+	}
+
+	tx, err := s.userRepo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	event := revocation.Event{UserID: userID, ExceptSessionID: keepSessionID}
+	defer publishRevocation(ctx, s.logger, s.revocations, &event, &err)
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	revoked, err = s.repo.RevokeUserSessionsWithTx(ctx, tx, userID, except)
+	if err != nil {
+		s.logger.Error("Failed to revoke sessions", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("RevokeAllSessions: %w", err)
+	}
+
+	s.logger.Info("Sessions revoked", "user_id", userID, "kept_session_id", keepSessionID, "revoked", revoked)
+	return revoked, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// concurrentCompletions число одновременных запросов на выполнение одного задания
+const concurrentCompletions = 20
+
+// newTestDB подключается к тестовой базе данных из DATABASE_URL с примененными миграциями.
+// Без DATABASE_URL тест пропускается
+func newTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	if err = pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		t.Fatalf("ping database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// createTestUser создает пользователя с уникальным именем. Журнал баланса только дополняется и ссылается
+// на пользователя, поэтому пользователь и его операции после теста остаются в базе
+func createTestUser(t *testing.T, repo *repository.UserRepo) int {
+	t.Helper()
+	ctx := context.Background()
+
+	tx, err := repo.BeginTransaction(ctx)
+	if err != nil {
+		t.Fatalf("BeginTransaction: %v", err)
+	}
+	userID, err := repo.CreateUserWithTx(ctx, tx, &models.User{UserName: fmt.Sprintf("concurrency_%d", time.Now().UnixNano())})
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		t.Fatalf("CreateUserWithTx: %v", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return userID
+}
+
+// createTestTask создает задание без проверки и удаляет его вместе с заявками после теста
+func createTestTask(t *testing.T, pool *pgxpool.Pool, task *models.Task) *models.Task {
+	t.Helper()
+	ctx := context.Background()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	created, err := repository.NewTaskRepo(pool, discardLogger()).CreateTaskWithTx(ctx, tx, task)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		t.Fatalf("CreateTaskWithTx: %v", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := pool.Exec(ctx, `DELETE FROM tasks WHERE id = $1`, created.ID); err != nil {
+			t.Errorf("delete task: %v", err)
+		}
+	})
+	return created
+}
+
+func TestTaskCompleteConcurrentDB(t *testing.T) {
+	pool := newTestDB(t)
+
+	maxCompletions := 3
+	tests := []struct {
+		name       string
+		task       models.Task
+		wantCredit int   // Число начислений награды
+		limitErr   error // Ошибка для запросов сверх лимита
+	}{
+		{name: "once", task: models.Task{Recurrence: models.TaskRecurrenceOnce}, wantCredit: 1, limitErr: ErrIsCompletedTask},
+		{name: "daily", task: models.Task{Recurrence: models.TaskRecurrenceDaily}, wantCredit: 1, limitErr: ErrTaskLimitReached},
+		{
+			name:       "limited",
+			task:       models.Task{Recurrence: models.TaskRecurrenceLimited, MaxCompletions: &maxCompletions},
+			wantCredit: maxCompletions,
+			limitErr:   ErrTaskLimitReached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := repository.NewUserRepository(pool, discardLogger())
+			svc := NewUserService(repo, nil, nil, NewTaskVerifiers(nil), EmailPolicy{}, nil, discardLogger())
+
+			tt.task.Description = "concurrency " + tt.name
+			tt.task.Reward = testTaskReward
+			tt.task.Verification = models.TaskVerificationNone
+			task := createTestTask(t, pool, &tt.task)
+			userID := createTestUser(t, repo)
+
+			var (
+				wg    sync.WaitGroup
+				start = make(chan struct{})
+				errs  = make([]error, concurrentCompletions)
+			)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+					_, errs[i] = svc.TaskComplete(ctx, userID, &dto.TaskDTO{ID: task.ID})
+				}(i)
+			}
+			close(start)
+			wg.Wait()
+
+			// Лишние запросы получают ошибку лимита (409), а не внутреннюю ошибку
+			succeeded := 0
+			for i, err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case !errors.Is(err, tt.limitErr):
+					t.Errorf("request %d error = %v, want nil or %v", i, err, tt.limitErr)
+				}
+			}
+			if succeeded != tt.wantCredit {
+				t.Errorf("succeeded = %d, want %d", succeeded, tt.wantCredit)
+			}
+
+			var completions, credits, credited int
+			err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM completed_tasks WHERE user_id = $1 AND task_id = $2`, userID, task.ID).Scan(&completions)
+			if err != nil {
+				t.Fatalf("count completions: %v", err)
+			}
+			err = pool.QueryRow(ctx, `SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM point_transactions WHERE user_id = $1 AND reason = $2`,
+				userID, models.PointReasonTaskReward).Scan(&credits, &credited)
+			if err != nil {
+				t.Fatalf("count point transactions: %v", err)
+			}
+			user, err := repo.GetUserByID(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+
+			want := tt.wantCredit * testTaskReward
+			if completions != tt.wantCredit || credits != tt.wantCredit || credited != want || user.Balance != want {
+				t.Errorf("completions = %d, credits = %d (%d points), balance = %d, want %d, %d (%d points), %d",
+					completions, credits, credited, user.Balance, tt.wantCredit, tt.wantCredit, want, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Ошибки управления заданиями
+var (
+	ErrInvalidTaskWindow     = errors.New("task end must be after start")
+	ErrInvalidTaskRecurrence = errors.New("max_completions must be set only for limited recurrence")
+	ErrTaskArchived          = errors.New("task is archived")
+
+	ErrInvalidTaskVerification = errors.New("proof_code must be set only for proof_code verification")
+)
+
+type TaskService interface {
+	CreateTask(ctx context.Context, actorID int, input *dto.TaskInputDTO) (*dto.AdminTaskDTO, error)
+	UpdateTask(ctx context.Context, actorID, taskID int, input *dto.TaskInputDTO) (*dto.AdminTaskDTO, error)
+	ArchiveTask(ctx context.Context, actorID, taskID int) (*dto.AdminTaskDTO, error)
+	ListTasks(ctx context.Context, query *dto.TaskListQueryDTO) (*dto.AdminTasksDTO, error)
+	TaskAudit(ctx context.Context, taskID int) ([]dto.TaskAuditDTO, error)
+	TaskCatalogue(ctx context.Context, userID int, query *dto.TaskCatalogueQueryDTO) (*dto.TaskCatalogueDTO, error)
+}
+
+type DefaultTaskService struct {
+	repo   repository.TaskRepository
+	logger *slog.Logger
+}
+
+func NewTaskService(repo repository.TaskRepository, logger *slog.Logger) *DefaultTaskService {
+	return &DefaultTaskService{repo: repo, logger: logger}
+}
+
+// CreateTask создает новое задание и записывает действие в журнал аудита
+func (s *DefaultTaskService) CreateTask(ctx context.Context, actorID int, input *dto.TaskInputDTO) (result *dto.AdminTaskDTO, err error) {
+	s.logger.Info("Starting to create task", "actor_id", actorID)
+
+	if err = validateTaskInput(input); err != nil {
+		return nil, err
+	}
+	if input.Verification == string(models.TaskVerificationProofCode) && input.ProofCode == nil {
+		return nil, ErrInvalidTaskVerification
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	created, err := s.repo.CreateTaskWithTx(ctx, tx, toTaskModel(0, input))
+	if err != nil {
+		s.logger.Error("Failed to create task", "error", err)
+		return nil, fmt.Errorf("error creating task: %w", err)
+	}
+
+	if err = s.audit(ctx, tx, actorID, models.TaskAuditActionCreate, nil, created); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Task created successfully", "task_id", created.ID, "actor_id", actorID)
+	return toAdminTaskDTO(created), nil
+}
+
+// UpdateTask заменяет описание, награду и период доступности задания
+func (s *DefaultTaskService) UpdateTask(ctx context.Context, actorID, taskID int, input *dto.TaskInputDTO) (result *dto.AdminTaskDTO, err error) {
+	s.logger.Info("Starting to update task", "actor_id", actorID, "task_id", taskID)
+
+	if err = validateTaskInput(input); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	before, err := s.repo.GetTaskWithTx(ctx, tx, taskID)
+	if err != nil {
+		s.logger.Error("Failed to get task", "error", err)
+		return nil, fmt.Errorf("error getting task: %w", err)
+	}
+	if before.IsArchived {
+		s.logger.Warn("Attempt to update archived task", "task_id", taskID)
+		return nil, ErrTaskArchived
+	}
+	// При смене способа подтверждения на proof_code код обязателен, иначе сохраняется прежний
+	if input.Verification == string(models.TaskVerificationProofCode) && input.ProofCode == nil && before.VerificationCodeHash == nil {
+		return nil, ErrInvalidTaskVerification
+	}
+
+	updated, err := s.repo.UpdateTaskWithTx(ctx, tx, toTaskModel(taskID, input))
+	if err != nil {
+		s.logger.Error("Failed to update task", "error", err)
+		return nil, fmt.Errorf("error updating task: %w", err)
+	}
+
+	if err = s.audit(ctx, tx, actorID, models.TaskAuditActionUpdate, before, updated); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Task updated successfully", "task_id", taskID, "actor_id", actorID)
+	return toAdminTaskDTO(updated), nil
+}
+
+// ArchiveTask переносит задание в архив, после чего его нельзя выполнить
+func (s *DefaultTaskService) ArchiveTask(ctx context.Context, actorID, taskID int) (result *dto.AdminTaskDTO, err error) {
+	s.logger.Info("Starting to archive task", "actor_id", actorID, "task_id", taskID)
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		s.logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer handleTransaction(ctx, s.logger, tx, &err)
+
+	before, err := s.repo.GetTaskWithTx(ctx, tx, taskID)
+	if err != nil {
+		s.logger.Error("Failed to get task", "error", err)
+		return nil, fmt.Errorf("error getting task: %w", err)
+	}
+	if before.IsArchived {
+		s.logger.Warn("Task already archived", "task_id", taskID)
+		return nil, ErrTaskArchived
+	}
+
+	archived, err := s.repo.ArchiveTaskWithTx(ctx, tx, taskID)
+	if err != nil {
+		s.logger.Error("Failed to archive task", "error", err)
+		return nil, fmt.Errorf("error archiving task: %w", err)
+	}
+
+	if err = s.audit(ctx, tx, actorID, models.TaskAuditActionArchive, before, archived); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Task archived successfully", "task_id", taskID, "actor_id", actorID)
+	return toAdminTaskDTO(archived), nil
+}
+
+// ListTasks предоставляет страницу списка заданий
+func (s *DefaultTaskService) ListTasks(ctx context.Context, query *dto.TaskListQueryDTO) (*dto.AdminTasksDTO, error) {
+	s.logger.Info("Fetching tasks", "include_archived", query.IncludeArchived)
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	total, err := s.repo.CountTasks(ctx, query.IncludeArchived)
+	if err != nil {
+		s.logger.Error("Failed to count tasks", "error", err)
+		return nil, fmt.Errorf("ListTasks: error counting tasks: %w", err)
+	}
+
+	tasks, err := s.repo.ListTasks(ctx, query.IncludeArchived, limit, query.Offset)
+	if err != nil {
+		s.logger.Error("Failed to list tasks", "error", err)
+		return nil, fmt.Errorf("ListTasks: error listing tasks: %w", err)
+	}
+
+	items := make([]dto.AdminTaskDTO, 0, len(tasks))
+	for i := range tasks {
+		items = append(items, *toAdminTaskDTO(&tasks[i]))
+	}
+
+	return &dto.AdminTasksDTO{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// TaskAudit предоставляет журнал изменений задания
+func (s *DefaultTaskService) TaskAudit(ctx context.Context, taskID int) ([]dto.TaskAuditDTO, error) {
+	s.logger.Info("Fetching task audit", "task_id", taskID)
+
+	entries, err := s.repo.GetTaskAudit(ctx, taskID)
+	if err != nil {
+		s.logger.Error("Failed to get task audit", "error", err)
+		return nil, fmt.Errorf("TaskAudit: %w", err)
+	}
+
+	result := make([]dto.TaskAuditDTO, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, dto.TaskAuditDTO{
+			ID:        e.ID,
+			TaskID:    e.TaskID,
+			ActorID:   e.ActorID,
+			Action:    string(e.Action),
+			Before:    e.Before,
+			After:     e.After,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// TaskCatalogue предоставляет страницу активных заданий со статусом их выполнения пользователем.
+// Для анонимного запроса userID равен 0 и все задания считаются невыполненными
+func (s *DefaultTaskService) TaskCatalogue(ctx context.Context, userID int, query *dto.TaskCatalogueQueryDTO) (*dto.TaskCatalogueDTO, error) {
+	s.logger.Info("Fetching task catalogue", "user_id", userID, "status", query.Status)
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	var completed *bool
+	if query.Status != "" {
+		isCompleted := query.Status == dto.TaskStatusCompleted
+		completed = &isCompleted
+	}
+
+	now := time.Now()
+
+	total, err := s.repo.CountUserTasks(ctx, userID, now, completed)
+	if err != nil {
+		s.logger.Error("Failed to count user tasks", "error", err)
+		return nil, fmt.Errorf("TaskCatalogue: error counting tasks: %w", err)
+	}
+
+	tasks, err := s.repo.ListUserTasks(ctx, userID, now, completed, limit, query.Offset)
+	if err != nil {
+		s.logger.Error("Failed to list user tasks", "error", err)
+		return nil, fmt.Errorf("TaskCatalogue: error listing tasks: %w", err)
+	}
+
+	items := make([]dto.TaskCatalogueItemDTO, 0, len(tasks))
+	for _, t := range tasks {
+		items = append(items, dto.TaskCatalogueItemDTO{
+			ID:             t.ID,
+			Description:    t.Description,
+			Reward:         t.Reward,
+			StartsAt:       t.StartsAt,
+			EndsAt:         t.EndsAt,
+			Recurrence:     string(t.Recurrence),
+			MaxCompletions: t.MaxCompletions,
+			Verification:   string(t.Verification),
+			Completions:    t.Completions,
+			Pending:        t.Pending,
+			Completed:      t.Completed,
+			CompletedAt:    t.CompletedAt,
+		})
+	}
+
+	return &dto.TaskCatalogueDTO{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// audit записывает снимки задания до и после изменения в журнал аудита
+func (s *DefaultTaskService) audit(ctx context.Context, tx pgx.Tx, actorID int, action models.TaskAuditAction, before, after *models.Task) error {
+	entry := &models.TaskAuditEntry{
+		TaskID:  after.ID,
+		ActorID: actorID,
+		Action:  action,
+	}
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(toAdminTaskDTO(before)); err != nil {
+			return fmt.Errorf("error encoding task snapshot: %w", err)
+		}
+	}
+	if entry.After, err = json.Marshal(toAdminTaskDTO(after)); err != nil {
+		return fmt.Errorf("error encoding task snapshot: %w", err)
+	}
+
+	if err = s.repo.AddTaskAuditWithTx(ctx, tx, entry); err != nil {
+		s.logger.Error("Failed to write task audit", "error", err)
+		return fmt.Errorf("error writing task audit: %w", err)
+	}
+
+	return nil
+}
+
+// validateTaskInput проверяет согласованность периода доступности, политики повторения и способа подтверждения задания
+func validateTaskInput(input *dto.TaskInputDTO) error {
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		return ErrInvalidTaskWindow
+	}
+	if (input.Recurrence == string(models.TaskRecurrenceLimited)) != (input.MaxCompletions != nil) {
+		return ErrInvalidTaskRecurrence
+	}
+	if input.ProofCode != nil && input.Verification != string(models.TaskVerificationProofCode) {
+		return ErrInvalidTaskVerification
+	}
+	return nil
+}
+
+// toTaskModel преобразует данные администратора в модель задания
+func toTaskModel(taskID int, input *dto.TaskInputDTO) *models.Task {
+	recurrence := models.TaskRecurrence(input.Recurrence)
+	if recurrence == "" {
+		recurrence = models.TaskRecurrenceOnce
+	}
+
+	verification := models.TaskVerification(input.Verification)
+	if verification == "" {
+		verification = models.TaskVerificationNone
+	}
+
+	// Код подтверждения хранится только в виде хэша
+	var codeHash *string
+	if input.ProofCode != nil {
+		hash := hashToken(*input.ProofCode)
+		codeHash = &hash
+	}
+
+	return &models.Task{
+		ID:                   taskID,
+		Description:          input.Description,
+		Reward:               input.Reward,
+		StartsAt:             input.StartsAt,
+		EndsAt:               input.EndsAt,
+		Recurrence:           recurrence,
+		MaxCompletions:       input.MaxCompletions,
+		Verification:         verification,
+		VerificationCodeHash: codeHash,
+	}
+}
+
+// toAdminTaskDTO преобразует модель задания в DTO для администратора
+func toAdminTaskDTO(t *models.Task) *dto.AdminTaskDTO {
+	return &dto.AdminTaskDTO{
+		ID:             t.ID,
+		Description:    t.Description,
+		Reward:         t.Reward,
+		StartsAt:       t.StartsAt,
+		EndsAt:         t.EndsAt,
+		IsArchived:     t.IsArchived,
+		Recurrence:     string(t.Recurrence),
+		MaxCompletions: t.MaxCompletions,
+		Verification:   string(t.Verification),
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+	}
+}
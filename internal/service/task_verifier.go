@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"user-management/internal/models"
+)
+
+// Ошибки проверки выполнения заданий
+var (
+	ErrInvalidProof            = errors.New("invalid proof code")
+	ErrUnsupportedVerification = errors.New("unsupported task verification")
+)
+
+// TaskVerifier проверяет заявку пользователя на выполнение задания и возвращает
+// статус, с которым заявка будет сохранена. Баллы начисляются только для статуса approved
+type TaskVerifier interface {
+	Verify(ctx context.Context, task *models.Task, userID int, proof string) (models.CompletionStatus, error)
+}
+
+// TaskVerifiers набор проверок, выбираемых по способу подтверждения задания
+type TaskVerifiers map[models.TaskVerification]TaskVerifier
+
+// NewTaskVerifiers возвращает стандартный набор проверок
+func NewTaskVerifiers(callback *CallbackVerifier) TaskVerifiers {
+	return TaskVerifiers{
+		models.TaskVerificationNone:      AutoVerifier{},
+		models.TaskVerificationManual:    ManualReviewVerifier{},
+		models.TaskVerificationCallback:  callback,
+		models.TaskVerificationProofCode: ProofCodeVerifier{},
+	}
+}
+
+// Verify выбирает проверку по способу подтверждения задания
+func (v TaskVerifiers) Verify(ctx context.Context, task *models.Task, userID int, proof string) (models.CompletionStatus, error) {
+	verifier, ok := v[task.Verification]
+	if !ok {
+		return "", ErrUnsupportedVerification
+	}
+	return verifier.Verify(ctx, task, userID, proof)
+}
+
+// AutoVerifier засчитывает выполнение сразу
+type AutoVerifier struct{}
+
+func (AutoVerifier) Verify(context.Context, *models.Task, int, string) (models.CompletionStatus, error) {
+	return models.CompletionStatusApproved, nil
+}
+
+// ManualReviewVerifier ставит заявку в очередь на проверку администратором
+type ManualReviewVerifier struct{}
+
+func (ManualReviewVerifier) Verify(context.Context, *models.Task, int, string) (models.CompletionStatus, error) {
+	return models.CompletionStatusPending, nil
+}
+
+// CallbackVerifier ставит заявку в ожидание подписанного обратного вызова внешней системы
+type CallbackVerifier struct {
+	secret []byte
+}
+
+func NewCallbackVerifier(secret string) *CallbackVerifier {
+	return &CallbackVerifier{secret: []byte(secret)}
+}
+
+func (v *CallbackVerifier) Verify(context.Context, *models.Task, int, string) (models.CompletionStatus, error) {
+	return models.CompletionStatusPending, nil
+}
+
+// CheckSignature проверяет подпись тела обратного вызова (HMAC-SHA256 в hex).
+// Без настроенного секрета обратные вызовы не принимаются
+func (v *CallbackVerifier) CheckSignature(payload []byte, signature string) bool {
+	if len(v.secret) == 0 {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ProofCodeVerifier засчитывает выполнение при вводе кода подтверждения, заданного администратором
+type ProofCodeVerifier struct{}
+
+func (ProofCodeVerifier) Verify(_ context.Context, task *models.Task, _ int, proof string) (models.CompletionStatus, error) {
+	if task.VerificationCodeHash == nil || proof == "" {
+		return "", ErrInvalidProof
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(proof)), []byte(*task.VerificationCodeHash)) != 1 {
+		return "", ErrInvalidProof
+	}
+	return models.CompletionStatusApproved, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"user-management/internal/models"
+)
+
+func TestCallbackVerifierCheckSignature(t *testing.T) {
+	payload := []byte(`{"completion_id":7,"approved":false}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: testSecret, signature: sign(testSecret, payload), want: true},
+		{name: "uppercase hex", secret: testSecret, signature: strings.ToUpper(sign(testSecret, payload)), want: true},
+		{name: "wrong secret", secret: testSecret, signature: sign("other", payload), want: false},
+		{name: "truncated", secret: testSecret, signature: sign(testSecret, payload)[:32], want: false},
+		{name: "not hex", secret: testSecret, signature: "zz" + sign(testSecret, payload)[2:], want: false},
+		{name: "empty signature", secret: testSecret, signature: "", want: false},
+		{name: "secret not configured", secret: "", signature: sign("", payload), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewCallbackVerifier(tt.secret).CheckSignature(payload, tt.signature); got != tt.want {
+				t.Errorf("CheckSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProofCodeVerifier(t *testing.T) {
+	codeHash := hashToken("secret-code")
+
+	tests := []struct {
+		name    string
+		hash    *string
+		proof   string
+		wantErr error
+	}{
+		{name: "matching code", hash: &codeHash, proof: "secret-code"},
+		{name: "wrong code", hash: &codeHash, proof: "other-code", wantErr: ErrInvalidProof},
+		{name: "empty code", hash: &codeHash, proof: "", wantErr: ErrInvalidProof},
+		{name: "code not configured", hash: nil, proof: "secret-code", wantErr: ErrInvalidProof},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &models.Task{Verification: models.TaskVerificationProofCode, VerificationCodeHash: tt.hash}
+
+			status, err := ProofCodeVerifier{}.Verify(context.Background(), task, testUserID, tt.proof)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && status != models.CompletionStatusApproved {
+				t.Errorf("status = %s, want %s", status, models.CompletionStatusApproved)
+			}
+		})
+	}
+}
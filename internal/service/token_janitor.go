@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/pkg/metrics"
+	"user-management/internal/repository"
+)
+
+// Таблицы, очищаемые от устаревших строк (ключи метрики удаленных строк)
+const (
+	purgeTableTokens        = "tokens"
+	purgeTableRefreshTokens = "refresh_tokens"
+	purgeTableSessions      = "sessions"
+	purgeTableAuthRequests  = "oidc_auth_requests"
+	purgeTableOAuthCodes    = "oauth_authorization_codes"
+)
+
+// PurgeResult число строк, удаленных за одну очистку
+type PurgeResult struct {
+	Tokens        int64
+	RefreshTokens int64
+	Sessions      int64
+	AuthRequests  int64
+	OAuthCodes    int64
+}
+
+type TokenJanitor interface {
+	Run(ctx context.Context)
+	Purge(ctx context.Context) (*PurgeResult, error)
+}
+
+type DefaultTokenJanitor struct {
+	tokenRepo    repository.TokenRepository
+	sessionRepo  repository.SessionRepository
+	identityRepo repository.IdentityRepository
+	oauthRepo    repository.OAuthRepository
+	interval     time.Duration
+	retention    time.Duration
+	batchSize    int
+	logger       *slog.Logger
+}
+
+func NewTokenJanitor(tokenRepo repository.TokenRepository, sessionRepo repository.SessionRepository, identityRepo repository.IdentityRepository,
+	oauthRepo repository.OAuthRepository, cfg *config.ApiServer, logger *slog.Logger) *DefaultTokenJanitor {
+	return &DefaultTokenJanitor{
+		tokenRepo:    tokenRepo,
+		sessionRepo:  sessionRepo,
+		identityRepo: identityRepo,
+		oauthRepo:    oauthRepo,
+		interval:     cfg.TokenCleanupInterval,
+		retention:    cfg.TokenRetention,
+		batchSize:    max(cfg.TokenCleanupBatchSize, 1),
+		logger:       logger,
+	}
+}
+
+// Run выполняет очистку при запуске и затем с заданным интервалом до отмены контекста.
+// Нулевой интервал отключает очистку
+func (j *DefaultTokenJanitor) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		j.logger.Info("Token cleanup is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		result, err := j.Purge(ctx)
+
+		metrics.TokenJanitorRuns.Add(1)
+		metrics.TokenJanitorLastRun.Set(time.Now().Unix())
+		metrics.TokenJanitorLastDuration.Set(time.Since(start).Seconds())
+		if err != nil && ctx.Err() == nil {
+			metrics.TokenJanitorFailures.Add(1)
+			j.logger.Error("Token cleanup failed", "error", err)
+		} else if err == nil {
+			j.logger.Info("Token cleanup completed", "tokens", result.Tokens, "refresh_tokens", result.RefreshTokens,
+				"sessions", result.Sessions, "oidc_auth_requests", result.AuthRequests, "oauth_authorization_codes", result.OAuthCodes, "duration", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge удаляет access и refresh токены и сессии, истекшие или отозванные раньше чем retention назад.
+// Токены удаляются раньше сессий, чтобы удаление сессии не обновляло ссылки на нее в токенах.
+// Незавершенные входы через внешних провайдеров и коды авторизации OAuth2 не нужны после истечения
+// и удаляются без задержки retention
+func (j *DefaultTokenJanitor) Purge(ctx context.Context) (*PurgeResult, error) {
+	var result PurgeResult
+	var err error
+
+	if result.Tokens, err = j.purgeBatches(ctx, purgeTableTokens, j.tokenRepo.DeleteExpiredTokens); err != nil {
+		return &result, err
+	}
+	if result.RefreshTokens, err = j.purgeBatches(ctx, purgeTableRefreshTokens, j.tokenRepo.DeleteExpiredRefreshTokens); err != nil {
+		return &result, err
+	}
+	if result.Sessions, err = j.purgeBatches(ctx, purgeTableSessions, j.sessionRepo.DeleteExpiredSessions); err != nil {
+		return &result, err
+	}
+	deleteAuthRequests := func(ctx context.Context, _ time.Duration, limit int) (int64, error) {
+		return j.identityRepo.DeleteExpiredAuthRequests(ctx, limit)
+	}
+	if result.AuthRequests, err = j.purgeBatches(ctx, purgeTableAuthRequests, deleteAuthRequests); err != nil {
+		return &result, err
+	}
+	deleteOAuthCodes := func(ctx context.Context, _ time.Duration, limit int) (int64, error) {
+		return j.oauthRepo.DeleteExpiredAuthorizationCodes(ctx, limit)
+	}
+	if result.OAuthCodes, err = j.purgeBatches(ctx, purgeTableOAuthCodes, deleteOAuthCodes); err != nil {
+		return &result, err
+	}
+
+	return &result, nil
+}
+
+// purgeBatches удаляет устаревшие строки таблицы пачками по batchSize, пока не будет удалена неполная пачка,
+// чтобы не держать длинные блокировки
+func (j *DefaultTokenJanitor) purgeBatches(ctx context.Context, table string, deleteBatch func(context.Context, time.Duration, int) (int64, error)) (int64, error) {
+	var total int64
+
+	for {
+		deleted, err := deleteBatch(ctx, j.retention, j.batchSize)
+		if err != nil {
+			return total, fmt.Errorf("purge %s: %w", table, err)
+		}
+
+		total += deleted
+		metrics.TokenJanitorPurged.Add(table, deleted)
+
+		if deleted < int64(j.batchSize) {
+			return total, nil
+		}
+		if err = ctx.Err(); err != nil {
+			return total, fmt.Errorf("purge %s: %w", table, err)
+		}
+	}
+}
@@ -2,50 +2,127 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/models"
+	"user-management/internal/pkg/jwtkeys"
+	"user-management/internal/pkg/rbac"
+	"user-management/internal/pkg/revocation"
+	"user-management/internal/pkg/tokencache"
 	"user-management/internal/repository"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Ошибки работы с refresh токенами
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// sessionTouchInterval как часто обновляется время последнего обращения в рамках сессии
+const sessionTouchInterval = time.Minute
+
+// AccessClaims данные, извлеченные из проверенного access токена или API ключа
+type AccessClaims struct {
+	UserID    int
+	Roles     []string
+	SessionID int64    // 0 для токенов, выпущенных вне сессии, и API ключей
+	APIKeyID  int64    // 0 для access токенов
+	ClientID  string   // Приложение OAuth2, которому выдан токен; пустая строка для токенов пользователя
+	Scopes    []string // Права, которыми ограничен API ключ или токен приложения; пустой список — без ограничений
+}
+
 type TokenService interface {
 	GenerateToken(ctx context.Context, userID int) (string, time.Time, error)
-	ValidateToken(ctx context.Context, token string) (int, error)
+	GenerateTokenPair(ctx context.Context, userID int, client ClientInfo) (*dto.TokenPairDTO, error)
+	GenerateClientToken(ctx context.Context, client *models.OAuthClient, userID *int, scopes []string) (string, time.Time, error)
+	RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (*dto.TokenPairDTO, error)
+	ValidateToken(ctx context.Context, token string) (*AccessClaims, error)
 	RevokeToken(ctx context.Context, token string) error
+	RevokeClientToken(ctx context.Context, token string, clientID string) error
+	RevokeRefreshToken(ctx context.Context, refreshToken string) error
 }
 type DefaultTokenService struct {
-	repo      repository.TokenRepository
-	secretKey string
-	logger    *slog.Logger
+	repo            repository.TokenRepository
+	roleRepo        repository.RoleRepository
+	sessionRepo     repository.SessionRepository
+	keys            jwtkeys.KeySet
+	cache           *tokencache.Cache
+	revocations     revocation.Bus
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	logger          *slog.Logger
 }
 
-func NewTokenService(repo repository.TokenRepository, secretKey string, logger *slog.Logger) *DefaultTokenService {
+func NewTokenService(repo repository.TokenRepository, roleRepo repository.RoleRepository, sessionRepo repository.SessionRepository, keys jwtkeys.KeySet,
+	revocations revocation.Bus, cfg *config.ApiServer, logger *slog.Logger) *DefaultTokenService {
+	// Кэш сбрасывает записи отозванных токенов по событиям отзыва всех экземпляров сервиса
+	cache := tokencache.New(cfg.TokenCacheSize, cfg.TokenCacheTTL)
+	revocations.Subscribe(cache)
+
 	return &DefaultTokenService{
-		repo:      repo,
-		secretKey: secretKey,
-		logger:    logger,
+		repo:            repo,
+		roleRepo:        roleRepo,
+		sessionRepo:     sessionRepo,
+		keys:            keys,
+		cache:           cache,
+		revocations:     revocations,
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		logger:          logger,
 	}
 }
 
-// GenerateToken генерирует токен
+// GenerateToken генерирует access токен с ролями пользователя в claims
 func (s *DefaultTokenService) GenerateToken(ctx context.Context, userID int) (string, time.Time, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return s.generateAccessToken(ctx, userID, nil)
+}
+
+// generateAccessToken генерирует access токен; токен, выпущенный в рамках сессии, содержит ее ID в claim sid
+func (s *DefaultTokenService) generateAccessToken(ctx context.Context, userID int, sessionID *int64) (string, time.Time, error) {
+	storedRoles, err := s.roleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user roles", "method", "GenerateToken", "user_id", userID, "error", err)
+		return "", time.Time{}, err
+	}
+	roles := append([]string{string(rbac.RoleUser)}, storedRoles...)
+
+	// Токен идентифицируется случайным jti; в базе данных хранится только его хэш
+	tokenID, err := generateOpaqueToken(16)
+	if err != nil {
+		s.logger.Error("Failed to generate token id", "method", "GenerateToken", "user_id", userID, "error", err)
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.accessTokenTTL)
+	claims := jwt.MapClaims{
+		"jti":     tokenID,
 		"user_id": userID,
+		"roles":   roles,
 		"exp":     expiresAt.Unix(),
-	})
+	}
+	if sessionID != nil {
+		claims["sid"] = *sessionID
+	}
 
-	tokenString, err := token.SignedString([]byte(s.secretKey))
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		s.logger.Error("Failed to generate token", "method", "GenerateToken", "user_id", userID, "error", err)
 		return "", time.Time{}, err
 	}
 
-	err = s.repo.StoreToken(ctx, userID, tokenString, expiresAt)
+	err = s.repo.StoreToken(ctx, userID, sessionID, hashToken(tokenID), expiresAt)
 	if err != nil {
 		s.logger.Error("Failed to store token in database", "method", "GenerateToken", "user_id", userID, "error", err)
 		return "", time.Time{}, err
@@ -54,56 +131,380 @@ func (s *DefaultTokenService) GenerateToken(ctx context.Context, userID int) (st
 	return tokenString, expiresAt, nil
 }
 
-// ValidateToken проверяет валидность токена
-func (s *DefaultTokenService) ValidateToken(ctx context.Context, tokenString string) (int, error) {
-	parsedToken, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		// Проверка метода подписи токена
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			err := fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-			s.logger.Error("Invalid token signing method", "method", "ValidateToken", "error", err, "token", tokenString)
+// GenerateClientToken генерирует access токен стороннего приложения, ограниченный scopes. Токен, выданный от имени
+// пользователя, содержит его ID и роли; токен client_credentials действует только от имени приложения
+func (s *DefaultTokenService) GenerateClientToken(ctx context.Context, client *models.OAuthClient, userID *int, scopes []string) (string, time.Time, error) {
+	// Пустой список ограничений означал бы все права ролей пользователя
+	if len(scopes) == 0 {
+		return "", time.Time{}, errors.New("client token requires at least one scope")
+	}
+
+	tokenID, err := generateOpaqueToken(16)
+	if err != nil {
+		s.logger.Error("Failed to generate token id", "method", "GenerateClientToken", "client_id", client.ClientID, "error", err)
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.accessTokenTTL)
+	claims := jwt.MapClaims{
+		"jti":       tokenID,
+		"client_id": client.ClientID,
+		"scope":     strings.Join(scopes, " "),
+		"exp":       expiresAt.Unix(),
+	}
+	if userID != nil {
+		storedRoles, err := s.roleRepo.GetUserRoles(ctx, *userID)
+		if err != nil {
+			s.logger.Error("Failed to get user roles", "method", "GenerateClientToken", "user_id", *userID, "error", err)
+			return "", time.Time{}, err
+		}
+		claims["user_id"] = *userID
+		claims["roles"] = append([]string{string(rbac.RoleUser)}, storedRoles...)
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		s.logger.Error("Failed to generate token", "method", "GenerateClientToken", "client_id", client.ClientID, "error", err)
+		return "", time.Time{}, err
+	}
+
+	if err = s.repo.StoreClientToken(ctx, userID, client.ID, hashToken(tokenID), expiresAt); err != nil {
+		s.logger.Error("Failed to store token in database", "method", "GenerateClientToken", "client_id", client.ClientID, "error", err)
+		return "", time.Time{}, err
+	}
+
+	s.logger.Info("Client token successfully generated and stored", "method", "GenerateClientToken", "client_id", client.ClientID, "expires_at", expiresAt)
+	return tokenString, expiresAt, nil
+}
+
+// GenerateTokenPair начинает новую сессию и генерирует access токен и refresh токен нового семейства
+func (s *DefaultTokenService) GenerateTokenPair(ctx context.Context, userID int, client ClientInfo) (*dto.TokenPairDTO, error) {
+	familyID, err := generateOpaqueToken(16)
+	if err != nil {
+		s.logger.Error("Failed to generate refresh token family", "method", "GenerateTokenPair", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	return s.issueTokenPair(ctx, userID, familyID, client)
+}
+
+// RefreshToken обменивает refresh токен на новую пару токенов (ротация) в рамках той же сессии.
+// Повторное использование уже обменянного refresh токена отзывает всё его семейство.
+func (s *DefaultTokenService) RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (*dto.TokenPairDTO, error) {
+	tokenHash := hashToken(refreshToken)
+
+	storedToken, err := s.repo.UseRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if !errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			s.logger.Error("Failed to use refresh token", "method", "RefreshToken", "error", err)
 			return nil, err
 		}
-		return []byte(s.secretKey), nil
+		return nil, s.handleInactiveRefreshToken(ctx, tokenHash)
+	}
+
+	s.logger.Info("Refresh token rotated", "method", "RefreshToken", "user_id", storedToken.UserID, "family_id", storedToken.FamilyID)
+	return s.issueTokenPair(ctx, storedToken.UserID, storedToken.FamilyID, client)
+}
+
+// RevokeRefreshToken отзывает семейство, к которому принадлежит refresh токен
+func (s *DefaultTokenService) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	storedToken, err := s.repo.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		s.logger.Error("Failed to get refresh token", "method", "RevokeRefreshToken", "error", err)
+		return err
+	}
+
+	if err = s.revokeFamily(ctx, storedToken.FamilyID, "RevokeRefreshToken"); err != nil {
+		return err
+	}
+
+	s.logger.Info("Refresh token family revoked", "method", "RevokeRefreshToken", "user_id", storedToken.UserID, "family_id", storedToken.FamilyID)
+	return nil
+}
+
+// handleInactiveRefreshToken определяет причину, по которой refresh токен не может быть использован,
+// и при повторном использовании отзывает всё семейство
+func (s *DefaultTokenService) handleInactiveRefreshToken(ctx context.Context, tokenHash string) error {
+	storedToken, err := s.repo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			s.logger.Warn("Unknown refresh token", "method", "RefreshToken")
+			return ErrInvalidRefreshToken
+		}
+		s.logger.Error("Failed to get refresh token", "method", "RefreshToken", "error", err)
+		return err
+	}
+
+	if storedToken.UsedAt == nil {
+		s.logger.Warn("Refresh token is expired or revoked", "method", "RefreshToken", "user_id", storedToken.UserID, "family_id", storedToken.FamilyID)
+		return ErrInvalidRefreshToken
+	}
+
+	s.logger.Warn("Refresh token reuse detected, revoking family", "method", "RefreshToken", "user_id", storedToken.UserID, "family_id", storedToken.FamilyID)
+	if err = s.revokeFamily(ctx, storedToken.FamilyID, "RefreshToken"); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// revokeFamily отзывает семейство refresh токенов вместе с сессией и ее access токенами и сообщает об отзыве
+// остальным экземплярам: иначе закэшированный access токен сессии принимался бы до истечения срока жизни кэша
+func (s *DefaultTokenService) revokeFamily(ctx context.Context, familyID, method string) error {
+	sessionID, err := s.repo.RevokeRefreshTokenFamily(ctx, familyID)
+	if err != nil {
+		s.logger.Error("Failed to revoke refresh token family", "method", method, "family_id", familyID, "error", err)
+		return err
+	}
+	if sessionID == 0 {
+		return nil
+	}
+
+	if err = s.revocations.Publish(ctx, revocation.Event{SessionID: sessionID}); err != nil {
+		s.logger.Error("Failed to publish token revocation", "method", method, "error", err)
+	}
+	return nil
+}
+
+// issueTokenPair выпускает access токен и refresh токен в указанном семействе и сохраняет сессию,
+// к которой относится семейство, с данными клиента и новым сроком действия
+func (s *DefaultTokenService) issueTokenPair(ctx context.Context, userID int, familyID string, client ClientInfo) (*dto.TokenPairDTO, error) {
+	refreshExpiresAt := time.Now().Add(s.refreshTokenTTL)
+
+	sessionID, err := s.sessionRepo.SaveSession(ctx, &models.Session{
+		UserID:    userID,
+		FamilyID:  familyID,
+		UserAgent: client.userAgent(),
+		IP:        client.IP,
+		ExpiresAt: refreshExpiresAt,
 	})
-	if err != nil || !parsedToken.Valid {
-		s.logger.Error("Invalid token", "method", "ValidateToken", "token", tokenString, "error", err)
-		return 0, errors.New("invalid token")
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.logger.Warn("Session is revoked", "method", "issueTokenPair", "user_id", userID, "family_id", familyID)
+			return nil, ErrInvalidRefreshToken
+		}
+		s.logger.Error("Failed to save session", "method", "issueTokenPair", "user_id", userID, "error", err)
+		return nil, err
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		s.logger.Error("Invalid token claims", "method", "ValidateToken", "token", tokenString)
-		return 0, errors.New("invalid token claims")
+	accessToken, expiresAt, err := s.generateAccessToken(ctx, userID, &sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken(32)
+	if err != nil {
+		s.logger.Error("Failed to generate refresh token", "method", "issueTokenPair", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	if err = s.repo.StoreRefreshToken(ctx, userID, hashToken(refreshToken), familyID, refreshExpiresAt); err != nil {
+		s.logger.Error("Failed to store refresh token in database", "method", "issueTokenPair", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	return &dto.TokenPairDTO{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// ValidateToken проверяет валидность токена
+func (s *DefaultTokenService) ValidateToken(ctx context.Context, tokenString string) (*AccessClaims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		s.logger.Error("Invalid token", "method", "ValidateToken", "error", err)
+		return nil, errors.New("invalid token")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		s.logger.Error("Missing token id in token claims", "method", "ValidateToken")
+		return nil, errors.New("invalid token id in token")
+	}
+
+	// Токены приложений содержат client_id и scope; токен client_credentials выдан без пользователя и ролей
+	clientID, _ := claims["client_id"].(string)
+	var scopes []string
+	var roles []string
+	if clientID != "" {
+		scope, _ := claims["scope"].(string)
+		scopes = strings.Fields(scope)
+		if len(scopes) == 0 {
+			s.logger.Error("Missing scope in client token claims", "method", "ValidateToken", "client_id", clientID)
+			return nil, errors.New("invalid scope in token")
+		}
 	}
 
 	userID, ok := claims["user_id"].(float64)
-	if !ok {
-		s.logger.Error("Invalid user ID in token claims", "method", "ValidateToken", "token", tokenString)
-		return 0, errors.New("invalid user ID in token")
+	if !ok && (clientID == "" || claims["user_id"] != nil) {
+		s.logger.Error("Invalid user ID in token claims", "method", "ValidateToken")
+		return nil, errors.New("invalid user ID in token")
+	}
+
+	if ok {
+		roles, err = parseRolesClaim(claims["roles"])
+		if err != nil {
+			s.logger.Error("Invalid roles in token claims", "method", "ValidateToken", "user_id", int(userID), "error", err)
+			return nil, err
+		}
+	}
+
+	// Токены, выпущенные вне сессии, не содержат claim sid
+	var sessionID int64
+	if sid, ok := claims["sid"].(float64); ok {
+		sessionID = int64(sid)
 	}
 
-	isValid, err := s.repo.IsTokenValid(ctx, tokenString)
+	isValid, err := s.isTokenValid(ctx, hashToken(tokenID), tokencache.Entry{UserID: int(userID), SessionID: sessionID, ClientID: clientID})
 	if err != nil {
-		s.logger.Error("Failed to validate token in database", "method", "ValidateToken", "token", tokenString, "error", err)
+		s.logger.Error("Failed to validate token in database", "method", "ValidateToken", "user_id", int(userID), "error", err)
 	}
 
 	if !isValid {
-		s.logger.Warn("Token is invalid or revoked", "method", "ValidateToken", "token", tokenString)
-		return 0, errors.New("token is invalid or revoked")
+		s.logger.Warn("Token is invalid or revoked", "method", "ValidateToken", "user_id", int(userID))
+		return nil, errors.New("token is invalid or revoked")
 	}
 
-	s.logger.Info("Token validated successfully", "method", "ValidateToken", "user_id", int(userID))
-	return int(userID), nil
+	// Ошибка обновления времени последнего обращения не мешает выполнению запроса
+	if sessionID != 0 {
+		if err = s.sessionRepo.TouchSession(ctx, sessionID, sessionTouchInterval); err != nil {
+			s.logger.Error("Failed to touch session", "method", "ValidateToken", "session_id", sessionID, "error", err)
+		}
+	}
+
+	s.logger.Info("Token validated successfully", "method", "ValidateToken", "user_id", int(userID), "client_id", clientID)
+	return &AccessClaims{UserID: int(userID), Roles: roles, SessionID: sessionID, ClientID: clientID, Scopes: scopes}, nil
 }
 
-// RevokeToken отзывает токен
+// RevokeToken отзывает токен. Подпись проверяется, а срок действия нет: отозвать можно и просроченный токен
 func (s *DefaultTokenService) RevokeToken(ctx context.Context, token string) error {
-	err := s.repo.RevokeToken(ctx, token)
+	claims, err := s.parseToken(token, jwt.WithoutClaimsValidation())
+	if err != nil {
+		s.logger.Error("Invalid token", "method", "RevokeToken", "error", err)
+		return errors.New("invalid token")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		s.logger.Error("Missing token id in token claims", "method", "RevokeToken")
+		return errors.New("invalid token id in token")
+	}
+
+	return s.revoke(ctx, tokenID, "RevokeToken")
+}
+
+// RevokeClientToken отзывает токен, выданный приложению clientID. Невалидный токен и токен другого приложения
+// не отзываются без ошибки: по RFC 7009 ответ не должен раскрывать, существует ли такой токен
+func (s *DefaultTokenService) RevokeClientToken(ctx context.Context, token string, clientID string) error {
+	claims, err := s.parseToken(token, jwt.WithoutClaimsValidation())
 	if err != nil {
-		s.logger.Error("Failed to revoke token", "method", "RevokeToken", "token", token, "error", err)
+		s.logger.Warn("Invalid token", "method", "RevokeClientToken", "client_id", clientID, "error", err)
+		return nil
+	}
+
+	tokenID, _ := claims["jti"].(string)
+	if owner, _ := claims["client_id"].(string); tokenID == "" || owner != clientID {
+		s.logger.Warn("Token was not issued to client", "method", "RevokeClientToken", "client_id", clientID)
+		return nil
+	}
+
+	return s.revoke(ctx, tokenID, "RevokeClientToken")
+}
+
+// revoke отзывает токен по его jti и сообщает об отзыве остальным экземплярам
+func (s *DefaultTokenService) revoke(ctx context.Context, tokenID, method string) error {
+	tokenHash := hashToken(tokenID)
+	if err := s.repo.RevokeToken(ctx, tokenHash); err != nil {
+		s.logger.Error("Failed to revoke token", "method", method, "error", err)
 		return err
 	}
 
-	s.logger.Info("Token successfully revoked", "method", "RevokeToken", "token", token)
+	// Отзыв уже сохранен: экземпляры, не получившие событие, перестанут принимать токен по истечении срока жизни кэша
+	if err := s.revocations.Publish(ctx, revocation.Event{TokenHash: tokenHash}); err != nil {
+		s.logger.Error("Failed to publish token revocation", "method", method, "error", err)
+	}
+
+	s.logger.Info("Token successfully revoked", "method", method)
 	return nil
 }
+
+// isTokenValid проверяет, что токен не отозван, сначала по кэшу, а при промахе по базе данных.
+// Результат сохраняется в кэш вместе с владельцем токена из entry, по которому его находят события отзыва
+func (s *DefaultTokenService) isTokenValid(ctx context.Context, tokenHash string, entry tokencache.Entry) (bool, error) {
+	if cached, ok := s.cache.Get(tokenHash); ok {
+		return cached.Valid, nil
+	}
+
+	// Поколение фиксируется до запроса: если токен отзовут, пока идет запрос, результат не попадет в кэш
+	generation := s.cache.Generation()
+	isValid, err := s.repo.IsTokenValid(ctx, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	entry.Valid = isValid
+	s.cache.Set(tokenHash, entry, generation)
+	return isValid, nil
+}
+
+// parseToken проверяет подпись access токена ключом, указанным в его заголовке kid, и возвращает claims
+func (s *DefaultTokenService) parseToken(tokenString string, options ...jwt.ParserOption) (jwt.MapClaims, error) {
+	parsedToken, err := jwt.Parse(tokenString, s.keys.Keyfunc, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !parsedToken.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+// parseRolesClaim извлекает список ролей из claims. Токены без ролей считаются токенами обычного пользователя
+func parseRolesClaim(raw interface{}) ([]string, error) {
+	if raw == nil {
+		return []string{string(rbac.RoleUser)}, nil
+	}
+
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("invalid roles in token")
+	}
+
+	roles := make([]string, 0, len(items))
+	for _, item := range items {
+		role, ok := item.(string)
+		if !ok {
+			return nil, errors.New("invalid roles in token")
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// generateOpaqueToken генерирует случайную строку из n байт в base64url
+func generateOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken возвращает SHA-256 хэш токена в hex
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management/internal/config"
+	"user-management/internal/dto"
+	"user-management/internal/pkg/jwtkeys"
+	"user-management/internal/pkg/revocation"
+)
+
+// newTestTokenService создает сервис токенов с хранилищем в памяти и подписью общим секретом
+func newTestTokenService() (*DefaultTokenService, *fakeAuthRepo) {
+	repo := newFakeAuthRepo()
+	return newTestTokenInstance(repo, revocation.NewLocalBus()), repo
+}
+
+// newTestTokenInstance создает экземпляр сервиса токенов над общим хранилищем со своей шиной событий отзыва
+func newTestTokenInstance(repo *fakeAuthRepo, bus revocation.Bus) *DefaultTokenService {
+	cfg := &config.ApiServer{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		TokenCacheSize:  100,
+		TokenCacheTTL:   time.Minute,
+	}
+	keys := jwtkeys.NewSecretKeySet("test-secret")
+
+	return NewTokenService(repo, repo, repo, keys, bus, cfg, discardLogger())
+}
+
+// fakeClusterBus шина экземпляра, которая доставляет события своим подписчикам сразу, а остальным экземплярам
+// только при вызове deliver, как канал Postgres или Redis
+type fakeClusterBus struct {
+	*revocation.LocalBus
+	sent []revocation.Event
+}
+
+func (b *fakeClusterBus) Publish(ctx context.Context, event revocation.Event) error {
+	b.sent = append(b.sent, event)
+	return b.LocalBus.Publish(ctx, event)
+}
+
+// deliver передает отправленные события шине другого экземпляра
+func (b *fakeClusterBus) deliver(ctx context.Context, to *fakeClusterBus) {
+	for _, event := range b.sent {
+		to.LocalBus.Publish(ctx, event)
+	}
+	b.sent = nil
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	tests := []struct {
+		name string
+		// present возвращает refresh токен, который предъявляется после одной ротации first -> second
+		present     func(first, second *dto.TokenPairDTO) string
+		wantErr     error
+		wantRevoked bool // Семейство first и second отозвано
+	}{
+		{
+			name:    "current token rotates",
+			present: func(_, second *dto.TokenPairDTO) string { return second.RefreshToken },
+		},
+		{
+			name:        "reused token revokes family",
+			present:     func(first, _ *dto.TokenPairDTO) string { return first.RefreshToken },
+			wantErr:     ErrRefreshTokenReused,
+			wantRevoked: true,
+		},
+		{
+			name:    "unknown token",
+			present: func(_, _ *dto.TokenPairDTO) string { return "unknown" },
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newTestTokenService()
+			ctx := context.Background()
+			client := ClientInfo{IP: "127.0.0.1", UserAgent: "test"}
+
+			first, err := svc.GenerateTokenPair(ctx, testUserID, client)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+			second, err := svc.RefreshToken(ctx, first.RefreshToken, client)
+			if err != nil {
+				t.Fatalf("RefreshToken: %v", err)
+			}
+			familyID := repo.refreshTokens[hashToken(first.RefreshToken)].FamilyID
+			if got := repo.refreshTokens[hashToken(second.RefreshToken)].FamilyID; got != familyID {
+				t.Fatalf("rotated token family = %s, want %s", got, familyID)
+			}
+
+			// Ротация продолжает ту же сессию
+			firstClaims, err := svc.ValidateToken(ctx, first.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateToken(first): %v", err)
+			}
+			secondClaims, err := svc.ValidateToken(ctx, second.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateToken(second): %v", err)
+			}
+			if firstClaims.SessionID == 0 || firstClaims.SessionID != secondClaims.SessionID {
+				t.Errorf("session ids = %d and %d, want the same session", firstClaims.SessionID, secondClaims.SessionID)
+			}
+
+			// Другое семейство того же пользователя не затрагивается отзывом
+			other, err := svc.GenerateTokenPair(ctx, testUserID, client)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair(other): %v", err)
+			}
+
+			_, err = svc.RefreshToken(ctx, tt.present(first, second), client)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshToken error = %v, want %v", err, tt.wantErr)
+			}
+
+			if revoked := repo.session(familyID).RevokedAt != nil; revoked != tt.wantRevoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			// После отзыва семейства не принимается и последний выданный в нем токен, а access токены сессии
+			// отклоняются сразу, хотя результат их проверки уже в кэше
+			if tt.wantRevoked {
+				for name, pair := range map[string]*dto.TokenPairDTO{"first": first, "second": second} {
+					if _, err = svc.ValidateToken(ctx, pair.AccessToken); err == nil {
+						t.Errorf("access token %s of the revoked family is still valid", name)
+					}
+				}
+				if _, err = svc.RefreshToken(ctx, second.RefreshToken, client); !errors.Is(err, ErrInvalidRefreshToken) {
+					t.Errorf("refresh with the latest token after reuse = %v, want %v", err, ErrInvalidRefreshToken)
+				}
+			}
+
+			if _, err = svc.ValidateToken(ctx, other.AccessToken); err != nil {
+				t.Errorf("access token of another family: %v", err)
+			}
+			if _, err = svc.RefreshToken(ctx, other.RefreshToken, client); err != nil {
+				t.Errorf("refresh of another family: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	svc, repo := newTestTokenService()
+	ctx := context.Background()
+
+	pair, err := svc.GenerateTokenPair(ctx, testUserID, ClientInfo{})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	stored := repo.refreshTokens[hashToken(pair.RefreshToken)]
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+
+	// Просроченный, но не использованный токен не считается повторным использованием и не отзывает семейство
+	if _, err = svc.RefreshToken(ctx, pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshToken error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if stored.IsRevoked || repo.session(stored.FamilyID).RevokedAt != nil {
+		t.Errorf("family revoked after expired token, want untouched")
+	}
+}
+
+func TestTokenRevokedOnAnotherInstance(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(ctx context.Context, svc *DefaultTokenService, pair *dto.TokenPairDTO) error
+	}{
+		{
+			name: "access token",
+			revoke: func(ctx context.Context, svc *DefaultTokenService, pair *dto.TokenPairDTO) error {
+				return svc.RevokeToken(ctx, pair.AccessToken)
+			},
+		},
+		{
+			name: "refresh token family",
+			revoke: func(ctx context.Context, svc *DefaultTokenService, pair *dto.TokenPairDTO) error {
+				return svc.RevokeRefreshToken(ctx, pair.RefreshToken)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeAuthRepo()
+			busA := &fakeClusterBus{LocalBus: revocation.NewLocalBus()}
+			busB := &fakeClusterBus{LocalBus: revocation.NewLocalBus()}
+			instanceA := newTestTokenInstance(repo, busA)
+			instanceB := newTestTokenInstance(repo, busB)
+
+			pair, err := instanceA.GenerateTokenPair(ctx, testUserID, ClientInfo{})
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+			// Результат проверки попадает в кэш экземпляра B
+			if _, err = instanceB.ValidateToken(ctx, pair.AccessToken); err != nil {
+				t.Fatalf("ValidateToken before revocation: %v", err)
+			}
+
+			if err = tt.revoke(ctx, instanceA, pair); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+			if _, err = instanceA.ValidateToken(ctx, pair.AccessToken); err == nil {
+				t.Errorf("revoking instance accepts the revoked token")
+			}
+			// Пока событие не дошло, B отвечает из кэша: отзыв виден другим экземплярам только через шину
+			if _, err = instanceB.ValidateToken(ctx, pair.AccessToken); err != nil {
+				t.Fatalf("cached token rejected before the event arrived: %v", err)
+			}
+
+			busA.deliver(ctx, busB)
+			if _, err = instanceB.ValidateToken(ctx, pair.AccessToken); err == nil {
+				t.Errorf("token revoked on another instance is still valid after the event arrived")
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,                                       -- Уникальный идентификатор refresh токена
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,    -- ID пользователя, получившего токен
+    token_hash VARCHAR(64) NOT NULL UNIQUE,                         -- SHA-256 хэш refresh токена
+    family_id VARCHAR(64) NOT NULL,                                 -- Идентификатор семейства (цепочки ротаций) токенов
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время создания токена
+    expires_at TIMESTAMP NOT NULL,                                  -- Дата и время истечения срока действия токена
+    used_at TIMESTAMP,                                              -- Дата и время использования токена для ротации
+    is_revoked BOOLEAN DEFAULT FALSE                                -- Флаг, указывающий отозван ли токен
+    );
+
+-- Индекс для быстрого отзыва всего семейства токенов
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS point_transactions CASCADE;
+DROP FUNCTION IF EXISTS point_transactions_append_only();
\ No newline at end of file
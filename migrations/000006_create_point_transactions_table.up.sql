@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS point_transactions (
+    id BIGSERIAL PRIMARY KEY,                                   -- Уникальный идентификатор операции
+    user_id INT NOT NULL REFERENCES users(id),                  -- ID пользователя, чей баланс изменился
+    amount BIGINT NOT NULL,                                     -- Изменение баланса (может быть отрицательным)
+    balance_after BIGINT NOT NULL,                              -- Баланс пользователя после операции
+    reason VARCHAR(32) NOT NULL,                                -- Тип операции
+    reference_id BIGINT,                                        -- ID связанной сущности (задание, приглашенный пользователь и т.д.)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,           -- Дата и время операции
+    CHECK (reason IN ('opening_balance', 'task_reward', 'referral_bonus', 'admin_adjustment'))
+    );
+
+-- Индекс для постраничного просмотра истории операций пользователя
+CREATE INDEX IF NOT EXISTS idx_point_transactions_user_id ON point_transactions(user_id, id DESC);
+
+-- Журнал операций только дополняется: изменение и удаление записей запрещены
+CREATE OR REPLACE FUNCTION point_transactions_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'point_transactions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_point_transactions_append_only
+    BEFORE UPDATE OR DELETE ON point_transactions
+    FOR EACH ROW EXECUTE FUNCTION point_transactions_append_only();
+
+-- Переносим уже накопленные балансы в журнал, чтобы он сходился с users.balance
+INSERT INTO point_transactions (user_id, amount, balance_after, reason)
+SELECT id, balance, balance, 'opening_balance' FROM users WHERE balance <> 0;
\ No newline at end of file
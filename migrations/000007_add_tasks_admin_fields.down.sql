@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS task_audit_log CASCADE;
+ALTER TABLE tasks
+    DROP CONSTRAINT IF EXISTS chk_tasks_window,
+    DROP COLUMN IF EXISTS starts_at,
+    DROP COLUMN IF EXISTS ends_at,
+    DROP COLUMN IF EXISTS is_archived,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at;
\ No newline at end of file
@@ -0,0 +1,20 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ,                               -- Начало периода доступности задания
+    ADD COLUMN IF NOT EXISTS ends_at TIMESTAMPTZ,                                 -- Окончание периода доступности задания
+    ADD COLUMN IF NOT EXISTS is_archived BOOLEAN NOT NULL DEFAULT FALSE,          -- Флаг, указывающий находится ли задание в архиве
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,    -- Время создания
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,    -- Время последнего изменения
+    ADD CONSTRAINT chk_tasks_window CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at);
+
+CREATE TABLE IF NOT EXISTS task_audit_log (
+    id BIGSERIAL PRIMARY KEY,                                       -- Уникальный идентификатор записи
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,    -- Измененное задание
+    actor_id INT NOT NULL REFERENCES users(id),                     -- Пользователь, внесший изменение
+    action VARCHAR(32) NOT NULL,                                    -- Действие: create, update, archive
+    before JSONB,                                                   -- Состояние задания до изменения
+    after JSONB,                                                    -- Состояние задания после изменения
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP                -- Дата и время изменения
+    );
+
+-- Индекс для просмотра истории изменений задания
+CREATE INDEX IF NOT EXISTS idx_task_audit_log_task_id ON task_audit_log(task_id, id DESC);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS user_roles CASCADE;
\ No newline at end of file
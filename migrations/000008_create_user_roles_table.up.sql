@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,    -- ID пользователя
+    role VARCHAR(32) NOT NULL,                                      -- Название роли
+    granted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,               -- Дата и время выдачи роли
+    PRIMARY KEY (user_id, role)
+    );
\ No newline at end of file
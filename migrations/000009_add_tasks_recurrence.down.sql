@@ -0,0 +1,13 @@
+-- Оставляем только первое выполнение каждого задания пользователем
+DELETE FROM completed_tasks a USING completed_tasks b
+    WHERE a.user_id = b.user_id AND a.task_id = b.task_id AND a.id > b.id;
+
+ALTER TABLE completed_tasks DROP CONSTRAINT IF EXISTS completed_tasks_user_task_period_key;
+ALTER TABLE completed_tasks ADD CONSTRAINT completed_tasks_user_id_task_id_key UNIQUE (user_id, task_id);
+ALTER TABLE completed_tasks DROP COLUMN IF EXISTS period_key;
+
+ALTER TABLE tasks
+    DROP CONSTRAINT IF EXISTS chk_tasks_max_completions,
+    DROP CONSTRAINT IF EXISTS chk_tasks_recurrence,
+    DROP COLUMN IF EXISTS max_completions,
+    DROP COLUMN IF EXISTS recurrence;
\ No newline at end of file
@@ -0,0 +1,13 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS recurrence VARCHAR(16) NOT NULL DEFAULT 'once',  -- Политика повторения: once, daily, weekly, limited
+    ADD COLUMN IF NOT EXISTS max_completions INT,                             -- Максимальное число выполнений для политики limited
+    ADD CONSTRAINT chk_tasks_recurrence CHECK (recurrence IN ('once', 'daily', 'weekly', 'limited')),
+    ADD CONSTRAINT chk_tasks_max_completions CHECK ((recurrence = 'limited') = (max_completions IS NOT NULL) AND (max_completions IS NULL OR max_completions > 0));
+
+-- Ключ периода выполнения: дата для daily, ISO неделя для weekly, порядковый номер выполнения (#N) для once и limited
+ALTER TABLE completed_tasks ADD COLUMN IF NOT EXISTS period_key VARCHAR(32) NOT NULL DEFAULT '#1';
+ALTER TABLE completed_tasks ALTER COLUMN period_key DROP DEFAULT;
+
+-- Одно выполнение задания пользователем на каждый период вместо одного выполнения навсегда
+ALTER TABLE completed_tasks DROP CONSTRAINT IF EXISTS completed_tasks_user_id_task_id_key;
+ALTER TABLE completed_tasks ADD CONSTRAINT completed_tasks_user_task_period_key UNIQUE (user_id, task_id, period_key);
\ No newline at end of file
@@ -0,0 +1,17 @@
+DELETE FROM completed_tasks WHERE status <> 'approved';
+
+DROP INDEX IF EXISTS idx_completed_tasks_pending;
+DROP INDEX IF EXISTS uq_completed_tasks_user_task_period;
+ALTER TABLE completed_tasks ADD CONSTRAINT completed_tasks_user_task_period_key UNIQUE (user_id, task_id, period_key);
+
+ALTER TABLE completed_tasks
+    DROP CONSTRAINT IF EXISTS chk_completed_tasks_status,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS proof,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE tasks
+    DROP CONSTRAINT IF EXISTS chk_tasks_verification,
+    DROP COLUMN IF EXISTS verification_code_hash,
+    DROP COLUMN IF EXISTS verification;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS verification VARCHAR(16) NOT NULL DEFAULT 'none',   -- Способ проверки выполнения: none, manual, callback, proof_code
+    ADD COLUMN IF NOT EXISTS verification_code_hash VARCHAR(64),                 -- SHA-256 хэш кода подтверждения для proof_code
+    ADD CONSTRAINT chk_tasks_verification CHECK (verification IN ('none', 'manual', 'callback', 'proof_code'));
+
+ALTER TABLE completed_tasks
+    ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'approved',     -- Статус проверки: pending, approved, rejected
+    ADD COLUMN IF NOT EXISTS proof VARCHAR(255),                                 -- Доказательство выполнения, переданное пользователем
+    ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ,                            -- Дата и время проверки
+    ADD COLUMN IF NOT EXISTS reviewed_by INT REFERENCES users(id),               -- Администратор, проверивший выполнение (NULL для автоматической проверки)
+    ADD CONSTRAINT chk_completed_tasks_status CHECK (status IN ('pending', 'approved', 'rejected'));
+
+-- Отклоненное выполнение не занимает период: пользователь может отправить задание повторно
+ALTER TABLE completed_tasks DROP CONSTRAINT IF EXISTS completed_tasks_user_task_period_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_completed_tasks_user_task_period ON completed_tasks(user_id, task_id, period_key) WHERE status <> 'rejected';
+
+-- Индекс для очереди проверки
+CREATE INDEX IF NOT EXISTS idx_completed_tasks_pending ON completed_tasks(id) WHERE status = 'pending';
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id BIGSERIAL PRIMARY KEY,                                       -- Уникальный идентификатор токена сброса пароля
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,    -- ID пользователя, запросившего сброс
+    token_hash VARCHAR(64) NOT NULL UNIQUE,                         -- SHA-256 хэш токена сброса
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,               -- Дата и время создания токена
+    expires_at TIMESTAMPTZ NOT NULL,                                -- Дата и время истечения срока действия токена
+    used_at TIMESTAMPTZ                                             -- Дата и время использования (или аннулирования) токена
+    );
+
+-- Индекс для аннулирования ранее выданных токенов пользователя
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id) WHERE used_at IS NULL;
+
+-- Индекс для отзыва всех refresh токенов пользователя при смене пароля
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS uq_users_email;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS email;
\ No newline at end of file
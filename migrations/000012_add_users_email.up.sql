@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email VARCHAR(255),         -- Адрес электронной почты (необязателен для ранее зарегистрированных пользователей)
+    ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;    -- Дата и время подтверждения адреса
+
+-- Адрес может принадлежать только одному пользователю независимо от регистра
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_email ON users(LOWER(email));
\ No newline at end of file
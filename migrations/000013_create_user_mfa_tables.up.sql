@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,   -- ID пользователя
+    totp_secret VARCHAR(64) NOT NULL,                                 -- Секрет TOTP в base32
+    confirmed_at TIMESTAMPTZ,                                         -- Дата и время подтверждения подключения (NULL, пока не подтверждено)
+    last_used_step BIGINT,                                            -- Последний использованный шаг времени (защита от повторного использования кода)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP                  -- Дата и время создания секрета
+    );
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор кода восстановления
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя
+    code_hash VARCHAR(64) NOT NULL,                                   -- SHA-256 хэш кода восстановления
+    used_at TIMESTAMPTZ,                                              -- Дата и время использования кода
+    UNIQUE (user_id, code_hash)
+    );
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор запроса второго фактора
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя, прошедшего проверку пароля
+    token_hash VARCHAR(64) NOT NULL UNIQUE,                           -- SHA-256 хэш токена запроса
+    attempts INT NOT NULL DEFAULT 0,                                  -- Число попыток ввода кода
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время создания
+    expires_at TIMESTAMPTZ NOT NULL,                                  -- Дата и время истечения срока действия
+    used_at TIMESTAMPTZ                                               -- Дата и время успешного ввода кода
+    );
\ No newline at end of file
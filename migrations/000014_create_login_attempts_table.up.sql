@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    scope VARCHAR(16) NOT NULL,                                  -- Область учета: account (имя пользователя) или ip (адрес клиента)
+    subject VARCHAR(255) NOT NULL,                               -- Имя пользователя или IP адрес
+    failures INT NOT NULL DEFAULT 0,                             -- Число неудачных попыток подряд
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Дата и время последней неудачной попытки
+    locked_until TIMESTAMPTZ,                                    -- Блокировка входа до указанного времени
+    PRIMARY KEY (scope, subject),
+    CONSTRAINT chk_login_attempts_scope CHECK (scope IN ('account', 'ip'))
+    );
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tokens_session_id;
+
+ALTER TABLE tokens
+    DROP COLUMN IF EXISTS session_id;
+
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор сессии
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя
+    family_id VARCHAR(64) NOT NULL UNIQUE,                            -- Семейство refresh токенов, выпущенных в рамках сессии
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',                      -- User-Agent клиента
+    ip VARCHAR(45) NOT NULL DEFAULT '',                               -- IP адрес клиента при последнем входе или обновлении токенов
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время входа
+    last_seen_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,               -- Дата и время последнего обращения
+    expires_at TIMESTAMPTZ NOT NULL,                                  -- Дата и время истечения последнего refresh токена сессии
+    revoked_at TIMESTAMPTZ                                            -- Дата и время завершения сессии
+    );
+
+-- Индекс для вывода сессий пользователя
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Access токены привязываются к сессии, чтобы при ее завершении отзывались все ее токены
+ALTER TABLE tokens
+    ADD COLUMN IF NOT EXISTS session_id BIGINT REFERENCES sessions(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_session_id ON tokens(session_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tokens
+    ALTER COLUMN token_hash TYPE VARCHAR(255);
+
+ALTER TABLE tokens RENAME COLUMN token_hash TO token;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- Сохраненные JWT заменяются хэшами и отзываются: токены без claim jti больше не принимаются
+UPDATE tokens SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex'), is_revoked = TRUE;
+
+ALTER TABLE tokens RENAME COLUMN token TO token_hash;
+
+ALTER TABLE tokens
+    ALTER COLUMN token_hash TYPE VARCHAR(64);                  -- SHA-256 хэш идентификатора токена (claim jti)
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_expires_at;
+DROP INDEX IF EXISTS idx_tokens_expires_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_user_id;
+DROP INDEX IF EXISTS idx_tokens_user_id;
+DROP INDEX IF EXISTS idx_tokens_token_hash;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(token_hash);
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Индекс idx_tokens_user_id на самом деле был построен по токену: он заменяется индексами по хэшу и по пользователю
+DROP INDEX IF EXISTS idx_tokens_user_id;
+
+-- Индекс для проверки и отзыва access токена по хэшу идентификатора
+CREATE INDEX IF NOT EXISTS idx_tokens_token_hash ON tokens(token_hash);
+
+-- Индекс для отзыва всех токенов пользователя
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+-- Индексы для удаления устаревших токенов и сессий
+CREATE INDEX IF NOT EXISTS idx_tokens_expires_at ON tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор ключа
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID владельца ключа
+    name VARCHAR(100) NOT NULL,                                       -- Название ключа, заданное владельцем
+    prefix VARCHAR(16) NOT NULL,                                      -- Начало ключа для отображения в списке
+    key_hash VARCHAR(64) NOT NULL UNIQUE,                             -- SHA-256 хэш ключа
+    scopes TEXT[] NOT NULL DEFAULT '{}',                              -- Права, которыми ограничен ключ (пустой список — без ограничений)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время создания
+    expires_at TIMESTAMPTZ,                                           -- Дата и время истечения срока действия (NULL — бессрочный)
+    last_used_at TIMESTAMPTZ,                                         -- Дата и время последнего обращения с ключом
+    last_used_ip VARCHAR(45),                                         -- IP адрес клиента при последнем обращении
+    revoked_at TIMESTAMPTZ                                            -- Дата и время отзыва ключа
+    );
+
+-- Индекс для вывода ключей пользователя
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
\ No newline at end of file
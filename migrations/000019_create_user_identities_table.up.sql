@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор привязки
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя
+    provider VARCHAR(32) NOT NULL,                                    -- Имя внешнего провайдера из настроек
+    subject VARCHAR(255) NOT NULL,                                    -- Идентификатор учетной записи у провайдера (claim sub)
+    email VARCHAR(255),                                               -- Адрес электронной почты, сообщенный провайдером при последнем входе
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время привязки
+    last_login_at TIMESTAMPTZ,                                        -- Дата и время последнего входа через провайдера
+    CONSTRAINT uq_user_identities_subject UNIQUE (provider, subject),
+    CONSTRAINT uq_user_identities_user_provider UNIQUE (user_id, provider)
+    );
+
+CREATE TABLE IF NOT EXISTS oidc_auth_requests (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор запроса входа
+    state_hash VARCHAR(64) NOT NULL UNIQUE,                           -- SHA-256 хэш параметра state
+    provider VARCHAR(32) NOT NULL,                                    -- Имя внешнего провайдера
+    nonce VARCHAR(64) NOT NULL,                                       -- Nonce, который должен вернуться в ID токене
+    code_verifier VARCHAR(128) NOT NULL,                              -- Code verifier PKCE для обмена кода авторизации
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,               -- ID пользователя, к которому привязывается учетная запись (NULL при входе)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время начала входа
+    expires_at TIMESTAMPTZ NOT NULL                                   -- Дата и время истечения срока действия
+    );
+
+-- Индекс для удаления просроченных запросов входа
+CREATE INDEX IF NOT EXISTS idx_oidc_auth_requests_expires_at ON oidc_auth_requests(expires_at);
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_tokens_client_id;
+
+DELETE FROM tokens WHERE user_id IS NULL;
+
+ALTER TABLE tokens
+    DROP COLUMN IF EXISTS client_id,
+    ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_clients;
\ No newline at end of file
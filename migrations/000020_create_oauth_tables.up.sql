@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор приложения
+    client_id VARCHAR(64) NOT NULL UNIQUE,                            -- Публичный идентификатор приложения (параметр client_id)
+    secret_hash VARCHAR(64),                                          -- SHA-256 хэш секрета (NULL — публичное приложение, только PKCE)
+    name VARCHAR(100) NOT NULL,                                       -- Название приложения, показываемое пользователю при согласии
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',                       -- Разрешенные адреса возврата кода авторизации
+    grant_types TEXT[] NOT NULL,                                      -- Разрешенные способы получения токена
+    scopes TEXT[] NOT NULL,                                           -- Права, которые приложение может запросить
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,           -- ID администратора, зарегистрировавшего приложение
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP                  -- Дата и время регистрации
+    );
+
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор согласия
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя, давшего согласие
+    client_id BIGINT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE, -- ID приложения
+    scopes TEXT[] NOT NULL,                                           -- Права, переданные приложению
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время первого согласия
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время последнего расширения прав
+    CONSTRAINT uq_oauth_consents_user_client UNIQUE (user_id, client_id)
+    );
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор кода
+    code_hash VARCHAR(64) NOT NULL UNIQUE,                            -- SHA-256 хэш кода авторизации
+    client_id BIGINT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE, -- ID приложения, которому выдан код
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- ID пользователя, разрешившего доступ
+    redirect_uri TEXT NOT NULL,                                       -- Адрес возврата из запроса авторизации
+    scopes TEXT[] NOT NULL,                                           -- Права, на которые будет выпущен токен
+    code_challenge VARCHAR(128) NOT NULL,                             -- Code challenge PKCE (S256)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,                 -- Дата и время выдачи
+    expires_at TIMESTAMPTZ NOT NULL                                   -- Дата и время истечения срока действия
+    );
+
+-- Индекс для удаления просроченных кодов авторизации
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+
+-- Токены приложений: токен, выпущенный по client credentials, не принадлежит пользователю
+ALTER TABLE tokens
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS client_id BIGINT REFERENCES oauth_clients(id) ON DELETE CASCADE; -- ID приложения, получившего токен (NULL — токен самого пользователя)
+
+-- Индекс для отзыва токенов приложения при отзыве согласия
+CREATE INDEX IF NOT EXISTS idx_tokens_client_id ON tokens(client_id) WHERE client_id IS NOT NULL;
\ No newline at end of file
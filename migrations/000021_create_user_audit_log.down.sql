@@ -0,0 +1 @@
+DROP TABLE IF EXISTS user_audit_log;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_audit_log (
+    id BIGSERIAL PRIMARY KEY,                                         -- Уникальный идентификатор записи
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,      -- Пользователь, с учетной записью которого связано событие
+    action VARCHAR(32) NOT NULL,                                      -- Действие: password_change
+    ip VARCHAR(45),                                                   -- IP адрес клиента
+    user_agent VARCHAR(512),                                          -- User-Agent клиента
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP                  -- Дата и время события
+    );
+
+-- Индекс для просмотра истории событий пользователя
+CREATE INDEX IF NOT EXISTS idx_user_audit_log_user_id ON user_audit_log(user_id, id DESC);
\ No newline at end of file